@@ -0,0 +1,5 @@
+// Package smartcommitv1 contains the generated Go bindings for the
+// smart-commit gRPC API defined in smartcommit.proto.
+package smartcommitv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/smartcommit/v1/smartcommit.proto
@@ -0,0 +1,220 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/smartcommit/v1/smartcommit.proto
+
+package smartcommitv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SmartCommit_Suggest_FullMethodName   = "/smartcommit.v1.SmartCommit/Suggest"
+	SmartCommit_Lint_FullMethodName      = "/smartcommit.v1.SmartCommit/Lint"
+	SmartCommit_Explain_FullMethodName   = "/smartcommit.v1.SmartCommit/Explain"
+	SmartCommit_Changelog_FullMethodName = "/smartcommit.v1.SmartCommit/Changelog"
+)
+
+// SmartCommitClient is the client API for SmartCommit service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SmartCommitClient interface {
+	Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error)
+	Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (*LintResponse, error)
+	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+	Changelog(ctx context.Context, in *ChangelogRequest, opts ...grpc.CallOption) (*ChangelogResponse, error)
+}
+
+type smartCommitClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSmartCommitClient(cc grpc.ClientConnInterface) SmartCommitClient {
+	return &smartCommitClient{cc}
+}
+
+func (c *smartCommitClient) Suggest(ctx context.Context, in *SuggestRequest, opts ...grpc.CallOption) (*SuggestResponse, error) {
+	out := new(SuggestResponse)
+	err := c.cc.Invoke(ctx, SmartCommit_Suggest_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smartCommitClient) Lint(ctx context.Context, in *LintRequest, opts ...grpc.CallOption) (*LintResponse, error) {
+	out := new(LintResponse)
+	err := c.cc.Invoke(ctx, SmartCommit_Lint_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smartCommitClient) Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error) {
+	out := new(ExplainResponse)
+	err := c.cc.Invoke(ctx, SmartCommit_Explain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *smartCommitClient) Changelog(ctx context.Context, in *ChangelogRequest, opts ...grpc.CallOption) (*ChangelogResponse, error) {
+	out := new(ChangelogResponse)
+	err := c.cc.Invoke(ctx, SmartCommit_Changelog_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SmartCommitServer is the server API for SmartCommit service.
+// All implementations must embed UnimplementedSmartCommitServer
+// for forward compatibility
+type SmartCommitServer interface {
+	Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error)
+	Lint(context.Context, *LintRequest) (*LintResponse, error)
+	Explain(context.Context, *ExplainRequest) (*ExplainResponse, error)
+	Changelog(context.Context, *ChangelogRequest) (*ChangelogResponse, error)
+	mustEmbedUnimplementedSmartCommitServer()
+}
+
+// UnimplementedSmartCommitServer must be embedded to have forward compatible implementations.
+type UnimplementedSmartCommitServer struct {
+}
+
+func (UnimplementedSmartCommitServer) Suggest(context.Context, *SuggestRequest) (*SuggestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Suggest not implemented")
+}
+func (UnimplementedSmartCommitServer) Lint(context.Context, *LintRequest) (*LintResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lint not implemented")
+}
+func (UnimplementedSmartCommitServer) Explain(context.Context, *ExplainRequest) (*ExplainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
+func (UnimplementedSmartCommitServer) Changelog(context.Context, *ChangelogRequest) (*ChangelogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Changelog not implemented")
+}
+func (UnimplementedSmartCommitServer) mustEmbedUnimplementedSmartCommitServer() {}
+
+// UnsafeSmartCommitServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SmartCommitServer will
+// result in compilation errors.
+type UnsafeSmartCommitServer interface {
+	mustEmbedUnimplementedSmartCommitServer()
+}
+
+func RegisterSmartCommitServer(s grpc.ServiceRegistrar, srv SmartCommitServer) {
+	s.RegisterService(&SmartCommit_ServiceDesc, srv)
+}
+
+func _SmartCommit_Suggest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmartCommitServer).Suggest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmartCommit_Suggest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmartCommitServer).Suggest(ctx, req.(*SuggestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmartCommit_Lint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LintRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmartCommitServer).Lint(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmartCommit_Lint_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmartCommitServer).Lint(ctx, req.(*LintRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmartCommit_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmartCommitServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmartCommit_Explain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmartCommitServer).Explain(ctx, req.(*ExplainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SmartCommit_Changelog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangelogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SmartCommitServer).Changelog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SmartCommit_Changelog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SmartCommitServer).Changelog(ctx, req.(*ChangelogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SmartCommit_ServiceDesc is the grpc.ServiceDesc for SmartCommit service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SmartCommit_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "smartcommit.v1.SmartCommit",
+	HandlerType: (*SmartCommitServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Suggest",
+			Handler:    _SmartCommit_Suggest_Handler,
+		},
+		{
+			MethodName: "Lint",
+			Handler:    _SmartCommit_Lint_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _SmartCommit_Explain_Handler,
+		},
+		{
+			MethodName: "Changelog",
+			Handler:    _SmartCommit_Changelog_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/smartcommit/v1/smartcommit.proto",
+}
@@ -0,0 +1,167 @@
+// Package client is a reference client for the smart-commit stdio protocol.
+// It starts `smart-commit serve --stdio` as a child process, negotiates the
+// protocol version and capabilities, and exposes each method as a Go call.
+//
+// Editor plugin authors can use it directly from Go tooling or read it as a
+// compact, working description of the protocol.
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/chalfel/smart-commit/protocol"
+)
+
+// Options configures how the server is started.
+type Options struct {
+	// Binary is the smart-commit executable; "smart-commit" when empty.
+	Binary string
+	// Dir is the working directory of the server process.
+	Dir string
+	// Env is the environment of the server process; the caller's when nil.
+	Env []string
+	// Stderr receives the server's log output; it is discarded when nil.
+	Stderr io.Writer
+	// ClientName identifies the client in the server's logs.
+	ClientName string
+	// Capabilities to request; every known capability when empty.
+	Capabilities []string
+}
+
+// Client is a connection to a running server. It is safe for concurrent use;
+// requests are serialized.
+type Client struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	scanner *bufio.Scanner
+
+	mu     sync.Mutex
+	nextID int64
+
+	// Info is the server's answer to the initialize request.
+	Info protocol.InitializeResult
+}
+
+// Start launches the server and performs the initialize handshake.
+func Start(opts Options) (*Client, error) {
+	binary := opts.Binary
+	if binary == "" {
+		binary = "smart-commit"
+	}
+	capabilities := opts.Capabilities
+	if len(capabilities) == 0 {
+		capabilities = []string{protocol.CapabilitySuggest, protocol.CapabilityCompare}
+	}
+
+	cmd := exec.Command(binary, "serve", "--stdio")
+	cmd.Dir = opts.Dir
+	cmd.Env = opts.Env
+	cmd.Stderr = opts.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %v", binary, err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	c := &Client{cmd: cmd, stdin: stdin, scanner: scanner}
+
+	err = c.call(protocol.MethodInitialize, protocol.InitializeParams{
+		ClientName:       opts.ClientName,
+		ProtocolVersions: protocol.SupportedVersions,
+		Capabilities:     capabilities,
+	}, &c.Info)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// HasCapability reports whether the server enabled capability for this session.
+func (c *Client) HasCapability(capability string) bool {
+	for _, enabled := range c.Info.Capabilities {
+		if enabled == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest returns a commit message for the staged changes in dir.
+func (c *Client) Suggest(dir string) (protocol.SuggestResult, error) {
+	var result protocol.SuggestResult
+	err := c.call(protocol.MethodSuggest, protocol.SuggestParams{Dir: dir}, &result)
+	return result, err
+}
+
+// Compare returns a Markdown summary of what merging head into base changes.
+func (c *Client) Compare(dir, base, head string) (string, error) {
+	var result protocol.CompareResult
+	err := c.call(protocol.MethodCompare, protocol.CompareParams{Dir: dir, Base: base, Head: head}, &result)
+	return result.Markdown, err
+}
+
+// Close asks the server to shut down and waits for it to exit.
+func (c *Client) Close() error {
+	c.call(protocol.MethodShutdown, nil, nil)
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// call sends one request and decodes the matching response into result.
+func (c *Client) call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	req := protocol.Request{JSONRPC: "2.0", ID: c.nextID, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("sending %s: %v", method, err)
+	}
+
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return fmt.Errorf("reading %s response: %v", method, err)
+		}
+		return fmt.Errorf("reading %s response: server closed the connection", method)
+	}
+	var resp protocol.Response
+	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+		return fmt.Errorf("decoding %s response: %v", method, err)
+	}
+	if resp.ID != req.ID {
+		return fmt.Errorf("%s: response id %d does not match request id %d", method, resp.ID, req.ID)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Result) > 0 {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Command smart-commit-hook is a prepare-commit-msg hook that pre-fills the
+// message git opens in the editor with one generated for the staged
+// changes. It runs the commit flow of the pipeline package, whose commit
+// stage it replaces with filling in the file, without the rest of the
+// smart-commit CLI, so it starts fast; `smart-commit hook install
+// --fast` installs it, or call it from a hook of your own:
+//
+//	exec smart-commit-hook "$@"
+//
+// The provider, model, language, diff budget, types and prompt come from
+// the same settings and environment variables as smart-commit.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+	"github.com/chalfel/smart-commit/pipeline"
+)
+
+func main() {
+	// A failing hook would block the commit; report and let git go on
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "smart-commit-hook: %v\n", err)
+	}
+}
+
+// run fills the message file args[0]. It only acts on a plain `git
+// commit`: when git passes a source (-m or -F, a template, a merge, a
+// squash or an amend), or the file already has a message, it is left
+// alone.
+func run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: smart-commit-hook FILE [SOURCE [SHA]]")
+	}
+	if len(args) > 1 && args[1] != "" {
+		return nil
+	}
+	f, err := git.ReadMessageFile(args[0])
+	if err != nil || !f.Empty() {
+		return err
+	}
+
+	cfg := config.Current()
+	name, model := cfg.SelectedProvider()
+	gen, err := generator.New(name, model, nil)
+	if err != nil {
+		return err
+	}
+
+	// git commits once the editor closes; the hook's commit stage only
+	// fills in the message
+	flow := pipeline.Default(git.Repo{}, gen, cfg).
+		Use(pipeline.Before(pipeline.Generate, func(ctx context.Context, s *pipeline.State) error {
+			if err := gen.Check(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Generating commit message with %s...\n", gen.Name())
+			return nil
+		})).
+		Handle(pipeline.Commit, func(ctx context.Context, s *pipeline.State) error {
+			if s.ProviderErr != nil {
+				fmt.Fprintf(os.Stderr, "smart-commit-hook: %v; the message is worked out from the diff\n", s.ProviderErr)
+			}
+			return f.Prefill(s.Message)
+		})
+	err = flow.Run(context.Background(), &pipeline.State{})
+	if errors.Is(err, pipeline.ErrNothingStaged) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": "feat(api): add users endpoint"}}},
+		})
+	}))
+	defer server.Close()
+
+	repo := t.TempDir()
+	for k, v := range map[string]string{
+		"HOME": repo, "XDG_CONFIG_HOME": filepath.Join(repo, ".config"), "GIT_CONFIG_NOSYSTEM": "1",
+		"SMART_COMMIT_PROVIDER": "openai", "OPENAI_API_KEY": "test", "OPENAI_BASE_URL": server.URL,
+	} {
+		t.Setenv(k, v)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.WriteFile("users.go", []byte("package api\n"), 0644)
+	if out, err := exec.Command("git", "add", "users.go").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+
+	comments := "\n# Please enter the commit message for your changes.\n"
+	tests := []struct {
+		name    string
+		content string
+		source  string
+		want    string
+	}{
+		{"plain commit", comments, "", "feat(api): add users endpoint\n" + comments},
+		{"message given", comments, "message", comments},
+		{"message in the file", "fix: typo\n" + comments, "", "fix: typo\n" + comments},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+			os.WriteFile(file, []byte(tt.content), 0644)
+			if err := run([]string{file, tt.source}); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(file)
+			if got := string(data); got != tt.want {
+				t.Errorf("message file = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if err := run(nil); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("run() without a file = %v, want the usage", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Command smart-commit-server suggests commit messages to editor plugins
+// and other tools, over the stdio protocol (--stdio, see package client)
+// or the gRPC API (--grpc ADDR). It is built on the library packages
+// alone, so it stays small and can be deployed without the smart-commit
+// CLI; `smart-commit serve` also compares branches, explains commits,
+// renders changelogs, receives webhooks and serves tenants.
+//
+// Settings are read from the repository each request is about, with the
+// same environment variables as smart-commit. The gRPC API listens on the
+// loopback interface unless it is given a host and --token, which calls
+// then authenticate with.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	smartcommitv1 "github.com/chalfel/smart-commit/api/smartcommit/v1"
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+	"github.com/chalfel/smart-commit/protocol"
+	"github.com/chalfel/smart-commit/server"
+)
+
+// version is the release, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("smart-commit-server", flag.ExitOnError)
+	stdio := fs.Bool("stdio", false, "speak the JSON-RPC protocol over stdin/stdout")
+	grpcAddr := fs.String("grpc", "", "serve the gRPC API on this address (e.g. :50051, on the loopback interface)")
+	token := fs.String("token", os.Getenv("SMART_COMMIT_SERVER_TOKEN"), "require \"authorization: Bearer TOKEN\" metadata on gRPC calls; needed to listen beyond this machine")
+	fs.Parse(args)
+
+	switch {
+	case *stdio && *grpcAddr != "":
+		return fmt.Errorf("choose one transport: --stdio or --grpc")
+	case *stdio:
+		return newStdioServer().Serve(os.Stdin, os.Stdout)
+	case *grpcAddr != "":
+		lis, err := server.Listen(*grpcAddr, *token != "")
+		if err != nil {
+			return fmt.Errorf("%v; pass --token", err)
+		}
+		var opts []grpc.ServerOption
+		if *token != "" {
+			opts = append(opts, grpc.UnaryInterceptor(requireToken(*token)))
+		}
+		s := grpc.NewServer(opts...)
+		smartcommitv1.RegisterSmartCommitServer(s, grpcServer{})
+		fmt.Fprintf(os.Stderr, "Serving gRPC on %s\n", lis.Addr())
+		return s.Serve(lis)
+	}
+	return fmt.Errorf("smart-commit-server needs a transport; use --stdio or --grpc ADDR")
+}
+
+// requireToken refuses gRPC calls without "authorization: Bearer TOKEN"
+// metadata.
+func requireToken(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if got := md.Get("authorization"); len(got) == 0 || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(got[0], "Bearer ")), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "a valid token is required")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// errNothingStaged is returned when there is nothing to suggest a message
+// for.
+var errNothingStaged = errors.New("no staged changes")
+
+// suggest generates a message for what is staged in dir. When the provider
+// fails, the message is worked out from the diff and fallback is set.
+func suggest(ctx context.Context, dir string) (message string, fallback bool, err error) {
+	changes, err := git.Repo{Dir: dir}.LoadChangeSet("--cached")
+	if err != nil {
+		return "", false, fmt.Errorf("reading staged changes: %v", err)
+	}
+	if len(changes.Files) == 0 {
+		return "", false, errNothingStaged
+	}
+	cfg, err := config.LoadDir(dir)
+	if err != nil {
+		return "", false, err
+	}
+	name, model := cfg.SelectedProvider()
+	gen, err := generator.New(name, model, nil)
+	if err != nil {
+		return "", false, err
+	}
+	if t := cfg.RequestTimeout(); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+	message, err = generator.CommitMessage(ctx, gen, changes, cfg.CommitOptions())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", gen.Name(), err)
+		commitType, scope, description := generator.Heuristic(changes)
+		return conventional.WithScope(commitType+": "+description, scope), true, nil
+	}
+	return message, false, nil
+}
+
+// newStdioServer returns the protocol server, offering suggestions.
+func newStdioServer() *server.Server {
+	return server.New(version).Handle(protocol.MethodSuggest, protocol.CapabilitySuggest, func(raw json.RawMessage) (interface{}, error) {
+		var params protocol.SuggestParams
+		if err := server.Params(raw, &params); err != nil {
+			return nil, err
+		}
+		message, fallback, err := suggest(context.Background(), params.Dir)
+		return protocol.SuggestResult{Message: message, Fallback: fallback}, err
+	})
+}
+
+// grpcServer implements Suggest of the SmartCommit service; the other
+// methods answer Unimplemented.
+type grpcServer struct {
+	smartcommitv1.UnimplementedSmartCommitServer
+}
+
+func (grpcServer) Suggest(ctx context.Context, req *smartcommitv1.SuggestRequest) (*smartcommitv1.SuggestResponse, error) {
+	message, fallback, err := suggest(ctx, req.GetDir())
+	switch {
+	case errors.Is(err, errNothingStaged):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &smartcommitv1.SuggestResponse{Message: message, Fallback: fallback}, nil
+}
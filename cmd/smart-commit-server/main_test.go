@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/chalfel/smart-commit/protocol"
+)
+
+func TestStdioSuggest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tests := []struct {
+		name, answer string
+		want         protocol.SuggestResult
+	}{
+		{"generated", "feat(api): add users endpoint", protocol.SuggestResult{Message: "feat(api): add users endpoint"}},
+		{"provider down", "", protocol.SuggestResult{Message: "feat: add users", Fallback: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.answer == "" {
+					http.Error(w, "overloaded", http.StatusServiceUnavailable)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": tt.answer}}},
+				})
+			}))
+			defer server.Close()
+
+			repo := t.TempDir()
+			for k, v := range map[string]string{
+				"HOME": repo, "XDG_CONFIG_HOME": filepath.Join(repo, ".config"), "GIT_CONFIG_NOSYSTEM": "1",
+				"SMART_COMMIT_PROVIDER": "openai", "OPENAI_API_KEY": "test", "OPENAI_BASE_URL": server.URL,
+			} {
+				t.Setenv(k, v)
+			}
+			for _, args := range [][]string{{"init", "-q"}, {"add", "users.go"}} {
+				if args[0] == "add" {
+					os.WriteFile(filepath.Join(repo, "users.go"), []byte("package api\n"), 0644)
+				}
+				cmd := exec.Command("git", args...)
+				cmd.Dir = repo
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git %s: %v\n%s", args[0], err, out)
+				}
+			}
+
+			params, _ := json.Marshal(protocol.SuggestParams{Dir: repo})
+			in := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersions":[1],"capabilities":["suggest","compare"]}}` + "\n" +
+				`{"jsonrpc":"2.0","id":2,"method":"suggest","params":` + string(params) + `}` + "\n"
+			var out bytes.Buffer
+			if err := newStdioServer().Serve(strings.NewReader(in), &out); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			var init protocol.InitializeResult
+			var initResp, resp protocol.Response
+			json.Unmarshal([]byte(lines[0]), &initResp)
+			json.Unmarshal(initResp.Result, &init)
+			if len(init.Capabilities) != 1 || init.Capabilities[0] != protocol.CapabilitySuggest {
+				t.Errorf("negotiated %v, want only suggest", init.Capabilities)
+			}
+			json.Unmarshal([]byte(lines[1]), &resp)
+			if resp.Error != nil {
+				t.Fatalf("suggest failed: %v", resp.Error)
+			}
+			var got protocol.SuggestResult
+			json.Unmarshal(resp.Result, &got)
+			if got != tt.want {
+				t.Errorf("suggest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	check := requireToken("s3cret")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{"valid", metadata.Pairs("authorization", "Bearer s3cret"), codes.OK},
+		{"wrong", metadata.Pairs("authorization", "Bearer guess"), codes.Unauthenticated},
+		{"missing", nil, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+		_, err := check(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: code %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if err := run([]string{"--grpc", "0.0.0.0:0"}); err == nil || !strings.Contains(err.Error(), "--token") {
+		t.Errorf("serving beyond this machine without a token = %v, want a refusal", err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// messageAnnotationPath is the file CI annotations about commit messages are
+// attached to. Commit messages do not live in a file, so annotations point at
+// the workflow directory, which every repository running CI has.
+const messageAnnotationPath = ".github"
+
+// actionEvent is the part of a GitHub Actions pull_request event payload the
+// action uses.
+type actionEvent struct {
+	PullRequest *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// checkAnnotation is a single annotation of a check run.
+type checkAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+}
+
+// runAction implements `smart-commit action`, meant to run inside GitHub
+// Actions on pull_request events. It validates the pull request's commit
+// messages or proposes a squash title and reports through a check run.
+func runAction(args []string) error {
+	fs := flag.NewFlagSet("action", flag.ExitOnError)
+	mode := fs.String("mode", "lint", "what to do: lint (validate every commit message) or squash-title (propose a squash merge title)")
+	checkName := fs.String("check-name", "smart-commit", "name of the check run to create")
+	fs.Parse(args)
+
+	if *mode != "lint" && *mode != "squash-title" {
+		return fmt.Errorf("unknown --mode %q (expected lint or squash-title)", *mode)
+	}
+
+	eventPath := os.Getenv("GITHUB_EVENT_PATH")
+	if eventPath == "" {
+		return fmt.Errorf("GITHUB_EVENT_PATH is not set; smart-commit action must run inside GitHub Actions")
+	}
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return fmt.Errorf("reading event payload: %v", err)
+	}
+	var event actionEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("parsing event payload: %v", err)
+	}
+	if event.PullRequest == nil {
+		fmt.Printf("Event %q is not a pull request; nothing to do.\n", os.Getenv("GITHUB_EVENT_NAME"))
+		return nil
+	}
+
+	token := githubToken()
+	repo := event.Repository.FullName
+	commits, err := pullRequestCommits(repo, event.PullRequest.Number, token)
+	if err != nil {
+		return err
+	}
+
+	var run checkRun
+	if *mode == "lint" {
+		run = lintCheckRun(commits)
+	} else {
+		run = squashTitleCheckRun(event.PullRequest.Title, commits)
+	}
+	run.Name = *checkName
+	run.HeadSHA = event.PullRequest.Head.SHA
+	run.Status = "completed"
+
+	fmt.Println(run.Output.Summary)
+	if err := githubAPI("POST", fmt.Sprintf("/repos/%s/check-runs", repo), token, run, nil); err != nil {
+		return fmt.Errorf("creating check run: %v", err)
+	}
+	if run.Conclusion == "failure" {
+		return fmt.Errorf("%s", run.Output.Title)
+	}
+	return nil
+}
+
+// checkRun is the request body of the GitHub check run API.
+type checkRun struct {
+	Name       string `json:"name"`
+	HeadSHA    string `json:"head_sha"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	Output     struct {
+		Title       string            `json:"title"`
+		Summary     string            `json:"summary"`
+		Annotations []checkAnnotation `json:"annotations,omitempty"`
+	} `json:"output"`
+}
+
+// pullRequestCommits lists the commits of a pull request, following the
+// pages of the list.
+func pullRequestCommits(repo string, number int, token string) ([]git.Commit, error) {
+	var commits []git.Commit
+	path := fmt.Sprintf("/repos/%s/pulls/%d/commits?per_page=100", repo, number)
+	for path != "" {
+		var page []struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Message string `json:"message"`
+			} `json:"commit"`
+		}
+		next, err := githubAPIPage("GET", path, token, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, pc := range page {
+			commits = append(commits, commitFromMessage(pc.SHA, pc.Commit.Message))
+		}
+		path = next
+	}
+	return commits, nil
+}
+
+// lintCheckRun validates every commit message and turns problems into
+// annotations.
+func lintCheckRun(commits []git.Commit) checkRun {
+	var run checkRun
+	var summary strings.Builder
+	invalid := 0
+	for _, c := range commits {
+		problems := lintMessage(c.Message())
+		if len(problems) == 0 {
+			fmt.Fprintf(&summary, "- ✅ `%s` %s\n", git.ShortHash(c.Hash), c.Subject)
+			continue
+		}
+		invalid++
+		fmt.Fprintf(&summary, "- ❌ `%s` %s\n", git.ShortHash(c.Hash), c.Subject)
+		for _, p := range problems {
+			fmt.Fprintf(&summary, "  - %s\n", p)
+			run.Output.Annotations = append(run.Output.Annotations, checkAnnotation{
+				Path:            messageAnnotationPath,
+				StartLine:       1,
+				EndLine:         1,
+				AnnotationLevel: "failure",
+				Title:           fmt.Sprintf("%s: %s", git.ShortHash(c.Hash), p.Rule),
+				Message:         fmt.Sprintf("%s\n\n%s", c.Subject, p.Message),
+			})
+		}
+	}
+
+	// The API accepts at most 50 annotations per request
+	if len(run.Output.Annotations) > 50 {
+		run.Output.Annotations = run.Output.Annotations[:50]
+	}
+
+	if invalid == 0 {
+		run.Conclusion = "success"
+		run.Output.Title = fmt.Sprintf("All %d commit messages follow the conventions", len(commits))
+	} else {
+		run.Conclusion = "failure"
+		run.Output.Title = fmt.Sprintf("%d of %d commit messages need fixing", invalid, len(commits))
+	}
+	run.Output.Summary = summary.String()
+	return run
+}
+
+// squashTitleCheckRun proposes a conventional title for squash-merging the
+// pull request and exposes it as the squash_title step output, with the full
+// message including the commits' trailers as squash_message.
+func squashTitleCheckRun(prTitle string, commits []git.Commit) checkRun {
+	var changes strings.Builder
+	fmt.Fprintf(&changes, "Pull request: %s\n", prTitle)
+	for _, c := range commits {
+		fmt.Fprintf(&changes, "- %s\n", c.Subject)
+	}
+
+	title, err := askModel(generator.RenderPrompt(generator.PromptSquashTitle, map[string]string{"Changes": changes.String()}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+		title = prTitle
+	}
+	title = conventional.Enforce(strings.SplitN(title, "\n", 2)[0], conventional.DetermineType(changes.String()))
+
+	// GitHub drops the trailers of squashed commits unless they are in the
+	// merge message
+	message := title
+	if trailers := squashTrailers(commits); len(trailers) > 0 {
+		if withTrailers, err := addTrailers(title, trailers); err == nil {
+			message = withTrailers
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if outputPath := os.Getenv("GITHUB_OUTPUT"); outputPath != "" {
+		if err := writeActionOutputs(outputPath, map[string]string{"squash_title": title, "squash_message": message}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing step outputs: %v\n", err)
+		}
+	}
+
+	var run checkRun
+	run.Conclusion = "neutral"
+	run.Output.Title = "Suggested squash title: " + title
+	run.Output.Summary = fmt.Sprintf("Squash-merge this pull request as:\n\n```\n%s\n```\n", message)
+	return run
+}
+
+// writeActionOutputs appends step outputs to the GITHUB_OUTPUT file at path.
+// Values are written between delimiters chosen at random for each one, so
+// a value, which may come from commit messages, cannot end its own early
+// and set other outputs.
+func writeActionOutputs(path string, outputs map[string]string) error {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		value := outputs[name]
+		var delimiter string
+		for delimiter == "" || strings.Contains(value, delimiter) {
+			random := make([]byte, 16)
+			if _, err := rand.Read(random); err != nil {
+				return err
+			}
+			delimiter = "SMART_COMMIT_EOF_" + hex.EncodeToString(random)
+		}
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPullRequestCommitsPages(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/o/r/pulls/1/commits?per_page=100&page=2>; rel="next", <%s/repos/o/r/pulls/1/commits?per_page=100&page=2>; rel="last"`, srv.URL, srv.URL))
+			fmt.Fprint(w, `[{"sha": "a1", "commit": {"message": "feat: one"}}]`)
+		case "2":
+			// A next link off the API is not followed
+			w.Header().Set("Link", `<https://elsewhere.example/page3>; rel="next"`)
+			fmt.Fprint(w, `[{"sha": "b2", "commit": {"message": "fix: two"}}]`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GITHUB_API_URL", srv.URL)
+
+	commits, err := pullRequestCommits("o/r", 1, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Hash != "a1" || commits[1].Hash != "b2" {
+		t.Errorf("pullRequestCommits() = %+v, want both pages", commits)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	base := "https://api.github.com"
+	tests := []struct {
+		link, want string
+	}{
+		{"", ""},
+		{`<https://api.github.com/repos/o/r/pulls/1/commits?page=2>; rel="next", <https://api.github.com/repos/o/r/pulls/1/commits?page=5>; rel="last"`, "/repos/o/r/pulls/1/commits?page=2"},
+		{`<https://api.github.com/repos/o/r/pulls/1/commits?page=1>; rel="prev"`, ""},
+		{`<https://api.github.com.evil.example/x>; rel="next"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextPage(tt.link, base); got != tt.want {
+			t.Errorf("nextPage(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestWriteActionOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	message := "feat: add users\n\nSMART_COMMIT_EOF\nsquash_title=injected"
+	if err := writeActionOutputs(path, map[string]string{"squash_title": "feat: add users", "squash_message": message}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse the file the way the runner does
+	outputs := map[string]string{}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		name, delimiter, ok := strings.Cut(lines[i], "<<")
+		if !ok {
+			t.Fatalf("line %q is not a delimited output", lines[i])
+		}
+		var value []string
+		for i++; lines[i] != delimiter; i++ {
+			value = append(value, lines[i])
+		}
+		outputs[name] = strings.Join(value, "\n")
+	}
+	if outputs["squash_title"] != "feat: add users" || outputs["squash_message"] != message {
+		t.Errorf("outputs = %q", outputs)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// airGapped is set in binaries built with the airgap tag, or at run time by
+// SMART_COMMIT_AIR_GAPPED=1: only local providers are available and no
+// request leaves the machine.
+var airGapped bool
+
+// localProviders are the providers that run on the machine itself.
+var localProviders = map[string]bool{"ollama": true}
+
+// enableAirGap turns air-gapped mode on for the rest of the run.
+func enableAirGap() {
+	if airGapped {
+		return
+	}
+	airGapped = true
+	http.DefaultTransport = airGapTransport{base: http.DefaultTransport}
+	httpClient.Transport = airGapTransport{base: httpClient.Transport}
+	providerClient.Transport = httpClient.Transport
+	git.DefaultRunner = airGapRunner{next: git.DefaultRunner}
+}
+
+// defaultProvider is the provider used when none is configured.
+func defaultProvider() string {
+	if airGapped {
+		return "ollama"
+	}
+	return "copilot"
+}
+
+// availableProviders lists the providers that can be used in this run.
+func availableProviders() []string {
+	if !airGapped {
+		return generator.Names
+	}
+	var names []string
+	for _, name := range generator.Names {
+		if localProviders[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// airGapTransport refuses every request but those to the loopback
+// interface, where local providers such as Ollama listen.
+type airGapTransport struct {
+	base http.RoundTripper
+}
+
+func (t airGapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isLoopback(req.URL.Hostname()) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("refusing to connect to %s in air-gapped mode", req.URL.Host)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// isLoopback reports whether host names the machine itself, without
+// looking it up.
+func isLoopback(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// airGapRunner refuses the GitHub CLI, which talks to GitHub for the
+// Copilot provider, pull requests and the API. git itself still reaches
+// the repository's own remotes, e.g. to push.
+type airGapRunner struct {
+	next git.Runner
+}
+
+func (r airGapRunner) Run(cmd *exec.Cmd) error {
+	if len(cmd.Args) > 0 && strings.TrimSuffix(filepath.Base(cmd.Args[0]), ".exe") == "gh" {
+		return fmt.Errorf("refusing to run %q in air-gapped mode", strings.Join(cmd.Args, " "))
+	}
+	return r.next.Run(cmd)
+}
+
+func init() {
+	if airGapBuild || os.Getenv("SMART_COMMIT_AIR_GAPPED") == "1" {
+		enableAirGap()
+	}
+}
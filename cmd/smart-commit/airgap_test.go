@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"localhost", true},
+		{"LOCALHOST.", true},
+		{"ollama.localhost", true},
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"10.0.0.5", false},
+		{"api.openai.com", false},
+		{"localhost.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.host); got != tt.want {
+			t.Errorf("isLoopback(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestAirGapped(t *testing.T) {
+	if !airGapped {
+		defaultTransport, transport, runner := http.DefaultTransport, httpClient.Transport, git.DefaultRunner
+		t.Cleanup(func() {
+			airGapped = false
+			http.DefaultTransport, httpClient.Transport, providerClient.Transport = defaultTransport, transport, transport
+			git.DefaultRunner = runner
+		})
+		enableAirGap()
+	}
+
+	for _, name := range []string{"copilot", "openai", "anthropic"} {
+		if _, err := newGenerator(name, ""); err == nil || !strings.Contains(err.Error(), "air-gapped") {
+			t.Errorf("newGenerator(%s) = %v, want it refused", name, err)
+		}
+	}
+	if gen, err := newGenerator("", ""); err != nil || gen.Name() != "Ollama" {
+		t.Errorf("newGenerator(\"\") = %v, %v, want Ollama", gen, err)
+	}
+
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer local.Close()
+	resp, err := httpClient.Get(local.URL)
+	if err != nil {
+		t.Fatalf("a request to the loopback interface failed: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := httpClient.Get("https://api.openai.com/v1/models"); err == nil || !strings.Contains(err.Error(), "air-gapped") {
+		t.Errorf("a request to api.openai.com = %v, want it refused", err)
+	}
+	if _, err := git.Output("version"); err != nil {
+		t.Errorf("git version: %v", err)
+	}
+	if err := git.DefaultRunner.Run(exec.Command("gh", "api", "user")); err == nil {
+		t.Error("gh ran in air-gapped mode")
+	}
+}
+
+// TestAirGapBuildLinksNoNetworkProviders builds the airgap binary and checks
+// that no code reaching the network providers was linked into it.
+func TestAirGapBuildLinksNoNetworkProviders(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a binary")
+	}
+	bin := filepath.Join(t.TempDir(), "smart-commit")
+	if out, err := exec.Command("go", "build", "-tags", "airgap", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build -tags airgap: %v\n%s", err, out)
+	}
+	out, err := exec.Command("go", "tool", "nm", bin).Output()
+	if err != nil {
+		t.Fatalf("go tool nm: %v", err)
+	}
+	for _, symbol := range []string{
+		"generator.NewOpenAI",
+		"generator.NewAnthropic",
+		"generator.(*OpenAI).Generate",
+		"generator.(*Anthropic).Generate",
+		"generator.(*Copilot).Generate",
+	} {
+		if strings.Contains(string(out), "smart-commit/"+symbol+"\n") {
+			t.Errorf("the airgap build links %s", symbol)
+		}
+	}
+}
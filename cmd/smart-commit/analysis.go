@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// analyzers describe what changes do that their lines do not show, for the
+// prompt. Each returns "" when it has nothing to say.
+var analyzers = []func(*git.ChangeSet) string{
+	describeInfraChanges,
+	describeSQLChanges,
+	describeConfigChanges,
+	describeCallers,
+}
+
+// describeAnalysis is what the analyzers say about changes.
+func describeAnalysis(changes *git.ChangeSet) string {
+	var parts []string
+	for _, analyze := range analyzers {
+		if s := analyze(changes); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// apiBase is the revision the staged Go packages are compared with to
+// report public API changes; main sets it to HEAD^ when amending.
+var apiBase = "HEAD"
+
+// maxAPIChanges bounds how many API changes the body lists.
+const maxAPIChanges = 15
+
+// apiDecl is an exported declaration of a Go package: a func, method,
+// type, field, interface method, const or var, with its signature.
+type apiDecl struct {
+	Kind, Signature string
+}
+
+// apiChange is a change to the exported API of a package.
+type apiChange struct {
+	Package  string // directory of the package, or its name at the top
+	Name     string // e.g. Load, Config.Port or Config.Merge
+	Change   string // added, removed or changed
+	Decl     apiDecl
+	Breaking bool
+}
+
+func (c apiChange) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%s: %s %s", c.Package, c.Change, c.Decl.Kind)
+	}
+	s := fmt.Sprintf("%s: %s %s %s", c.Package, c.Change, c.Decl.Kind, c.Name)
+	if sig := c.Decl.Signature; c.Change != "removed" && c.Decl.Kind != "type" && sig != "" && sig != "embedded" {
+		if !strings.HasPrefix(sig, "(") {
+			s += " "
+		}
+		s += sig
+	}
+	return s
+}
+
+// wantAPIChanges reports whether Go API and contract changes are
+// reported, as the api_changes setting says.
+func wantAPIChanges() bool {
+	return config.Bool(config.Current().APIChanges, true)
+}
+
+// apiSection is a section of the body listing API changes; ref names a
+// change in the BREAKING CHANGE footer.
+type apiSection struct {
+	Title  string
+	Report []apiChange
+	ref    func(apiChange) string
+}
+
+// addAPIChanges adds a "Public API changes" section to message listing how
+// the staged changes alter the exported API of Go packages, and a "Contract
+// changes" one for proto and OpenAPI specs. When that breaks callers, it
+// adds a BREAKING CHANGE footer and the "!" that goes with it.
+func addAPIChanges(message string, changes *git.ChangeSet) string {
+	if !wantAPIChanges() {
+		return message
+	}
+	sections := []apiSection{
+		{"Public API changes", stagedAPIChanges(changes), func(c apiChange) string {
+			return strings.TrimSuffix(c.Package+"."+c.Name, ".")
+		}},
+		{"Contract changes", stagedContractChanges(changes), func(c apiChange) string {
+			if c.Name == "" {
+				return c.Package
+			}
+			return c.Name + " in " + c.Package
+		}},
+	}
+	text, trailers := conventional.SplitTrailers(message)
+	var b strings.Builder
+	b.WriteString(text)
+	var breaking []string
+	for _, s := range sections {
+		if len(s.Report) == 0 {
+			continue
+		}
+		b.WriteString("\n\n" + s.Title + ":\n")
+		for i, c := range s.Report {
+			if i == maxAPIChanges {
+				fmt.Fprintf(&b, "- and %d more\n", len(s.Report)-i)
+			}
+			if i < maxAPIChanges {
+				b.WriteString("- " + c.String() + "\n")
+			}
+			if c.Breaking && len(breaking) < maxAPIChanges {
+				breaking = append(breaking, s.ref(c)+" "+c.Change)
+			}
+		}
+	}
+	if b.Len() == len(text) {
+		return message
+	}
+	hasFooter := false
+	for _, t := range trailers {
+		hasFooter = hasFooter || t.Token == "BREAKING CHANGE" || t.Token == "BREAKING-CHANGE"
+	}
+	if len(breaking) > 0 && !hasFooter {
+		trailers = append(trailers, conventional.Trailer{Token: "BREAKING CHANGE", Value: "incompatible public API changes: " + strings.Join(breaking, ", ")})
+	}
+	message = strings.TrimRight(b.String(), "\n")
+	if len(trailers) > 0 {
+		message += "\n"
+		for _, t := range trailers {
+			message += "\n" + t.String()
+		}
+	}
+	if len(breaking) > 0 {
+		message = markBreaking(message)
+	}
+	return message
+}
+
+// stagedAPIChanges compares the exported API of every Go package with
+// changed files as staged and as of apiBase. Main packages, internal
+// packages and tests are left out, as nothing outside can use them.
+func stagedAPIChanges(changes *git.ChangeSet) []apiChange {
+	// A first commit adds everything; there is nothing to compare with
+	if !git.RefExists(apiBase) {
+		return nil
+	}
+	dirs := map[string]bool{}
+	for _, f := range changes.Files {
+		for _, p := range []string{f.OldPath, f.Path} {
+			if p != "" && isAPIFile(p) {
+				dirs[path.Dir(p)] = true
+			}
+		}
+	}
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+
+	var report []apiChange
+	for _, dir := range sorted {
+		if dir == "internal" || strings.HasPrefix(dir, "internal/") || strings.Contains(dir, "/internal/") || strings.HasSuffix(dir, "/internal") {
+			continue
+		}
+		oldFiles, newFiles := readPackage(dir, apiBase), readPackage(dir, "")
+		oldName, oldAPI := packageAPI(oldFiles)
+		newName, newAPI := packageAPI(newFiles)
+		name := dir
+		if dir == "." {
+			name = newName
+			if name == "" {
+				name = oldName
+			}
+		}
+		// A package added or removed whole is one change
+		switch {
+		case len(oldFiles) == 0 && len(newAPI) > 0:
+			report = append(report, apiChange{Package: name, Change: "added", Decl: apiDecl{Kind: "package"}})
+		case len(oldAPI) > 0 && len(newFiles) == 0:
+			report = append(report, apiChange{Package: name, Change: "removed", Decl: apiDecl{Kind: "package"}, Breaking: true})
+		default:
+			report = append(report, diffAPI(name, oldAPI, newAPI)...)
+		}
+	}
+	return report
+}
+
+// isAPIFile reports whether p is a Go source file other than a test.
+func isAPIFile(p string) bool {
+	return strings.HasSuffix(p, ".go") && !strings.HasSuffix(p, "_test.go")
+}
+
+// readPackage returns the Go files of dir, by name, at rev, or as staged
+// when rev is empty.
+func readPackage(dir, rev string) map[string][]byte {
+	args := []string{"ls-files", "--full-name", "-z", "--", ":(top)" + dir}
+	if rev != "" {
+		args = []string{"ls-tree", "--full-tree", "--name-only", "-z", rev}
+		if dir != "." {
+			args = append(args, "--", dir+"/")
+		}
+	}
+	list, err := git.Output(args...)
+	if err != nil {
+		return nil
+	}
+	files := map[string][]byte{}
+	for _, p := range strings.Split(list, "\x00") {
+		if !isAPIFile(p) || path.Dir(p) != dir {
+			continue
+		}
+		if src, err := git.Output("show", rev+":"+p); err == nil {
+			files[p] = []byte(src)
+		}
+	}
+	return files
+}
+
+// packageAPI returns the name and the exported declarations of the package
+// made of files, keyed by name. Files that do not parse are skipped, and
+// main packages have no API.
+func packageAPI(files map[string][]byte) (string, map[string]apiDecl) {
+	fset := token.NewFileSet()
+	api := map[string]apiDecl{}
+	name := ""
+	for filename, src := range files {
+		f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+		if err != nil || f.Name.Name == "main" {
+			continue
+		}
+		name = f.Name.Name
+		for _, decl := range f.Decls {
+			addDecls(api, decl)
+		}
+	}
+	return name, api
+}
+
+// addDecls adds the exported declarations of decl to api.
+func addDecls(api map[string]apiDecl, decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if !d.Name.IsExported() {
+			return
+		}
+		if d.Recv == nil {
+			api[d.Name.Name] = apiDecl{"func", funcSignature(d.Type)}
+			return
+		}
+		if recv := receiverName(d.Recv.List[0].Type); ast.IsExported(recv) {
+			api[recv+"."+d.Name.Name] = apiDecl{"method", funcSignature(d.Type)}
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if s.Name.IsExported() {
+					addType(api, s)
+				}
+			case *ast.ValueSpec:
+				kind := "var"
+				if d.Tok == token.CONST {
+					kind = "const"
+				}
+				for _, n := range s.Names {
+					if n.IsExported() {
+						api[n.Name] = apiDecl{kind, exprString(s.Type)}
+					}
+				}
+			}
+		}
+	}
+}
+
+// addType adds an exported type to api, with the exported fields of a
+// struct and the methods of an interface.
+func addType(api map[string]apiDecl, s *ast.TypeSpec) {
+	name := s.Name.Name
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		api[name] = apiDecl{"type", "struct"}
+		for _, field := range t.Fields.List {
+			names := field.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{{Name: receiverName(field.Type)}}
+			}
+			for _, n := range names {
+				if ast.IsExported(n.Name) {
+					api[name+"."+n.Name] = apiDecl{"field", exprString(field.Type)}
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		api[name] = apiDecl{"type", "interface"}
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				api[name+"."+exprString(m.Type)] = apiDecl{"interface method", "embedded"}
+				continue
+			}
+			if ft, ok := m.Type.(*ast.FuncType); ok {
+				api[name+"."+m.Names[0].Name] = apiDecl{"interface method", funcSignature(ft)}
+			}
+		}
+	default:
+		sig := exprString(s.Type)
+		if s.Assign.IsValid() {
+			sig = "= " + sig
+		}
+		api[name] = apiDecl{"type", sig}
+	}
+}
+
+// diffAPI compares the exported declarations of a package before and
+// after. Removing or changing a declaration breaks callers, as does adding
+// a method to an existing interface, which its implementations lack.
+// Members of types that are added or removed wholesale are not listed.
+// Contracts are compared the same way.
+func diffAPI(pkg string, before, after map[string]apiDecl) []apiChange {
+	parentChanged := func(name string) bool {
+		parts := strings.Split(name, ".")
+		for i := 1; i < len(parts); i++ {
+			parent := strings.Join(parts[:i], ".")
+			_, had := before[parent]
+			_, has := after[parent]
+			if had != has {
+				return true
+			}
+		}
+		return false
+	}
+	var changes []apiChange
+	for name, old := range before {
+		if parentChanged(name) {
+			continue
+		}
+		if now, ok := after[name]; !ok {
+			changes = append(changes, apiChange{Package: pkg, Name: name, Change: "removed", Decl: old, Breaking: true})
+		} else if now != old {
+			changes = append(changes, apiChange{Package: pkg, Name: name, Change: "changed", Decl: now, Breaking: true})
+		}
+	}
+	for name, now := range after {
+		if _, ok := before[name]; ok || parentChanged(name) {
+			continue
+		}
+		changes = append(changes, apiChange{Package: pkg, Name: name, Change: "added", Decl: now, Breaking: now.Kind == "interface method"})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Breaking != changes[j].Breaking {
+			return changes[i].Breaking
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// receiverName is the name of the type of a receiver or embedded field,
+// without pointer and type parameters.
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// funcSignature prints a func type without the func keyword, e.g.
+// "(path string) (*Config, error)".
+func funcSignature(t *ast.FuncType) string {
+	return strings.TrimPrefix(exprString(t), "func")
+}
+
+// exprString prints a type expression; nil prints as "".
+func exprString(expr ast.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	var b bytes.Buffer
+	printer.Fprint(&b, token.NewFileSet(), expr)
+	return b.String()
+}
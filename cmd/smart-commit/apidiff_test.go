@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffAPI(t *testing.T) {
+	tests := []struct {
+		name, before, after string
+		want                []string
+	}{
+		{
+			"added function",
+			"package config\n\nfunc Load() error { return nil }\n",
+			"package config\n\nfunc Load() error { return nil }\n\nfunc Save(path string) error { return nil }\nfunc helper() {}\n",
+			[]string{"config: added func Save(path string) error"},
+		},
+		{
+			"removed and changed",
+			"package config\n\nfunc Load() error { return nil }\nfunc Merge(o Config) {}\n\ntype Config struct{ Port int; name string }\n",
+			"package config\n\nfunc Merge(o *Config) {}\n\ntype Config struct {\n\tPort string\n\tHost string\n}\n",
+			[]string{"config: changed field Config.Port string (breaking)", "config: removed func Load (breaking)", "config: changed func Merge(o *Config) (breaking)", "config: added field Config.Host string"},
+		},
+		{
+			"interface method",
+			"package gen\n\ntype Generator interface{ Name() string }\n",
+			"package gen\n\ntype Generator interface {\n\tName() string\n\tPing() error\n}\n\ntype Options struct{ Model string }\n\nfunc (o *Options) Valid() bool { return true }\n",
+			[]string{"gen: added interface method Generator.Ping() error (breaking)", "gen: added type Options"},
+		},
+		{
+			"unexported only",
+			"package gen\n\nfunc run() {}\n",
+			"package gen\n\nfunc run(n int) {}\n",
+			nil,
+		},
+		{
+			"main package",
+			"package main\n\nfunc Run() {}\n",
+			"package main\n\nfunc Run(n int) {}\n",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, before := packageAPI(map[string][]byte{"a.go": []byte(tt.before)})
+			pkg, after := packageAPI(map[string][]byte{"a.go": []byte(tt.after)})
+			var got []string
+			for _, c := range diffAPI(pkg, before, after) {
+				s := c.String()
+				if c.Breaking {
+					s += " (breaking)"
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("diffAPI() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// deviceFlowProvider describes a service that supports the OAuth 2.0 device
+// authorization grant, letting the tool obtain tokens without a browser on
+// the same machine.
+type deviceFlowProvider struct {
+	Name          string
+	Host          string
+	DeviceCodeURL string
+	TokenURL      string
+	ClientID      string
+	Scope         string
+}
+
+// deviceFlowProviders lists the providers `auth login` supports. Client IDs
+// come from the environment so organizations can use their own OAuth apps;
+// github logs in to the GitHub host in use (see githubHost).
+func deviceFlowProviders() map[string]deviceFlowProvider {
+	host := githubHost()
+	return map[string]deviceFlowProvider{
+		"github": {
+			Name:          "github",
+			Host:          host,
+			DeviceCodeURL: "https://" + host + "/login/device/code",
+			TokenURL:      "https://" + host + "/login/oauth/access_token",
+			ClientID:      os.Getenv("SMART_COMMIT_GITHUB_CLIENT_ID"),
+			Scope:         "repo",
+		},
+	}
+}
+
+// storedToken is a token obtained through the device flow.
+type storedToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	Host         string    `json:"host,omitempty"`
+}
+
+// host is the host the token was issued by. Tokens stored before hosts
+// were recorded are github.com's.
+func (t storedToken) host() string {
+	if t.Host == "" {
+		return "github.com"
+	}
+	return t.Host
+}
+
+// expired reports whether the token is past (or about to reach) its expiry.
+func (t storedToken) expired() bool {
+	return !t.Expiry.IsZero() && time.Now().Add(time.Minute).After(t.Expiry)
+}
+
+// tokenResponse is the token endpoint's answer, successful or not.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+	Interval     int    `json:"interval"`
+}
+
+func (r tokenResponse) token() storedToken {
+	t := storedToken{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken}
+	if r.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return t
+}
+
+// deviceLogin runs the device authorization flow: it shows the user a code
+// to enter on the provider's site and polls until access is granted.
+func deviceLogin(p deviceFlowProvider) (storedToken, error) {
+	if p.ClientID == "" {
+		return storedToken{}, fmt.Errorf("no OAuth client ID configured for %s (set SMART_COMMIT_%s_CLIENT_ID)", p.Name, strings.ToUpper(p.Name))
+	}
+
+	var code struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	form := url.Values{"client_id": {p.ClientID}, "scope": {p.Scope}}
+	if err := postForm(p.DeviceCodeURL, form, &code); err != nil {
+		return storedToken{}, fmt.Errorf("requesting device code: %v", err)
+	}
+
+	fmt.Printf("Open %s and enter the code: %s\n", code.VerificationURI, code.UserCode)
+	fmt.Println("Waiting for authorization...")
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		var resp tokenResponse
+		form := url.Values{
+			"client_id":   {p.ClientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}
+		if err := postForm(p.TokenURL, form, &resp); err != nil {
+			return storedToken{}, fmt.Errorf("polling for token: %v", err)
+		}
+		switch resp.Error {
+		case "":
+			return resp.token(), nil
+		case "authorization_pending":
+		case "slow_down":
+			// The provider asks for a longer polling interval
+			interval += 5 * time.Second
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			}
+		case "expired_token":
+			return storedToken{}, fmt.Errorf("the code expired before it was entered; run auth login again")
+		case "access_denied":
+			return storedToken{}, fmt.Errorf("authorization was denied")
+		default:
+			return storedToken{}, fmt.Errorf("%s: %s", resp.Error, resp.ErrorDesc)
+		}
+	}
+	return storedToken{}, fmt.Errorf("the code expired before it was entered; run auth login again")
+}
+
+// refreshToken exchanges a refresh token for a new access token.
+func refreshToken(p deviceFlowProvider, t storedToken) (storedToken, error) {
+	var resp tokenResponse
+	form := url.Values{
+		"client_id":     {p.ClientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {t.RefreshToken},
+	}
+	if err := postForm(p.TokenURL, form, &resp); err != nil {
+		return storedToken{}, err
+	}
+	if resp.Error != "" {
+		return storedToken{}, fmt.Errorf("%s: %s", resp.Error, resp.ErrorDesc)
+	}
+	return resp.token(), nil
+}
+
+// postForm posts an OAuth form request and decodes the JSON answer.
+func postForm(endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(interrupted, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// OAuth errors such as authorization_pending come back as 400 with a
+	// JSON body, which callers inspect
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// credentialsPath is the file device-flow tokens are stored in.
+func credentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smart-commit", "credentials.json"), nil
+}
+
+// loadCredentials reads the stored tokens by provider. Tokens are kept
+// encrypted with the local key, like other secrets.
+func loadCredentials() (map[string]storedToken, error) {
+	creds := map[string]storedToken{}
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var sealed map[string]string
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for name, value := range sealed {
+		plaintext, err := decryptSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s credentials: %v", name, err)
+		}
+		var t storedToken
+		if err := json.Unmarshal([]byte(plaintext), &t); err != nil {
+			return nil, fmt.Errorf("%s credentials: %v", name, err)
+		}
+		creds[name] = t
+	}
+	return creds, nil
+}
+
+// saveCredentials writes the stored tokens, encrypting each one.
+func saveCredentials(creds map[string]storedToken) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	sealed := map[string]string{}
+	for name, t := range creds {
+		data, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if sealed[name], err = encryptSecret(string(data)); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// providerToken returns the stored device-flow token for a provider,
+// refreshing it first when it has expired. It is empty when the user has not
+// logged in.
+func providerToken(name string) string {
+	p, ok := deviceFlowProviders()[name]
+	if !ok {
+		return ""
+	}
+	path, err := credentialsPath()
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	creds, err := loadCredentials()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reading stored credentials: %v\n", err)
+		return ""
+	}
+	t, ok := creds[name]
+	if !ok || t.host() != p.Host {
+		// A token is never sent to, or refreshed at, another host
+		return ""
+	}
+	if !t.expired() {
+		return t.AccessToken
+	}
+	if t.RefreshToken == "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s token expired; run `smart-commit auth login %s`\n", name, name)
+		return ""
+	}
+
+	refreshed, err := refreshToken(p, t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: refreshing %s token failed: %v; run `smart-commit auth login %s`\n", name, err, name)
+		return ""
+	}
+	refreshed.Host = t.Host
+	creds[name] = refreshed
+	if err := saveCredentials(creds); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saving refreshed %s token: %v\n", name, err)
+	}
+	return refreshed.AccessToken
+}
+
+// runAuth implements `smart-commit auth`.
+func runAuth(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: smart-commit auth login|logout|status [PROVIDER]")
+	}
+	name := "github"
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	switch args[0] {
+	case "login":
+		p, ok := deviceFlowProviders()[name]
+		if !ok {
+			return fmt.Errorf("provider %q does not support device login", name)
+		}
+		t, err := deviceLogin(p)
+		if err != nil {
+			return err
+		}
+		t.Host = p.Host
+		creds, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		creds[name] = t
+		if err := saveCredentials(creds); err != nil {
+			return fmt.Errorf("saving credentials: %v", err)
+		}
+		fmt.Printf("Logged in to %s on %s.\n", name, p.Host)
+		return nil
+
+	case "logout":
+		creds, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		delete(creds, name)
+		return saveCredentials(creds)
+
+	case "status":
+		creds, err := loadCredentials()
+		if err != nil {
+			return err
+		}
+		if len(creds) == 0 {
+			fmt.Println("Not logged in to any provider.")
+		}
+		for provider, t := range creds {
+			switch {
+			case t.Expiry.IsZero():
+				fmt.Printf("%s: logged in\n", provider)
+			case t.expired() && t.RefreshToken == "":
+				fmt.Printf("%s: token expired\n", provider)
+			default:
+				fmt.Printf("%s: logged in (token refreshes automatically)\n", provider)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown auth command %q", args[0])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+)
+
+// bodyWidth is the column commit message bodies are wrapped at.
+const bodyWidth = 72
+
+// withBody and withFooter are set by --body and --footer; nil means the
+// body and footer settings apply.
+var withBody, withFooter *bool
+
+// wantBody reports whether generated messages get a body explaining why.
+func wantBody() bool {
+	if withBody != nil {
+		return *withBody
+	}
+	return config.Bool(config.Current().Body, false)
+}
+
+// wantFooter reports whether generated messages get a BREAKING CHANGE
+// footer when the change breaks compatibility.
+func wantFooter() bool {
+	if withFooter != nil {
+		return *withFooter
+	}
+	return config.Bool(config.Current().Footer, false)
+}
+
+// withEmoji is set by --emoji; nil means the emoji setting applies.
+var withEmoji *bool
+
+// addEmoji starts the subject with the emoji for its type when emoji are
+// on: the gitmoji, or the one emoji_map sets, placed as emoji_placement
+// says.
+func addEmoji(message string) string {
+	cfg := config.Current()
+	if !config.Bool(withEmoji, config.Bool(cfg.Emoji, false)) {
+		return message
+	}
+	emojis := make(map[string]string, len(conventional.Gitmoji)+len(cfg.EmojiMap))
+	for t, e := range conventional.Gitmoji {
+		emojis[t] = e
+	}
+	for t, e := range cfg.EmojiMap {
+		emojis[strings.ToLower(t)] = e
+	}
+	return conventional.WithEmoji(message, emojis, cfg.EmojiPlacement == "after")
+}
+
+// markBreaking marks the subject of message as a breaking change with "!"
+// unless it is already.
+func markBreaking(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	if strings.Contains(strings.SplitN(subject, ":", 2)[0], "!") {
+		return message
+	}
+	if i := strings.Index(subject, ":"); i > 0 {
+		return message[:i] + "!" + message[i:]
+	}
+	return message
+}
+
+// addBodySection adds a section listing items to the body of message,
+// after what is there and before the trailers. Items past maxAPIChanges
+// are counted rather than listed.
+func addBodySection(message, title string, items []string) string {
+	if len(items) == 0 {
+		return message
+	}
+	text, trailers := conventional.SplitTrailers(message)
+	var b strings.Builder
+	b.WriteString(text + "\n\n" + title + ":\n")
+	for i, item := range items {
+		if i == maxAPIChanges {
+			fmt.Fprintf(&b, "- and %d more\n", len(items)-i)
+			break
+		}
+		b.WriteString("- " + item + "\n")
+	}
+	message = strings.TrimRight(b.String(), "\n")
+	if len(trailers) > 0 {
+		message += "\n"
+		for _, t := range trailers {
+			message += "\n" + t.String()
+		}
+	}
+	return message
+}
+
+// formatMessage tidies a generated message: the body is wrapped, a
+// BREAKING CHANGE footer marks the subject with "!", the subject gets its
+// emoji when emoji are on, and the ticket the branch is named after is
+// referenced.
+func formatMessage(message string) (string, error) {
+	text, trailers := conventional.SplitTrailers(message)
+	subject, body, _ := strings.Cut(text, "\n")
+	message = subject
+	if body = strings.TrimSpace(body); body != "" {
+		width := bodyWidth
+		if max := currentPolicy().BodyMaxLineLength; max > 0 && max < width {
+			width = max
+		}
+		message += "\n\n" + conventional.WrapBody(body, width)
+	}
+
+	for _, t := range trailers {
+		if t.Token == "BREAKING CHANGE" {
+			message = markBreaking(message)
+			break
+		}
+	}
+
+	message = addEmoji(message)
+	message, err := addTrailers(message, trailers)
+	if err != nil {
+		return "", err
+	}
+	return addBranchTicket(message)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// localBranch describes a local branch for cleanup purposes.
+type localBranch struct {
+	Name       string
+	LastCommit time.Time
+	Merged     bool
+	Summary    string
+}
+
+// branchSummary is a listed branch in branches --render json.
+type branchSummary struct {
+	Name       string `json:"name"`
+	State      string `json:"state"`
+	LastCommit string `json:"last_commit"`
+	Summary    string `json:"summary"`
+}
+
+// runBranches implements `smart-commit branches`, listing local branches and,
+// with --stale, offering to delete the merged or abandoned ones.
+func runBranches(args []string) error {
+	fs := flag.NewFlagSet("branches", flag.ExitOnError)
+	stale := fs.Bool("stale", false, "only list merged or stale branches and offer to delete them")
+	days := fs.Int("days", 30, "branches without commits for this many days count as stale")
+	base := fs.String("base", "", "branch merges are checked against (default: the repository's default branch)")
+	noAI := fs.Bool("no-ai", false, "describe branches by their latest commit instead of an AI summary")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	if *base == "" {
+		b, err := git.DefaultBranch()
+		if err != nil {
+			return err
+		}
+		*base = b
+	}
+
+	branches, err := listLocalBranches(*base)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*days)
+	var selected []localBranch
+	for _, b := range branches {
+		if !*stale || b.Merged || b.LastCommit.Before(cutoff) {
+			selected = append(selected, b)
+		}
+	}
+	if len(selected) == 0 {
+		r := report{Data: []branchSummary{}}
+		r.paragraph("No branches to clean up.")
+		return printReport(*format, r)
+	}
+
+	useAI := !*noAI && checkProvider() == nil
+	for i := range selected {
+		selected[i].Summary = summarizeBranch(*base, selected[i].Name, useAI)
+	}
+
+	var rows [][]string
+	var data []branchSummary
+	for _, b := range selected {
+		state := "active"
+		if b.Merged {
+			state = "merged"
+		} else if b.LastCommit.Before(cutoff) {
+			state = "stale"
+		}
+		date := b.LastCommit.Format("2006-01-02")
+		rows = append(rows, []string{b.Name, state, date, b.Summary})
+		data = append(data, branchSummary{b.Name, state, date, b.Summary})
+	}
+	r := report{Data: data}
+	r.table([]string{"Branch", "State", "Last commit", "Summary"}, rows)
+	if err := writeReport(reportStdout(*format), *format, r); err != nil {
+		return err
+	}
+
+	if !*stale || !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	fmt.Println()
+	for _, b := range selected {
+		if !confirm(fmt.Sprintf("Delete %s?", b.Name)) {
+			continue
+		}
+		// Unmerged branches need a forced delete; the prompt above is the safeguard
+		deleteFlag := "-d"
+		if !b.Merged {
+			deleteFlag = "-D"
+		}
+		if err := executeCommand("git", "branch", deleteFlag, b.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting %s: %v\n", b.Name, err)
+		}
+	}
+	return nil
+}
+
+// listLocalBranches returns every local branch except base and the current
+// one, with its last commit time and whether it is merged into base.
+func listLocalBranches(base string) ([]localBranch, error) {
+	out, err := executeCommandWithOutput("git", "for-each-ref", "--format=%(refname:short)\t%(committerdate:unix)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("listing branches: %v", err)
+	}
+	mergedOut, err := executeCommandWithOutput("git", "branch", "--format=%(refname:short)", "--merged", base)
+	if err != nil {
+		return nil, fmt.Errorf("listing merged branches: %v", err)
+	}
+	merged := map[string]bool{}
+	for _, name := range strings.Split(mergedOut, "\n") {
+		merged[strings.TrimSpace(name)] = true
+	}
+	current, _ := git.CurrentBranch()
+
+	var branches []localBranch
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 || parts[0] == base || parts[0] == current {
+			continue
+		}
+		unix, _ := strconv.ParseInt(parts[1], 10, 64)
+		branches = append(branches, localBranch{
+			Name:       parts[0],
+			LastCommit: time.Unix(unix, 0),
+			Merged:     merged[parts[0]],
+		})
+	}
+	return branches, nil
+}
+
+// summarizeBranch describes in one line what a branch contained, using
+// the AI provider when available and the latest commit subject otherwise.
+func summarizeBranch(base, branch string, useAI bool) string {
+	commits, _ := git.LoadCommits("--no-merges", base+".."+branch)
+	if len(commits) == 0 {
+		// Merged branches have nothing beyond base; their tip commits are the work
+		commits, _ = git.LoadCommits("--no-merges", "-n", "5", branch)
+	}
+	if len(commits) == 0 {
+		return ""
+	}
+	if !useAI {
+		return commits[0].Subject
+	}
+
+	summary, err := askModel(generator.RenderPrompt(generator.PromptBranchSummary, map[string]interface{}{"Branch": branch, "Commits": commits}))
+	if err != nil || summary == "" {
+		return commits[0].Subject
+	}
+	return strings.SplitN(summary, "\n", 2)[0]
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// matchBranchRule returns the first rule whose pattern matches branch, or
+// nil. A pattern ending in /** matches every branch below its prefix.
+func matchBranchRule(branch string, rules []config.BranchRule) (*config.BranchRule, error) {
+	for i, rule := range rules {
+		if prefix, ok := strings.CutSuffix(rule.Pattern, "/**"); ok {
+			if strings.HasPrefix(branch, prefix+"/") {
+				return &rules[i], nil
+			}
+			continue
+		}
+		matched, err := path.Match(rule.Pattern, branch)
+		if err != nil {
+			return nil, fmt.Errorf("branch_rules: invalid pattern %q: %v", rule.Pattern, err)
+		}
+		if matched {
+			return &rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// currentBranchRule returns the rule for the current branch, or nil on a
+// detached HEAD or a branch no rule matches.
+func currentBranchRule() (*config.BranchRule, error) {
+	rules := config.Current().BranchRules
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	branch, err := git.CurrentBranch()
+	if err != nil {
+		return nil, nil
+	}
+	return matchBranchRule(branch, rules)
+}
+
+// checkTicketRequired refuses message when the branch rule requires a
+// ticket and the message references none.
+func checkTicketRequired(rule *config.BranchRule, message string) error {
+	if rule == nil || !rule.RequireTicket {
+		return nil
+	}
+	if keyReference.MatchString(message) || numberReference.MatchString(message) {
+		return nil
+	}
+	return fmt.Errorf("commits on %s branches must reference a ticket; name the branch after it or add one with --trailer \"Refs: ABC-123\"", rule.Pattern)
+}
+
+// showReminder prints the branch rule's reminder after committing.
+func showReminder(rule *config.BranchRule) {
+	if rule != nil && rule.Reminder != "" {
+		fmt.Printf("Reminder for %s branches: %s\n", rule.Pattern, rule.Reminder)
+	}
+}
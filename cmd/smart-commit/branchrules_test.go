@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+func TestMatchBranchRule(t *testing.T) {
+	rules := []config.BranchRule{
+		{Pattern: "hotfix/*", Type: "fix"},
+		{Pattern: "release/**", Reminder: "update the changelog"},
+		{Pattern: "*", RequireTicket: true},
+	}
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"hotfix/login", "hotfix/*"},
+		{"hotfix/a/b", ""},
+		{"release/1.2", "release/**"},
+		{"release/2024/q1", "release/**"},
+		{"main", "*"},
+		{"feature/login", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			rule, err := matchBranchRule(tt.branch, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if rule != nil {
+				got = rule.Pattern
+			}
+			if got != tt.want {
+				t.Errorf("matchBranchRule(%q) = %q, want %q", tt.branch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTicketRequired(t *testing.T) {
+	rule := &config.BranchRule{Pattern: "hotfix/*", RequireTicket: true}
+	tests := []struct {
+		message string
+		ok      bool
+	}{
+		{"fix: handle expired sessions\n\nRefs: ABC-123", true},
+		{"fix: handle expired sessions (#42)", true},
+		{"fix: handle expired sessions", false},
+	}
+	for _, tt := range tests {
+		if err := checkTicketRequired(rule, tt.message); (err == nil) != tt.ok {
+			t.Errorf("checkTicketRequired(%q) = %v, want ok %v", tt.message, err, tt.ok)
+		}
+	}
+	if err := checkTicketRequired(nil, "fix: anything"); err != nil {
+		t.Errorf("checkTicketRequired without a rule = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// defaultTicketPattern finds the ticket a branch is named after: a tracker
+// key such as JIRA-1234 or eng-123 (Linear), or an issue number, at the
+// start of a path segment, as in feature/JIRA-1234-add-login or
+// fix/452-null-pointer.
+const defaultTicketPattern = `(?i)(?:^|/)([a-z][a-z0-9]+-\d+|\d+)(?:[-_/]|$)`
+
+// Default ticket templates for each placement.
+const (
+	defaultTicketFooter  = "Refs: {{.Ticket}}"
+	defaultTicketSubject = "{{.Subject}} ({{.Ticket}})"
+)
+
+// branchTicket is a ticket reference found in a branch name.
+type branchTicket struct {
+	// Ticket is the reference as it is written in messages: #452 for an
+	// issue number, JIRA-1234 for a tracker key.
+	Ticket string
+	// ID is the bare ID: 452 or JIRA-1234.
+	ID     string
+	Branch string
+}
+
+// parseBranchTicket finds a ticket in branch with pattern. When the pattern
+// has a capture group, the first group is the ID; otherwise the whole
+// match is. It returns nil when the branch names no ticket.
+func parseBranchTicket(branch, pattern string) (*branchTicket, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket_pattern: %v", err)
+	}
+	m := re.FindStringSubmatch(branch)
+	if m == nil {
+		return nil, nil
+	}
+	id := m[0]
+	if len(m) > 1 {
+		id = m[1]
+	}
+	id = strings.ToUpper(strings.TrimPrefix(id, "#"))
+	if id == "" {
+		return nil, nil
+	}
+	t := &branchTicket{ID: id, Ticket: id, Branch: branch}
+	if strings.Trim(id, "0123456789") == "" {
+		t.Ticket = "#" + id
+	}
+	return t, nil
+}
+
+// currentBranchTicket returns the ticket the current branch is named after,
+// or nil on a detached HEAD or a branch without one.
+func currentBranchTicket() (*branchTicket, error) {
+	branch, err := git.CurrentBranch()
+	if err != nil {
+		return nil, nil
+	}
+	pattern := config.Current().TicketPattern
+	if pattern == "" {
+		pattern = defaultTicketPattern
+	}
+	return parseBranchTicket(branch, pattern)
+}
+
+// addBranchTicket references the current branch's ticket in message, in a
+// footer or in the subject depending on the branch_ticket setting, unless
+// the message mentions it already.
+func addBranchTicket(message string) (string, error) {
+	cfg := config.Current()
+	placement := cfg.BranchTicket
+	if placement == "" {
+		placement = "footer"
+	}
+	if placement == "off" {
+		return message, nil
+	}
+	ticket, err := currentBranchTicket()
+	if err != nil || ticket == nil {
+		return message, err
+	}
+	if mentionsTicket(message, ticket) {
+		return message, nil
+	}
+
+	text := cfg.TicketTemplate
+	if text == "" {
+		text = defaultTicketFooter
+		if placement == "subject" {
+			text = defaultTicketSubject
+		}
+	}
+	subject, rest, _ := strings.Cut(message, "\n")
+	commitType, scope, description, breaking, _ := conventional.ParseSubject(subject)
+	rendered, err := renderTicketTemplate(text, map[string]interface{}{
+		"Ticket": ticket.Ticket, "ID": ticket.ID, "Branch": ticket.Branch,
+		"Subject": subject, "Type": commitType, "Scope": scope,
+		"Description": description, "Breaking": breaking,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if placement == "subject" {
+		if rest != "" {
+			return rendered + "\n" + rest, nil
+		}
+		return rendered, nil
+	}
+	t, err := conventional.ParseTrailer(rendered)
+	if err != nil {
+		return "", fmt.Errorf("ticket_template: %v", err)
+	}
+	return addTrailers(message, []conventional.Trailer{t})
+}
+
+// mentionsTicket reports whether message already references ticket, in any
+// letter case.
+func mentionsTicket(message string, ticket *branchTicket) bool {
+	re := regexp.MustCompile(`(?i)(^|[^A-Za-z0-9-])` + regexp.QuoteMeta(ticket.Ticket) + `\b`)
+	return re.MatchString(message)
+}
+
+// renderTicketTemplate renders the ticket_template setting.
+func renderTicketTemplate(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("ticket_template").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing ticket_template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering ticket_template: %v", err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
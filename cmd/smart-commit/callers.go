@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Bounds of the callers listed in the prompt.
+const (
+	maxCallerSymbols = 5
+	maxCallers       = 5
+)
+
+// changedSymbols returns the names whose declarations changes touch, or
+// whose bodies they change, by file.
+func changedSymbols(changes *git.ChangeSet) map[string][]string {
+	symbols := map[string][]string{}
+	for _, f := range changes.Files {
+		decl := symbolDeclarations[strings.ToLower(path.Ext(f.Path))]
+		if decl == nil || f.Binary {
+			continue
+		}
+		seen := map[string]bool{}
+		note := func(line string) {
+			if m := decl.FindStringSubmatch(line); m != nil && !seen[m[1]] {
+				seen[m[1]] = true
+				symbols[f.Path] = append(symbols[f.Path], m[1])
+			}
+		}
+		for _, h := range f.Hunks {
+			note(h.Header)
+			for _, line := range h.Lines {
+				if line != "" && (line[0] == '+' || line[0] == '-') {
+					note(line[1:])
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// describeCallers lists, from the repository index, the files calling the
+// symbols changes touch, so the prompt can tell what else a change affects.
+func describeCallers(changes *git.ChangeSet) string {
+	symbols := changedSymbols(changes)
+	if len(symbols) == 0 {
+		return ""
+	}
+	idx := currentIndex()
+	if idx == nil {
+		return ""
+	}
+	changed := map[string]bool{}
+	for _, p := range changes.Paths() {
+		changed[p] = true
+	}
+	var lines []string
+	for _, f := range changes.Files {
+		for _, name := range symbols[f.Path] {
+			if len(lines) == maxCallerSymbols {
+				break
+			}
+			var callers []string
+			for _, file := range idx.callersOf(name) {
+				if !changed[file] {
+					callers = append(callers, file)
+				}
+			}
+			if len(callers) == 0 {
+				continue
+			}
+			more := ""
+			if len(callers) > maxCallers {
+				more = fmt.Sprintf(" and %d more", len(callers)-maxCallers)
+				callers = callers[:maxCallers]
+			}
+			lines = append(lines, fmt.Sprintf("- %s (%s): %s%s", name, f.Path, strings.Join(callers, ", "), more))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Callers of changed symbols:\n" + strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// canaryArm is one side of a canary comparison: the provider, model and
+// prompt version used, and what they produced.
+type canaryArm struct {
+	Provider      string `json:"provider"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion int    `json:"prompt_version"`
+	Message       string `json:"message,omitempty"`
+	LatencyMS     int64  `json:"latency_ms"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Label identifies the arm's configuration in reports.
+func (a canaryArm) Label() string {
+	label := a.Provider
+	if a.Model != "" {
+		label += "/" + a.Model
+	}
+	return fmt.Sprintf("%s prompt v%d", label, a.PromptVersion)
+}
+
+// canaryEntry is one logged commit with both arms' suggestions and the
+// message that was finally committed.
+type canaryEntry struct {
+	Time      time.Time `json:"time"`
+	Repo      string    `json:"repo"`
+	Files     int       `json:"files"`
+	Current   canaryArm `json:"current"`
+	Candidate canaryArm `json:"candidate"`
+	Committed string    `json:"committed"`
+}
+
+// parseCanarySpec parses a candidate such as
+// "provider=anthropic,model=claude-3-5-sonnet-latest,prompt=3". Omitted
+// settings are taken from current; the model is only inherited when the
+// provider is the same.
+func parseCanarySpec(spec string, current canaryArm) (canaryArm, error) {
+	arm := current
+	providerSet, modelSet := false, false
+	for _, field := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return arm, fmt.Errorf("invalid canary setting %q: expected key=value", field)
+		}
+		switch key {
+		case "provider":
+			arm.Provider, providerSet = strings.ToLower(value), true
+		case "model":
+			arm.Model, modelSet = value, true
+		case "prompt":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return arm, fmt.Errorf("invalid canary prompt version %q", value)
+			}
+			if v < 1 || v > generator.LatestPromptVersion(generator.PromptCommitMessage) {
+				return arm, fmt.Errorf("%s has no prompt version %d", generator.PromptCommitMessage, v)
+			}
+			arm.PromptVersion = v
+		default:
+			return arm, fmt.Errorf("unknown canary setting %q (expected provider, model or prompt)", key)
+		}
+	}
+	if providerSet && !modelSet && arm.Provider != current.Provider {
+		arm.Model = ""
+	}
+	if arm == current {
+		return arm, fmt.Errorf("canary %q is the same as the current configuration", spec)
+	}
+	return arm, nil
+}
+
+// runCanary generates a message for changes with the candidate in the
+// background. Failures are recorded in the arm rather than reported, so the
+// canary never disturbs the commit.
+func runCanary(arm canaryArm, changes *git.ChangeSet) <-chan canaryArm {
+	result := make(chan canaryArm, 1)
+	go func() {
+		gen, err := newGenerator(arm.Provider, arm.Model)
+		if err != nil {
+			arm.Error = err.Error()
+			result <- arm
+			return
+		}
+		prompt, err := generator.RenderPromptVersion(generator.PromptCommitMessage, arm.PromptVersion, generator.PromptData(changes, commitOptions(changes, "")))
+		if err != nil {
+			arm.Error = err.Error()
+			result <- arm
+			return
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		start := time.Now()
+		answer, err := gen.Generate(ctx, prompt)
+		arm.LatencyMS = time.Since(start).Milliseconds()
+		if err != nil {
+			arm.Error = err.Error()
+		} else {
+			arm.Message = conventional.Enforce(generator.CleanOutput(answer), changes.CommitType())
+		}
+		result <- arm
+	}()
+	return result
+}
+
+// canaryLogPath is the log shared by all repositories, so a candidate can be
+// judged on everything the user commits.
+func canaryLogPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smart-commit", "canary.jsonl"), nil
+}
+
+// logCanary appends an entry to the canary log.
+func logCanary(entry canaryEntry) error {
+	path, err := canaryLogPath()
+	if err != nil {
+		return err
+	}
+	if entry.Repo == "" {
+		if root, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel"); err == nil {
+			entry.Repo = filepath.Base(strings.TrimSpace(root))
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// canaryStats aggregates the logged results of one configuration.
+type canaryStats struct {
+	Label      string
+	Samples    int
+	Errors     int
+	Compliant  int
+	Accepted   int
+	Similarity float64
+	LatencyMS  int64
+}
+
+// runCanaryCmd implements `smart-commit canary report`.
+func runCanaryCmd(args []string) error {
+	if len(args) == 0 || args[0] != "report" {
+		return fmt.Errorf("usage: smart-commit canary report [--log FILE]")
+	}
+	fs := flag.NewFlagSet("canary report", flag.ExitOnError)
+	logFile := fs.String("log", "", "canary log to read (default: the user's canary log)")
+	fs.Parse(args[1:])
+
+	if *logFile == "" {
+		path, err := canaryLogPath()
+		if err != nil {
+			return err
+		}
+		*logFile = path
+	}
+	f, err := os.Open(*logFile)
+	if err != nil {
+		return fmt.Errorf("reading canary log: %v", err)
+	}
+	defer f.Close()
+
+	stats := map[string]*canaryStats{}
+	entries := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e canaryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries++
+		for _, arm := range []canaryArm{e.Current, e.Candidate} {
+			s, ok := stats[arm.Label()]
+			if !ok {
+				s = &canaryStats{Label: arm.Label()}
+				stats[arm.Label()] = s
+			}
+			s.Samples++
+			if arm.Error != "" {
+				s.Errors++
+				continue
+			}
+			s.LatencyMS += arm.LatencyMS
+			if len(lintMessage(arm.Message)) == 0 {
+				s.Compliant++
+			}
+			subject := strings.SplitN(arm.Message, "\n", 2)[0]
+			committed := strings.SplitN(e.Committed, "\n", 2)[0]
+			if subject == committed {
+				s.Accepted++
+			}
+			s.Similarity += subjectSimilarity(subject, committed)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading canary log: %v", err)
+	}
+	if entries == 0 {
+		fmt.Println("The canary log is empty.")
+		return nil
+	}
+
+	labels := make([]string, 0, len(stats))
+	for label := range stats {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	fmt.Printf("%d canary commits\n\n", entries)
+	fmt.Printf("%-44s %7s %7s %9s %9s %10s %9s\n", "configuration", "samples", "errors", "compliant", "committed", "similarity", "latency")
+	for _, label := range labels {
+		s := stats[label]
+		ok := s.Samples - s.Errors
+		if ok == 0 {
+			fmt.Printf("%-44s %7d %7d\n", s.Label, s.Samples, s.Errors)
+			continue
+		}
+		fmt.Printf("%-44s %7d %7d %8.0f%% %8.0f%% %10.2f %7dms\n", s.Label, s.Samples, s.Errors,
+			100*float64(s.Compliant)/float64(ok), 100*float64(s.Accepted)/float64(ok),
+			s.Similarity/float64(ok), s.LatencyMS/int64(ok))
+	}
+	return nil
+}
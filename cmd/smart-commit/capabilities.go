@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// verbose is set by --verbose or SMART_COMMIT_VERBOSE=1: the run reports
+// the capabilities it found and what it did without the missing ones.
+var verbose bool
+
+// capability is something optional a run can use, and whether it can.
+type capability struct {
+	Name      string
+	Available bool
+	Detail    string
+}
+
+// capabilities are those found for a run, in the order they are reported.
+type capabilities []capability
+
+// has reports whether the named capability is available; unknown ones are
+// assumed to be.
+func (c capabilities) has(name string) bool {
+	for _, cp := range c {
+		if cp.Name == name {
+			return cp.Available
+		}
+	}
+	return true
+}
+
+// detail is what was found out about the named capability.
+func (c capabilities) detail(name string) string {
+	for _, cp := range c {
+		if cp.Name == name {
+			return cp.Detail
+		}
+	}
+	return ""
+}
+
+// networkProbeTimeout bounds connecting to the provider to see whether the
+// network is up.
+const networkProbeTimeout = 2 * time.Second
+
+// detectCapabilities finds out what the run can use: the gh CLI, the
+// network the provider is reached over, the provider itself, an
+// interactive terminal and the repository's hooks.
+func detectCapabilities(provider string) capabilities {
+	var caps capabilities
+	if path, err := exec.LookPath("gh"); err == nil {
+		caps = append(caps, capability{"gh", true, path})
+	} else {
+		caps = append(caps, capability{"gh", false, "not found on PATH"})
+	}
+
+	network := capability{"network", true, "not needed by " + provider}
+	endpoint := providerEndpoint(provider)
+	switch {
+	case airGapped:
+		network = capability{"network", false, "air-gapped"}
+	case endpoint != "":
+		network.Detail, network.Available = probeNetwork(endpoint)
+	}
+	caps = append(caps, network)
+
+	gen := currentGenerator()
+	switch err := gen.Check(); {
+	case err != nil:
+		caps = append(caps, capability{"provider", false, err.Error()})
+	case !network.Available && endpoint != "":
+		caps = append(caps, capability{"provider", false, gen.Name() + " is unreachable: " + network.Detail})
+	default:
+		caps = append(caps, capability{"provider", true, gen.Name()})
+	}
+
+	switch {
+	case !isTerminal(os.Stdin):
+		caps = append(caps, capability{"terminal", false, "stdin is not a terminal"})
+	case !isTerminal(os.Stdout):
+		caps = append(caps, capability{"terminal", false, "stdout is not a terminal"})
+	default:
+		caps = append(caps, capability{"terminal", true, os.Getenv("TERM")})
+	}
+
+	hooks, skipped := commitHooks()
+	switch {
+	case len(skipped) > 0:
+		for _, name := range skipped {
+			degrade("hooks", "git skips the %s hook, which is not executable", name)
+		}
+		caps = append(caps, capability{"hooks", len(hooks) > 0, orNone(hooks) + "; not executable: " + strings.Join(skipped, ", ")})
+	default:
+		caps = append(caps, capability{"hooks", len(hooks) > 0, orNone(hooks)})
+	}
+	return caps
+}
+
+// providerEndpoint is the address a network provider is reached at, or ""
+// for a local one.
+func providerEndpoint(provider string) string {
+	switch strings.ToLower(provider) {
+	case "copilot":
+		return "https://api.github.com"
+	case "openai":
+		if base := os.Getenv("OPENAI_BASE_URL"); base != "" {
+			return base
+		}
+		return "https://api.openai.com"
+	case "anthropic":
+		if base := os.Getenv("ANTHROPIC_BASE_URL"); base != "" {
+			return base
+		}
+		return "https://api.anthropic.com"
+	}
+	return ""
+}
+
+// probeNetwork connects to the host of endpoint, or the proxy requests to
+// it go through, and says how that went.
+func probeNetwork(endpoint string) (string, bool) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("invalid endpoint %q", endpoint), false
+	}
+	if proxy, err := http.ProxyFromEnvironment(&http.Request{URL: u}); err == nil && proxy != nil {
+		u = proxy
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	ctx, cancel := context.WithTimeout(interrupted, networkProbeTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return fmt.Sprintf("cannot reach %s: %v", host, err), false
+	}
+	conn.Close()
+	return host + " reachable", true
+}
+
+// commitHooks lists the hooks git runs on commit, and those it skips
+// because they are not executable.
+func commitHooks() (hooks, skipped []string) {
+	names := []string{"pre-commit", "prepare-commit-msg", "commit-msg", "post-commit"}
+	args := []string{"rev-parse"}
+	for _, name := range names {
+		args = append(args, "--git-path", "hooks/"+name)
+	}
+	out, err := executeCommandWithOutput("git", args...)
+	if err != nil {
+		return nil, nil
+	}
+	for i, path := range strings.Split(strings.TrimSpace(out), "\n") {
+		info, err := os.Stat(path)
+		if err != nil || i >= len(names) || info.IsDir() {
+			continue
+		}
+		if info.Mode()&0111 == 0 {
+			skipped = append(skipped, names[i])
+		} else {
+			hooks = append(hooks, names[i])
+		}
+	}
+	return hooks, skipped
+}
+
+// degradations are what the run did differently for want of a capability.
+var degradations []string
+
+// capabilitiesReported is set once --verbose has printed the capabilities;
+// later degradations are printed as they happen.
+var capabilitiesReported bool
+
+// degrade records that the run falls back from what it would do with the
+// capability, and says so in verbose runs.
+func degrade(capability, format string, args ...interface{}) {
+	what := capability + ": " + fmt.Sprintf(format, args...)
+	degradations = append(degradations, what)
+	if verbose && capabilitiesReported {
+		fmt.Fprintf(os.Stderr, "Degraded %s\n", what)
+	}
+}
+
+// reportCapabilities prints what was found and what the run does without
+// the missing capabilities so far, for --verbose.
+func reportCapabilities(caps capabilities) {
+	fmt.Fprintln(os.Stderr, "Capabilities:")
+	for _, c := range caps {
+		state := "yes"
+		if !c.Available {
+			state = "no"
+		}
+		fmt.Fprintf(os.Stderr, "  %-9s %-4s %s\n", c.Name, state, c.Detail)
+	}
+	for _, d := range degradations {
+		fmt.Fprintf(os.Stderr, "Degraded %s\n", d)
+	}
+	capabilitiesReported = true
+}
+
+// unavailableGenerator stands in for a provider that cannot be used, so
+// every message falls back to the one worked out from the diff.
+type unavailableGenerator struct {
+	generator.Generator
+	err error
+}
+
+func (g unavailableGenerator) Check() error { return g.err }
+
+func (g unavailableGenerator) Generate(context.Context, string) (string, error) {
+	return "", g.err
+}
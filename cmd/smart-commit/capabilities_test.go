@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbeNetwork(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		endpoint string
+		want     bool
+	}{
+		{"http://" + ln.Addr().String() + "/v1", true},
+		{"http://" + closedAddr, false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		if detail, got := probeNetwork(tt.endpoint); got != tt.want {
+			t.Errorf("probeNetwork(%q) = %v (%s), want %v", tt.endpoint, got, detail, tt.want)
+		}
+	}
+}
+
+func TestProviderEndpoint(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:8080/v1")
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	tests := []struct {
+		provider, want string
+	}{
+		{"copilot", "https://api.github.com"},
+		{"OpenAI", "http://localhost:8080/v1"},
+		{"anthropic", "https://api.anthropic.com"},
+		{"ollama", ""},
+	}
+	for _, tt := range tests {
+		if got := providerEndpoint(tt.provider); got != tt.want {
+			t.Errorf("providerEndpoint(%q) = %q, want %q", tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestCommitFlowDegradesWithoutProvider(t *testing.T) {
+	repo, env := testRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("# Test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hooks := filepath.Join(repo, ".git", "hooks")
+	os.MkdirAll(hooks, 0755)
+	os.WriteFile(filepath.Join(hooks, "pre-commit"), []byte("#!/bin/sh\nexit 0\n"), 0755)
+	os.WriteFile(filepath.Join(hooks, "commit-msg"), []byte("#!/bin/sh\nexit 1\n"), 0644)
+	// A PATH with git but no gh, so the Copilot CLI cannot be found
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	bin := t.TempDir()
+	if err := os.Symlink(gitPath, filepath.Join(bin, "git")); err != nil {
+		t.Fatal(err)
+	}
+	env = append(env, "PATH="+bin)
+
+	// Air-gapped builds have no Copilot; their Ollama server is down instead
+	provider, warning, baseURL := "copilot", "Warning: GitHub Copilot CLI is not installed", fakeProvider(t, "")
+	if airGapBuild {
+		provider, warning, baseURL = "ollama", "Warning: Ollama is not reachable", "http://127.0.0.1:1"
+	}
+	out, err := runCLI(t, repo, env, baseURL, "--provider", provider, "--verbose", "--yes", "--no-push")
+	if err != nil {
+		t.Fatalf("smart-commit failed without its provider: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		warning,
+		"gh        no   not found on PATH",
+		"hooks     yes  pre-commit; not executable: commit-msg",
+		"Degraded provider: the message is worked out from the diff",
+		"Degraded hooks: git skips the commit-msg hook",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if got := strings.TrimSpace(runGit(t, repo, env, "log", "-1", "--format=%s")); got != "docs: add README.md" {
+		t.Errorf("committed %q, want the fallback message", got)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Changelog formats written by `smart-commit changelog`.
+const (
+	formatKeepAChangelog = "keepachangelog"
+	formatConventional   = "conventional"
+)
+
+// keepAChangelogHeader starts a new Keep a Changelog file.
+const keepAChangelogHeader = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+
+`
+
+// runChangelog implements `smart-commit changelog`: it adds the commits
+// since the last tag to CHANGELOG.md, grouped by type and scope.
+func runChangelog(args []string) error {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	from := fs.String("from", "", "start after this revision (default: the last tag)")
+	to := fs.String("to", "HEAD", "end at this revision")
+	version := fs.String("version", "", "version the section is for (default: the tag on --to, or Unreleased)")
+	format := fs.String("format", "", "keepachangelog or conventional (default: what the file uses, else keepachangelog)")
+	file := fs.String("file", "CHANGELOG.md", "changelog file to write or update")
+	stdout := fs.Bool("stdout", false, "print the section instead of writing the file")
+	render := renderFlag(fs, renderMarkdown)
+	summary := fs.Bool("summary", false, "open the section with an AI-written summary of the release")
+	fs.Parse(args)
+
+	existing, err := os.ReadFile(*file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %v", *file, err)
+	}
+	if *format == "" {
+		*format = formatKeepAChangelog
+		if len(existing) > 0 && !strings.Contains(string(existing), "Keep a Changelog") {
+			*format = formatConventional
+		}
+	}
+	if *format != formatKeepAChangelog && *format != formatConventional {
+		return fmt.Errorf("unknown --format %q: expected %s or %s", *format, formatKeepAChangelog, formatConventional)
+	}
+	if err := checkRender(*render); err != nil {
+		return err
+	}
+
+	// A tagged revision is documented as that release, since the tag
+	// before it
+	if *version == "" {
+		if tag, err := executeCommandWithOutput("git", "describe", "--tags", "--exact-match", *to); err == nil {
+			*version = strings.TrimSpace(tag)
+		}
+	}
+	if *from == "" {
+		base := *to
+		if *version != "" && git.RefExists(*version) {
+			base = *to + "^"
+		}
+		if tag, err := executeCommandWithOutput("git", "describe", "--tags", "--abbrev=0", base); err == nil {
+			*from = strings.TrimSpace(tag)
+		}
+	}
+	revRange := *to
+	if *from != "" {
+		revRange = *from + ".." + *to
+	}
+	commits, err := git.LoadCommits("--no-merges", revRange)
+	if err != nil {
+		return err
+	}
+
+	title := *version
+	if title == "" {
+		title = "Unreleased"
+	}
+	text := ""
+	if *summary && len(commits) > 0 {
+		if err := checkProvider(); err != nil {
+			return err
+		}
+		text, err = askModel(generator.RenderPrompt(generator.PromptReleaseSummary, map[string]interface{}{"Version": title, "Commits": commits}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+		}
+	}
+
+	var r report
+	date := time.Now().Format("2006-01-02")
+	if *format == formatKeepAChangelog {
+		heading := "[" + title + "]"
+		if *version != "" {
+			heading += " - " + date
+		}
+		r = keepAChangelogReport(heading, text, commits)
+	} else {
+		heading := title
+		if *version != "" {
+			heading += " (" + date + ")"
+		}
+		r = changelogReport(heading, text, commits)
+	}
+
+	if *stdout {
+		return printReport(*render, r)
+	}
+	section := r.markdown()
+	header := "# Changelog\n\n"
+	if *format == formatKeepAChangelog {
+		header = keepAChangelogHeader
+	}
+	if err := os.WriteFile(*file, []byte(updateChangelog(string(existing), section, header)), 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", *file, err)
+	}
+	since := "the first commit"
+	if *from != "" {
+		since = *from
+	}
+	fmt.Printf("Added %s to %s: %d commit(s) since %s.\n", title, *file, len(commits), since)
+	return nil
+}
+
+// updateChangelog puts section into the changelog document existing: in
+// place of the section for the same version, or for a release, of the
+// Unreleased section it ships; else above the newest section. A new
+// document starts with header.
+func updateChangelog(existing, section, header string) string {
+	section = strings.TrimRight(section, "\n") + "\n"
+	if strings.TrimSpace(existing) == "" {
+		return header + section
+	}
+	heading, _, _ := strings.Cut(section, "\n")
+	lines := strings.SplitAfter(existing, "\n")
+	start, end := findSection(lines, sectionKey(heading))
+	if start < 0 && sectionKey(heading) != "Unreleased" {
+		start, end = findSection(lines, "Unreleased")
+	}
+	if start < 0 {
+		// Insert above the newest section, or append when there is none
+		for i, line := range lines {
+			if strings.HasPrefix(line, "## ") {
+				return strings.Join(lines[:i], "") + section + "\n" + strings.Join(lines[i:], "")
+			}
+		}
+		return strings.TrimRight(existing, "\n") + "\n\n" + section
+	}
+	rest := strings.Join(lines[end:], "")
+	if rest != "" {
+		section += "\n"
+	}
+	return strings.Join(lines[:start], "") + section + rest
+}
+
+// findSection returns the lines the section for version spans, from its
+// heading up to the next one; start is -1 when there is no such section.
+func findSection(lines []string, version string) (start, end int) {
+	start = -1
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "## ") {
+			continue
+		}
+		if start >= 0 {
+			return start, i
+		}
+		if sectionKey(line) == version {
+			start = i
+		}
+	}
+	return start, len(lines)
+}
+
+// sectionKey identifies a changelog section by the version in its
+// heading, so a release dated differently still replaces it.
+func sectionKey(heading string) string {
+	heading = strings.TrimSpace(strings.TrimPrefix(heading, "## "))
+	heading, _, _ = strings.Cut(heading, " ")
+	return strings.Trim(heading, "[]")
+}
+
+// keepAChangelogSection picks the Keep a Changelog section for a commit.
+// Housekeeping (docs, tests, CI, chores, ...) is left out unless it breaks
+// something.
+func keepAChangelogSection(c git.Commit) string {
+	description := strings.ToLower(c.Description)
+	switch {
+	case c.Scope == "security" || c.Type == "security":
+		return "Security"
+	case strings.HasPrefix(description, "deprecate"):
+		return "Deprecated"
+	case strings.HasPrefix(description, "remove") || strings.HasPrefix(description, "drop"):
+		return "Removed"
+	case c.Type == "feat":
+		return "Added"
+	case c.Type == "fix":
+		return "Fixed"
+	case c.Type == "perf" || c.Type == "refactor" || c.Type == "revert" || c.Breaking:
+		return "Changed"
+	}
+	return ""
+}
+
+// keepAChangelogSections orders the sections of a Keep a Changelog release.
+var keepAChangelogSections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+// renderKeepAChangelog formats commits as a Keep a Changelog release
+// section, with summary as its opening paragraph when there is one.
+func renderKeepAChangelog(heading, summary string, commits []git.Commit) string {
+	return keepAChangelogReport(heading, summary, commits).markdown()
+}
+
+// keepAChangelogReport is the Keep a Changelog release section of commits.
+func keepAChangelogReport(heading, summary string, commits []git.Commit) report {
+	var r report
+	r.heading(2, "%s", heading)
+	if summary = strings.TrimSpace(summary); summary != "" {
+		r.paragraph("%s", summary)
+	}
+
+	bySection := map[string][]git.Commit{}
+	for _, c := range byScope(commits) {
+		if section := keepAChangelogSection(c); section != "" {
+			bySection[section] = append(bySection[section], c)
+		}
+	}
+	if len(bySection) == 0 {
+		r.paragraph("_No notable changes._")
+		return r
+	}
+	for _, section := range keepAChangelogSections {
+		if len(bySection[section]) == 0 {
+			continue
+		}
+		r.heading(3, "%s", section)
+		var items []string
+		for _, c := range bySection[section] {
+			item := ""
+			if c.Breaking {
+				item += "**Breaking:** "
+			}
+			if c.Scope != "" {
+				item += c.Scope + ": "
+			}
+			items = append(items, item+fmt.Sprintf("%s (%s)", capitalize(c.Description), git.ShortHash(c.Hash)))
+		}
+		r.list(items)
+	}
+	return r
+}
+
+// byScope returns commits with those of the same scope next to each
+// other, unscoped ones first, otherwise keeping their order.
+func byScope(commits []git.Commit) []git.Commit {
+	sorted := append([]git.Commit(nil), commits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Scope < sorted[j].Scope })
+	return sorted
+}
+
+// capitalize upper-cases the first letter of s.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// changelogSections orders the commit types shown in a changelog and gives
+// each its heading. Types not listed are collected under "Other Changes".
+var changelogSections = []struct {
+	Type    string
+	Heading string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"refactor", "Code Refactoring"},
+	{"revert", "Reverts"},
+	{"docs", "Documentation"},
+	{"build", "Build System"},
+	{"ci", "Continuous Integration"},
+	{"test", "Tests"},
+	{"style", "Styles"},
+	{"chore", "Chores"},
+}
+
+// renderChangelog formats commits as a Markdown changelog section grouped by
+// type and scope, with breaking changes called out first.
+func renderChangelog(title string, commits []git.Commit) string {
+	return renderChangelogSummary(title, "", commits)
+}
+
+// renderChangelogSummary is renderChangelog with summary as the section's
+// opening paragraph.
+func renderChangelogSummary(title, summary string, commits []git.Commit) string {
+	return changelogReport(title, summary, commits).markdown()
+}
+
+// changelogReport is the changelog section of commits grouped by type and
+// scope, with breaking changes called out first.
+func changelogReport(title, summary string, commits []git.Commit) report {
+	var r report
+	r.heading(2, "%s", title)
+	if summary = strings.TrimSpace(summary); summary != "" {
+		r.paragraph("%s", summary)
+	}
+
+	if len(commits) == 0 {
+		r.paragraph("_No changes._")
+		return r
+	}
+
+	entries := func(commits []git.Commit) []string {
+		var items []string
+		for _, c := range commits {
+			if c.Scope != "" {
+				items = append(items, fmt.Sprintf("**%s:** %s (%s)", c.Scope, c.Description, git.ShortHash(c.Hash)))
+			} else {
+				items = append(items, fmt.Sprintf("%s (%s)", c.Description, git.ShortHash(c.Hash)))
+			}
+		}
+		return items
+	}
+
+	var breaking []git.Commit
+	byType := map[string][]git.Commit{}
+	for _, c := range byScope(commits) {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+		byType[c.Type] = append(byType[c.Type], c)
+	}
+
+	if len(breaking) > 0 {
+		r.heading(3, "⚠ BREAKING CHANGES")
+		r.list(entries(breaking))
+	}
+
+	known := map[string]bool{}
+	for _, section := range changelogSections {
+		known[section.Type] = true
+		if len(byType[section.Type]) == 0 {
+			continue
+		}
+		r.heading(3, "%s", section.Heading)
+		r.list(entries(byType[section.Type]))
+	}
+
+	var other []git.Commit
+	for _, c := range byScope(commits) {
+		if !known[c.Type] {
+			other = append(other, c)
+		}
+	}
+	if len(other) > 0 {
+		r.heading(3, "Other Changes")
+		r.list(entries(other))
+	}
+	return r
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestUpdateChangelog(t *testing.T) {
+	const header = "# Changelog\n\n"
+	tests := []struct {
+		name, existing, section, want string
+	}{
+		{"new file", "", "## [Unreleased]\n\n- A\n\n", "# Changelog\n\n## [Unreleased]\n\n- A\n"},
+		{"above newest", "# Changelog\n\n## [1.0.0] - 2024-01-01\n\n- A\n", "## [1.1.0] - 2024-02-01\n\n- B\n",
+			"# Changelog\n\n## [1.1.0] - 2024-02-01\n\n- B\n\n## [1.0.0] - 2024-01-01\n\n- A\n"},
+		{"replaces same version", "# Changelog\n\n## [Unreleased]\n\n- old\n\n## [1.0.0] - 2024-01-01\n\n- A\n", "## [Unreleased]\n\n- new\n",
+			"# Changelog\n\n## [Unreleased]\n\n- new\n\n## [1.0.0] - 2024-01-01\n\n- A\n"},
+		{"release replaces unreleased", "# Changelog\n\n## [Unreleased]\n\n- B\n\n## [1.0.0] - 2024-01-01\n\n- A\n", "## [1.1.0] - 2024-02-01\n\n- B\n",
+			"# Changelog\n\n## [1.1.0] - 2024-02-01\n\n- B\n\n## [1.0.0] - 2024-01-01\n\n- A\n"},
+		{"redated release", "# Changelog\n\n## 1.1.0 (2024-02-01)\n\n- B\n", "## 1.1.0 (2024-02-03)\n\n- B\n- C\n",
+			"# Changelog\n\n## 1.1.0 (2024-02-03)\n\n- B\n- C\n"},
+		{"no sections yet", "# Changelog\n\nNotes.\n", "## [Unreleased]\n\n- A\n", "# Changelog\n\nNotes.\n\n## [Unreleased]\n\n- A\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateChangelog(tt.existing, tt.section, header); got != tt.want {
+				t.Errorf("updateChangelog() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderKeepAChangelog(t *testing.T) {
+	commits := []git.Commit{
+		{Hash: "1111111", Type: "feat", Scope: "web", Description: "add dark mode"},
+		{Hash: "2222222", Type: "fix", Description: "handle nil config"},
+		{Hash: "3333333", Type: "docs", Description: "fix typos"},
+		{Hash: "4444444", Type: "feat", Scope: "api", Description: "add teams"},
+		{Hash: "5555555", Type: "chore", Description: "deprecate the v1 client"},
+		{Hash: "6666666", Type: "refactor", Scope: "api", Description: "drop XML output", Breaking: true},
+	}
+	want := "## [1.1.0] - 2024-02-01\n\nShips dark mode.\n\n" +
+		"### Added\n\n- api: Add teams (4444444)\n- web: Add dark mode (1111111)\n\n" +
+		"### Deprecated\n\n- Deprecate the v1 client (5555555)\n\n" +
+		"### Removed\n\n- **Breaking:** api: Drop XML output (6666666)\n\n" +
+		"### Fixed\n\n- Handle nil config (2222222)\n"
+	if got := renderKeepAChangelog("[1.1.0] - 2024-02-01", "Ships dark mode.", commits); got != want {
+		t.Errorf("renderKeepAChangelog() =\n%s\nwant:\n%s", got, want)
+	}
+	if got := renderKeepAChangelog("[Unreleased]", "", commits[2:3]); got != "## [Unreleased]\n\n_No notable changes._\n" {
+		t.Errorf("renderKeepAChangelog() with only docs = %q", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/pipeline"
+)
+
+// checkpoint is where the commit flow stopped, kept in
+// .git/smart-commit/state until the flow finishes, so `smart-commit
+// continue` can pick it up from that stage with the same options.
+type checkpoint struct {
+	// Stage is the stage that was running when the flow stopped; it is
+	// run again.
+	Stage pipeline.Stage `json:"stage"`
+	// Args are the command-line arguments of the run.
+	Args []string `json:"args"`
+	// Tree is the staged tree when the stage started.
+	Tree    string    `json:"tree,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Commit  string    `json:"commit,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// checkpointPath is where the checkpoint is kept.
+func checkpointPath() (string, error) {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/state")
+	if err != nil {
+		return "", fmt.Errorf("locating repository: %v", err)
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// saveCheckpoint replaces the checkpoint atomically. Failing to save one
+// only warns: the run itself can go on.
+func saveCheckpoint(cp checkpoint) {
+	if !writesAllowed() {
+		return
+	}
+	path, err := checkpointPath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saving the checkpoint for smart-commit continue: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saving the checkpoint for smart-commit continue: %v\n", err)
+	}
+}
+
+// loadCheckpoint reads the checkpoint; ok is false when there is none.
+func loadCheckpoint() (cp checkpoint, ok bool, err error) {
+	path, err := checkpointPath()
+	if err != nil {
+		return cp, false, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, false, nil
+	} else if err != nil {
+		return cp, false, fmt.Errorf("reading %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil || cp.Stage == "" {
+		return cp, false, fmt.Errorf("%s is damaged; smart-commit continue --abort forgets it", path)
+	}
+	return cp, true, nil
+}
+
+// clearCheckpoint forgets the checkpoint once the flow has finished.
+func clearCheckpoint() {
+	if path, err := checkpointPath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// checkpoints is middleware saving a checkpoint as each stage starts, and
+// the error when it fails. A stage that exits the process outright, as a
+// failed push does, leaves the checkpoint of its start.
+func checkpoints(args []string) pipeline.Middleware {
+	return func(stage pipeline.Stage, next pipeline.Handler) pipeline.Handler {
+		return func(ctx context.Context, s *pipeline.State) error {
+			cp := checkpoint{Stage: stage, Args: args, Message: s.Message, Commit: s.Commit, Time: time.Now()}
+			if stage != pipeline.Publish {
+				cp.Tree, _ = stagedTree()
+			}
+			saveCheckpoint(cp)
+			err := next(ctx, s)
+			if err != nil && !errors.Is(err, pipeline.ErrStop) {
+				cp.Error = err.Error()
+				saveCheckpoint(cp)
+			}
+			return err
+		}
+	}
+}
+
+// continueFlow implements `smart-commit continue`: it returns the
+// checkpoint of the run to resume, whose arguments main runs the commit
+// flow with again, from the stage it stopped at. --abort forgets it.
+func continueFlow(args []string) (*checkpoint, error) {
+	fs := flag.NewFlagSet("continue", flag.ExitOnError)
+	abort := fs.Bool("abort", false, "forget the stopped run instead of resuming it")
+	fs.Parse(args)
+
+	cp, ok, err := loadCheckpoint()
+	if err != nil && !*abort {
+		return nil, err
+	}
+	if *abort {
+		clearCheckpoint()
+		clearPendingMessage()
+		fmt.Println("Forgot the stopped run.")
+		return nil, nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("no stopped run to continue")
+	}
+	how := "stopped"
+	if cp.Error != "" {
+		how = "failed: " + cp.Error
+	}
+	fmt.Printf("Continuing at the %s stage, where the run of %s %s\n", cp.Stage, cp.Time.Format("Jan 2 15:04"), how)
+	if cp.Tree != "" {
+		if tree, err := stagedTree(); err == nil && tree != cp.Tree {
+			fmt.Println("The staged changes have changed since; continuing with them.")
+		}
+	}
+	return &cp, nil
+}
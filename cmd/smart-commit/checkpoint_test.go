@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitFlowContinue(t *testing.T) {
+	repo, env := testRepo(t)
+	os.WriteFile(filepath.Join(repo, "users.go"), []byte("package users\n"), 0644)
+
+	// A commit-msg hook failing stops the flow at the commit stage
+	hook := filepath.Join(repo, ".git", "hooks", "commit-msg")
+	os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if out, err := runCLI(t, repo, env, fakeProvider(t, "feat: add users"), "--yes", "--no-push"); err == nil {
+		t.Fatalf("commit with a failing hook succeeded\n%s", out)
+	}
+	state, err := os.ReadFile(filepath.Join(repo, ".git", "smart-commit", "state"))
+	if err != nil || !strings.Contains(string(state), `"stage": "commit"`) || !strings.Contains(string(state), "feat: add users") {
+		t.Fatalf("checkpoint = %s, %v; want the commit stage with the message", state, err)
+	}
+
+	// Continuing commits with the message generated before, without asking
+	// the provider again
+	os.Remove(hook)
+	out, err := runCLI(t, repo, env, fakeProvider(t, ""), "continue")
+	if err != nil || !strings.Contains(out, "Continuing at the commit stage") {
+		t.Fatalf("smart-commit continue: %v\n%s", err, out)
+	}
+	if got := strings.TrimSpace(runGit(t, repo, env, "log", "-1", "--format=%B")); got != "feat: add users" {
+		t.Errorf("committed message = %q, want feat: add users", got)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "smart-commit", "state")); !os.IsNotExist(err) {
+		t.Errorf("the checkpoint was kept after the flow finished: %v", err)
+	}
+	if out, err := runCLI(t, repo, env, fakeProvider(t, ""), "continue"); err == nil || !strings.Contains(out, "no stopped run") {
+		t.Errorf("continue without a stopped run: %v\n%s", err, out)
+	}
+}
+
+func TestCommitFlowContinuePush(t *testing.T) {
+	repo, env := testRepo(t)
+	os.WriteFile(filepath.Join(repo, "users.go"), []byte("package users\n"), 0644)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, repo, env, "init", "-q", "--bare", remote)
+	runGit(t, repo, env, "remote", "add", "origin", remote)
+	hook := filepath.Join(remote, "hooks", "pre-receive")
+	os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if out, err := runCLI(t, repo, env, fakeProvider(t, "feat: add users"), "--yes", "--set-upstream"); err == nil {
+		t.Fatalf("push to a rejecting remote succeeded\n%s", out)
+	}
+
+	os.Remove(hook)
+	out, err := runCLI(t, repo, env, fakeProvider(t, ""), "continue")
+	if err != nil || !strings.Contains(out, "Continuing at the publish stage") {
+		t.Fatalf("smart-commit continue: %v\n%s", err, out)
+	}
+	if count := strings.TrimSpace(runGit(t, repo, env, "rev-list", "--count", "HEAD")); count != "2" {
+		t.Errorf("continue committed again: %s commits", count)
+	}
+	if head, pushed := runGit(t, repo, env, "rev-parse", "HEAD"), runGit(t, remote, env, "rev-parse", "main"); head != pushed {
+		t.Errorf("remote main = %s, want %s", pushed, head)
+	}
+}
+
+func TestContinueAbort(t *testing.T) {
+	repo, env := testRepo(t)
+	dir := filepath.Join(repo, ".git", "smart-commit")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "state"), []byte(`{"stage": "validate", "args": ["--yes"]}`), 0644)
+	if out, err := runCLI(t, repo, env, fakeProvider(t, ""), "continue", "--abort"); err != nil {
+		t.Fatalf("continue --abort: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state")); !os.IsNotExist(err) {
+		t.Errorf("continue --abort kept the checkpoint: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// shellCommand builds a command that runs a user-supplied command line
+// through the platform shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(interrupted, "cmd", "/C", command)
+	}
+	return exec.CommandContext(interrupted, "sh", "-c", command)
+}
+
+// shellQuote quotes s as a single argument in a shellCommand command line.
+// Windows paths cannot contain double quotes, so wrapping them is enough
+// there; elsewhere s goes in single quotes, which the shell takes literally.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// checkResult is the outcome of one pre-commit check command.
+type checkResult struct {
+	Command string
+	Output  string
+	Summary string
+	Err     error
+}
+
+// runCheck runs a check command, capturing its combined output.
+func runCheck(command string) checkResult {
+	var output bytes.Buffer
+	cmd := shellCommand(command)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	result := checkResult{Command: command}
+	err := git.DefaultRunner.Run(cmd)
+	result.Output = output.String()
+	result.Summary = lastLines(result.Output, 5)
+	if err != nil {
+		result.Err = fmt.Errorf("check %q failed: %v", command, err)
+	}
+	return result
+}
+
+// runChecksAsync starts every command concurrently and returns a channel that
+// delivers all results, in command order, once the last one has finished.
+// This lets slow checks overlap with message generation.
+func runChecksAsync(commands []string) <-chan []checkResult {
+	done := make(chan []checkResult, 1)
+	go func() {
+		results := make([]checkResult, len(commands))
+		var wg sync.WaitGroup
+		for i, command := range commands {
+			wg.Add(1)
+			go func(i int, command string) {
+				defer wg.Done()
+				results[i] = runCheck(command)
+			}(i, command)
+		}
+		wg.Wait()
+		done <- results
+	}()
+	return done
+}
+
+// runStagedChecks runs commands one after another against the staged tree,
+// with unstaged changes to tracked files set aside until they are done, and
+// returns the first failure after showing its output. Untracked files stay
+// in place.
+func runStagedChecks(commands []string) error {
+	restore, err := setAsideUnstaged()
+	if err != nil {
+		return err
+	}
+	unregister := onInterrupt(func() { restore() })
+	defer unregister()
+
+	for _, command := range commands {
+		fmt.Printf("Running check: %s\n", command)
+		result := runCheck(command)
+		if result.Err != nil {
+			restore()
+			fmt.Fprintf(os.Stderr, "%s\n", strings.TrimRight(result.Output, "\n"))
+			return result.Err
+		}
+		fmt.Printf("Check passed: %s\n", command)
+	}
+	return restore()
+}
+
+// setAsideUnstaged saves the unstaged changes to tracked files as a patch
+// and checks the staged versions out. restore applies the patch again; when
+// that fails the patch is kept and restore says where.
+func setAsideUnstaged() (restore func() error, err error) {
+	patch, err := git.Output("diff", "--binary", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return nil, fmt.Errorf("saving unstaged changes: %v", err)
+	}
+	if patch == "" {
+		return func() error { return nil }, nil
+	}
+	path, err := git.Output("rev-parse", "--git-path", "smart-commit-unstaged.patch")
+	if err != nil {
+		return nil, fmt.Errorf("saving unstaged changes: %v", err)
+	}
+	path = strings.TrimSpace(path)
+	if err := os.WriteFile(path, []byte(patch), 0644); err != nil {
+		return nil, fmt.Errorf("saving unstaged changes: %v", err)
+	}
+	if _, err := git.Output("checkout", "--", ":/"); err != nil {
+		return nil, fmt.Errorf("setting unstaged changes aside: %v", err)
+	}
+
+	var once sync.Once
+	var restoreErr error
+	return func() error {
+		once.Do(func() {
+			if _, err := git.Output("apply", "--whitespace=nowarn", path); err != nil {
+				restoreErr = fmt.Errorf("restoring unstaged changes: %v; they are saved in %s", err, path)
+				return
+			}
+			os.Remove(path)
+		})
+		return restoreErr
+	}, nil
+}
+
+// testSummaryBody formats a passing test run for the commit body.
+func testSummaryBody(command, summary string) string {
+	body := fmt.Sprintf("Tests: `%s` passed", command)
+	if summary != "" {
+		body += "\n\n" + summary
+	}
+	return body
+}
+
+// lastLines returns the last n non-empty lines of s.
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, "\r "))
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	for _, arg := range []string{"plain", "with space", "it's", `"quoted" $HOME $(echo x) \n`, ""} {
+		out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(arg)).Output()
+		if err != nil {
+			t.Fatalf("sh: %v", err)
+		}
+		if got := string(out); got != arg {
+			t.Errorf("shellQuote(%q) round-tripped to %q", arg, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// commitlintFiles are the commitlint configuration files read from the
+// repository root, in commitlint's own search order.
+var commitlintFiles = []string{
+	".commitlintrc", ".commitlintrc.json", ".commitlintrc.yaml", ".commitlintrc.yml",
+	".commitlintrc.js", ".commitlintrc.cjs", ".commitlintrc.mjs", ".commitlintrc.ts",
+	"commitlint.config.js", "commitlint.config.cjs", "commitlint.config.mjs", "commitlint.config.ts",
+}
+
+// caseRule restricts the case of part of a subject: it must be one of
+// Cases, or none of them when Never is set.
+type caseRule struct {
+	Never bool     `json:"never,omitempty"`
+	Cases []string `json:"cases"`
+}
+
+// allows reports whether s satisfies the rule.
+func (r *caseRule) allows(s string) bool {
+	if r == nil || s == "" {
+		return true
+	}
+	matched := false
+	for _, c := range r.Cases {
+		matched = matched || matchesCase(s, c)
+	}
+	return matched != r.Never
+}
+
+func (r *caseRule) String() string {
+	if r.Never {
+		return "not " + strings.Join(r.Cases, " or ")
+	}
+	return strings.Join(r.Cases, " or ")
+}
+
+// commitlintRule is a rule from a commitlint configuration: [level, when,
+// value]. Level 0 turns it off.
+type commitlintRule struct {
+	Level int
+	Never bool
+	Value interface{}
+}
+
+// loadCommitlintRules reads the commitlint configuration at the root of the
+// repository, from its own files or the commitlint key of package.json. It
+// returns nil when there is none.
+func loadCommitlintRules() (map[string]commitlintRule, error) {
+	root := "."
+	if out, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel"); err == nil && strings.TrimSpace(out) != "" {
+		root = strings.TrimSpace(out)
+	}
+	for _, name := range commitlintFiles {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".js" || ext == ".cjs" || ext == ".mjs" || ext == ".ts" {
+			data = jsObjectLiteral(data)
+		}
+		rules, err := parseCommitlintRules(data)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", name, err)
+		}
+		return rules, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return nil, nil
+	}
+	var pkg struct {
+		Commitlint json.RawMessage `json:"commitlint"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil || len(pkg.Commitlint) == 0 {
+		return nil, nil
+	}
+	rules, err := parseCommitlintRules(pkg.Commitlint)
+	if err != nil {
+		return nil, fmt.Errorf("reading package.json: %v", err)
+	}
+	return rules, nil
+}
+
+var (
+	jsLineComment  = regexp.MustCompile(`(?m)^\s*//.*$`)
+	jsBlockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// jsObjectLiteral extracts the exported object from a JavaScript or
+// TypeScript configuration file. Plain object literals are close enough to
+// YAML flow mappings to be read as one; anything computed is not supported.
+func jsObjectLiteral(data []byte) []byte {
+	src := jsBlockComment.ReplaceAllString(string(data), "")
+	src = jsLineComment.ReplaceAllString(src, "")
+	for _, marker := range []string{"module.exports", "export default"} {
+		if i := strings.Index(src, marker); i >= 0 {
+			src = src[i:]
+			break
+		}
+	}
+	start, end := strings.Index(src, "{"), strings.LastIndex(src, "}")
+	if start < 0 || end < start {
+		return nil
+	}
+	return []byte(src[start : end+1])
+}
+
+// parseCommitlintRules decodes the rules of a commitlint configuration,
+// given as JSON or YAML.
+func parseCommitlintRules(data []byte) (map[string]commitlintRule, error) {
+	var doc struct {
+		Rules map[string][]interface{} `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	rules := map[string]commitlintRule{}
+	for name, spec := range doc.Rules {
+		if len(spec) == 0 {
+			continue
+		}
+		level, ok := spec[0].(int)
+		if !ok {
+			return nil, fmt.Errorf("rule %s: level must be 0, 1 or 2", name)
+		}
+		rule := commitlintRule{Level: level}
+		if len(spec) > 1 {
+			rule.Never = spec[1] == "never"
+		}
+		if len(spec) > 2 {
+			rule.Value = spec[2]
+		}
+		rules[name] = rule
+	}
+	return rules, nil
+}
+
+// applyCommitlintRules narrows p to the commitlint rules smart-commit can
+// check. Rules at level 0 are off; warnings are enforced like errors, as a
+// generated message may as well satisfy them.
+func applyCommitlintRules(p *policy, rules map[string]commitlintRule) {
+	for name, rule := range rules {
+		if rule.Level == 0 {
+			continue
+		}
+		switch name {
+		case "type-enum":
+			if types := stringValues(rule.Value); !rule.Never && len(types) > 0 {
+				p.Types = types
+			}
+		case "scope-enum":
+			if scopes := stringValues(rule.Value); !rule.Never && len(scopes) > 0 {
+				p.Scopes = scopes
+			}
+		case "header-max-length":
+			if n, ok := rule.Value.(int); ok && !rule.Never {
+				p.MaxSubjectLength = n
+			}
+		case "body-max-line-length":
+			if n, ok := rule.Value.(int); ok && !rule.Never {
+				p.BodyMaxLineLength = n
+			}
+		case "scope-empty":
+			p.ScopeRequired = rule.Never
+		case "type-case":
+			p.TypeCase = &caseRule{Never: rule.Never, Cases: stringValues(rule.Value)}
+		case "scope-case":
+			p.ScopeCase = &caseRule{Never: rule.Never, Cases: stringValues(rule.Value)}
+		case "subject-case":
+			p.SubjectCase = &caseRule{Never: rule.Never, Cases: stringValues(rule.Value)}
+		}
+	}
+}
+
+// stringValues turns a rule value that is a string or a list of strings
+// into a list.
+func stringValues(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// matchesCase reports whether s is written in a commitlint case: lower-case,
+// upper-case, camel-case, kebab-case, pascal-case, sentence-case,
+// snake-case or start-case. Unknown cases match anything.
+func matchesCase(s, c string) bool {
+	first := []rune(s)[0]
+	switch c {
+	case "lower-case", "lowercase":
+		return s == strings.ToLower(s)
+	case "upper-case", "uppercase":
+		return s == strings.ToUpper(s)
+	case "sentence-case", "sentencecase":
+		return !unicode.IsLower(first)
+	case "start-case", "startcase":
+		for _, word := range strings.Fields(s) {
+			if unicode.IsLower([]rune(word)[0]) {
+				return false
+			}
+		}
+		return true
+	case "pascal-case", "pascalcase":
+		return unicode.IsUpper(first) && !strings.ContainsAny(s, " -_")
+	case "camel-case", "camelcase":
+		return unicode.IsLower(first) && !strings.ContainsAny(s, " -_")
+	case "kebab-case", "kebabcase":
+		return s == strings.ToLower(s) && !strings.ContainsAny(s, " _")
+	case "snake-case", "snakecase":
+		return s == strings.ToLower(s) && !strings.ContainsAny(s, " -")
+	}
+	return true
+}
+
+// toCase rewrites s in a case that can be reached without knowing word
+// boundaries: lower-case, upper-case or sentence-case. ok is false for the
+// others.
+func toCase(s, c string) (string, bool) {
+	switch c {
+	case "lower-case", "lowercase":
+		return strings.ToLower(s), true
+	case "upper-case", "uppercase":
+		return strings.ToUpper(s), true
+	case "sentence-case", "sentencecase":
+		r := []rune(s)
+		return string(unicode.ToUpper(r[0])) + string(r[1:]), true
+	}
+	return s, false
+}
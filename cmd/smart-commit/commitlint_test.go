@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommitlintRules(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"json", []byte(`{"extends": ["@commitlint/config-conventional"], "rules": {"type-enum": [2, "always", ["feat", "fix", "deps"]], "header-max-length": [2, "always", 50], "subject-case": [2, "never", ["sentence-case", "upper-case"]], "scope-empty": [1, "never"], "body-max-line-length": [0, "always", 100]}}`)},
+		{"yaml", []byte("extends:\n  - '@commitlint/config-conventional'\nrules:\n  type-enum: [2, always, [feat, fix, deps]]\n  header-max-length: [2, always, 50]\n  subject-case: [2, never, [sentence-case, upper-case]]\n  scope-empty: [1, never]\n  body-max-line-length: [0, always, 100]\n")},
+		{"javascript", jsObjectLiteral([]byte(`// commitlint.config.js
+/* eslint-disable */
+module.exports = {
+  extends: ['@commitlint/config-conventional'],
+  rules: {
+    'type-enum': [2, 'always', ['feat', 'fix', 'deps']],
+    'header-max-length': [2, 'always', 50],
+    // Lower-case subjects only
+    'subject-case': [2, 'never', ['sentence-case', 'upper-case']],
+    'scope-empty': [1, 'never'],
+    'body-max-line-length': [0, 'always', 100],
+  },
+};
+`))},
+	}
+	want := &policy{
+		Types:            []string{"feat", "fix", "deps"},
+		MaxSubjectLength: 50,
+		ScopeRequired:    true,
+		SubjectCase:      &caseRule{Never: true, Cases: []string{"sentence-case", "upper-case"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseCommitlintRules(tt.data)
+			if err != nil {
+				t.Fatalf("parseCommitlintRules: %v", err)
+			}
+			got := &policy{}
+			applyCommitlintRules(got, rules)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("policy = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCaseRules(t *testing.T) {
+	conventional := &caseRule{Never: true, Cases: []string{"sentence-case", "start-case", "pascal-case", "upper-case"}}
+	lower := &caseRule{Cases: []string{"lower-case"}}
+	tests := []struct {
+		rule    *caseRule
+		s       string
+		allowed bool
+		fixed   string
+	}{
+		{conventional, "add users endpoint", true, "add users endpoint"},
+		{conventional, "Add users endpoint", false, "add users endpoint"},
+		{conventional, "Add Users Endpoint", false, "add Users Endpoint"},
+		{lower, "ADD USERS", false, "add users"},
+		{lower, "add API client", false, "add api client"},
+		{lower, "add api client", true, "add api client"},
+		{nil, "Anything Goes", true, "Anything Goes"},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.allows(tt.s); got != tt.allowed {
+			t.Errorf("%v allows %q = %v, want %v", tt.rule, tt.s, got, tt.allowed)
+		}
+		if tt.rule == nil {
+			continue
+		}
+		if got := recase(tt.s, tt.rule); got != tt.fixed {
+			t.Errorf("recase(%q, %v) = %q, want %q", tt.s, tt.rule, got, tt.fixed)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// runCompare implements `smart-commit compare <base>..<head>`, a prose summary
+// of the net difference between two refs plus a structured change list.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	noAI := fs.Bool("no-ai", false, "skip the AI-written summary and only print the change list")
+	graphExport := fs.String("graph-export", "", "print the branch commits as a graph instead of Markdown: json or dot")
+	format := renderFlag(fs, renderMarkdown)
+	fs.Parse(args)
+
+	if err := validateGraphExport(*graphExport); err != nil {
+		return err
+	}
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: smart-commit compare [--no-ai] [--graph-export json|dot] [--render FORMAT] <base>..<head>")
+	}
+	base, head, err := splitRange(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *graphExport != "" {
+		commits, err := git.LoadCommits("--no-merges", base+".."+head)
+		if err != nil {
+			return err
+		}
+		graph, err := renderCommitGraph(*graphExport, commits)
+		if err != nil {
+			return err
+		}
+		fmt.Print(graph)
+		return nil
+	}
+
+	// Progress goes to stderr, so JSON on stdout stays parseable
+	useAI := !*noAI
+	if useAI {
+		if err := checkProvider(); err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping AI summary: %v\n", err)
+			useAI = false
+		} else {
+			fmt.Fprintf(os.Stderr, "Summarizing differences with %s...\n", currentGenerator().Name())
+		}
+	}
+
+	r, err := buildComparison(base, head, useAI)
+	if err != nil {
+		return err
+	}
+	return printReport(*format, r)
+}
+
+// buildComparison compares two refs, with an AI summary when useAI is set.
+// Provider failures only drop the summary.
+func buildComparison(base, head string, useAI bool) (report, error) {
+	// Three-dot diff shows what head adds since it forked from base, which is
+	// what lands when the branch is merged.
+	diffRange := base + "..." + head
+	changes, err := git.LoadChangeSet(diffRange)
+	if err != nil {
+		return report{}, err
+	}
+	commits, err := git.LoadCommits("--no-merges", base+".."+head)
+	if err != nil {
+		return report{}, err
+	}
+
+	summary := ""
+	if useAI && len(changes.Files) > 0 {
+		summary, err = askModel(generator.RenderPrompt(generator.PromptCompareSummary, map[string]interface{}{
+			"Base": base, "Head": head, "Commits": commits, "Changes": changes.Describe(),
+		}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+		}
+	}
+	return comparisonReport(base, head, summary, commits, changes), nil
+}
+
+// splitRange parses "base..head" or "base...head" into its two refs.
+func splitRange(spec string) (string, string, error) {
+	sep := ".."
+	if strings.Contains(spec, "...") {
+		sep = "..."
+	}
+	parts := strings.SplitN(spec, sep, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid range %q: expected <base>..<head>", spec)
+	}
+	return parts[0], parts[1], nil
+}
+
+// comparisonReport is the comparison of two refs: the summary, the
+// commits and the changed files.
+func comparisonReport(base, head, summary string, commits []git.Commit, changes *git.ChangeSet) report {
+	var r report
+	r.heading(1, "%s..%s", base, head)
+
+	if summary != "" {
+		r.heading(2, "Summary")
+		r.paragraph("%s", strings.TrimSpace(summary))
+	}
+
+	r.heading(2, "Commits (%d)", len(commits))
+	var items []string
+	for _, c := range commits {
+		items = append(items, fmt.Sprintf("`%s` %s", git.ShortHash(c.Hash), c.Subject))
+	}
+	r.list(items)
+
+	added, deleted := changes.Stats()
+	r.heading(2, "Changed files (%d, +%d/-%d)", len(changes.Files), added, deleted)
+	items = nil
+	for _, c := range changes.Files {
+		path := fmt.Sprintf("`%s`", c.Path)
+		if c.Renamed() {
+			path = fmt.Sprintf("`%s` → `%s`", c.OldPath, c.Path)
+		}
+		if c.Binary {
+			items = append(items, fmt.Sprintf("%s %s (binary)", c.Status, path))
+			continue
+		}
+		items = append(items, fmt.Sprintf("%s %s (+%d/-%d)", c.Status, path, c.Added, c.Deleted))
+	}
+	r.list(items)
+	return r
+}
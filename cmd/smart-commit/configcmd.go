@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// runConfig implements `smart-commit config`.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: smart-commit config list | get KEY | set [--global] KEY VALUE | encrypt [VALUE] | decrypt VALUE")
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("config list", flag.ExitOnError)
+		format := renderFlag(fs, "")
+		fs.Parse(args[1:])
+		if err := checkRender(*format); err != nil {
+			return err
+		}
+		cfg := config.Current()
+		var rows [][]string
+		values := map[string]string{}
+		for _, k := range config.Keys {
+			value, err := config.Value(cfg, k.Name)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, []string{k.Name, value, k.Help})
+			values[k.Name] = value
+		}
+		r := report{Data: values}
+		r.table([]string{"Setting", "Value", "Description"}, rows)
+		return printReport(*format, r)
+
+	case "get":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: smart-commit config get KEY")
+		}
+		if !config.IsKey(args[1]) {
+			return fmt.Errorf("unknown setting %q", args[1])
+		}
+		value, err := config.Value(config.Current(), args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+
+	case "set":
+		fs := flag.NewFlagSet("config set", flag.ExitOnError)
+		global := fs.Bool("global", false, "write the user's global configuration instead of the repository's")
+		fs.Parse(args[1:])
+		if fs.NArg() != 2 {
+			return fmt.Errorf("usage: smart-commit config set [--global] KEY VALUE")
+		}
+		if !*global && config.GlobalOnly(fs.Arg(0)) {
+			return fmt.Errorf("%s can only be set in the global configuration; use --global", fs.Arg(0))
+		}
+		path, err := config.RepoPath()
+		if *global {
+			path, err = config.GlobalPath()
+		}
+		if err != nil {
+			return err
+		}
+		if err := config.Set(path, fs.Arg(0), fs.Arg(1)); err != nil {
+			return err
+		}
+		fmt.Printf("Set %s in %s\n", fs.Arg(0), path)
+		return nil
+
+	case "encrypt":
+		var value string
+		if len(args) > 1 {
+			value = args[1]
+		} else {
+			// Read the value without putting it in shell history
+			read, err := readSecretValue()
+			if err != nil {
+				return err
+			}
+			value = read
+		}
+		if value == "" {
+			return fmt.Errorf("nothing to encrypt")
+		}
+		encrypted, err := encryptSecret(value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(encrypted)
+		return nil
+
+	case "decrypt":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: smart-commit config decrypt VALUE")
+		}
+		plaintext, err := decryptSecret(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(plaintext)
+		return nil
+	}
+	return fmt.Errorf("unknown config command %q", args[0])
+}
+
+// readSecretValue reads a value to encrypt from the terminal without echo, or
+// from piped stdin.
+func readSecretValue() (string, error) {
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(os.Stderr, "Value to encrypt: ")
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
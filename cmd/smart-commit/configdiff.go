@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+	"gopkg.in/yaml.v3"
+)
+
+// configChange is a setting of an application config file that a change
+// adds, removes or sets to another value.
+type configChange struct {
+	Path, Key string
+	Old, New  string
+	Had, Has  bool
+}
+
+func (c configChange) String() string {
+	switch {
+	case !c.Had:
+		return fmt.Sprintf("%s: %s added (%s)", c.Path, c.Key, c.New)
+	case !c.Has:
+		return fmt.Sprintf("%s: %s removed", c.Path, c.Key)
+	case c.Old == c.New:
+		// Both values are redacted
+		return fmt.Sprintf("%s: %s changed (%s)", c.Path, c.Key, c.New)
+	}
+	return fmt.Sprintf("%s: %s: %s → %s", c.Path, c.Key, c.Old, c.New)
+}
+
+// wantConfigChanges reports whether changed settings are listed in the
+// body, as the config_changes setting says.
+func wantConfigChanges() bool {
+	return config.Bool(config.Current().ConfigChanges, true)
+}
+
+// configMemo keeps the config changes of the last change set, as the
+// prompt and the body both need them.
+var configMemo struct {
+	changes *git.ChangeSet
+	report  []configChange
+}
+
+// stagedConfigChanges returns the settings the staged changes make to the
+// YAML, JSON, TOML, INI and .env config files they edit, with secrets
+// redacted. Kubernetes manifests and OpenAPI specs are reported as
+// infrastructure and contracts instead.
+func stagedConfigChanges(changes *git.ChangeSet) []configChange {
+	if configMemo.changes == changes {
+		return configMemo.report
+	}
+	var report []configChange
+	for _, f := range changes.Files {
+		// The settings of a file added or removed whole are not news
+		if f.Category != git.CategoryConfig || f.Binary || f.Status == "A" || f.Status == "D" {
+			continue
+		}
+		before, after := stagedVersions(f)
+		if manifestObjects(before) != nil || manifestObjects(after) != nil {
+			continue
+		}
+		if _, ok := openAPIContract(after); ok {
+			continue
+		}
+		old, ok1 := readSettings(f.Path, before)
+		now, ok2 := readSettings(f.Path, after)
+		if ok1 && ok2 {
+			report = append(report, diffSettings(f.Path, old, now)...)
+		}
+	}
+	configMemo.changes, configMemo.report = changes, report
+	return report
+}
+
+// describeConfigChanges lists the changed settings for the prompt.
+func describeConfigChanges(changes *git.ChangeSet) string {
+	report := stagedConfigChanges(changes)
+	if len(report) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Configuration changes:\n")
+	for _, c := range report {
+		b.WriteString("- " + c.String() + "\n")
+	}
+	return b.String()
+}
+
+// addConfigChanges adds a "Configuration changes" section to message
+// listing the settings the change adds, removes or changes.
+func addConfigChanges(message string, changes *git.ChangeSet) string {
+	if !wantConfigChanges() {
+		return message
+	}
+	var items []string
+	for _, c := range stagedConfigChanges(changes) {
+		items = append(items, c.String())
+	}
+	return addBodySection(message, "Configuration changes", items)
+}
+
+// diffSettings compares the settings of a file before and after, by key.
+func diffSettings(file string, before, after map[string]string) []configChange {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var report []configChange
+	for _, k := range keys {
+		old, had := before[k]
+		now, has := after[k]
+		if had && has && old == now {
+			continue
+		}
+		report = append(report, configChange{
+			Path: file, Key: k, Had: had, Has: has,
+			Old: settingValue(k, old), New: settingValue(k, now),
+		})
+	}
+	return report
+}
+
+// secretSetting matches the names of settings that hold secrets.
+var secretSetting = regexp.MustCompile(`(?i)(passw(or)?d|passwd|pwd|secret|token|api[_-]?key|private[_-]?key|access[_-]?key|credential|(^|[._-])auth($|[._-])|dsn|salt|signing)`)
+
+// urlPassword matches the password in a URL such as postgres://u:p@host.
+var urlPassword = regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`)
+
+// settingValue prints a setting for the commit body: secrets, whether
+// told by the name of the setting or the look of the value, are redacted,
+// as are passwords in URLs, and long values are cut short.
+func settingValue(key, value string) string {
+	if value == "" {
+		return `""`
+	}
+	if _, _, ok := findSecret(value); ok || secretSetting.MatchString(key) {
+		return "redacted"
+	}
+	return shortValue(urlPassword.ReplaceAllString(value, "${1}redacted@"))
+}
+
+// readSettings reads the settings of a config file as flat keys, such as
+// server.port or servers[0].host, and their values. An empty src has no
+// settings; ok is false when src does not parse.
+func readSettings(file, src string) (settings map[string]string, ok bool) {
+	settings = map[string]string{}
+	if src == "" {
+		return settings, true
+	}
+	base := strings.ToLower(path.Base(file))
+	switch ext := path.Ext(base); {
+	case ext == ".toml" || ext == ".ini":
+		return iniSettings(src), true
+	case ext == ".yaml" || ext == ".yml" || ext == ".json":
+		dec := yaml.NewDecoder(strings.NewReader(src))
+		for i := 0; ; i++ {
+			var doc interface{}
+			if err := dec.Decode(&doc); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, false
+			}
+			// Further documents of a stream are told apart by number
+			prefix := ""
+			if i > 0 {
+				prefix = fmt.Sprintf("(%d)", i+1)
+			}
+			flattenSettings(doc, prefix, settings)
+		}
+		return settings, true
+	case strings.HasPrefix(base, ".env"):
+		return envSettings(src), true
+	}
+	return nil, false
+}
+
+// flattenSettings adds the scalars of a parsed YAML or JSON value to
+// settings, keyed by their dotted path from at.
+func flattenSettings(v interface{}, at string, settings map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			key := k
+			if at != "" {
+				key = at + "." + k
+			}
+			flattenSettings(item, key, settings)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenSettings(item, fmt.Sprintf("%s[%d]", at, i), settings)
+		}
+	case nil:
+		settings[at] = "null"
+	default:
+		settings[at] = fmt.Sprint(v)
+	}
+}
+
+var (
+	iniSection = regexp.MustCompile(`^\[\[?\s*([^\]]+?)\s*\]\]?$`)
+	iniValue   = regexp.MustCompile(`^([A-Za-z0-9_.\-"']+)\s*[=:]\s*(.*)$`)
+	envValue   = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_.]*)\s*=\s*(.*)$`)
+)
+
+// iniSettings reads the key = value lines of a TOML or INI file, keyed by
+// their section: [server] port = 80 is server.port. Values spanning lines
+// are cut at the first.
+func iniSettings(src string) map[string]string {
+	settings := map[string]string{}
+	section := ""
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if m := iniSection.FindStringSubmatch(line); m != nil {
+			section = m[1] + "."
+			continue
+		}
+		if m := iniValue.FindStringSubmatch(line); m != nil {
+			settings[section+strings.Trim(m[1], `"'`)] = settingText(m[2])
+		}
+	}
+	return settings
+}
+
+// envSettings reads the variables a .env file sets.
+func envSettings(src string) map[string]string {
+	settings := map[string]string{}
+	for _, line := range strings.Split(src, "\n") {
+		if m := envValue.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			settings[m[1]] = settingText(m[2])
+		}
+	}
+	return settings
+}
+
+// settingText is a value as written in a TOML, INI or .env file, without
+// its quotes or a comment after it.
+func settingText(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// predictedConflict is a file a trial merge could not merge cleanly, with a
+// short description of what each side did to it.
+type predictedConflict struct {
+	Path    string
+	Summary string
+}
+
+// predictConflicts performs a trial merge of HEAD into upstream inside a
+// temporary worktree and returns the files that would conflict. The current
+// worktree and index are never touched.
+func predictConflicts(upstream string) ([]string, error) {
+	head, err := executeCommandWithOutput("git", "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("resolving HEAD: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "smart-commit-trial-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := executeCommandWithOutput("git", "worktree", "add", "--detach", dir, upstream); err != nil {
+		return nil, fmt.Errorf("creating trial worktree: %v", err)
+	}
+	defer executeCommandWithOutput("git", "worktree", "remove", "--force", dir)
+
+	// A failing merge is the interesting case; the conflicted paths tell us why
+	executeCommandWithOutput("git", "-C", dir, "merge", "--no-commit", "--no-ff", strings.TrimSpace(head))
+	out, err := executeCommandWithOutput("git", "-C", dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, fmt.Errorf("listing trial merge conflicts: %v", err)
+	}
+
+	return gitPathLines(out), nil
+}
+
+// describeConflicts summarizes what HEAD and upstream each changed in the
+// conflicting files since they diverged, using the AI provider when available.
+func describeConflicts(upstream string, files []string, useAI bool) []predictedConflict {
+	forkPoint, _ := executeCommandWithOutput("git", "merge-base", "HEAD", upstream)
+	forkPoint = strings.TrimSpace(forkPoint)
+
+	conflicts := make([]predictedConflict, 0, len(files))
+	for _, file := range files {
+		c := predictedConflict{Path: file}
+		if useAI && forkPoint != "" {
+			ours, _ := executeCommandWithOutput("git", "diff", forkPoint, "HEAD", "--", file)
+			theirs, _ := executeCommandWithOutput("git", "diff", forkPoint, upstream, "--", file)
+			prompt := generator.RenderPrompt(generator.PromptConflictSummary, map[string]string{"File": file, "Ours": truncate(ours, 4000), "Theirs": truncate(theirs, 4000)})
+			if summary, err := askModel(prompt); err == nil {
+				c.Summary = summary
+			}
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts
+}
+
+// printConflictReport lists predicted conflicts and their summaries.
+func printConflictReport(upstream string, conflicts []predictedConflict) {
+	fmt.Printf("Rebasing onto %s is likely to conflict in %d file(s):\n", upstream, len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  %s\n", c.Path)
+		for _, line := range strings.Split(strings.TrimSpace(c.Summary), "\n") {
+			if line != "" {
+				fmt.Printf("      %s\n", line)
+			}
+		}
+	}
+}
+
+// truncate shortens s to at most n bytes, marking that it was cut.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
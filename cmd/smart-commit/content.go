@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// contentRule is a content_rules entry of the policy: content that must
+// not reach the provider or a commit message. Rules naming a built-in
+// pattern, such as email, need no pattern of their own.
+type contentRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern,omitempty"`
+	// Action is block, the default, or redact.
+	Action string `json:"action,omitempty"`
+	// Scope is diff, message or both, the default.
+	Scope string `json:"scope,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// builtinContent are the patterns rules can name instead of giving one.
+var builtinContent = map[string]string{
+	"email":       `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`,
+	"ipv4":        `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`,
+	"credit-card": `\b(?:\d[ -]?){12,15}\d\b`,
+	"us-ssn":      `\b\d{3}-\d{2}-\d{4}\b`,
+}
+
+// compileContentRules checks the rules of a policy and compiles them.
+func compileContentRules(rules []contentRule) error {
+	for i := range rules {
+		r := &rules[i]
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = builtinContent[r.Name]
+		}
+		switch {
+		case r.Name == "":
+			return fmt.Errorf("content rule %d has no name", i+1)
+		case pattern == "":
+			return fmt.Errorf("content rule %q has no pattern and is not one of the built-in rules", r.Name)
+		case r.Action != "" && r.Action != "block" && r.Action != "redact":
+			return fmt.Errorf("content rule %q: action is %q; expected block or redact", r.Name, r.Action)
+		case r.Scope != "" && r.Scope != "diff" && r.Scope != "message" && r.Scope != "both":
+			return fmt.Errorf("content rule %q: scope is %q; expected diff, message or both", r.Name, r.Scope)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("content rule %q: %v", r.Name, err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// contentFinding is a match of a content rule.
+type contentFinding struct {
+	Rule       *contentRule
+	Start, End int
+}
+
+// classifier finds banned content in text bound for scope, diff or
+// message. The policy's regular expressions are the one classifier now; a
+// model-based one can join them behind the same interface.
+type classifier interface {
+	classify(text, scope string) []contentFinding
+}
+
+// regexClassifier matches the content rules of the policy.
+type regexClassifier []contentRule
+
+func (rules regexClassifier) classify(text, scope string) []contentFinding {
+	var found []contentFinding
+	for i := range rules {
+		r := &rules[i]
+		if r.Scope != "" && r.Scope != "both" && r.Scope != scope {
+			continue
+		}
+		for _, m := range r.re.FindAllStringIndex(text, -1) {
+			found = append(found, contentFinding{Rule: r, Start: m[0], End: m[1]})
+		}
+	}
+	return found
+}
+
+// classifiers are the classifiers in effect.
+func classifiers() []classifier {
+	return []classifier{regexClassifier(currentPolicy().ContentRules)}
+}
+
+// screenContent redacts the redact findings in text as [REDACTED:rule]
+// and returns the names of the block rules it matches.
+func screenContent(text, scope string) (string, []string) {
+	var redact []contentFinding
+	var blocked []string
+	for _, c := range classifiers() {
+		for _, f := range c.classify(text, scope) {
+			if f.Rule.Action == "redact" {
+				redact = append(redact, f)
+			} else if !contains(blocked, f.Rule.Name) {
+				blocked = append(blocked, f.Rule.Name)
+			}
+		}
+	}
+	// Replace from the end so earlier offsets stay valid; overlapping
+	// matches are covered by the first
+	sort.Slice(redact, func(i, j int) bool { return redact[i].Start > redact[j].Start })
+	end := len(text) + 1
+	for _, f := range redact {
+		if f.End > end {
+			continue
+		}
+		text = text[:f.Start] + "[REDACTED:" + f.Rule.Name + "]" + text[f.End:]
+		end = f.Start
+	}
+	return text, blocked
+}
+
+// screenPrompt is the Screen of every provider: it redacts the diff and
+// other context sent to the model, and refuses to send it when a block
+// rule matches.
+func screenPrompt(prompt string) (string, error) {
+	prompt, blocked := screenContent(prompt, "diff")
+	if len(blocked) > 0 {
+		return "", fmt.Errorf("not sending the changes to the provider: they match content rule %s", strings.Join(blocked, ", "))
+	}
+	return prompt, nil
+}
+
+// withScreening wraps gen so prompts go through the content rules.
+func withScreening(gen generator.Generator) generator.Generator {
+	return &generator.Screened{Generator: gen, Screen: screenPrompt}
+}
+
+// screenMessage redacts a commit message, warning about what it redacts,
+// and fails when a block rule matches.
+func screenMessage(message string) (string, error) {
+	screened, blocked := screenContent(message, "message")
+	if len(blocked) > 0 {
+		return message, fmt.Errorf("the commit message matches content rule %s; edit it out", strings.Join(blocked, ", "))
+	}
+	if screened != message {
+		fmt.Fprintln(os.Stderr, "Warning: redacted content the policy does not allow in commit messages")
+	}
+	return screened, nil
+}
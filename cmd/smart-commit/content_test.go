@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// withContentRules puts a policy with rules in effect for the test.
+func withContentRules(t *testing.T, rules string) {
+	t.Helper()
+	previous := activePolicy
+	t.Cleanup(func() { usePolicy(previous) })
+	p, err := parsePolicy([]byte(`{"content_rules": ` + rules + `}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usePolicy(p)
+}
+
+func TestScreenContent(t *testing.T) {
+	withContentRules(t, `[
+		{"name": "email", "action": "redact"},
+		{"name": "internal-host", "pattern": "\\b[a-z0-9-]+\\.corp\\.example\\.com\\b"},
+		{"name": "ticket-secret", "pattern": "SECRET-\\d+", "scope": "message"}
+	]`)
+	tests := []struct {
+		text, scope string
+		want        string
+		wantBlocked []string
+	}{
+		{"contact ann@example.org or bob@example.org", "diff", "contact [REDACTED:email] or [REDACTED:email]", nil},
+		{"connect to db1.corp.example.com", "diff", "connect to db1.corp.example.com", []string{"internal-host"}},
+		{"fix SECRET-42", "diff", "fix SECRET-42", nil},
+		{"fix SECRET-42 for ann@example.org", "message", "fix SECRET-42 for [REDACTED:email]", []string{"ticket-secret"}},
+		{"nothing to see", "message", "nothing to see", nil},
+	}
+	for _, tt := range tests {
+		got, blocked := screenContent(tt.text, tt.scope)
+		if got != tt.want || !reflect.DeepEqual(blocked, tt.wantBlocked) {
+			t.Errorf("screenContent(%q, %s) = %q, %v, want %q, %v", tt.text, tt.scope, got, blocked, tt.want, tt.wantBlocked)
+		}
+	}
+}
+
+func TestContentRulesValidation(t *testing.T) {
+	for _, rules := range []string{
+		`[{"pattern": "x"}]`,
+		`[{"name": "hostname"}]`,
+		`[{"name": "email", "action": "warn"}]`,
+		`[{"name": "email", "scope": "branch"}]`,
+		`[{"name": "bad", "pattern": "("}]`,
+	} {
+		if _, err := parsePolicy([]byte(`{"content_rules": ` + rules + `}`)); err == nil {
+			t.Errorf("parsePolicy accepted content rules %s", rules)
+		}
+	}
+}
+
+// promptRecorder is a provider that keeps the prompt it is sent.
+type promptRecorder struct{ prompt string }
+
+func (g *promptRecorder) Name() string { return "recorder" }
+func (g *promptRecorder) Check() error { return nil }
+func (g *promptRecorder) Generate(ctx context.Context, prompt string) (string, error) {
+	g.prompt = prompt
+	return "feat: add users", nil
+}
+
+func TestScreenedPrompt(t *testing.T) {
+	withContentRules(t, `[{"name": "email", "action": "redact"}, {"name": "us-ssn"}]`)
+	rec := &promptRecorder{}
+	gen := withScreening(rec)
+	if _, err := gen.Generate(context.Background(), "+owner = ann@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if rec.prompt != "+owner = [REDACTED:email]" {
+		t.Errorf("the provider was sent %q, want the address redacted", rec.prompt)
+	}
+	rec.prompt = ""
+	if _, err := gen.Generate(context.Background(), "+ssn = 123-45-6789"); err == nil || rec.prompt != "" {
+		t.Errorf("Generate() = %v and sent %q, want it refused before reaching the provider", err, rec.prompt)
+	}
+	if _, ok := gen.(*generator.Screened); !ok {
+		t.Errorf("withScreening() = %T, want *generator.Screened", gen)
+	}
+}
+
+func TestLintBannedContent(t *testing.T) {
+	withContentRules(t, `[{"name": "internal-host", "pattern": "\\.corp\\.example\\.com", "scope": "message"}]`)
+	problems := lintMessage("fix: point at db1.corp.example.com")
+	if len(problems) != 1 || problems[0].Rule != "banned-content" || !strings.Contains(problems[0].Message, "internal-host") {
+		t.Errorf("lintMessage() = %v, want a banned-content problem", problems)
+	}
+}
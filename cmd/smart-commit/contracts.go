@@ -0,0 +1,339 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+	"gopkg.in/yaml.v3"
+)
+
+// httpMethods are the operations an OpenAPI path item can have.
+var httpMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// stagedContractChanges compares every staged proto file and OpenAPI spec
+// with its version as of apiBase: messages, fields, enum values, services
+// and RPCs of the one, and endpoints, schemas and their properties of the
+// other. Removing or changing any of them breaks clients, as does adding a
+// required property.
+func stagedContractChanges(changes *git.ChangeSet) []apiChange {
+	if !git.RefExists(apiBase) {
+		return nil
+	}
+	var report []apiChange
+	for _, f := range changes.Files {
+		oldPath := f.Path
+		if f.OldPath != "" {
+			oldPath = f.OldPath
+		}
+		if !isContractFile(oldPath) && !isContractFile(f.Path) {
+			continue
+		}
+		before, hadContract := readContract(oldPath, apiBase)
+		after, hasContract := readContract(f.Path, "")
+		kind := "OpenAPI spec"
+		if strings.HasSuffix(f.Path, ".proto") {
+			kind = "proto file"
+		}
+		switch {
+		case !hadContract && hasContract:
+			report = append(report, apiChange{Package: f.Path, Change: "added", Decl: apiDecl{Kind: kind}})
+		case hadContract && !hasContract:
+			report = append(report, apiChange{Package: f.Path, Change: "removed", Decl: apiDecl{Kind: kind}, Breaking: true})
+		case hadContract:
+			report = append(report, diffContract(f.Path, before, after)...)
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		return report[i].Breaking && !report[j].Breaking
+	})
+	return report
+}
+
+// diffContract compares the declarations of a contract before and after,
+// as diffAPI does; adding a required property breaks clients too.
+func diffContract(file string, before, after map[string]apiDecl) []apiChange {
+	changes := diffAPI(file, before, after)
+	for i, c := range changes {
+		changes[i].Breaking = c.Breaking || c.Decl.Kind == "property" && strings.HasSuffix(c.Decl.Signature, "(required)")
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Breaking && !changes[j].Breaking
+	})
+	return changes
+}
+
+// isContractFile reports whether p may be a proto file or an OpenAPI spec.
+func isContractFile(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".proto", ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readContract returns the declarations of the contract at p, at rev or as
+// staged when rev is empty; ok is false when there is no such file or it is
+// not a contract.
+func readContract(p, rev string) (api map[string]apiDecl, ok bool) {
+	src, err := git.Output("show", rev+":"+p)
+	if err != nil {
+		return nil, false
+	}
+	if strings.HasSuffix(p, ".proto") {
+		return protoAPI(src), true
+	}
+	return openAPIContract(src)
+}
+
+// protoAPI returns the declarations of a proto file, keyed by their name
+// qualified with the enclosing ones: messages, their fields, enums, their
+// values, services and their RPCs. Options, reserved ranges and extensions
+// are skipped.
+func protoAPI(src string) map[string]apiDecl {
+	toks := protoTokens(src)
+	api := map[string]apiDecl{}
+	type frame struct{ kind, name string }
+	var stack []frame
+	for i := 0; i < len(toks); {
+		t := toks[i]
+		var top frame
+		if n := len(stack); n > 0 {
+			top = stack[n-1]
+		}
+		switch {
+		case t == "}":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			i++
+		case t == ";":
+			i++
+		case (t == "message" || t == "enum" || t == "service" || t == "oneof") && i+2 < len(toks) && toks[i+2] == "{":
+			// The fields of a oneof belong to its message
+			name := top.name
+			if t != "oneof" {
+				name = toks[i+1]
+				if top.name != "" {
+					name = top.name + "." + name
+				}
+				api[name] = apiDecl{Kind: t}
+			}
+			stack = append(stack, frame{t, name})
+			i += 3
+		default:
+			stmt, next := protoStatement(toks, i)
+			i = next
+			if len(stmt) < 2 {
+				continue
+			}
+			switch top.kind {
+			case "service":
+				if stmt[0] == "rpc" {
+					sig := stmt[2:]
+					if k := indexOf(sig, "{"); k >= 0 {
+						sig = sig[:k]
+					}
+					api[top.name+"."+stmt[1]] = apiDecl{"rpc", protoJoin(sig)}
+				}
+			case "message", "oneof":
+				switch stmt[0] {
+				case "option", "reserved", "extensions", "extend":
+					continue
+				}
+				if k := indexOf(stmt, "="); k >= 2 && k+1 < len(stmt) {
+					api[top.name+"."+stmt[k-1]] = apiDecl{"field", protoJoin(stmt[:k-1]) + " = " + stmt[k+1]}
+				}
+			case "enum":
+				if stmt[1] == "=" && stmt[0] != "option" && stmt[0] != "reserved" {
+					value := stmt[2:]
+					if k := indexOf(value, "["); k >= 0 {
+						value = value[:k]
+					}
+					api[top.name+"."+stmt[0]] = apiDecl{"enum value", "= " + protoJoin(value)}
+				}
+			}
+		}
+	}
+	return api
+}
+
+// protoStatement returns the tokens of the statement at toks[i], up to its
+// ";" or the "}" closing its body, and where the next one starts. A "}"
+// closing the enclosing block is left for the caller.
+func protoStatement(toks []string, i int) (stmt []string, next int) {
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		switch toks[j] {
+		case "{", "[", "(":
+			depth++
+		case "]", ")":
+			depth--
+		case "}":
+			if depth == 0 {
+				return toks[i:j], j
+			}
+			if depth--; depth == 0 {
+				return toks[i : j+1], j + 1
+			}
+		case ";":
+			if depth == 0 {
+				return toks[i:j], j + 1
+			}
+		}
+	}
+	return toks[i:], len(toks)
+}
+
+// protoTokens splits a proto file into words, which include dotted names
+// and numbers, quoted strings and single punctuation characters. Comments
+// are dropped.
+func protoTokens(src string) []string {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			if end := strings.Index(src[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(src)
+			}
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				j = len(src) - 1
+			}
+			toks = append(toks, src[i:j+1])
+			i = j + 1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isProtoWord(c):
+			j := i
+			for j < len(src) && isProtoWord(src[j]) {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks
+}
+
+func isProtoWord(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// protoJoin prints tokens the way proto files are written, e.g.
+// "repeated string", "map<string,int32>" or "(stream Req) returns (Resp)".
+func protoJoin(toks []string) string {
+	var b strings.Builder
+	for i, t := range toks {
+		if i > 0 {
+			prev := toks[i-1]
+			if isProtoWord(prev[len(prev)-1]) && isProtoWord(t[0]) || t == "(" || prev == ")" {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+func indexOf(toks []string, tok string) int {
+	for i, t := range toks {
+		if t == tok {
+			return i
+		}
+	}
+	return -1
+}
+
+// openAPIContract returns the declarations of an OpenAPI or Swagger spec,
+// in YAML or JSON: its endpoints, such as "GET /users", and its schemas
+// with their properties. ok is false when src is not such a spec.
+func openAPIContract(src string) (api map[string]apiDecl, ok bool) {
+	// Most YAML and JSON files are not specs; spare parsing them
+	if !strings.Contains(src, "openapi") && !strings.Contains(src, "swagger") {
+		return nil, false
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return nil, false
+	}
+	if doc["openapi"] == nil && doc["swagger"] == nil {
+		return nil, false
+	}
+	api = map[string]apiDecl{}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for p, item := range paths {
+		ops, _ := item.(map[string]interface{})
+		for method := range ops {
+			if httpMethods[method] {
+				api[strings.ToUpper(method)+" "+p] = apiDecl{Kind: "endpoint"}
+			}
+		}
+	}
+	// Swagger 2 keeps schemas under definitions, OpenAPI 3 under components
+	schemas, _ := doc["definitions"].(map[string]interface{})
+	if components, ok := doc["components"].(map[string]interface{}); ok {
+		schemas, _ = components["schemas"].(map[string]interface{})
+	}
+	for name, s := range schemas {
+		schema, _ := s.(map[string]interface{})
+		api[name] = apiDecl{Kind: "schema"}
+		required := map[string]bool{}
+		list, _ := schema["required"].([]interface{})
+		for _, r := range list {
+			if r, ok := r.(string); ok {
+				required[r] = true
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for prop, p := range props {
+			sig := schemaType(p)
+			if required[prop] {
+				sig += " (required)"
+			}
+			api[name+"."+prop] = apiDecl{"property", sig}
+		}
+	}
+	return api, true
+}
+
+// schemaType describes the type of a schema: its type, the schema it
+// refers to, or []T for an array.
+func schemaType(s interface{}) string {
+	schema, _ := s.(map[string]interface{})
+	if ref, ok := schema["$ref"].(string); ok {
+		return path.Base(ref)
+	}
+	typ, _ := schema["type"].(string)
+	if typ == "array" {
+		return "[]" + schemaType(schema["items"])
+	}
+	if format, ok := schema["format"].(string); ok && typ != "" {
+		return typ + "/" + format
+	}
+	if typ == "" {
+		return "object"
+	}
+	return typ
+}
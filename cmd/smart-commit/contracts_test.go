@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContractDiff(t *testing.T) {
+	const proto = `syntax = "proto3";
+package users.v1;
+
+// Users manages accounts.
+service Users {
+  rpc Get(GetRequest) returns (User);
+  rpc Watch(GetRequest) returns (stream User) { option deprecated = true; }
+}
+
+message User {
+  string id = 1;
+  /* display name */
+  string name = 2 [json_name = "displayName"];
+  map<string, string> labels = 3;
+  oneof contact {
+    string email = 4;
+    string phone = 5;
+  }
+  enum Status {
+    STATUS_UNSPECIFIED = 0;
+    STATUS_ACTIVE = 1;
+  }
+  reserved 9;
+}
+
+message GetRequest { string id = 1; }
+`
+	const spec = `openapi: 3.0.0
+info: {title: Users, version: "1.0"}
+paths:
+  /users:
+    get: {responses: {"200": {description: ok}}}
+  /users/{id}:
+    parameters: []
+    get: {responses: {"200": {description: ok}}}
+components:
+  schemas:
+    User:
+      type: object
+      required: [id]
+      properties:
+        id: {type: string}
+        tags: {type: array, items: {type: string}}
+        manager: {$ref: "#/components/schemas/User"}
+`
+	tests := []struct {
+		name, file, before, after string
+		want                      []string
+	}{
+		{
+			"proto fields and rpcs",
+			"users.proto",
+			proto,
+			strings.NewReplacer(
+				"  string name = 2 [json_name = \"displayName\"];\n", "",
+				"string phone = 5;", "int64 phone = 5;",
+				"STATUS_ACTIVE = 1;", "STATUS_ACTIVE = 1;\n    STATUS_BANNED = 2;",
+				"  rpc Get(GetRequest) returns (User);\n", "  rpc Get(GetRequest) returns (User);\n  rpc Delete(GetRequest) returns (GetRequest);\n",
+			).Replace(proto),
+			[]string{
+				"users.proto: removed field User.name (breaking)",
+				"users.proto: changed field User.phone int64 = 5 (breaking)",
+				"users.proto: added enum value User.Status.STATUS_BANNED = 2",
+				"users.proto: added rpc Users.Delete(GetRequest) returns (GetRequest)",
+			},
+		},
+		{
+			"proto comments only",
+			"users.proto",
+			proto,
+			strings.Replace(proto, "// Users manages accounts.", "// Users manages user accounts.", 1),
+			nil,
+		},
+		{
+			"openapi endpoints and properties",
+			"openapi.yaml",
+			spec,
+			strings.NewReplacer(
+				"  /users:\n    get: {responses: {\"200\": {description: ok}}}\n", "",
+				"    get: {responses", "    delete: {responses: {\"204\": {description: gone}}}\n    get: {responses",
+				"required: [id]", "required: [id, email]",
+				"        id: {type: string}\n", "        id: {type: string}\n        email: {type: string, format: email}\n",
+			).Replace(spec),
+			[]string{
+				"openapi.yaml: removed endpoint GET /users (breaking)",
+				"openapi.yaml: added property User.email string/email (required) (breaking)",
+				"openapi.yaml: added endpoint DELETE /users/{id}",
+			},
+		},
+		{
+			"not a spec",
+			"config.yaml",
+			"name: app\nport: 80\n",
+			"name: app\nport: 8080\n",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := func(src string) (map[string]apiDecl, bool) {
+				if strings.HasSuffix(tt.file, ".proto") {
+					return protoAPI(src), true
+				}
+				return openAPIContract(src)
+			}
+			before, _ := read(tt.before)
+			after, ok := read(tt.after)
+			if !ok && tt.want != nil {
+				t.Fatalf("%s is not read as a contract", tt.file)
+			}
+			var got []string
+			for _, c := range diffContract(tt.file, before, after) {
+				s := c.String()
+				if c.Breaking {
+					s += " (breaking)"
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("contract diff =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// gitCredential asks the configured git credential helpers (manager-core,
+// osxkeychain, store, ...) for the credentials of rawURL. It never prompts:
+// ok is false when no helper has credentials for the URL.
+func gitCredential(rawURL string) (username, password string, ok bool) {
+	cmd := exec.CommandContext(interrupted, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("url=%s\n\n", rawURL))
+	// Without these git would fall back to asking on the terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return "", "", false
+	}
+
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	return username, password, password != ""
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// releaseVersion matches the version `cut-release` is given: 1.4, 1.4.0 or
+// v1.4.0.
+var releaseVersion = regexp.MustCompile(`^(v?)(\d+)\.(\d+)(?:\.(\d+))?$`)
+
+// parseReleaseVersion parses a version given as 1.4, 1.4.0 or v1.4.0.
+func parseReleaseVersion(s string) (semver, error) {
+	m := releaseVersion.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, fmt.Errorf("invalid version %q: expected major.minor or major.minor.patch", s)
+	}
+	v := semver{Prefix: m[1]}
+	v.Major, _ = strconv.Atoi(m[2])
+	v.Minor, _ = strconv.Atoi(m[3])
+	v.Patch, _ = strconv.Atoi(m[4])
+	return v, nil
+}
+
+// runCutRelease implements `smart-commit cut-release 1.4`: it creates the
+// release branch, writes the version into the version files, adds the
+// release to the changelog, commits that with a generated message and
+// pushes the branch.
+func runCutRelease(args []string) error {
+	fs := flag.NewFlagSet("cut-release", flag.ExitOnError)
+	branch := fs.String("branch", "", "name of the release branch (default: release/<version>)")
+	from := fs.String("from", "HEAD", "revision to branch off")
+	file := fs.String("changelog", "CHANGELOG.md", "changelog file to update")
+	noPush := fs.Bool("no-push", false, "create and commit on the branch without pushing it")
+	remote := fs.String("remote", "", "remote to push the branch to (default: where it would push)")
+	noAI := fs.Bool("no-ai", false, "commit with a fixed message instead of a generated one")
+	dryRun := fs.Bool("dry-run", false, "show what would be done without doing it")
+	yes := fs.Bool("yes", false, "cut the release without asking for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: smart-commit cut-release [flags] <version>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("cut-release takes one version, such as 1.4")
+	}
+
+	next, err := parseReleaseVersion(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	current, tag, released := latestRelease()
+	if next.Prefix == "" {
+		next.Prefix = "v"
+		if released {
+			next.Prefix = current.Prefix
+		}
+	}
+	if released && !current.less(next) {
+		return fmt.Errorf("%s is not newer than the latest release %s", next, tag)
+	}
+	version := strings.TrimPrefix(next.String(), "v")
+	if *branch == "" {
+		*branch = "release/" + strings.TrimPrefix(fs.Arg(0), "v")
+	}
+	if git.RefExists("refs/heads/" + *branch) {
+		return fmt.Errorf("branch %s already exists", *branch)
+	}
+	if out, _ := executeCommandWithOutput("git", "diff", "--cached", "--name-only"); strings.TrimSpace(out) != "" {
+		return fmt.Errorf("changes are staged; commit or unstage them before cutting a release")
+	}
+
+	// Check the version files before anything is changed
+	files := versionFiles()
+	bumped, err := bumpVersionFiles(files, version, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Release %s on branch %s from %s\n", next, *branch, *from)
+	for _, path := range bumped {
+		fmt.Printf("  set version %s in %s\n", version, path)
+	}
+	fmt.Printf("  add %s to %s\n", next, *file)
+	if *dryRun {
+		return nil
+	}
+	if !*yes && isTerminal(os.Stdin) && isTerminal(os.Stdout) && !confirm(fmt.Sprintf("Cut release %s?", next)) {
+		return fmt.Errorf("release aborted")
+	}
+
+	if err := executeCommand("git", "checkout", "-b", *branch, *from); err != nil {
+		return fmt.Errorf("creating branch %s: %v", *branch, err)
+	}
+	if bumped, err = bumpVersionFiles(files, version, false); err != nil {
+		return err
+	}
+	if err := runChangelog([]string{"--version", next.String(), "--file", *file}); err != nil {
+		return err
+	}
+	if err := executeCommand("git", "add", "--", *file); err != nil {
+		return fmt.Errorf("staging %s: %v", *file, err)
+	}
+	if len(bumped) > 0 {
+		if err := executeCommand("git", append([]string{"add", "--"}, topPathspecs(bumped)...)...); err != nil {
+			return fmt.Errorf("staging version files: %v", err)
+		}
+	}
+
+	changes, err := git.LoadChangeSet("--cached")
+	if err != nil {
+		return err
+	}
+	messageNote = "prepare release " + next.String()
+	pinnedType, pinnedScope = "chore", "release"
+	message := settleParts(changes, conventional.Enforce(messageNote, pinnedType))
+	if !*noAI {
+		if err := checkProvider(); err != nil {
+			return err
+		}
+		fmt.Printf("Generating commit message with %s...\n", currentGenerator().Name())
+		if message, err = suggestCommitMessage(changes, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+		}
+	}
+	if err := gitCommit("-m", message); err != nil {
+		return fmt.Errorf("committing release %s: %v", next, err)
+	}
+	fmt.Printf("Committed on %s: %s\n", *branch, strings.SplitN(message, "\n", 2)[0])
+	if *noPush {
+		return nil
+	}
+	if *remote == "" {
+		*remote = pushRemote(*branch)
+	}
+	if err := executeCommand("git", "push", "--set-upstream", *remote, *branch); err != nil {
+		return fmt.Errorf("pushing %s to %s: %v", *branch, *remote, err)
+	}
+	fmt.Printf("Pushed %s to %s.\n", *branch, *remote)
+	return nil
+}
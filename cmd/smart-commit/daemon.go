@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// maxPregenerated is how many pre-generated messages are kept.
+const maxPregenerated = 20
+
+// runDaemon implements `smart-commit daemon`: it watches the index and,
+// once the staged changes stop changing, generates their message ahead of
+// time, so the next commit gets it instantly.
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Second, "how often to look at the index")
+	settle := fs.Duration("settle", 3*time.Second, "how long the staged changes must stay the same before generating")
+	fs.Parse(args)
+
+	indexPath, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "index")
+	if err != nil {
+		return fmt.Errorf("locating repository: %v", err)
+	}
+	indexPath = strings.TrimSpace(indexPath)
+	if err := checkProvider(); err != nil {
+		return err
+	}
+	fmt.Printf("Watching the index; messages are pre-generated with %s.\n", currentGenerator().Name())
+	watchConfig()
+
+	var seen, changed time.Time
+	for {
+		time.Sleep(*interval)
+		info, err := os.Stat(indexPath)
+		if err != nil {
+			continue
+		}
+		// Wait for the index to stop changing, then generate once
+		if !info.ModTime().Equal(seen) {
+			seen, changed = info.ModTime(), time.Now()
+			continue
+		}
+		if changed.IsZero() || time.Since(changed) < *settle {
+			continue
+		}
+		changed = time.Time{}
+		if err := pregenerate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// pregenerate generates and stores the message for the staged changes,
+// unless one is stored already.
+func pregenerate() error {
+	changes, err := git.LoadChangeSet("--cached")
+	if err != nil || len(changes.Files) == 0 {
+		return err
+	}
+	if _, ok := loadPregenerated(changes); ok {
+		return nil
+	}
+	// suggestCommitMessage stores what it generates
+	message, err := suggestCommitMessage(changes, "")
+	if err != nil {
+		return fmt.Errorf("%s error: %v", currentGenerator().Name(), err)
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	fmt.Printf("Pre-generated for %d staged file(s): %s\n", len(changes.Files), subject)
+	return nil
+}
+
+// pregeneratedDir is where pre-generated messages are stored.
+func pregeneratedDir() (string, error) {
+	dir, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/pregenerated")
+	if err != nil {
+		return "", fmt.Errorf("locating repository: %v", err)
+	}
+	return strings.TrimSpace(dir), nil
+}
+
+// pregeneratedKey identifies the message for changes: a hash of the whole
+// diff, the provider, the model and the prompt, which covers every option
+// in effect.
+func pregeneratedKey(changes *git.ChangeSet) (string, error) {
+	prompt, err := generator.CommitPrompt(changes, commitOptions(changes, ""))
+	if err != nil {
+		return "", err
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return "", err
+	}
+	gen := currentGenerator()
+	model := ""
+	if m, ok := gen.(modelNamer); ok {
+		model = m.Model()
+	}
+	sum := sha256.Sum256([]byte(gen.Name() + "\x00" + model + "\x00" + prompt + "\x00" + string(diff)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadPregenerated returns the message generated earlier for changes, by
+// the daemon or by a run that did not get to commit it.
+func loadPregenerated(changes *git.ChangeSet) (string, bool) {
+	dir, err := pregeneratedDir()
+	if err != nil {
+		return "", false
+	}
+	key, err := pregeneratedKey(changes)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key))
+	if err != nil || len(data) == 0 {
+		return "", false
+	}
+	return string(data), true
+}
+
+// savePregenerated stores message for changes, dropping the oldest
+// messages beyond maxPregenerated.
+func savePregenerated(changes *git.ChangeSet, message string) error {
+	if !writesAllowed() {
+		return nil
+	}
+	dir, err := pregeneratedDir()
+	if err != nil {
+		return err
+	}
+	key, err := pregeneratedKey(changes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, key), []byte(message), 0644); err != nil {
+		return fmt.Errorf("storing pre-generated message: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxPregenerated {
+		return nil
+	}
+	modTime := func(e os.DirEntry) time.Time {
+		info, err := e.Info()
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+	sort.Slice(entries, func(i, j int) bool { return modTime(entries[i]).After(modTime(entries[j])) })
+	for _, e := range entries[maxPregenerated:] {
+		os.Remove(filepath.Join(dir, e.Name()))
+	}
+	return nil
+}
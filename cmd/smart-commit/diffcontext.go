@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// diffBudget is the size limit set by --max-diff; negative when unset.
+var diffBudget = -1
+
+// maxDiffBudget is the size limit for the diff included in commit message
+// prompts: --max-diff, SMART_COMMIT_MAX_DIFF, the max_diff setting or the
+// default. Zero sends file names only.
+func maxDiffBudget() int {
+	if diffBudget >= 0 {
+		return diffBudget
+	}
+	return config.Current().CommitOptions().DiffBudget
+}
+
+// messageNote is the draft message given with -m, expanded rather than
+// replaced by the generated message.
+var messageNote string
+
+// messageWhy is the reason for the change given with --why.
+var messageWhy string
+
+// messageNotes is the content of the --notes file.
+var messageNotes string
+
+// pinnedType and pinnedScope are set by --type and --scope.
+var pinnedType, pinnedScope string
+
+// styleCommits is the number of recent commits set by --style-history;
+// negative when unset.
+var styleCommits = -1
+
+// styleHistory is how many recent commits generated messages imitate:
+// --style-history, SMART_COMMIT_STYLE_HISTORY or the style_history setting.
+func styleHistory() int {
+	if styleCommits >= 0 {
+		return styleCommits
+	}
+	if n, err := strconv.Atoi(os.Getenv("SMART_COMMIT_STYLE_HISTORY")); err == nil && n >= 0 {
+		return n
+	}
+	return config.Current().StyleHistory
+}
+
+// maxStyleExamples bounds the subjects quoted in the prompt as examples.
+const maxStyleExamples = 15
+
+var (
+	styleOnce sync.Once
+	style     string
+)
+
+// commitStyle describes the style of the repository's recent commits, read
+// once per run. It is empty when style learning is off or there is no
+// history yet.
+func commitStyle() string {
+	styleOnce.Do(func() {
+		n := styleHistory()
+		if n <= 0 {
+			return
+		}
+		// The index holds the latest subjects, or all of them when there
+		// are fewer than it keeps
+		var commits []git.Commit
+		if idx := currentIndex(); idx != nil && (n <= len(idx.History) || len(idx.History) < scopeHistory) {
+			if n > len(idx.History) {
+				n = len(idx.History)
+			}
+			for _, c := range idx.History[:n] {
+				commit := git.Commit{Subject: c.Subject}
+				git.ClassifyCommit(&commit)
+				commits = append(commits, commit)
+			}
+		} else {
+			var err error
+			if commits, err = git.LoadCommits("--no-merges", "-n", strconv.Itoa(n)); err != nil {
+				return
+			}
+		}
+		style = generator.DescribeStyle(commits, maxStyleExamples)
+	})
+	return style
+}
+
+// retryHistory holds the messages turned down during review, with the
+// author's feedback, for the next regeneration.
+var retryHistory []generator.Attempt
+
+// commitOptions are the generator options for the commit message of
+// changes, from the flags and settings in effect, with extra context from
+// the author.
+func commitOptions(changes *git.ChangeSet, extra string) generator.Options {
+	cfg := config.Current()
+	return generator.Options{
+		DiffBudget:     maxDiffBudget(),
+		Language:       languageName(commitLanguage()),
+		Body:           wantBody(),
+		Footer:         wantFooter(),
+		Note:           messageNote,
+		Why:            messageWhy,
+		Notes:          messageNotes,
+		Type:           pinnedType,
+		Scope:          pinnedScope,
+		Types:          currentPolicy().Types,
+		Rules:          currentPolicy().describeRules(),
+		Style:          commitStyle(),
+		History:        retryHistory,
+		Extra:          extra,
+		Analysis:       describeAnalysis(changes),
+		Structural:     structuralDiffs(changes),
+		PromptVersion:  commitPromptVersion(),
+		PromptTemplate: cfg.PromptTemplate,
+	}
+}
+
+// commitPromptVersion is the built-in commit-message prompt version in use.
+func commitPromptVersion() int {
+	if v := config.Current().PromptVersion; v != 0 {
+		return v
+	}
+	return generator.LatestPromptVersion(generator.PromptCommitMessage)
+}
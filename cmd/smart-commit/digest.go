@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// runDigest implements `smart-commit digest`, a Markdown summary of recent
+// commits grouped by scope, meant for sprint reviews.
+func runDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	since := fs.String("since", "1w", "how far back to look (e.g. 3d, 1w, 2m, or any date git understands)")
+	authors := fs.String("authors", "me", `whose commits to include: "me", "all", or a comma-separated list of names/emails`)
+	output := fs.String("output", "", "write the digest to this file instead of stdout")
+	slackWebhook := fs.String("slack-webhook", secretEnv("SMART_COMMIT_SLACK_WEBHOOK"), "post the digest to this Slack incoming webhook URL")
+	graphExport := fs.String("graph-export", "", "export the commits as a graph instead of Markdown: json or dot")
+	format := renderFlag(fs, renderMarkdown)
+	fs.Parse(args)
+
+	if err := validateGraphExport(*graphExport); err != nil {
+		return err
+	}
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	logArgs := []string{"--no-merges", "--since=" + gitSince(*since)}
+	authorArgs, err := authorFilters(*authors)
+	if err != nil {
+		return err
+	}
+	logArgs = append(logArgs, authorArgs...)
+
+	commits, err := git.LoadCommits(logArgs...)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	if *graphExport != "" {
+		graph, err := renderCommitGraph(*graphExport, commits)
+		if err != nil {
+			return err
+		}
+		out.WriteString(graph)
+	} else if err := writeReport(&out, *format, digestReport(commits, *since, *authors)); err != nil {
+		return err
+	}
+	digest := out.String()
+
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(digest), 0644); err != nil {
+			return fmt.Errorf("writing digest: %v", err)
+		}
+		fmt.Printf("Digest written to %s\n", *output)
+	} else if *slackWebhook == "" {
+		fmt.Print(digest)
+	}
+
+	if *slackWebhook != "" {
+		// Slack is sent Markdown whatever is printed
+		if *graphExport == "" {
+			digest = digestReport(commits, *since, *authors).markdown()
+		}
+		if err := postToSlack(*slackWebhook, digest); err != nil {
+			return err
+		}
+		fmt.Println("Digest posted to Slack.")
+	}
+	return nil
+}
+
+// relativeSince matches shorthand durations such as 3d, 1w or 2m.
+var relativeSince = regexp.MustCompile(`^(\d+)([hdwmy])$`)
+
+// gitSince converts shorthand durations into a date git understands, passing
+// anything else through unchanged.
+func gitSince(since string) string {
+	m := relativeSince.FindStringSubmatch(since)
+	if m == nil {
+		return since
+	}
+	units := map[string]string{"h": "hours", "d": "days", "w": "weeks", "m": "months", "y": "years"}
+	return fmt.Sprintf("%s %s ago", m[1], units[m[2]])
+}
+
+// authorFilters turns the --authors value into git log --author arguments.
+func authorFilters(authors string) ([]string, error) {
+	switch strings.TrimSpace(authors) {
+	case "", "all", "team":
+		return nil, nil
+	case "me":
+		email, err := executeCommandWithOutput("git", "config", "user.email")
+		if err != nil || strings.TrimSpace(email) == "" {
+			return nil, fmt.Errorf("cannot resolve \"me\": git user.email is not configured")
+		}
+		return []string{"--fixed-strings", "--author=" + strings.TrimSpace(email)}, nil
+	}
+
+	args := []string{"--fixed-strings"}
+	for _, author := range strings.Split(authors, ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			args = append(args, "--author="+author)
+		}
+	}
+	return args, nil
+}
+
+// digestReport is the digest of commits, one section per scope.
+func digestReport(commits []git.Commit, since, authors string) report {
+	var r report
+	r.heading(1, "Commit digest (since %s, authors: %s)", since, authors)
+
+	if len(commits) == 0 {
+		r.paragraph("_No commits in this period._")
+		return r
+	}
+
+	groups := map[string][]git.Commit{}
+	for _, c := range commits {
+		scope := c.Scope
+		if scope == "" {
+			scope = "general"
+		}
+		groups[scope] = append(groups[scope], c)
+	}
+
+	scopes := make([]string, 0, len(groups))
+	for scope := range groups {
+		scopes = append(scopes, scope)
+	}
+	// Busiest scopes first, then alphabetical for a stable order
+	sort.Slice(scopes, func(i, j int) bool {
+		if len(groups[scopes[i]]) != len(groups[scopes[j]]) {
+			return len(groups[scopes[i]]) > len(groups[scopes[j]])
+		}
+		return scopes[i] < scopes[j]
+	})
+
+	for _, scope := range scopes {
+		r.heading(2, "%s (%d)", scope, len(groups[scope]))
+		var items []string
+		for _, c := range groups[scope] {
+			label := c.Type
+			if label == "" {
+				label = "other"
+			}
+			if c.Breaking {
+				label += "!"
+			}
+			items = append(items, fmt.Sprintf("**%s**: %s (`%s`, %s, %s)", label, c.Description, git.ShortHash(c.Hash), c.Author, c.Date))
+		}
+		r.list(items)
+	}
+
+	r.paragraph("_%d commits across %d scopes._", len(commits), len(scopes))
+	return r
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// commitMessageFiles are the files git asks the editor to edit for a commit
+// message; anything else, such as a rebase todo list, is handed straight to
+// the real editor.
+var commitMessageFiles = []string{"COMMIT_EDITMSG", "MERGE_MSG", "SQUASH_MSG"}
+
+// runEditMsg implements `smart-commit edit-msg FILE`, for use as the editor
+// (GIT_EDITOR="smart-commit edit-msg"). It writes a generated message into
+// the file, keeping git's comment section, then opens the real editor on it.
+func runEditMsg(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: GIT_EDITOR=\"smart-commit edit-msg\" git commit|rebase|merge ...")
+	}
+	path := args[0]
+	if contains(commitMessageFiles, filepath.Base(path)) {
+		// The real editor still opens when generation fails
+		if err := suggestIntoMessageFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "smart-commit: %v\n", err)
+		}
+	}
+	return openEditor(path)
+}
+
+// suggestIntoMessageFile puts a generated message at the top of a commit
+// message file. A message already in the file, such as the one being
+// reworded or git's merge message, is kept below it as a comment.
+func suggestIntoMessageFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	comment := git.CommentChar()
+	existing, comments := splitMessageFile(string(data), comment)
+
+	// A new commit or a merge is described by what is staged. When amending
+	// or rewording, the file holds HEAD's message and the whole commit being
+	// rewritten is described
+	args := []string{"--cached"}
+	if existing != "" && git.RefExists("HEAD^") {
+		if head, err := executeCommandWithOutput("git", "log", "-1", "--format=%B", "HEAD"); err == nil && strings.TrimSpace(head) == existing {
+			args = append(args, "HEAD^")
+		}
+	}
+	changes, err := git.LoadChangeSet(args...)
+	if err != nil {
+		return err
+	}
+	if len(changes.Files) == 0 {
+		return nil
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Generating commit message with %s...\n", currentGenerator().Name())
+	message, err := suggestCommitMessage(changes, "")
+	if err != nil {
+		return fmt.Errorf("%s error: %v", currentGenerator().Name(), err)
+	}
+	if existing == message {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(message + "\n\n")
+	if existing != "" {
+		fmt.Fprintf(&b, "%s Previous message:\n", comment)
+		for _, line := range strings.Split(existing, "\n") {
+			fmt.Fprintf(&b, "%s %s\n", comment, line)
+		}
+		fmt.Fprintf(&b, "%s\n", comment)
+	}
+	b.WriteString(comments)
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// splitMessageFile separates a commit message file into the message and the
+// comment section git appends, which starts at the first comment line.
+func splitMessageFile(data, comment string) (message, comments string) {
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, comment) {
+			return strings.TrimSpace(strings.Join(lines[:i], "\n")), strings.Join(lines[i:], "\n")
+		}
+	}
+	return strings.TrimSpace(data), ""
+}
+
+// realEditor finds the editor edit-msg hands over to: SMART_COMMIT_EDITOR,
+// else the editor git would use were smart-commit not set as the editor.
+func realEditor() string {
+	if editor := os.Getenv("SMART_COMMIT_EDITOR"); editor != "" {
+		return editor
+	}
+	os.Unsetenv("GIT_EDITOR")
+	if out, err := executeCommandWithOutput("git", "var", "GIT_EDITOR"); err == nil {
+		if editor := strings.TrimSpace(out); editor != "" && !strings.Contains(editor, "edit-msg") {
+			return editor
+		}
+	}
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" && !strings.Contains(editor, "edit-msg") {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// openEditor runs the real editor on path.
+func openEditor(path string) error {
+	editor := realEditor()
+	cmd := shellCommand(editor + " " + shellQuote(path))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return fmt.Errorf("editor %q failed: %v", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// evalSample is the outcome of regenerating the message of one commit.
+type evalSample struct {
+	Hash       string        `json:"hash"`
+	Human      string        `json:"human"`
+	Generated  string        `json:"generated"`
+	Compliant  bool          `json:"compliant"`
+	TypeMatch  bool          `json:"type_match"`
+	Similarity float64       `json:"similarity"`
+	Latency    time.Duration `json:"latency_ns"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// evalReport aggregates the samples of an evaluation run.
+type evalReport struct {
+	Provider       string        `json:"provider"`
+	PromptVersion  int           `json:"prompt_version"`
+	Samples        []evalSample  `json:"samples"`
+	Evaluated      int           `json:"evaluated"`
+	Failed         int           `json:"failed"`
+	ComplianceRate float64       `json:"compliance_rate"`
+	TypeMatchRate  float64       `json:"type_match_rate"`
+	MeanSimilarity float64       `json:"mean_similarity"`
+	LatencyP50     time.Duration `json:"latency_p50_ns"`
+	LatencyP95     time.Duration `json:"latency_p95_ns"`
+}
+
+// runEval implements `smart-commit eval`: regenerate the messages of recent
+// commits with the selected provider and measure how they compare with what
+// the authors wrote.
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	revRange := fs.String("range", "HEAD", "commits to evaluate, as accepted by git log")
+	limit := fs.Int("n", 20, "evaluate at most this many commits")
+	version := fs.Int("version", 0, "commit-message prompt version to evaluate (default: the configured one)")
+	format := renderFlag(fs, "")
+	legacyFormat := fs.String("format", "", "text or json; same as --render plain or json")
+	fs.Parse(args)
+
+	switch *legacyFormat {
+	case "":
+	case "text":
+		*format = renderPlain
+	case "json":
+		*format = renderJSON
+	default:
+		return fmt.Errorf("unknown format %q: expected text or json", *legacyFormat)
+	}
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+	if *version == 0 {
+		*version = commitPromptVersion()
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+
+	commits, err := git.LoadCommits("--no-merges", "-n", fmt.Sprint(*limit), *revRange)
+	if err != nil {
+		return err
+	}
+
+	results := evalReport{Provider: currentGenerator().Name(), PromptVersion: *version}
+	fmt.Fprintf(os.Stderr, "Evaluating %s v%d with %s on %d commits...\n", generator.PromptCommitMessage, *version, results.Provider, len(commits))
+	for _, c := range commits {
+		// Root commits have nothing to diff against
+		if len(c.Parents) == 0 {
+			continue
+		}
+		results.Samples = append(results.Samples, evaluateCommit(c, *version))
+	}
+	summarizeEval(&results)
+	return printReport(*format, evalOutput(results))
+}
+
+// evalOutput lays out an evaluation: a row per commit, then the metrics.
+func evalOutput(e evalReport) report {
+	r := report{Data: e}
+	r.heading(1, "%s v%d with %s", generator.PromptCommitMessage, e.PromptVersion, e.Provider)
+	var rows [][]string
+	for _, s := range e.Samples {
+		if s.Error != "" {
+			rows = append(rows, []string{git.ShortHash(s.Hash), "error", "-", "-", s.Error, s.Human})
+			continue
+		}
+		lint := "ok"
+		if !s.Compliant {
+			lint = "fails"
+		}
+		rows = append(rows, []string{git.ShortHash(s.Hash), lint, fmt.Sprintf("%.2f", s.Similarity), s.Latency.Round(time.Millisecond).String(), s.Generated, s.Human})
+	}
+	if len(rows) > 0 {
+		r.table([]string{"Commit", "Lint", "Similarity", "Latency", "Generated", "Human"}, rows)
+	}
+	r.paragraph("%d commits evaluated, %d failed to generate", e.Evaluated, e.Failed)
+	r.list([]string{
+		fmt.Sprintf("Format compliance: **%.0f%%**", e.ComplianceRate*100),
+		fmt.Sprintf("Type agreement: **%.0f%%**", e.TypeMatchRate*100),
+		fmt.Sprintf("Subject similarity: **%.2f**", e.MeanSimilarity),
+		fmt.Sprintf("Latency: p50 %s, p95 %s", e.LatencyP50.Round(time.Millisecond), e.LatencyP95.Round(time.Millisecond)),
+	})
+	return r
+}
+
+// evaluateCommit regenerates the message of a commit from its diff and
+// scores it against the message the author wrote.
+func evaluateCommit(c git.Commit, version int) evalSample {
+	s := evalSample{Hash: c.Hash, Human: c.Subject}
+	changes, err := git.LoadChangeSet(c.Parents[0], c.Hash)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	prompt, err := generator.RenderPromptVersion(generator.PromptCommitMessage, version, generator.PromptData(changes, commitOptions(changes, "")))
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+
+	start := time.Now()
+	message, err := askModel(prompt)
+	s.Latency = time.Since(start)
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+
+	s.Generated = strings.SplitN(message, "\n", 2)[0]
+	s.Compliant = len(lintMessage(message)) == 0
+	generatedType, _, generatedDesc, _, _ := conventional.ParseSubject(s.Generated)
+	s.TypeMatch = c.Type != "" && generatedType == c.Type
+	s.Similarity = subjectSimilarity(c.Description, generatedDesc)
+	return s
+}
+
+// summarizeEval fills in the aggregate metrics of a report. Failed samples
+// are excluded from every rate.
+func summarizeEval(r *evalReport) {
+	var latencies []time.Duration
+	var compliant, typed, typeMatches int
+	var similarity float64
+	for _, s := range r.Samples {
+		if s.Error != "" {
+			r.Failed++
+			continue
+		}
+		r.Evaluated++
+		latencies = append(latencies, s.Latency)
+		similarity += s.Similarity
+		if s.Compliant {
+			compliant++
+		}
+		if _, _, _, _, ok := conventional.ParseSubject(s.Human); ok {
+			typed++
+			if s.TypeMatch {
+				typeMatches++
+			}
+		}
+	}
+	if r.Evaluated == 0 {
+		return
+	}
+	r.ComplianceRate = float64(compliant) / float64(r.Evaluated)
+	r.MeanSimilarity = similarity / float64(r.Evaluated)
+	if typed > 0 {
+		r.TypeMatchRate = float64(typeMatches) / float64(typed)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.LatencyP50 = percentile(latencies, 50)
+	r.LatencyP95 = percentile(latencies, 95)
+}
+
+// percentile returns the p-th percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// subjectSimilarity scores how alike two subjects are as the Dice
+// coefficient of their lowercased word sets: 1 for the same words, 0 for
+// none in common.
+func subjectSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa)+len(wb) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wa)+len(wb))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// explainRevision asks the AI provider to explain in plain language what a
+// commit in repo does, based on its message and a size-limited patch, with
+// the provider of requests made with ctx.
+func explainRevision(ctx context.Context, repo git.Repo, rev string) (string, error) {
+	show, err := repo.Output("show", "--stat", "--patch", "--format=%B", rev)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %v", rev, err)
+	}
+	return askModelContext(ctx, generator.RenderPrompt(generator.PromptExplain, map[string]string{"Show": truncate(show, 8000)}))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// fileNotesRef is the notes ref per-file summaries are kept under, where
+// review tooling reads them with git notes --ref=smart-commit-files show.
+const fileNotesRef = "smart-commit-files"
+
+// fileNote is the summary of a changed file.
+type fileNote struct {
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+}
+
+// summarizeFiles asks the model for a short summary of each changed file,
+// given the commit message it goes with.
+func summarizeFiles(message string, changes *git.ChangeSet) (map[string]string, error) {
+	answer, err := askModel(generator.RenderPrompt(generator.PromptFileSummaries, map[string]string{
+		"Message": message,
+		"Changes": changes.Describe(),
+		"Diff":    generator.StructuredDiffContext(changes, maxDiffBudget(), structuralDiffs(changes)),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("summarizing files: %v", err)
+	}
+	return parseFileSummaries(answer, changes.Paths()), nil
+}
+
+// listMarker matches the bullet or number starting a list item.
+var listMarker = regexp.MustCompile(`^([-*•]|\d+[.)])\s+`)
+
+// parseFileSummaries reads "path: summary" lines, leniently: list markers
+// and quotes around the path are dropped, and lines about files that did
+// not change are ignored.
+func parseFileSummaries(answer string, paths []string) map[string]string {
+	summaries := map[string]string{}
+	for _, line := range strings.Split(answer, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		for _, p := range paths {
+			for _, quoted := range []string{p, "`" + p + "`", "**" + p + "**"} {
+				if rest, ok := strings.CutPrefix(line, quoted+":"); ok && strings.TrimSpace(rest) != "" {
+					summaries[p] = strings.TrimSpace(rest)
+				}
+			}
+		}
+	}
+	return summaries
+}
+
+// runFileNotes summarizes the changed files for --file-notes and, when
+// save is set, attaches the summaries to HEAD. Failures only warn, as the
+// commit is made either way.
+func runFileNotes(message string, changes *git.ChangeSet, save bool) []fileNote {
+	summaries, err := summarizeFiles(message, changes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	notes := fileNotes(summaries, changes)
+	if save {
+		if err := saveFileNotes(notes); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return notes
+}
+
+// fileNotes lists the summaries in the order of the changed files.
+func fileNotes(summaries map[string]string, changes *git.ChangeSet) []fileNote {
+	var notes []fileNote
+	for _, p := range changes.Paths() {
+		if s, ok := summaries[p]; ok {
+			notes = append(notes, fileNote{Path: p, Summary: s})
+		}
+	}
+	return notes
+}
+
+// saveFileNotes attaches the summaries to HEAD as a git note holding
+// {"files": [{"path": ..., "summary": ...}]}.
+func saveFileNotes(notes []fileNote) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(map[string][]fileNote{"files": notes})
+	if err != nil {
+		return err
+	}
+	if _, err := git.Output("notes", "--ref", fileNotesRef, "add", "-f", "-m", string(data), "HEAD"); err != nil {
+		return fmt.Errorf("saving file notes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFileSummaries(t *testing.T) {
+	answer := "Here are the summaries:\n" +
+		"- `api/users.go`: add the users endpoint\n" +
+		"api/users_test.go: cover listing users\n" +
+		"**README.md**: document the endpoint\n" +
+		"other.go: not changed\n" +
+		"go.mod:"
+	got := parseFileSummaries(answer, []string{"api/users.go", "api/users_test.go", "README.md", "go.mod"})
+	want := map[string]string{
+		"api/users.go":      "add the users endpoint",
+		"api/users_test.go": "cover listing users",
+		"README.md":         "document the endpoint",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFileSummaries() = %v, want %v", got, want)
+	}
+}
+
+func TestCommitFlowFileNotes(t *testing.T) {
+	repo, env := testRepo(t)
+	os.WriteFile(filepath.Join(repo, "hello.go"), []byte("package main\n"), 0644)
+	out, err := runCLI(t, repo, env, fakeProvider(t, "feat: add hello\n\nhello.go: start the program"), "--yes", "--no-push", "--file-notes")
+	if err != nil {
+		t.Fatalf("smart-commit: %v\n%s", err, out)
+	}
+	want := `{"files":[{"path":"hello.go","summary":"start the program"}]}`
+	if got := runGit(t, repo, env, "notes", "--ref", fileNotesRef, "show", "HEAD"); strings.TrimSpace(got) != want {
+		t.Errorf("file notes = %q, want %q", got, want)
+	}
+}
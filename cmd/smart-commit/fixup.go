@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// maxFixupCandidates is how much recent history the fixup picker offers.
+const maxFixupCandidates = 15
+
+// resolveFixupTarget returns the full hash of the commit a fixup targets:
+// rev when given, otherwise the one the user picks from recent history.
+func resolveFixupTarget(rev string, changes *git.ChangeSet, interactive bool) (git.Commit, error) {
+	if rev != "" {
+		commits, err := git.LoadCommits("-n", "1", rev+"^{commit}", "--")
+		if err != nil || len(commits) == 0 {
+			return git.Commit{}, fmt.Errorf("unknown --fixup revision %q", rev)
+		}
+		return commits[0], nil
+	}
+	if !interactive {
+		return git.Commit{}, fmt.Errorf("--fixup needs a revision when not run interactively, e.g. --fixup HEAD~2")
+	}
+	return pickFixupTarget(changes)
+}
+
+// pickFixupTarget lists recent commits, marking the ones that touched the
+// staged files, and returns the one the user picks.
+func pickFixupTarget(changes *git.ChangeSet) (git.Commit, error) {
+	commits, err := git.LoadCommits("--no-merges", "-n", strconv.Itoa(maxFixupCandidates))
+	if err != nil {
+		return git.Commit{}, err
+	}
+	if len(commits) == 0 {
+		return git.Commit{}, fmt.Errorf("no commits to fix up")
+	}
+	touched := map[string]bool{}
+	logArgs := append([]string{"log", "--format=%H", "-n", strconv.Itoa(maxFixupCandidates), "--"}, changes.Paths()...)
+	if out, err := executeCommandWithOutput("git", logArgs...); err == nil {
+		for _, hash := range strings.Fields(out) {
+			touched[hash] = true
+		}
+	}
+
+	fmt.Println("Recent commits (* touched the staged files):")
+	for i, c := range commits {
+		mark := " "
+		if touched[c.Hash] {
+			mark = "*"
+		}
+		fmt.Printf("  %2d) %s %s %s\n", i+1, mark, git.ShortHash(c.Hash), c.Subject)
+	}
+	answer, err := promptLine("Commit to fix up: ")
+	if err != nil {
+		return git.Commit{}, err
+	}
+	chosen, err := parseSelection(answer, len(commits))
+	if err != nil {
+		return git.Commit{}, err
+	}
+	if len(chosen) != 1 {
+		return git.Commit{}, fmt.Errorf("pick one commit to fix up")
+	}
+	return commits[chosen[0]], nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import "strings"
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// optionalString is a flag.Value for a flag whose value may be left out:
+// "--fixup" alone sets Given, "--fixup=REV" also sets Value. As the flag
+// package treats it like a boolean, "--fixup REV" leaves REV as the first
+// argument; see takeArg.
+type optionalString struct {
+	Given bool
+	Value string
+}
+
+func (o *optionalString) String() string {
+	if o == nil {
+		return ""
+	}
+	return o.Value
+}
+
+func (o *optionalString) Set(value string) error {
+	o.Given = true
+	if value != "true" {
+		o.Value = value
+	}
+	return nil
+}
+
+func (o *optionalString) IsBoolFlag() bool { return true }
+
+// takeArg fills in the value of a given flag from the first argument, for
+// the "--fixup REV" form.
+func (o *optionalString) takeArg(args []string) []string {
+	if o.Given && o.Value == "" && len(args) > 0 {
+		o.Value = args[0]
+		return args[1:]
+	}
+	return args
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// githubHost returns the GitHub host to talk to: the github_host setting
+// (SMART_COMMIT_GITHUB_HOST), gh's GH_HOST, the host of the GitHub remote
+// being worked with, or github.com.
+func githubHost() string {
+	for _, host := range []string{config.Setting("SMART_COMMIT_GITHUB_HOST", config.Current().GitHubHost), os.Getenv("GH_HOST")} {
+		if host = normalizeHost(host); host != "" {
+			return host
+		}
+	}
+	if remote, err := githubRemote(); err == nil {
+		return remote.Host
+	}
+	return "github.com"
+}
+
+// normalizeHost reduces a host setting that may be written as a URL, such
+// as https://github.example.com/, to the host name.
+func normalizeHost(host string) string {
+	host = strings.TrimSpace(strings.ToLower(host))
+	if _, rest, ok := strings.Cut(host, "://"); ok {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
+
+// isGitHubHost reports whether a remote on host is on GitHub: github.com,
+// GHE.com, the configured Enterprise Server, or a host named like one.
+func isGitHubHost(host string) bool {
+	if host == "github.com" || strings.HasSuffix(host, ".ghe.com") {
+		return true
+	}
+	for _, configured := range []string{config.Setting("SMART_COMMIT_GITHUB_HOST", config.Current().GitHubHost), os.Getenv("GH_HOST")} {
+		if configured = normalizeHost(configured); configured != "" && host == configured {
+			return true
+		}
+	}
+	return (&git.Remote{Host: host}).Platform() == "github"
+}
+
+// githubRemote returns the GitHub repository being worked with: the remote
+// the current branch tracks, else origin.
+func githubRemote() (*git.Remote, error) {
+	name := "origin"
+	if branch, err := git.CurrentBranch(); err == nil {
+		if out, err := executeCommandWithOutput("git", "config", "branch."+branch+".remote"); err == nil && strings.TrimSpace(out) != "" {
+			name = strings.TrimSpace(out)
+		}
+	}
+	remote, err := git.RemoteURL(name)
+	if err != nil {
+		return nil, err
+	}
+	if !isGitHubHost(remote.Host) {
+		return nil, fmt.Errorf("%s %s is not a GitHub repository (set github_host for a GitHub Enterprise Server)", name, remote.WebURL())
+	}
+	return remote, nil
+}
+
+// githubAPIURL is the REST API root for host: api.github.com, api.<host>
+// on GHE.com and https://<host>/api/v3 on Enterprise Server.
+// GITHUB_API_URL, which GitHub Actions sets, takes precedence.
+func githubAPIURL(host string) string {
+	if url := os.Getenv("GITHUB_API_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	switch {
+	case host == "github.com":
+		return "https://api.github.com"
+	case strings.HasSuffix(host, ".ghe.com"):
+		return "https://api." + host
+	}
+	return "https://" + host + "/api/v3"
+}
+
+// ghCommand builds a gh command for the GitHub host in use, which gh
+// otherwise only finds on its own from the repository's remotes.
+func ghCommand(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(interrupted, "gh", args...)
+	if host := githubHost(); host != "github.com" {
+		cmd.Env = append(os.Environ(), "GH_HOST="+host)
+	}
+	return cmd
+}
+
+// githubAPI performs a GitHub REST call. in is sent as the JSON body when not
+// nil, and the JSON response is decoded into out when not nil.
+func githubAPI(method, path, token string, in, out interface{}) error {
+	_, err := githubAPIPage(method, path, token, in, out)
+	return err
+}
+
+// githubAPIPage is githubAPI for a page of a list, also returning the path
+// of the next page, or "" on the last one.
+func githubAPIPage(method, path, token string, in, out interface{}) (string, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	base := githubAPIURL(githubHost())
+	req, err := http.NewRequestWithContext(interrupted, method, base+path, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GitHub %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("GitHub %s %s: unexpected status %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	next := nextPage(resp.Header.Get("Link"), base)
+	if out == nil {
+		return next, nil
+	}
+	return next, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// nextPage returns the path of the rel="next" link of a Link header, or ""
+// when there is none. Only links into base, the API, are followed, so the
+// token goes nowhere else.
+func nextPage(link, base string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		if strings.HasPrefix(target, base+"/") {
+			return strings.TrimPrefix(target, base)
+		}
+	}
+	return ""
+}
+
+// commitFromMessage builds a git.Commit from a hash and a full commit
+// message, as delivered by the GitHub API rather than git log.
+func commitFromMessage(hash, message string) git.Commit {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	c := git.Commit{Hash: hash, Subject: subject, Body: strings.TrimSpace(body)}
+	git.ClassifyCommit(&c)
+	return c
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitHubHosts(t *testing.T) {
+	t.Setenv("SMART_COMMIT_GITHUB_HOST", "https://git.corp.example/")
+	t.Setenv("GH_HOST", "")
+	t.Setenv("GITHUB_API_URL", "")
+	tests := []struct {
+		host   string
+		github bool
+		api    string
+	}{
+		{"github.com", true, "https://api.github.com"},
+		{"octo.ghe.com", true, "https://api.octo.ghe.com"},
+		{"git.corp.example", true, "https://git.corp.example/api/v3"},
+		{"github.acme.internal", true, "https://github.acme.internal/api/v3"},
+		{"gitlab.com", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := isGitHubHost(tt.host); got != tt.github {
+				t.Errorf("isGitHubHost(%q) = %v, want %v", tt.host, got, tt.github)
+			}
+			if tt.github {
+				if got := githubAPIURL(tt.host); got != tt.api {
+					t.Errorf("githubAPIURL(%q) = %q, want %q", tt.host, got, tt.api)
+				}
+			}
+		})
+	}
+	if got := githubHost(); got != "git.corp.example" {
+		t.Errorf("githubHost() = %q, want the configured host", got)
+	}
+}
+
+func TestGitHubToken(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	// Neither gh nor a git credential helper answers
+	t.Setenv("PATH", dir)
+	for _, name := range []string{"GH_HOST", "GITHUB_API_URL", "GITHUB_SERVER_URL", "GITHUB_TOKEN", "GH_TOKEN", "GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	if err := saveCredentials(map[string]storedToken{"github": {AccessToken: "device-login"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, host string
+		env        map[string]string
+		want       string
+	}{
+		{"github.com variable", "github.com", map[string]string{"GITHUB_TOKEN": "dotcom"}, "dotcom"},
+		{"github.com device login", "github.com", nil, "device-login"},
+		{"github.com variable elsewhere", "git.corp.example", map[string]string{"GITHUB_TOKEN": "dotcom", "GH_TOKEN": "dotcom"}, ""},
+		{"enterprise variable", "git.corp.example", map[string]string{"GITHUB_TOKEN": "dotcom", "GH_ENTERPRISE_TOKEN": "corp"}, "corp"},
+		{"actions on the host", "git.corp.example", map[string]string{"GITHUB_TOKEN": "actions", "GITHUB_SERVER_URL": "https://git.corp.example"}, "actions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SMART_COMMIT_GITHUB_HOST", tt.host)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if got := githubToken(); got != tt.want {
+				t.Errorf("githubToken() for %s = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubHostFromRepository(t *testing.T) {
+	repo, env := testRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, ".smartcommit.yml"), []byte("github_host: evil.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env = append(env, "SMART_COMMIT_GITHUB_HOST=", "GH_HOST=")
+	out, err := runCLI(t, repo, env, "", "config", "get", "github_host")
+	if err != nil || strings.Contains(out, "evil.example\n") || !strings.Contains(out, "Warning: ignoring github_host") {
+		t.Errorf("config get github_host = %q, %v; want the repository's host ignored", out, err)
+	}
+	if out, err := runCLI(t, repo, env, "", "config", "set", "github_host", "evil.example"); err == nil {
+		t.Errorf("config set github_host in the repository succeeded: %s", out)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// graphNode is a vertex of the exported commit graph: a commit, or one of the
+// types, scopes and tickets commits link to.
+type graphNode struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+}
+
+// graphEdge links a commit to its parent, type, scope or ticket.
+type graphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// commitGraph is the structured form of a set of commits for dashboards.
+type commitGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// graphExportFormats lists the values accepted by --graph-export.
+var graphExportFormats = []string{"json", "dot"}
+
+// validateGraphExport checks a --graph-export value; empty means disabled.
+func validateGraphExport(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range graphExportFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported --graph-export format %q (expected %s)", format, strings.Join(graphExportFormats, " or "))
+}
+
+// buildCommitGraph turns commits into nodes and edges. Parent edges are only
+// kept when the parent is part of the exported set.
+func buildCommitGraph(commits []git.Commit) commitGraph {
+	var g commitGraph
+	inSet := map[string]bool{}
+	for _, c := range commits {
+		inSet[c.Hash] = true
+	}
+
+	seen := map[string]bool{}
+	addNode := func(id, kind, label string) {
+		if !seen[id] {
+			seen[id] = true
+			g.Nodes = append(g.Nodes, graphNode{ID: id, Kind: kind, Label: label})
+		}
+	}
+
+	for _, c := range commits {
+		addNode(c.Hash, "commit", c.Subject)
+		for _, p := range c.Parents {
+			if inSet[p] {
+				g.Edges = append(g.Edges, graphEdge{From: c.Hash, To: p, Kind: "parent"})
+			}
+		}
+		if c.Type != "" {
+			addNode("type:"+c.Type, "type", c.Type)
+			g.Edges = append(g.Edges, graphEdge{From: c.Hash, To: "type:" + c.Type, Kind: "type"})
+		}
+		if c.Scope != "" {
+			addNode("scope:"+c.Scope, "scope", c.Scope)
+			g.Edges = append(g.Edges, graphEdge{From: c.Hash, To: "scope:" + c.Scope, Kind: "scope"})
+		}
+		for _, t := range c.Tickets {
+			addNode("ticket:"+t, "ticket", t)
+			g.Edges = append(g.Edges, graphEdge{From: c.Hash, To: "ticket:" + t, Kind: "ticket"})
+		}
+	}
+
+	sort.SliceStable(g.Nodes, func(i, j int) bool { return g.Nodes[i].Kind < g.Nodes[j].Kind })
+	return g
+}
+
+// renderCommitGraph serializes the commit graph in the requested format.
+func renderCommitGraph(format string, commits []git.Commit) (string, error) {
+	g := buildCommitGraph(commits)
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "dot":
+		var b strings.Builder
+		b.WriteString("digraph commits {\n")
+		b.WriteString("  rankdir=LR;\n")
+		shapes := map[string]string{"commit": "box", "type": "ellipse", "scope": "folder", "ticket": "note"}
+		for _, n := range g.Nodes {
+			label := n.Label
+			if n.Kind == "commit" {
+				label = git.ShortHash(n.ID) + " " + label
+			}
+			fmt.Fprintf(&b, "  %q [label=%q, shape=%s];\n", n.ID, label, shapes[n.Kind])
+		}
+		for _, e := range g.Edges {
+			fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Kind)
+		}
+		b.WriteString("}\n")
+		return b.String(), nil
+	}
+	return "", validateGraphExport(format)
+}
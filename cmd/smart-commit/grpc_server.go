@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	smartcommitv1 "github.com/chalfel/smart-commit/api/smartcommit/v1"
+	"github.com/chalfel/smart-commit/git"
+	"github.com/chalfel/smart-commit/protocol"
+	"github.com/chalfel/smart-commit/server"
+)
+
+// grpcServer implements the SmartCommit gRPC service on top of the same
+// helpers the CLI and the stdio server use.
+type grpcServer struct {
+	smartcommitv1.UnimplementedSmartCommitServer
+	tenants []*tenant
+}
+
+// serveGRPC listens on addr and serves the SmartCommit service until the
+// listener fails. With tenants, every call needs one's token; without, the
+// service is only served on the loopback interface.
+func serveGRPC(addr string, tenants []*tenant) error {
+	lis, err := server.Listen(addr, tenants != nil)
+	if err != nil {
+		return fmt.Errorf("%v; pass --tenants", err)
+	}
+	interceptors := []grpc.UnaryServerInterceptor{grpcMetrics}
+	if tenants != nil {
+		interceptors = append(interceptors, grpcTenants(tenants))
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	smartcommitv1.RegisterSmartCommitServer(server, &grpcServer{tenants: tenants})
+	fmt.Fprintf(os.Stderr, "Serving gRPC on %s\n", lis.Addr())
+	return server.Serve(lis)
+}
+
+// grpcMetrics records every call in the server metrics.
+func grpcMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.observeRequest("grpc", path.Base(info.FullMethod), status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+// grpcTenants authenticates calls by the "authorization: Bearer TOKEN"
+// metadata, keeps each tenant to the repositories in its directories and
+// runs calls on behalf of the tenant the token names.
+func grpcTenants(tenants []*tenant) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		token := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+			token = strings.TrimPrefix(md.Get("authorization")[0], "Bearer ")
+		}
+		t := findTenant(tenants, token)
+		if t == nil {
+			return nil, status.Error(codes.Unauthenticated, "a valid tenant token is required")
+		}
+		if r, ok := req.(interface{ GetDir() string }); ok && !t.allowsDir(r.GetDir()) {
+			return nil, status.Errorf(codes.PermissionDenied, "%q is not in a directory of tenant %s", r.GetDir(), t.Name)
+		}
+		if err := t.admit(); err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return handler(withTenant(ctx, t), req)
+	}
+}
+
+// Suggest answers in a process of its own started in the repository, see
+// inRepository, with the tenant's provider settings.
+func (s *grpcServer) Suggest(ctx context.Context, req *smartcommitv1.SuggestRequest) (*smartcommitv1.SuggestResponse, error) {
+	var env []string
+	if t := tenantFrom(ctx); t != nil {
+		env = t.environ(s.tenants)
+	}
+	c, err := inRepository(req.GetDir(), env)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	defer c.Close()
+	result, err := c.Suggest("")
+	if perr, ok := err.(*protocol.Error); ok && perr.Message == errNothingStaged.Error() {
+		return nil, status.Error(codes.FailedPrecondition, perr.Message)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &smartcommitv1.SuggestResponse{Message: result.Message, Fallback: result.Fallback}, nil
+}
+
+func (s *grpcServer) Lint(ctx context.Context, req *smartcommitv1.LintRequest) (*smartcommitv1.LintResponse, error) {
+	resp := &smartcommitv1.LintResponse{Valid: true}
+	for _, p := range lintMessage(req.GetMessage()) {
+		resp.Valid = false
+		resp.Problems = append(resp.Problems, &smartcommitv1.LintProblem{
+			Line:    int32(p.Line),
+			Rule:    p.Rule,
+			Message: p.Message,
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) Explain(ctx context.Context, req *smartcommitv1.ExplainRequest) (*smartcommitv1.ExplainResponse, error) {
+	revision := req.GetRevision()
+	if revision == "" {
+		revision = "HEAD"
+	}
+	repo := git.Repo{Dir: req.GetDir()}
+	hash, err := repo.ResolveCommit(revision)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	explanation, err := explainRevision(ctx, repo, hash)
+	return &smartcommitv1.ExplainResponse{Explanation: explanation}, grpcError(err)
+}
+
+func (s *grpcServer) Changelog(ctx context.Context, req *smartcommitv1.ChangelogRequest) (*smartcommitv1.ChangelogResponse, error) {
+	to := req.GetTo()
+	if to == "" {
+		to = "HEAD"
+	}
+	title := req.GetTitle()
+	if title == "" {
+		title = to
+	}
+
+	repo := git.Repo{Dir: req.GetDir()}
+	revRange, err := repo.ResolveCommit(to)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.GetFrom() != "" {
+		from, err := repo.ResolveCommit(req.GetFrom())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		revRange = from + ".." + revRange
+	}
+	commits, err := repo.LoadCommits("--no-merges", revRange)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &smartcommitv1.ChangelogResponse{Markdown: renderChangelog(title, commits)}, nil
+}
+
+// grpcError passes status errors through and wraps anything else as Internal.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
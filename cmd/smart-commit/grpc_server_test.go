@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	smartcommitv1 "github.com/chalfel/smart-commit/api/smartcommit/v1"
+)
+
+func TestGRPCRevisions(t *testing.T) {
+	repo, _ := testRepo(t)
+	written := filepath.Join(t.TempDir(), "written")
+	s := &grpcServer{}
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"changelog", func() error {
+			resp, err := s.Changelog(ctx, &smartcommitv1.ChangelogRequest{Dir: repo, Title: "v1.0.0"})
+			if err == nil && !strings.Contains(resp.GetMarkdown(), "init") {
+				t.Errorf("changelog = %q, want the init commit", resp.GetMarkdown())
+			}
+			return err
+		}, codes.OK},
+		{"option as to", func() error {
+			_, err := s.Changelog(ctx, &smartcommitv1.ChangelogRequest{Dir: repo, To: "--output=" + written})
+			return err
+		}, codes.InvalidArgument},
+		{"option as from", func() error {
+			_, err := s.Changelog(ctx, &smartcommitv1.ChangelogRequest{Dir: repo, From: "--output=" + written})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown to", func() error {
+			_, err := s.Changelog(ctx, &smartcommitv1.ChangelogRequest{Dir: repo, To: "nonesuch"})
+			return err
+		}, codes.InvalidArgument},
+		{"option as revision", func() error {
+			_, err := s.Explain(ctx, &smartcommitv1.ExplainRequest{Dir: repo, Revision: "--output=" + written})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.call()); got != tt.want {
+			t.Errorf("%s: code %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if _, err := os.Stat(written); err == nil {
+		t.Errorf("a revision wrote %s", written)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// prepareCommitMsgHook is the hook `smart-commit hook install` writes.
+const prepareCommitMsgHook = `#!/bin/sh
+# Installed by smart-commit hook install
+exec smart-commit hook prepare-commit-msg "$@"
+`
+
+// fastPrepareCommitMsgHook is the hook `smart-commit hook install --fast`
+// writes: it runs the standalone smart-commit-hook binary, which starts
+// faster but leaves out trailers, policies and the asynchronous mode.
+const fastPrepareCommitMsgHook = `#!/bin/sh
+# Installed by smart-commit hook install --fast
+exec smart-commit-hook "$@"
+`
+
+// ownHook reports whether a hook script is one smart-commit installed.
+func ownHook(data []byte) bool {
+	return strings.Contains(string(data), "smart-commit hook prepare-commit-msg") || strings.Contains(string(data), "# Installed by smart-commit hook install")
+}
+
+// runHook implements `smart-commit hook`.
+func runHook(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: smart-commit hook install [--fast] | uninstall | prepare-commit-msg FILE [SOURCE [SHA]]")
+	}
+	switch args[0] {
+	case "install":
+		fs := flag.NewFlagSet("hook install", flag.ExitOnError)
+		fast := fs.Bool("fast", false, "install a hook running the standalone smart-commit-hook binary, which starts faster")
+		fs.Parse(args[1:])
+		return installPrepareCommitMsgHook(*fast)
+	case "uninstall":
+		return uninstallPrepareCommitMsgHook()
+	case "fill-message":
+		// Internal: the background half of an asynchronous hook
+		if len(args) != 3 {
+			return fmt.Errorf("usage: smart-commit hook fill-message FILE GIT_PID")
+		}
+		pid, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid git PID %q", args[2])
+		}
+		return fillMessageFile(args[1], pid)
+	case "prepare-commit-msg":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: smart-commit hook prepare-commit-msg FILE [SOURCE [SHA]]")
+		}
+		source := ""
+		if len(args) > 2 {
+			source = args[2]
+		}
+		// A failing hook would block the commit; report and let git go on
+		if err := prepareCommitMsg(args[1], source); err != nil {
+			fmt.Fprintf(os.Stderr, "smart-commit: %v\n", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown hook command %q", args[0])
+}
+
+// prepareCommitMsgHookPath is where git looks for the hook, honoring
+// core.hooksPath.
+func prepareCommitMsgHookPath() (string, error) {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "hooks/prepare-commit-msg")
+	if err != nil {
+		return "", fmt.Errorf("locating hooks: %v", err)
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// installPrepareCommitMsgHook writes the hook, refusing to replace one that
+// smart-commit did not install. With fast, the hook runs smart-commit-hook.
+func installPrepareCommitMsgHook(fast bool) error {
+	path, err := prepareCommitMsgHookPath()
+	if err != nil {
+		return err
+	}
+	script := prepareCommitMsgHook
+	if fast {
+		if _, err := exec.LookPath("smart-commit-hook"); err != nil {
+			return fmt.Errorf("smart-commit-hook is not on PATH; install it with go install github.com/chalfel/smart-commit/cmd/smart-commit-hook@latest")
+		}
+		script = fastPrepareCommitMsgHook
+	}
+	if data, err := os.ReadFile(path); err == nil && !ownHook(data) {
+		return fmt.Errorf("%s is a custom hook; add `smart-commit hook prepare-commit-msg \"$@\"` to it by hand", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return fmt.Errorf("installing prepare-commit-msg hook: %v", err)
+	}
+	fmt.Printf("Installed prepare-commit-msg hook at %s.\n", path)
+	return nil
+}
+
+// uninstallPrepareCommitMsgHook removes the hook if smart-commit installed it.
+func uninstallPrepareCommitMsgHook() error {
+	path, err := prepareCommitMsgHookPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Println("No prepare-commit-msg hook is installed.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !ownHook(data) {
+		return fmt.Errorf("%s is a custom hook; remove the smart-commit line from it by hand", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s.\n", path)
+	return nil
+}
+
+// prepareCommitMsg pre-fills the commit message file git is about to open in
+// the editor. It only acts on a plain `git commit`: when git passes a source
+// (-m or -F, a template, a merge, a squash or an amend), or the file already
+// has a message, the file is left alone.
+func prepareCommitMsg(path, source string) error {
+	if source != "" {
+		return nil
+	}
+	f, err := git.ReadMessageFile(path)
+	if err != nil || !f.Empty() {
+		return err
+	}
+
+	changes, err := git.LoadChangeSet("--cached")
+	if err != nil {
+		return err
+	}
+	if len(changes.Files) == 0 {
+		return nil
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+	if config.Bool(config.Current().HookAsync, false) {
+		return startAsyncMessage(f, changes)
+	}
+	message, err := generateHookMessage(changes)
+	if err != nil {
+		return err
+	}
+	return f.Prefill(message)
+}
+
+// generateHookMessage generates the message the hook pre-fills, with the
+// configured trailers.
+func generateHookMessage(changes *git.ChangeSet) (string, error) {
+	fmt.Fprintf(os.Stderr, "Generating commit message with %s...\n", currentGenerator().Name())
+	message, err := suggestCommitMessage(changes, "")
+	if err != nil {
+		// The fallback message is not worth pre-filling
+		return "", fmt.Errorf("%s error: %v", currentGenerator().Name(), err)
+	}
+	trailers, err := configuredTrailers(nil)
+	if err != nil {
+		return "", err
+	}
+	return addTrailers(message, trailers)
+}
+
+// pendingNote marks a placeholder message, after the comment character.
+const pendingNote = " smart-commit is generating a message; it replaces the one above when ready."
+
+// startAsyncMessage writes a placeholder message at once, so git can open
+// the editor, and leaves generating the real one to a background process
+// that replaces it while the editor is still open.
+func startAsyncMessage(f *git.MessageFile, changes *git.ChangeSet) error {
+	trailers, err := configuredTrailers(nil)
+	if err != nil {
+		return err
+	}
+	placeholder, err := addTrailers(fallbackMessage(changes), trailers)
+	if err != nil {
+		return err
+	}
+	if err := f.Prefill(placeholder + "\n" + f.Comment + pendingNote); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// The hook execs smart-commit, so the parent is git itself
+	cmd := exec.Command(exe, "hook", "fill-message", f.Path, strconv.Itoa(os.Getppid()))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting background generation: %v", err)
+	}
+	return cmd.Process.Release()
+}
+
+// fillMessageFile generates the message for a placeholder written by
+// startAsyncMessage and puts it in the file, as long as git is still
+// waiting for the editor and the placeholder was not touched.
+func fillMessageFile(path string, gitPID int) error {
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	changes, err := git.LoadChangeSet("--cached")
+	if err != nil {
+		return err
+	}
+	message, err := generateHookMessage(changes)
+	if err != nil {
+		return err
+	}
+
+	now, err := os.ReadFile(path)
+	if err != nil || string(now) != string(before) || !processAlive(gitPID) {
+		return nil
+	}
+	content, ok := replacePlaceholder(string(now), message, git.CommentChar())
+	if !ok {
+		return nil
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// replacePlaceholder swaps the placeholder message in a message file for
+// message, dropping the pending note. It reports false when the file has
+// no pending placeholder.
+func replacePlaceholder(data, message, comment string) (string, bool) {
+	_, rest, ok := strings.Cut(data, "\n"+comment+pendingNote+"\n")
+	if !ok {
+		return "", false
+	}
+	return message + "\n" + rest, true
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestReplacePlaceholder(t *testing.T) {
+	gitComments := "\n# Please enter the commit message for your changes.\n"
+	tests := []struct {
+		name, data, comment string
+		want                string
+		ok                  bool
+	}{
+		{"pending", "chore: changes to a.go\n#" + pendingNote + "\n" + gitComments, "#", "feat: add a\n" + gitComments, true},
+		{"custom comment char", "chore: changes to a.go\n;" + pendingNote + "\n\n; Please enter", ";", "feat: add a\n\n; Please enter", true},
+		{"no placeholder", "fix: typed by hand\n" + gitComments, "#", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := replacePlaceholder(tt.data, "feat: add a", tt.comment)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("replacePlaceholder() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// hotspot aggregates how often a file changes and how many of those changes
+// were fixes.
+type hotspot struct {
+	Path    string `json:"path"`
+	Changes int    `json:"changes"`
+	Fixes   int    `json:"fixes"`
+}
+
+// Risk weighs churn by bug-proneness: a file that changes often and keeps
+// needing fixes scores highest.
+func (h hotspot) Risk() int {
+	return h.Changes * (h.Fixes + 1)
+}
+
+// runHotspots implements `smart-commit hotspots`, listing high-churn,
+// bug-prone files from the repository history.
+func runHotspots(args []string) error {
+	fs := flag.NewFlagSet("hotspots", flag.ExitOnError)
+	since := fs.String("since", "", "only analyze history since this point (e.g. 6m, 1y, or a date)")
+	limit := fs.Int("limit", 20, "number of files to list")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	logArgs := []string{"--no-merges"}
+	if *since != "" {
+		logArgs = append(logArgs, "--since="+gitSince(*since))
+	}
+	// Remaining arguments restrict the analysis to the given paths
+	if fs.NArg() > 0 {
+		logArgs = append(logArgs, "--")
+		logArgs = append(logArgs, fs.Args()...)
+	}
+
+	spots, err := collectHotspots(logArgs...)
+	if err != nil {
+		return err
+	}
+	var r report
+	if len(spots) == 0 {
+		r.paragraph("No history to analyze.")
+		r.Data = []hotspot{}
+		return printReport(*format, r)
+	}
+	if *limit > 0 && len(spots) > *limit {
+		spots = spots[:*limit]
+	}
+
+	type riskySpot struct {
+		hotspot
+		Risk int `json:"risk"`
+	}
+	var rows [][]string
+	var data []riskySpot
+	for _, s := range spots {
+		rows = append(rows, []string{strconv.Itoa(s.Risk()), strconv.Itoa(s.Changes), strconv.Itoa(s.Fixes), s.Path})
+		data = append(data, riskySpot{s, s.Risk()})
+	}
+	r.table([]string{"Risk", "Changes", "Fixes", "File"}, rows)
+	r.Data = data
+	return printReport(*format, r)
+}
+
+// collectHotspots walks git history and tallies changes and fix commits per
+// file, sorted by descending risk.
+func collectHotspots(args ...string) ([]hotspot, error) {
+	gitArgs := append([]string{"log", "--name-only", "--format=" + git.LogRecordSep + "%s"}, args...)
+	out, err := executeCommandWithOutput("git", gitArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("reading git history: %v", err)
+	}
+
+	byPath := map[string]*hotspot{}
+	for _, record := range strings.Split(out, git.LogRecordSep) {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+		commitType, _, _, _, _ := conventional.ParseSubject(lines[0])
+		isFix := commitType == "fix"
+
+		for _, path := range gitPathLines(strings.Join(lines[1:], "\n")) {
+			h, ok := byPath[path]
+			if !ok {
+				h = &hotspot{Path: path}
+				byPath[path] = h
+			}
+			h.Changes++
+			if isFix {
+				h.Fixes++
+			}
+		}
+	}
+
+	spots := make([]hotspot, 0, len(byPath))
+	for _, h := range byPath {
+		spots = append(spots, *h)
+	}
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Risk() != spots[j].Risk() {
+			return spots[i].Risk() > spots[j].Risk()
+		}
+		return spots[i].Path < spots[j].Path
+	})
+	return spots, nil
+}
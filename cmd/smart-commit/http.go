@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpClient is shared by every outbound API call the tool makes.
+var httpClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: gatewayTransportFromEnv(http.DefaultTransport),
+}
+
+// providerClient is used for AI provider requests, which are bounded by
+// the --timeout context rather than a fixed client timeout.
+var providerClient = &http.Client{Transport: httpClient.Transport}
+
+// Headers added to requests signed for an enterprise gateway.
+const (
+	signatureTimestampHeader = "X-Smart-Commit-Timestamp"
+	signatureHeader          = "X-Smart-Commit-Signature"
+)
+
+// gatewayTransport adds configured headers to outbound requests and signs
+// them with HMAC-SHA256, as required by gateways that attribute and meter
+// API usage.
+type gatewayTransport struct {
+	base       http.RoundTripper
+	headers    http.Header
+	signingKey []byte
+	// hosts limits headers and signing to these hosts, unless allHosts.
+	hosts    map[string]bool
+	allHosts bool
+}
+
+// gatewayTransportFromEnv wraps base according to the environment:
+//
+//	SMART_COMMIT_HTTP_HEADERS      extra headers, "Name: value" separated by newlines or ";"
+//	SMART_COMMIT_HTTP_SIGNING_KEY  HMAC key used to sign requests
+//	SMART_COMMIT_HTTP_HOSTS        comma-separated hosts to apply them to, or "*" for all
+//
+// Without SMART_COMMIT_HTTP_HOSTS they only go to the AI providers, so
+// gateway credentials never reach GitHub, trackers or Slack. base is
+// returned unchanged when neither headers nor a key are configured.
+func gatewayTransportFromEnv(base http.RoundTripper) http.RoundTripper {
+	t := &gatewayTransport{base: base, headers: http.Header{}, hosts: map[string]bool{}}
+	for _, entry := range strings.FieldsFunc(secretEnv("SMART_COMMIT_HTTP_HEADERS"), func(r rune) bool { return r == '\n' || r == ';' }) {
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring malformed header %q in SMART_COMMIT_HTTP_HEADERS\n", entry)
+			continue
+		}
+		t.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if key := secretEnv("SMART_COMMIT_HTTP_SIGNING_KEY"); key != "" {
+		t.signingKey = []byte(key)
+	}
+	hosts := os.Getenv("SMART_COMMIT_HTTP_HOSTS")
+	for _, host := range strings.Split(hosts, ",") {
+		switch host = strings.TrimSpace(host); host {
+		case "":
+		case "*":
+			t.allHosts = true
+		default:
+			t.hosts[strings.ToLower(host)] = true
+		}
+	}
+	if strings.TrimSpace(hosts) == "" {
+		t.hosts = providerHosts()
+	}
+
+	if len(t.headers) == 0 && t.signingKey == nil {
+		return base
+	}
+	return t
+}
+
+func (t *gatewayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.allHosts && !t.hosts[strings.ToLower(req.URL.Hostname())] {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	if t.signingKey != nil {
+		var body []byte
+		if req.Body != nil {
+			data, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			body = data
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(signatureTimestampHeader, timestamp)
+		req.Header.Set(signatureHeader, "sha256="+signRequest(t.signingKey, req.Method, req.URL.RequestURI(), timestamp, body))
+	}
+	return t.base.RoundTrip(req)
+}
+
+// providerHosts are the hosts of the AI provider endpoints, base URL
+// overrides included, which gateways sit in front of.
+func providerHosts() map[string]bool {
+	hosts := map[string]bool{}
+	endpoints := []string{providerEndpoint("openai"), providerEndpoint("anthropic"), os.Getenv("OLLAMA_HOST")}
+	for _, endpoint := range endpoints {
+		if endpoint != "" && !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+		if u, err := url.Parse(endpoint); err == nil && u.Hostname() != "" {
+			hosts[strings.ToLower(u.Hostname())] = true
+		}
+	}
+	return hosts
+}
+
+// signRequest computes the request signature: the hex HMAC-SHA256 of the
+// method, request URI, timestamp and body hash, one per line.
+func signRequest(key []byte, method, requestURI, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, requestURI, timestamp, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
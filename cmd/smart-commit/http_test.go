@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// headerRecorder records the requests it is asked to send.
+type headerRecorder struct{ last *http.Request }
+
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.last = req
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestGatewayTransportHosts(t *testing.T) {
+	t.Setenv("SMART_COMMIT_HTTP_HEADERS", "X-Team: payments")
+	t.Setenv("SMART_COMMIT_HTTP_SIGNING_KEY", "k")
+	t.Setenv("OPENAI_BASE_URL", "https://llm.corp.example/openai/v1")
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	t.Setenv("OLLAMA_HOST", "")
+
+	tests := []struct {
+		hosts, url string
+		signed     bool
+	}{
+		{"", "https://llm.corp.example/openai/v1/chat/completions", true},
+		{"", "https://api.anthropic.com/v1/messages", true},
+		{"", "https://api.github.com/repos/o/r", false},
+		{"", "https://hooks.slack.com/services/x", false},
+		{"", "https://acme.atlassian.net/rest/api/2/issue/A-1", false},
+		{"api.github.com", "https://api.github.com/repos/o/r", true},
+		{"api.github.com", "https://llm.corp.example/openai/v1/chat/completions", false},
+		{"*", "https://hooks.slack.com/services/x", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("SMART_COMMIT_HTTP_HOSTS", tt.hosts)
+		rec := &headerRecorder{}
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		if _, err := gatewayTransportFromEnv(rec).RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		signed := rec.last.Header.Get(signatureHeader) != "" && rec.last.Header.Get("X-Team") == "payments"
+		if signed != tt.signed {
+			t.Errorf("hosts %q, %s: signed = %v, want %v", tt.hosts, tt.url, signed, tt.signed)
+		}
+	}
+}
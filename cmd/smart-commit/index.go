@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// repoIndexVersion changes with the layout of the index, which rebuilds it.
+const repoIndexVersion = 1
+
+// maxIndexedFileSize leaves generated and vendored giants out of the
+// symbol map.
+const maxIndexedFileSize = 1 << 20
+
+// repoIndex is what prompt enrichment needs to know about the repository,
+// kept in .git/smart-commit/index.json so large monorepos are not scanned
+// on every run: the packages of a monorepo, the scope history and a map of
+// the symbols each file declares and calls. Each part is brought up to
+// date on its own, and only for what changed.
+type repoIndex struct {
+	Version int `json:"version"`
+	// Head is the commit History is up to date with.
+	Head string `json:"head"`
+	// History holds the latest scopeHistory commits, newest first.
+	History []indexedCommit `json:"history"`
+	// Manifests identifies the manifest files Packages was read from.
+	Manifests string `json:"manifests"`
+	// Packages maps the directory of each detected workspace to its scope.
+	Packages map[string]string `json:"packages"`
+	// Files maps the path of each indexed source file to its symbols.
+	Files map[string]indexedFile `json:"files"`
+}
+
+// indexedCommit is a commit of the scope history.
+type indexedCommit struct {
+	Subject string `json:"subject"`
+	// Dirs are the directories of the files it touched, and their parents.
+	Dirs []string `json:"dirs,omitempty"`
+}
+
+// indexedFile is the symbol map entry of a staged file.
+type indexedFile struct {
+	Blob string `json:"blob"`
+	// Symbols are the names the file declares.
+	Symbols []string `json:"symbols,omitempty"`
+	// Calls are the names the file calls.
+	Calls []string `json:"calls,omitempty"`
+}
+
+// symbolDeclarations match the declarations of a line by file extension;
+// the last group is the name.
+var symbolDeclarations = map[string]*regexp.Regexp{
+	".go":   regexp.MustCompile(`^(?:func(?: \([^)]*\))?|type|const|var) ([A-Za-z_]\w*)`),
+	".js":   regexp.MustCompile(`^\s*(?:export )?(?:default )?(?:async )?(?:function\*?|class|const|let|interface|type|enum) ([A-Za-z_$][\w$]*)`),
+	".py":   regexp.MustCompile(`^\s*(?:async )?(?:def|class) ([A-Za-z_]\w*)`),
+	".rb":   regexp.MustCompile(`^\s*(?:def|class|module) (?:self\.)?([A-Za-z_]\w*[?!]?)`),
+	".rs":   regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))? )?(?:async )?(?:fn|struct|enum|trait|type|const|static|mod) ([A-Za-z_]\w*)`),
+	".java": regexp.MustCompile(`^\s*(?:(?:public|private|protected|static|final|abstract|sealed) )*(?:class|interface|enum|record) ([A-Za-z_]\w*)`),
+}
+
+func init() {
+	for _, ext := range []string{".jsx", ".ts", ".tsx", ".mjs", ".cjs"} {
+		symbolDeclarations[ext] = symbolDeclarations[".js"]
+	}
+	symbolDeclarations[".kt"] = symbolDeclarations[".java"]
+}
+
+// symbolCall matches a call, or a declaration, of a name.
+var symbolCall = regexp.MustCompile(`\b([A-Za-z_]\w{2,})\s*\(`)
+
+// notCalls are keywords that look like calls.
+var notCalls = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "func": true, "function": true,
+	"return": true, "catch": true, "elif": true, "print": true, "make": true, "len": true,
+	"append": true, "new": true, "super": true, "typeof": true, "await": true, "select": true,
+}
+
+var (
+	indexMu sync.Mutex
+	// indexMemo is the index loaded by this process, with the staged files
+	// it was brought up to date with.
+	indexMemo struct {
+		path, head, staged string
+		index              *repoIndex
+	}
+)
+
+// currentIndex returns the index of the repository, brought up to date
+// with HEAD and the staged files. It is nil when the repository cannot be
+// read, and the callers scan for themselves.
+func currentIndex() *repoIndex {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	idx, err := updateIndex(false)
+	if err != nil {
+		return nil
+	}
+	return idx
+}
+
+// updateIndex brings the index up to date, from scratch when rebuild is
+// set, and saves it.
+func updateIndex(rebuild bool) (*repoIndex, error) {
+	file, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/index.json")
+	if err != nil {
+		return nil, fmt.Errorf("locating repository: %v", err)
+	}
+	file = strings.TrimSpace(file)
+	if abs, err := filepath.Abs(file); err == nil {
+		file = abs
+	}
+	root, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("locating repository: %v", err)
+	}
+	root = strings.TrimSpace(root)
+	head, _ := executeCommandWithOutput("git", "rev-parse", "-q", "--verify", "HEAD")
+	head = strings.TrimSpace(head)
+	staged, err := executeCommandWithOutput("git", "-C", root, "ls-files", "-s", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("listing files: %v", err)
+	}
+	sum := sha256.Sum256([]byte(staged))
+	stagedID := hex.EncodeToString(sum[:])
+	if !rebuild && indexMemo.index != nil && indexMemo.path == file && indexMemo.head == head && indexMemo.staged == stagedID {
+		return indexMemo.index, nil
+	}
+
+	var old repoIndex
+	if !rebuild {
+		if data, err := os.ReadFile(file); err == nil && json.Unmarshal(data, &old) == nil && old.Version != repoIndexVersion {
+			old = repoIndex{}
+		}
+	}
+	idx := &repoIndex{Version: repoIndexVersion, Head: head}
+	blobs := stagedBlobs(staged)
+
+	// The parts are independent, so they are brought up to date together
+	var wg sync.WaitGroup
+	var historyErr, symbolsErr error
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		idx.History, historyErr = indexHistory(old, head)
+	}()
+	go func() {
+		defer wg.Done()
+		idx.Manifests, idx.Packages = indexPackages(old, root, blobs)
+	}()
+	go func() {
+		defer wg.Done()
+		idx.Files, symbolsErr = indexSymbols(old, root, blobs)
+	}()
+	wg.Wait()
+	if historyErr != nil {
+		return nil, historyErr
+	}
+	if symbolsErr != nil {
+		return nil, symbolsErr
+	}
+
+	if writesAllowed() {
+		if err := saveIndex(file, idx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: saving the repository index: %v\n", err)
+		}
+	}
+	indexMemo.path, indexMemo.head, indexMemo.staged, indexMemo.index = file, head, stagedID, idx
+	return idx, nil
+}
+
+// saveIndex replaces the index file atomically.
+func saveIndex(file string, idx *repoIndex) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// stagedBlobs reads the output of git ls-files -s -z into the blob of each
+// staged path.
+func stagedBlobs(out string) map[string]string {
+	blobs := map[string]string{}
+	for _, entry := range strings.Split(strings.TrimRight(out, "\x00"), "\x00") {
+		info, file, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) < 3 || fields[0] == "160000" {
+			continue
+		}
+		blobs[file] = fields[1]
+	}
+	return blobs
+}
+
+// indexHistory brings the scope history up to date with head: only the
+// commits since the indexed head are read, unless history was rewritten.
+func indexHistory(old repoIndex, head string) ([]indexedCommit, error) {
+	if head == "" {
+		return nil, nil
+	}
+	if old.Head == head {
+		return old.History, nil
+	}
+	args := []string{"log", "--no-merges", "--format=%x00%s", "--name-only"}
+	incremental := false
+	if old.Head != "" {
+		_, err := executeCommandWithOutput("git", "merge-base", "--is-ancestor", old.Head, head)
+		incremental = err == nil
+	}
+	if incremental {
+		args = append(args, old.Head+".."+head)
+	} else {
+		args = append(args, "-n", strconv.Itoa(scopeHistory), head)
+	}
+	out, err := executeCommandWithOutput("git", args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading git history: %v", err)
+	}
+	var history []indexedCommit
+	for _, record := range strings.Split(out, "\x00")[1:] {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		c := indexedCommit{Subject: lines[0]}
+		seen := map[string]bool{}
+		for _, file := range lines[1:] {
+			for _, dir := range parentDirs(strings.TrimSpace(file)) {
+				if !seen[dir] {
+					seen[dir] = true
+					c.Dirs = append(c.Dirs, dir)
+				}
+			}
+		}
+		history = append(history, c)
+	}
+	if incremental {
+		history = append(history, old.History...)
+	}
+	if len(history) > scopeHistory {
+		history = history[:scopeHistory]
+	}
+	return history, nil
+}
+
+// manifestFile reports whether a file can declare monorepo workspaces.
+func manifestFile(file string) bool {
+	base := path.Base(file)
+	return base == "go.mod" || base == "package.json" || file == "pnpm-workspace.yaml"
+}
+
+// indexPackages detects the workspaces again when a manifest file changed.
+func indexPackages(old repoIndex, root string, blobs map[string]string) (string, map[string]string) {
+	var manifests []string
+	for file, blob := range blobs {
+		if manifestFile(file) {
+			manifests = append(manifests, blob+" "+file)
+		}
+	}
+	sort.Strings(manifests)
+	sum := sha256.Sum256([]byte(strings.Join(manifests, "\n")))
+	id := hex.EncodeToString(sum[:])
+	if old.Packages != nil && old.Manifests == id {
+		return id, old.Packages
+	}
+	var files []string
+	for _, m := range manifests {
+		_, file, _ := strings.Cut(m, " ")
+		files = append(files, file)
+	}
+	return id, detectPackages(root, files)
+}
+
+// indexSymbols reads the symbols of the staged source files whose content
+// changed since they were indexed, spreading the parsing over the CPUs.
+func indexSymbols(old repoIndex, root string, blobs map[string]string) (map[string]indexedFile, error) {
+	files := map[string]indexedFile{}
+	var stale []string
+	for file, blob := range blobs {
+		if symbolDeclarations[strings.ToLower(path.Ext(file))] == nil {
+			continue
+		}
+		if f, ok := old.Files[file]; ok && f.Blob == blob {
+			files[file] = f
+			continue
+		}
+		stale = append(stale, file)
+	}
+	if len(stale) == 0 {
+		return files, nil
+	}
+
+	var in strings.Builder
+	for _, file := range stale {
+		in.WriteString(blobs[file] + "\n")
+	}
+	cmd := exec.CommandContext(interrupted, "git", "-C", root, "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(in.String())
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return nil, fmt.Errorf("reading staged files: %v", err)
+	}
+
+	type job struct {
+		file string
+		src  []byte
+	}
+	jobs := make(chan job)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				f := fileSymbols(j.file, j.src)
+				f.Blob = blobs[j.file]
+				mu.Lock()
+				files[j.file] = f
+				mu.Unlock()
+			}
+		}()
+	}
+	r := bufio.NewReader(&out)
+	for _, file := range stale {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		fields := strings.Fields(header)
+		if len(fields) < 3 {
+			// A missing object: index what can be
+			continue
+		}
+		size, _ := strconv.Atoi(fields[2])
+		src := make([]byte, size+1)
+		if _, err := io.ReadFull(r, src); err != nil {
+			break
+		}
+		if size <= maxIndexedFileSize {
+			jobs <- job{file, src[:size]}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return files, nil
+}
+
+// fileSymbols finds the names a source file declares and calls.
+func fileSymbols(file string, src []byte) indexedFile {
+	decl := symbolDeclarations[strings.ToLower(path.Ext(file))]
+	var f indexedFile
+	declared, called := map[string]bool{}, map[string]bool{}
+	for _, line := range strings.Split(string(src), "\n") {
+		name := ""
+		if m := decl.FindStringSubmatch(line); m != nil {
+			name = m[1]
+			if !declared[name] {
+				declared[name] = true
+				f.Symbols = append(f.Symbols, name)
+			}
+		}
+		for _, m := range symbolCall.FindAllStringSubmatch(line, -1) {
+			if call := m[1]; call != name && !called[call] && !notCalls[call] {
+				called[call] = true
+				f.Calls = append(f.Calls, call)
+			}
+		}
+	}
+	sort.Strings(f.Symbols)
+	sort.Strings(f.Calls)
+	return f
+}
+
+// declaredIn returns the files declaring name.
+func (idx *repoIndex) declaredIn(name string) []string {
+	var files []string
+	for file, f := range idx.Files {
+		if i := sort.SearchStrings(f.Symbols, name); i < len(f.Symbols) && f.Symbols[i] == name {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// callersOf returns the files calling name.
+func (idx *repoIndex) callersOf(name string) []string {
+	var files []string
+	for file, f := range idx.Files {
+		if i := sort.SearchStrings(f.Calls, name); i < len(f.Calls) && f.Calls[i] == name {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
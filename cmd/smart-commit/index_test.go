@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSymbols(t *testing.T) {
+	tests := []struct {
+		file, src      string
+		symbols, calls []string
+	}{
+		{"users.go", "package users\n\nfunc (s *Store) Find(id int) (*User, error) {\n\treturn s.load(id)\n}\n\ntype User struct{}\n\nfunc List() {\n\tif (true) {\n\t\tFind(1)\n\t}\n}\n",
+			[]string{"Find", "List", "User"}, []string{"Find", "load"}},
+		{"app.ts", "export async function render(el) {\n  return mount(el)\n}\nclass View {}\n",
+			[]string{"View", "render"}, []string{"mount"}},
+		{"tool.py", "class Tool:\n    def run(self):\n        return helper()\n",
+			[]string{"Tool", "run"}, []string{"helper"}},
+	}
+	for _, tt := range tests {
+		got := fileSymbols(tt.file, []byte(tt.src))
+		if !reflect.DeepEqual(got.Symbols, tt.symbols) || !reflect.DeepEqual(got.Calls, tt.calls) {
+			t.Errorf("fileSymbols(%s) = %v, %v; want %v, %v", tt.file, got.Symbols, got.Calls, tt.symbols, tt.calls)
+		}
+	}
+}
+
+func TestIndexCommand(t *testing.T) {
+	repo, env := testRepo(t)
+	os.MkdirAll(filepath.Join(repo, "store"), 0755)
+	os.MkdirAll(filepath.Join(repo, "api"), 0755)
+	os.WriteFile(filepath.Join(repo, "store", "go.mod"), []byte("module example.com/store\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "store", "users.go"), []byte("package store\n\nfunc FindUser(id int) {}\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "api", "users.go"), []byte("package api\n\nfunc Get() {\n\tstore.FindUser(1)\n}\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "feat(store): find users")
+
+	out, err := runCLI(t, repo, env, "", "index")
+	if err != nil || !strings.Contains(out, "Indexed 2 source files declaring 2 symbols, 1 packages and 2 commits of history") {
+		t.Fatalf("smart-commit index: %v\n%s", err, out)
+	}
+	out, err = runCLI(t, repo, env, "", "index", "--symbol", "FindUser")
+	if err != nil || !strings.Contains(out, "Declared in: store/users.go") || !strings.Contains(out, "Called from: api/users.go") {
+		t.Errorf("smart-commit index --symbol: %v\n%s", err, out)
+	}
+
+	// A new commit and a staged change are picked up, the rest kept
+	runGit(t, repo, env, "commit", "-q", "--allow-empty", "-m", "chore(api): tidy")
+	os.WriteFile(filepath.Join(repo, "api", "users.go"), []byte("package api\n\nfunc Get() {}\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	if out, err := runCLI(t, repo, env, "", "index"); err != nil {
+		t.Fatalf("smart-commit index: %v\n%s", err, out)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, ".git", "smart-commit", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx repoIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.History) != 3 || idx.History[0].Subject != "chore(api): tidy" || idx.Packages["store"] != "store" {
+		t.Errorf("index = %+v", idx)
+	}
+	if callers := idx.callersOf("FindUser"); len(callers) != 0 {
+		t.Errorf("callers of FindUser = %v after the call was removed", callers)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runIndex implements `smart-commit index`: the repository index is
+// brought up to date, or built again with --rebuild, and summarized.
+// --symbol looks a name up in the symbol map instead.
+func runIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	rebuild := fs.Bool("rebuild", false, "discard the index and build it again")
+	symbol := fs.String("symbol", "", "list the files declaring and calling this name")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	indexMu.Lock()
+	idx, err := updateIndex(*rebuild)
+	indexMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if *symbol != "" {
+		declared, callers := idx.declaredIn(*symbol), idx.callersOf(*symbol)
+		if len(declared) == 0 && len(callers) == 0 {
+			return fmt.Errorf("%s is not in the index", *symbol)
+		}
+		r := report{Data: map[string]interface{}{"symbol": *symbol, "declared_in": nonNil(declared), "called_from": nonNil(callers)}}
+		r.list([]string{"Declared in: " + orNone(declared), "Called from: " + orNone(callers)})
+		return printReport(*format, r)
+	}
+	symbols := 0
+	for _, f := range idx.Files {
+		symbols += len(f.Symbols)
+	}
+	r := report{Data: map[string]int{"files": len(idx.Files), "symbols": symbols, "packages": len(idx.Packages), "commits": len(idx.History)}}
+	r.paragraph("Indexed %d source files declaring %d symbols, %d packages and %d commits of history.", len(idx.Files), symbols, len(idx.Packages), len(idx.History))
+	return printReport(*format, r)
+}
+
+// nonNil returns list, or an empty list for nil, so JSON shows [] rather
+// than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// orNone joins list, or says there is nothing in it.
+func orNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
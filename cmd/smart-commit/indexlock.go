@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// checkIndexLock looks for a leftover .git/index.lock before the flow
+// touches the index. git creates the file while it updates the index and
+// removes it when done, so one that exists while no git process runs was
+// left by a crashed or killed git. Such a stale lock is removed when clear
+// is set or the user agrees; otherwise the cause is explained.
+func checkIndexLock(clear bool) error {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "index.lock")
+	if err != nil {
+		return fmt.Errorf("locating index: %v", err)
+	}
+	path = strings.TrimSpace(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	if gitRunning() {
+		return fmt.Errorf("%s exists because another git process is using this repository; wait for it to finish and try again", path)
+	}
+
+	age := time.Since(info.ModTime()).Round(time.Second)
+	fmt.Fprintf(os.Stderr, "Found %s (%s old) but no git process is running.\n", path, age)
+	fmt.Fprintln(os.Stderr, "It was most likely left behind by a git command that crashed or was interrupted.")
+	if !clear && !(isTerminal(os.Stdin) && confirm("Remove the stale lock?")) {
+		return fmt.Errorf("stale %s blocks staging; remove it or rerun with --clear-index-lock", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("removing %s: %v", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %s\n", path)
+	return nil
+}
+
+// gitRunning reports whether any git process is running on this machine.
+// When the process list cannot be read it assumes one is, so a lock is never
+// removed from under a live git.
+func gitRunning() bool {
+	cmd := exec.Command("ps", "-A", "-o", "comm=")
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("tasklist", "/FO", "CSV", "/NH")
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return true
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		name := strings.TrimSpace(line)
+		if runtime.GOOS == "windows" {
+			name = strings.Trim(strings.SplitN(name, ",", 2)[0], `"`)
+		}
+		name = strings.TrimSuffix(strings.ToLower(filepath.Base(name)), ".exe")
+		if name == "git" || strings.HasPrefix(name, "git-") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+	"gopkg.in/yaml.v3"
+)
+
+// terraformPlan is set by main from --plan: the resource changes of a
+// Terraform plan, which replace those read from the staged .tf files.
+var terraformPlan []infraChange
+
+// maxInfraDetails bounds how many changed fields are named per resource.
+const maxInfraDetails = 3
+
+// infraChange is a change to a resource of the environment: a Terraform
+// resource, data source or module, or a Kubernetes object.
+type infraChange struct {
+	Action  string // create, update, destroy or replace
+	Address string // e.g. aws_s3_bucket.logs or Deployment/api
+	Source  string // the file it is declared in, or the plan
+	Details []string
+}
+
+func (c infraChange) String() string {
+	s := fmt.Sprintf("%s %s (%s)", c.Action, c.Address, c.Source)
+	if len(c.Details) > 0 {
+		s += ": " + strings.Join(c.Details, ", ")
+	}
+	return s
+}
+
+// wantInfraChanges reports whether infrastructure changes are listed in the
+// body, as the infra_changes setting says.
+func wantInfraChanges() bool {
+	return config.Bool(config.Current().InfraChanges, true)
+}
+
+// infraMemo keeps the infrastructure changes of the last change set, as the
+// prompt and the body both need them.
+var infraMemo struct {
+	changes *git.ChangeSet
+	report  []infraChange
+}
+
+// stagedInfraChanges returns the resources the staged changes create,
+// update or destroy: those of the Terraform plan given with --plan, or else
+// those of the staged .tf files, and the Kubernetes objects of the staged
+// manifests.
+func stagedInfraChanges(changes *git.ChangeSet) []infraChange {
+	if infraMemo.changes == changes {
+		return infraMemo.report
+	}
+	report := append([]infraChange(nil), terraformPlan...)
+	for _, f := range changes.Files {
+		switch strings.ToLower(path.Ext(f.Path)) {
+		case ".tf":
+			if terraformPlan == nil {
+				report = append(report, terraformChanges(f)...)
+			}
+		case ".yaml", ".yml":
+			report = append(report, manifestChanges(f)...)
+		}
+	}
+	infraMemo.changes, infraMemo.report = changes, report
+	return report
+}
+
+// describeInfraChanges lists the infrastructure changes for the prompt.
+func describeInfraChanges(changes *git.ChangeSet) string {
+	report := stagedInfraChanges(changes)
+	if len(report) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Infrastructure changes:\n")
+	for _, c := range report {
+		b.WriteString("- " + c.String() + "\n")
+	}
+	return b.String()
+}
+
+// addInfraChanges adds an "Infrastructure changes" section to message
+// listing the resources the change creates, updates or destroys.
+func addInfraChanges(message string, changes *git.ChangeSet) string {
+	if !wantInfraChanges() {
+		return message
+	}
+	var items []string
+	for _, c := range stagedInfraChanges(changes) {
+		items = append(items, c.String())
+	}
+	return addBodySection(message, "Infrastructure changes", items)
+}
+
+// terraformBlock matches the first line of a Terraform resource, data
+// source or module block.
+var terraformBlock = regexp.MustCompile(`^\s*(resource|data|module)\s+"([^"]+)"(?:\s+"([^"]+)")?`)
+
+// terraformChanges reads the blocks a .tf file's hunks add, remove or
+// change. A hunk belongs to the block git names in its header, or to the
+// last block line it shows.
+func terraformChanges(f git.FileChange) []infraChange {
+	var order []string
+	added, removed, changed := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	address := func(text string) string {
+		m := terraformBlock.FindStringSubmatch(text)
+		switch {
+		case m == nil:
+			return ""
+		case m[1] == "module":
+			return "module." + m[2]
+		case m[1] == "data":
+			return "data." + m[2] + "." + m[3]
+		}
+		return m[2] + "." + m[3]
+	}
+	note := func(set map[string]bool, addr string) {
+		if !added[addr] && !removed[addr] && !changed[addr] {
+			order = append(order, addr)
+		}
+		set[addr] = true
+	}
+	for _, h := range f.Hunks {
+		current := address(h.Header)
+		for _, line := range h.Lines {
+			if line == "" {
+				continue
+			}
+			if addr := address(line[1:]); addr != "" {
+				current = addr
+				switch line[0] {
+				case '+':
+					note(added, addr)
+				case '-':
+					note(removed, addr)
+				}
+				continue
+			}
+			if (line[0] == '+' || line[0] == '-') && current != "" && !added[current] && !removed[current] {
+				note(changed, current)
+			}
+		}
+	}
+	var report []infraChange
+	for _, addr := range order {
+		action := "update"
+		switch {
+		case added[addr] && !removed[addr]:
+			action = "create"
+		case removed[addr] && !added[addr]:
+			action = "destroy"
+		}
+		report = append(report, infraChange{Action: action, Address: addr, Source: f.Path})
+	}
+	return report
+}
+
+// readTerraformPlan reads the resource changes of a plan, as printed by
+// `terraform show -json PLANFILE` or streamed by `terraform plan -json`.
+// Resources the plan leaves alone or only reads are left out.
+func readTerraformPlan(file string) ([]infraChange, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan: %v", err)
+	}
+	actions := func(list ...string) string {
+		switch strings.Join(list, ",") {
+		case "create":
+			return "create"
+		case "update":
+			return "update"
+		case "delete", "remove":
+			return "destroy"
+		case "delete,create", "create,delete", "replace":
+			return "replace"
+		}
+		return ""
+	}
+	report := []infraChange{}
+
+	var doc struct {
+		FormatVersion   string `json:"format_version"`
+		ResourceChanges []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(data, &doc); err == nil && doc.FormatVersion != "" {
+		for _, rc := range doc.ResourceChanges {
+			if action := actions(rc.Change.Actions...); action != "" {
+				report = append(report, infraChange{Action: action, Address: rc.Address, Source: "plan"})
+			}
+		}
+		return report, nil
+	}
+
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		var msg struct {
+			Type   string `json:"type"`
+			Change struct {
+				Resource struct {
+					Addr string `json:"addr"`
+				} `json:"resource"`
+				Action string `json:"action"`
+			} `json:"change"`
+		}
+		if json.Unmarshal([]byte(line), &msg) != nil || msg.Type == "" {
+			continue
+		}
+		found = true
+		if msg.Type == "planned_change" {
+			if action := actions(msg.Change.Action); action != "" {
+				report = append(report, infraChange{Action: action, Address: msg.Change.Resource.Addr, Source: "plan"})
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not the JSON output of terraform plan -json or terraform show -json", file)
+	}
+	return report, nil
+}
+
+// manifestChanges compares the Kubernetes objects of a manifest as of
+// apiBase and as staged: objects added or removed, and for changed ones
+// the fields that changed. Files that are not manifests, such as Helm
+// templates, have no objects.
+func manifestChanges(f git.FileChange) []infraChange {
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+	var before, after map[string]interface{}
+	if git.RefExists(apiBase) {
+		if src, err := git.Output("show", apiBase+":"+oldPath); err == nil {
+			before = manifestObjects(src)
+		}
+	}
+	if src, err := git.Output("show", ":"+f.Path); err == nil {
+		after = manifestObjects(src)
+	}
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var report []infraChange
+	for _, name := range names {
+		old, had := before[name]
+		now, has := after[name]
+		switch {
+		case !had:
+			report = append(report, infraChange{Action: "create", Address: name, Source: f.Path})
+		case !has:
+			report = append(report, infraChange{Action: "destroy", Address: name, Source: f.Path})
+		default:
+			var details []string
+			structuralChanges(old, now, "", &details)
+			if len(details) == 0 {
+				continue
+			}
+			if len(details) > maxInfraDetails {
+				details = append(details[:maxInfraDetails], fmt.Sprintf("and %d more", len(details)-maxInfraDetails))
+			}
+			report = append(report, infraChange{Action: "update", Address: name, Source: f.Path, Details: details})
+		}
+	}
+	return report
+}
+
+// manifestObjects returns the Kubernetes objects of a YAML stream by
+// Kind/name, or Kind/namespace/name when they have a namespace. It is
+// nil when src does not parse or holds no objects.
+func manifestObjects(src string) map[string]interface{} {
+	objects := map[string]interface{}{}
+	dec := yaml.NewDecoder(strings.NewReader(src))
+	for {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil
+		}
+		kind, _ := doc["kind"].(string)
+		metadata, _ := doc["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		if _, ok := doc["apiVersion"].(string); !ok || kind == "" || name == "" {
+			continue
+		}
+		if ns, _ := metadata["namespace"].(string); ns != "" {
+			name = ns + "/" + name
+		}
+		objects[kind+"/"+name] = doc
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return objects
+}
+
+// structuralChanges appends to out how after differs from before, one
+// entry per changed leaf with its dotted path: "spec.replicas: 2 → 3",
+// "metadata.labels.tier added" or "spec.ports: 1 → 2 items".
+func structuralChanges(before, after interface{}, at string, out *[]string) {
+	join := func(key string) string {
+		if at == "" {
+			return key
+		}
+		return at + "." + key
+	}
+	switch old := before.(type) {
+	case map[string]interface{}:
+		now, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(old)+len(now))
+		for k := range old {
+			keys = append(keys, k)
+		}
+		for k := range now {
+			if _, ok := old[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			o, had := old[k]
+			n, has := now[k]
+			switch {
+			case !had:
+				*out = append(*out, join(k)+" added")
+			case !has:
+				*out = append(*out, join(k)+" removed")
+			default:
+				structuralChanges(o, n, join(k), out)
+			}
+		}
+		return
+	case []interface{}:
+		now, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		if len(old) != len(now) {
+			*out = append(*out, fmt.Sprintf("%s: %d → %d items", at, len(old), len(now)))
+			return
+		}
+		for i := range old {
+			structuralChanges(old[i], now[i], fmt.Sprintf("%s[%d]", at, i), out)
+		}
+		return
+	}
+	if fmt.Sprint(before) != fmt.Sprint(after) {
+		*out = append(*out, fmt.Sprintf("%s: %s → %s", at, shortValue(before), shortValue(after)))
+	}
+}
+
+// shortValue prints a value in a few words: scalars as they are, cut at 40
+// characters, and maps and lists by their size.
+func shortValue(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return fmt.Sprintf("%d keys", len(v))
+	case []interface{}:
+		return fmt.Sprintf("%d items", len(v))
+	case nil:
+		return "null"
+	}
+	s := []rune(fmt.Sprint(v))
+	if len(s) > 40 {
+		return string(s[:37]) + "..."
+	}
+	return string(s)
+}
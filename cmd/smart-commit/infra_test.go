@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestTerraformChanges(t *testing.T) {
+	f := git.FileChange{ChangedFile: git.ChangedFile{Status: "M", Path: "infra/main.tf"}, Hunks: []git.Hunk{
+		{Header: `resource "aws_lambda_function" "api" {`, Lines: []string{
+			`   runtime = "go1.x"`,
+			`-  memory_size = 128`,
+			`+  memory_size = 256`,
+			` }`,
+		}},
+		{Lines: []string{
+			` `,
+			`-resource "aws_iam_role" "old" {`,
+			`-  name = "old"`,
+			`-}`,
+			`+module "vpc" {`,
+			`+  source = "./vpc"`,
+			`+}`,
+			` data "aws_region" "current" {}`,
+			`+resource "aws_s3_bucket" "logs" {`,
+			`+  bucket = "logs"`,
+			`+}`,
+		}},
+	}}
+	var got []string
+	for _, c := range terraformChanges(f) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"update aws_lambda_function.api (infra/main.tf)",
+		"destroy aws_iam_role.old (infra/main.tf)",
+		"create module.vpc (infra/main.tf)",
+		"create aws_s3_bucket.logs (infra/main.tf)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("terraformChanges() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReadTerraformPlan(t *testing.T) {
+	tests := []struct {
+		name, plan string
+		want       []string
+	}{
+		{
+			"terraform show -json",
+			`{"format_version":"1.2","resource_changes":[` +
+				`{"address":"aws_s3_bucket.logs","change":{"actions":["create"]}},` +
+				`{"address":"aws_instance.web","change":{"actions":["delete","create"]}},` +
+				`{"address":"aws_vpc.main","change":{"actions":["no-op"]}}]}`,
+			[]string{"create aws_s3_bucket.logs (plan)", "replace aws_instance.web (plan)"},
+		},
+		{
+			"terraform plan -json",
+			`{"@level":"info","type":"version","terraform":"1.6.0"}` + "\n" +
+				`{"@level":"info","type":"planned_change","change":{"resource":{"addr":"aws_iam_role.old"},"action":"delete"}}` + "\n" +
+				`{"@level":"info","type":"planned_change","change":{"resource":{"addr":"aws_lambda_function.api"},"action":"update"}}` + "\n" +
+				`{"@level":"info","type":"change_summary","changes":{"add":0,"change":1,"remove":1}}`,
+			[]string{"destroy aws_iam_role.old (plan)", "update aws_lambda_function.api (plan)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "plan.json")
+			os.WriteFile(file, []byte(tt.plan), 0644)
+			report, err := readTerraformPlan(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range report {
+				got = append(got, c.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("readTerraformPlan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	file := filepath.Join(t.TempDir(), "plan.txt")
+	os.WriteFile(file, []byte("Plan: 1 to add, 0 to change, 0 to destroy.\n"), 0644)
+	if _, err := readTerraformPlan(file); err == nil {
+		t.Error("readTerraformPlan accepted human-readable output")
+	}
+}
+
+func TestCommitFlowInfraChanges(t *testing.T) {
+	repo, env := testRepo(t)
+	manifest := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n  namespace: prod\nspec:\n  replicas: 2\n  template:\n    spec:\n      containers:\n        - name: api\n          image: api:1.0\n"
+	os.WriteFile(filepath.Join(repo, "deploy.yaml"), []byte(manifest), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "chore: add deployment")
+
+	manifest = strings.NewReplacer("replicas: 2", "replicas: 3", "api:1.0", "api:1.1").Replace(manifest)
+	manifest += "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\nspec:\n  ports:\n    - port: 80\n"
+	os.WriteFile(filepath.Join(repo, "deploy.yaml"), []byte(manifest), 0644)
+	out, err := runCLI(t, repo, env, fakeProvider(t, "feat: scale the api and expose it"), "--yes", "--no-push")
+	if err != nil {
+		t.Fatalf("smart-commit: %v\n%s", err, out)
+	}
+	want := "feat: scale the api and expose it\n\nInfrastructure changes:\n" +
+		"- update Deployment/prod/api (deploy.yaml): spec.replicas: 2 → 3,\n  spec.template.spec.containers[0].image: api:1.0 → api:1.1\n" +
+		"- create Service/api (deploy.yaml)"
+	if got := strings.TrimSpace(runGit(t, repo, env, "log", "-1", "--format=%B")); got != want {
+		t.Errorf("commit message = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// exitInterrupted is the exit status after Ctrl-C, as shells report it.
+const exitInterrupted = 130
+
+// interrupted is done once the user presses Ctrl-C or the process is asked
+// to stop. Provider requests and the commands they start run with it.
+var interrupted = context.Background()
+
+var (
+	interruptMu sync.Mutex
+	cleanups    = map[int]func(){}
+	nextCleanup int
+)
+
+// handleInterrupts makes Ctrl-C and SIGTERM cancel interrupted, run the
+// cleanups registered with onInterrupt, newest first, and exit.
+func handleInterrupts() {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted = ctx
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+		fmt.Fprintln(os.Stderr, "\nInterrupted.")
+		interruptMu.Lock()
+		for id := nextCleanup - 1; id >= 0; id-- {
+			if cleanup, ok := cleanups[id]; ok {
+				cleanup()
+			}
+		}
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// waitIfInterrupted blocks once the process is interrupted, so that a
+// command failing because of it does not end the run before the interrupt
+// handler has cleaned up.
+func waitIfInterrupted() {
+	if interrupted.Err() != nil {
+		select {}
+	}
+}
+
+// onInterrupt registers cleanup to run if the process is interrupted. The
+// returned function unregisters it, once there is nothing left to undo.
+func onInterrupt(cleanup func()) func() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	id := nextCleanup
+	nextCleanup++
+	cleanups[id] = cleanup
+	return func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		delete(cleanups, id)
+	}
+}
+
+// snapshotIndex saves the index so it can be put back as it was before
+// staging when the run is interrupted. Once something was committed the
+// index belongs to the new HEAD and is left alone.
+func snapshotIndex() (restore func(), err error) {
+	index, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "index")
+	if err != nil {
+		return nil, fmt.Errorf("locating the index: %v", err)
+	}
+	index = strings.TrimSpace(index)
+	data, err := os.ReadFile(index)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	existed := err == nil
+	head, _ := git.Output("rev-parse", "--quiet", "--verify", "HEAD")
+	return func() {
+		// The context is canceled by now, so git runs without it
+		if now, _ := git.Output("rev-parse", "--quiet", "--verify", "HEAD"); now != head {
+			return
+		}
+		if !existed {
+			os.Remove(index)
+			return
+		}
+		tmp := index + ".smart-commit"
+		if err := os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, index)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: restoring the index: %v\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "The index is back as it was; nothing was committed.")
+	}, nil
+}
+
+// providerTimeout is set by --timeout; -1 means not given.
+var providerTimeout = -1
+
+// requestTimeout bounds each provider request: --timeout,
+// SMART_COMMIT_TIMEOUT, the timeout setting or a minute. Zero waits
+// indefinitely.
+func requestTimeout() time.Duration {
+	if providerTimeout >= 0 {
+		return time.Duration(providerTimeout) * time.Second
+	}
+	return config.Current().RequestTimeout()
+}
+
+// requestRetries is how often a request failing transiently is retried:
+// SMART_COMMIT_RETRIES, the retries setting, or twice.
+func requestRetries() int {
+	if n, err := strconv.Atoi(os.Getenv("SMART_COMMIT_RETRIES")); err == nil && n >= 0 {
+		return n
+	}
+	if r := config.Current().Retries; r != nil && *r >= 0 {
+		return *r
+	}
+	return 2
+}
+
+// requestContext bounds a single provider request by the timeout, for
+// requests made without withRetries.
+func requestContext() (context.Context, context.CancelFunc) {
+	if timeout := requestTimeout(); timeout > 0 {
+		return context.WithTimeout(interrupted, timeout)
+	}
+	return context.WithCancel(interrupted)
+}
+
+// retryBackoff is the wait before the first retry; it doubles after that.
+const retryBackoff = time.Second
+
+// withRetries bounds each request gen makes by the timeout and retries
+// transient failures with exponential backoff.
+func withRetries(gen generator.Generator) generator.Generator {
+	timeout, retries := requestTimeout(), requestRetries()
+	if timeout == 0 && retries == 0 {
+		return gen
+	}
+	return &generator.Retrying{Generator: gen, Retries: retries, Backoff: retryBackoff, Timeout: timeout, OnRetry: func(err error, wait time.Duration) {
+		fmt.Fprintf(os.Stderr, "%v; retrying in %s...\n", err, wait)
+	}}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// junkFiles are files editors and operating systems leave behind, never
+// staged automatically. A pattern matches a file name or a directory name
+// anywhere in the path.
+var junkFiles = []string{
+	".DS_Store", "._*", ".AppleDouble", "Thumbs.db", "ehthumbs.db", "desktop.ini",
+	"*.swp", "*.swo", "*~", ".#*", "#*#",
+	"__pycache__", "*.pyc",
+}
+
+// junkPatterns are the junk patterns in effect: the junk_files setting,
+// which an empty list turns off, or the built-in ones.
+func junkPatterns() []string {
+	if patterns := config.Current().JunkFiles; patterns != nil {
+		return patterns
+	}
+	return junkFiles
+}
+
+// junkPattern returns the junk pattern p matches, if any.
+func junkPattern(p string) (string, bool) {
+	for _, pattern := range junkPatterns() {
+		for _, part := range strings.Split(p, "/") {
+			if ok, _ := path.Match(pattern, part); ok {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// holdBackJunk reports the junk files among paths and returns them, so
+// staging can leave them out. When interactive, it offers to add their
+// patterns to .gitignore.
+func holdBackJunk(paths []string, interactive bool) ([]string, error) {
+	var held, patterns []string
+	seen := map[string]bool{}
+	for _, p := range paths {
+		pattern, ok := junkPattern(p)
+		if !ok {
+			continue
+		}
+		held = append(held, p)
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(held) == 0 {
+		return nil, nil
+	}
+
+	fmt.Println("Not staging editor and OS files:")
+	for _, p := range held {
+		fmt.Printf("  %s\n", p)
+	}
+	if interactive && confirm(fmt.Sprintf("Add %s to .gitignore?", strings.Join(patterns, ", "))) {
+		if err := appendGitignore(patterns); err != nil {
+			return nil, err
+		}
+		// Ignored now, so staging skips them by itself (and git refuses
+		// to name ignored files in pathspecs)
+		return nil, nil
+	}
+	return held, nil
+}
+
+// appendGitignore adds patterns to the .gitignore at the repository root.
+func appendGitignore(patterns []string) error {
+	root, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(strings.TrimSpace(root), ".gitignore")
+	existing, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading .gitignore: %v", err)
+	}
+
+	var b strings.Builder
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		b.WriteString("\n")
+	}
+	for _, pattern := range patterns {
+		b.WriteString(pattern + "\n")
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("updating .gitignore: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(b.String()); err != nil {
+		return fmt.Errorf("updating .gitignore: %v", err)
+	}
+	return nil
+}
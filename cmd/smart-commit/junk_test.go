@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestJunkPattern(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{".DS_Store", ".DS_Store"},
+		{"assets/.DS_Store", ".DS_Store"},
+		{"docs/Thumbs.db", "Thumbs.db"},
+		{"main.go.swp", "*.swp"},
+		{"notes.txt~", "*~"},
+		{"src/.#main.go", ".#*"},
+		{"pkg/__pycache__/util.cpython-311.pyc", "__pycache__"},
+		{"main.go", ""},
+		{"docs/store.md", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := junkPattern(tt.path); got != tt.want {
+			t.Errorf("junkPattern(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// messageLanguage is set by main from --lang; empty means
+// SMART_COMMIT_LANG or the language setting.
+var messageLanguage string
+
+// languages are the language codes smart-commit knows, with the name
+// prompts use and the description of the fallback message. Other
+// languages are passed to the model as given.
+var languages = map[string]struct{ Name, Fallback string }{
+	"en":    {"English", "changes to %s"},
+	"pt":    {"Portuguese", "alterações em %s"},
+	"pt-br": {"Brazilian Portuguese", "alterações em %s"},
+	"es":    {"Spanish", "cambios en %s"},
+	"fr":    {"French", "modifications de %s"},
+	"de":    {"German", "Änderungen an %s"},
+	"it":    {"Italian", "modifiche a %s"},
+	"nl":    {"Dutch", "wijzigingen in %s"},
+	"pl":    {"Polish", "zmiany w %s"},
+	"sv":    {"Swedish", "ändringar i %s"},
+	"tr":    {"Turkish", "%s dosyalarında değişiklikler"},
+	"ru":    {"Russian", "изменения в %s"},
+	"uk":    {"Ukrainian", "зміни в %s"},
+	"ja":    {"Japanese", "%s の変更"},
+	"ko":    {"Korean", "%s 변경"},
+	"zh":    {"Simplified Chinese", "修改 %s"},
+	"zh-cn": {"Simplified Chinese", "修改 %s"},
+	"zh-tw": {"Traditional Chinese", "修改 %s"},
+}
+
+// commitLanguage is the language commit message descriptions are written
+// in, as configured: --lang, SMART_COMMIT_LANG or the language setting.
+// Empty means English.
+func commitLanguage() string {
+	if messageLanguage != "" {
+		return messageLanguage
+	}
+	return config.Setting("SMART_COMMIT_LANG", config.Current().Language)
+}
+
+// languageCode returns the code of lang, a code such as pt-BR or pt_BR or
+// a name such as German, in lower case; ok is false for a language not in
+// languages.
+func languageCode(lang string) (code string, ok bool) {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if _, ok := languages[code]; ok {
+		return code, true
+	}
+	// Codes sharing a name share the fallback too, so any of them will do
+	for c, l := range languages {
+		if strings.EqualFold(l.Name, strings.TrimSpace(lang)) {
+			return c, true
+		}
+	}
+	// A regional variant falls back to its language, e.g. es-MX to es
+	if base, _, found := strings.Cut(code, "-"); found {
+		if _, ok := languages[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// languageName is how lang is named in prompts: "Brazilian Portuguese" for
+// pt-BR, and lang itself when it is not a known code. It is empty for
+// English, which needs no instruction.
+func languageName(lang string) string {
+	code, ok := languageCode(lang)
+	if !ok {
+		return strings.TrimSpace(lang)
+	}
+	if code == "en" {
+		return ""
+	}
+	return languages[code].Name
+}
+
+// fallbackDescription is the description of the fallback message for the
+// given files, in lang when smart-commit has a translation and else in
+// English.
+func fallbackDescription(lang, files string) string {
+	format := languages["en"].Fallback
+	if code, ok := languageCode(lang); ok {
+		format = languages[code].Fallback
+	}
+	return fmt.Sprintf(format, files)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		lang, name, fallback string
+	}{
+		{"", "", "changes to a.go"},
+		{"en", "", "changes to a.go"},
+		{"pt-BR", "Brazilian Portuguese", "alterações em a.go"},
+		{"pt_br", "Brazilian Portuguese", "alterações em a.go"},
+		{"es-MX", "Spanish", "cambios en a.go"},
+		{"ja", "Japanese", "a.go の変更"},
+		{"German", "German", "Änderungen an a.go"},
+		{"Klingon", "Klingon", "changes to a.go"},
+	}
+	for _, tt := range tests {
+		if got := languageName(tt.lang); got != tt.name {
+			t.Errorf("languageName(%q) = %q, want %q", tt.lang, got, tt.name)
+		}
+		if got := fallbackDescription(tt.lang, "a.go"); got != tt.fallback {
+			t.Errorf("fallbackDescription(%q) = %q, want %q", tt.lang, got, tt.fallback)
+		}
+	}
+}
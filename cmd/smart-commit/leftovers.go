@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// stagedProblem is something in the staged changes that must not be
+// committed, at a line of a file or, with Line 0, the file as a whole.
+type stagedProblem struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (p stagedProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+}
+
+var (
+	// conflictMarker matches the lines git writes around a conflict; the
+	// "=======" separator only counts next to the others, as it also
+	// underlines headings.
+	conflictMarker = regexp.MustCompile(`^(<{7}|\|{7}|>{7})( |$)`)
+	// mergetoolBackup matches the copies git mergetool leaves next to a
+	// file, such as main_BACKUP_1234.go.
+	mergetoolBackup = regexp.MustCompile(`_(BACKUP|BASE|LOCAL|REMOTE)_\d+(\.|$)`)
+)
+
+// mergeLeftovers finds conflict markers in the lines the change set adds,
+// and the .orig, .rej and mergetool backup files it adds.
+func mergeLeftovers(changes *git.ChangeSet) []stagedProblem {
+	var problems []stagedProblem
+	for _, f := range changes.Files {
+		if f.Status == "D" {
+			continue
+		}
+		base := path.Base(f.Path)
+		if ext := path.Ext(base); ext == ".orig" || ext == ".rej" || mergetoolBackup.MatchString(base) {
+			problems = append(problems, stagedProblem{Path: f.Path, Message: "backup file left by a merge or patch"})
+			continue
+		}
+
+		var markers []stagedProblem
+		separators := 0
+		for _, h := range f.Hunks {
+			line := h.NewStart
+			for _, l := range h.Lines {
+				if l == "" || l[0] == '-' || l[0] == '\\' {
+					continue
+				}
+				if l[0] == '+' {
+					text := strings.TrimRight(l[1:], "\r")
+					if m := conflictMarker.FindStringSubmatch(text); m != nil {
+						markers = append(markers, stagedProblem{Path: f.Path, Line: line, Message: fmt.Sprintf("conflict marker %q", m[1])})
+					} else if text == "=======" {
+						separators++
+						markers = append(markers, stagedProblem{Path: f.Path, Line: line, Message: `conflict marker "======="`})
+					}
+				}
+				line++
+			}
+		}
+		if len(markers) > separators {
+			problems = append(problems, markers...)
+		}
+	}
+	return problems
+}
+
+// checkMergeLeftovers fails with the list of merge leftovers when there are
+// any in the staged changes.
+func checkMergeLeftovers(changes *git.ChangeSet) error {
+	problems := mergeLeftovers(changes)
+	if len(problems) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("the staged changes contain merge leftovers:\n")
+	for _, p := range problems {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+	b.WriteString("resolve the conflicts, or unstage the files with git restore --staged <file>")
+	return fmt.Errorf("%s", b.String())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestMergeLeftovers(t *testing.T) {
+	file := func(status, path string, hunks ...git.Hunk) git.FileChange {
+		return git.FileChange{ChangedFile: git.ChangedFile{Status: status, Path: path}, Hunks: hunks}
+	}
+	conflict := git.Hunk{NewStart: 10, Lines: []string{
+		" func main() {",
+		"-\told()",
+		"+<<<<<<< HEAD",
+		"+\tours()",
+		"+=======",
+		"+\ttheirs()",
+		"+>>>>>>> feature",
+		" }",
+	}}
+	heading := git.Hunk{NewStart: 1, Lines: []string{"+Title", "+=======", "+", "+Text"}}
+
+	tests := []struct {
+		name  string
+		files []git.FileChange
+		want  []string
+	}{
+		{"clean", []git.FileChange{file("M", "main.go", git.Hunk{NewStart: 1, Lines: []string{"+x := 1"}})}, nil},
+		{"conflict", []git.FileChange{file("M", "main.go", conflict)}, []string{
+			`main.go:11: conflict marker "<<<<<<<"`,
+			`main.go:13: conflict marker "======="`,
+			`main.go:15: conflict marker ">>>>>>>"`,
+		}},
+		{"heading underline", []git.FileChange{file("A", "README.rst", heading)}, nil},
+		{"removed markers", []git.FileChange{file("M", "main.go", git.Hunk{NewStart: 1, Lines: []string{"-<<<<<<< HEAD", "->>>>>>> x"}})}, nil},
+		{"backups", []git.FileChange{file("A", "main.go.orig"), file("A", "fix.rej"), file("A", "app_BACKUP_4242.go"), file("D", "old.orig")}, []string{
+			"main.go.orig: backup file left by a merge or patch",
+			"fix.rej: backup file left by a merge or patch",
+			"app_BACKUP_4242.go: backup file left by a merge or patch",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range mergeLeftovers(&git.ChangeSet{Files: tt.files}) {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeLeftovers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
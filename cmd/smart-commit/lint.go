@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chalfel/smart-commit/conventional"
+)
+
+// maxSubjectLength is the longest subject line accepted by lint.
+const maxSubjectLength = 72
+
+// lintProblem is one rule violation found in a commit message.
+type lintProblem struct {
+	// Line is the 1-based line of the message the problem is on.
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (p lintProblem) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", p.Line, p.Message, p.Rule)
+}
+
+// lintMessage checks a commit message against the conventional commit rules
+// and the organization policy, and returns every problem found. Comment lines
+// starting with # are ignored, as git strips them.
+func lintMessage(message string) []lintProblem {
+	pol := currentPolicy()
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return []lintProblem{{Line: 1, Rule: "subject-empty", Message: "subject line is empty"}}
+	}
+
+	var problems []lintProblem
+	// A gitmoji before the type or after the colon is allowed
+	_, subject := conventional.SplitEmoji(strings.TrimSpace(lines[0]))
+
+	commitType, scope, description, _, ok := conventional.ParseSubject(subject)
+	_, description = conventional.SplitEmoji(description)
+	if !ok {
+		problems = append(problems, lintProblem{Line: 1, Rule: "format", Message: "subject must look like type(scope): description"})
+	} else {
+		if !contains(pol.Types, commitType) {
+			problems = append(problems, lintProblem{Line: 1, Rule: "type-enum", Message: fmt.Sprintf("type %q is not one of %s", commitType, strings.Join(pol.Types, ", "))})
+		}
+		if scope != "" && len(pol.Scopes) > 0 && !contains(pol.Scopes, scope) {
+			problems = append(problems, lintProblem{Line: 1, Rule: "scope-enum", Message: fmt.Sprintf("scope %q is not one of %s", scope, strings.Join(pol.Scopes, ", "))})
+		}
+		if rawType := strings.TrimSpace(subject)[:len(commitType)]; !pol.TypeCase.allows(rawType) {
+			problems = append(problems, lintProblem{Line: 1, Rule: "type-case", Message: fmt.Sprintf("type must be %s", pol.TypeCase)})
+		}
+		if scope == "" && pol.ScopeRequired {
+			problems = append(problems, lintProblem{Line: 1, Rule: "scope-empty", Message: "subject must have a scope"})
+		}
+		if !pol.ScopeCase.allows(scope) {
+			problems = append(problems, lintProblem{Line: 1, Rule: "scope-case", Message: fmt.Sprintf("scope must be %s", pol.ScopeCase)})
+		}
+		if !pol.SubjectCase.allows(description) {
+			problems = append(problems, lintProblem{Line: 1, Rule: "subject-case", Message: fmt.Sprintf("description must be %s", pol.SubjectCase)})
+		}
+		if strings.HasSuffix(strings.TrimSpace(description), ".") {
+			problems = append(problems, lintProblem{Line: 1, Rule: "subject-full-stop", Message: "subject must not end with a period"})
+		}
+	}
+
+	// Limits are in bytes, as servers count them
+	if len(lines[0]) > pol.MaxSubjectLength {
+		size := fmt.Sprintf("%d characters", len(lines[0]))
+		if n := utf8.RuneCountInString(lines[0]); n != len(lines[0]) {
+			size = fmt.Sprintf("%d bytes (%d characters)", len(lines[0]), n)
+		}
+		problems = append(problems, lintProblem{Line: 1, Rule: "subject-max-length", Message: fmt.Sprintf("subject is %s, longer than %d", size, pol.MaxSubjectLength)})
+	}
+	if !utf8.ValidString(lines[0]) || strings.IndexFunc(lines[0], unicode.IsControl) >= 0 {
+		problems = append(problems, lintProblem{Line: 1, Rule: "subject-encoding", Message: "subject is not valid UTF-8 or has control characters"})
+	} else if pol.SubjectASCII && !isASCII(lines[0]) {
+		problems = append(problems, lintProblem{Line: 1, Rule: "subject-ascii", Message: "subject must use ASCII characters only"})
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		problems = append(problems, lintProblem{Line: 2, Rule: "body-leading-blank", Message: "body must be separated from the subject by a blank line"})
+	}
+
+	if pol.BodyMaxLineLength > 0 {
+		for i, line := range lines[1:] {
+			if len(line) > pol.BodyMaxLineLength && !strings.Contains(line, "://") {
+				problems = append(problems, lintProblem{Line: i + 2, Rule: "body-max-line-length", Message: fmt.Sprintf("line is %d characters, longer than %d", len(line), pol.BodyMaxLineLength)})
+			}
+		}
+	}
+
+	_, trailers := conventional.SplitTrailers(message)
+	for _, required := range pol.RequiredTrailers {
+		found := false
+		for _, t := range trailers {
+			found = found || strings.EqualFold(t.Token, required)
+		}
+		if !found {
+			problems = append(problems, lintProblem{Line: len(lines), Rule: "trailer-required", Message: fmt.Sprintf("missing required %q trailer in the final trailer block", required)})
+		}
+	}
+	if _, blocked := screenContent(message, "message"); len(blocked) > 0 {
+		for _, rule := range blocked {
+			problems = append(problems, lintProblem{Line: 1, Rule: "banned-content", Message: fmt.Sprintf("matches content rule %q", rule)})
+		}
+	}
+	if pol.Provenance == "forbidden" {
+		for _, t := range trailers {
+			if isProvenanceTrailer(t.Token) {
+				problems = append(problems, lintProblem{Line: len(lines), Rule: "provenance-forbidden", Message: fmt.Sprintf("the policy forbids the %q provenance trailer", t.Token)})
+			}
+		}
+	}
+	for i, line := range lines {
+		for _, re := range pol.banned {
+			if re.MatchString(line) {
+				problems = append(problems, lintProblem{Line: i + 1, Rule: "banned-pattern", Message: fmt.Sprintf("matches banned pattern %q", re.String())})
+			}
+		}
+	}
+	return problems
+}
+
+// maxConformRetries bounds how often a generated message that breaks the
+// commit rules is regenerated.
+const maxConformRetries = 2
+
+// deferredRules are fixed after generation rather than by regenerating:
+// trailers are added and unknown scopes resolved later on.
+var deferredRules = []string{"trailer-required", "scope-enum"}
+
+// generatedProblems lints a generated message, leaving out the problems
+// dealt with later.
+func generatedProblems(message string) []lintProblem {
+	var problems []lintProblem
+	for _, p := range lintMessage(message) {
+		if !contains(deferredRules, p.Rule) {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// describeProblems lists lint problems as feedback for the model.
+func describeProblems(problems []lintProblem) string {
+	var parts []string
+	for _, p := range problems {
+		parts = append(parts, p.Message)
+	}
+	return "it breaks the project's commit rules: " + strings.Join(parts, "; ")
+}
+
+// repairMessage fixes what can be fixed in a subject without the model: the
+// case of the type, scope and description, and a trailing period.
+func repairMessage(message string) string {
+	pol := currentPolicy()
+	subject, rest, hasRest := strings.Cut(message, "\n")
+	commitType, scope, description, breaking, ok := conventional.ParseSubject(subject)
+	if !ok {
+		return message
+	}
+
+	description = strings.TrimSuffix(strings.TrimSpace(description), ".")
+	if scope != "" && !pol.ScopeCase.allows(scope) {
+		scope = strings.ToLower(scope)
+	}
+	if description != "" && !pol.SubjectCase.allows(description) {
+		description = recase(description, pol.SubjectCase)
+	}
+
+	subject = commitType
+	if scope != "" {
+		subject += "(" + scope + ")"
+	}
+	if breaking {
+		subject += "!"
+	}
+	subject += ": " + description
+	if hasRest {
+		return subject + "\n" + rest
+	}
+	return subject
+}
+
+// recase rewrites s to satisfy rule where that is possible without the
+// model, and returns it unchanged otherwise.
+func recase(s string, rule *caseRule) string {
+	var candidates []string
+	if rule.Never {
+		// Usually a ban on capitals: try lowering the first letter, then
+		// everything
+		r := []rune(s)
+		candidates = append(candidates, strings.ToLower(string(r[0]))+string(r[1:]), strings.ToLower(s))
+	} else {
+		for _, c := range rule.Cases {
+			if fixed, ok := toCase(s, c); ok {
+				candidates = append(candidates, fixed)
+			}
+		}
+	}
+	for _, fixed := range candidates {
+		if rule.allows(fixed) {
+			return fixed
+		}
+	}
+	return s
+}
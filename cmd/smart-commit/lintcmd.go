@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// lintResult is the outcome of linting one commit message.
+type lintResult struct {
+	// ID identifies the message: a commit hash or a file name.
+	ID       string        `json:"id"`
+	Subject  string        `json:"subject"`
+	Problems []lintProblem `json:"problems"`
+}
+
+// lintFormats lists the formats lint reports add to those of --render.
+var lintFormats = []string{"github", "junit", "sarif"}
+
+// runLint implements `smart-commit lint`, validating commit messages for CI
+// and hooks and reporting problems in formats CI systems understand.
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	messageFile := fs.String("message-file", "", "lint the message in this file (e.g. from a commit-msg hook) instead of commits")
+	format := renderFlag(fs, "", lintFormats...)
+	legacyFormat := fs.String("format", "", "text, github, junit or sarif; same as --render plain or the format of that name")
+	output := fs.String("output", "", "write the report to this file instead of stdout")
+	preReceive := fs.Bool("pre-receive", false, "run as a server-side pre-receive hook, linting the commits of the ref updates read from stdin")
+	fs.Parse(args)
+
+	switch *legacyFormat {
+	case "":
+	case "text":
+		*format = renderPlain
+	default:
+		*format = *legacyFormat
+	}
+	if err := checkRender(*format, lintFormats...); err != nil {
+		return err
+	}
+
+	var results []lintResult
+	if *preReceive {
+		commits, err := preReceiveCommits(os.Stdin)
+		if err != nil {
+			return err
+		}
+		results = lintCommits(commits)
+	} else if *messageFile != "" {
+		data, err := os.ReadFile(*messageFile)
+		if err != nil {
+			return fmt.Errorf("reading message: %v", err)
+		}
+		message := string(data)
+		results = append(results, lintResult{
+			ID:       *messageFile,
+			Subject:  strings.SplitN(message, "\n", 2)[0],
+			Problems: lintMessage(message),
+		})
+	} else {
+		// Lint the given revision range, or just HEAD
+		revs := fs.Args()
+		if len(revs) == 0 {
+			revs = []string{"-1", "HEAD"}
+		}
+		commits, err := git.LoadCommits(append([]string{"--no-merges"}, revs...)...)
+		if err != nil {
+			return err
+		}
+		results = lintCommits(commits)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("creating report: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeReport(out, *format, lintReport(results)); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if len(r.Problems) > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		if *preReceive {
+			return fmt.Errorf("push rejected: %d of %d new commit messages have problems; reword them (git rebase -i) and push again", failed, len(results))
+		}
+		return fmt.Errorf("%d of %d commit messages have problems", failed, len(results))
+	}
+	return nil
+}
+
+// preReceiveCommits reads pre-receive hook input ("<old> <new> <ref>" per
+// line) and returns the commits the push introduces. Only commits not yet
+// reachable from any existing ref are returned, so history that is already
+// on the server is never re-checked.
+func preReceiveCommits(r io.Reader) ([]git.Commit, error) {
+	var commits []git.Commit
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		newRev, ref := fields[1], fields[2]
+
+		// Deletions (an all-zero new name) introduce no commits, and tags
+		// are not validated
+		if strings.Trim(newRev, "0") == "" || !strings.HasPrefix(ref, "refs/heads/") {
+			continue
+		}
+
+		revCommits, err := git.LoadCommits("--no-merges", newRev, "--not", "--all")
+		if err != nil {
+			return nil, fmt.Errorf("listing commits for %s: %v", ref, err)
+		}
+		for _, c := range revCommits {
+			if !seen[c.Hash] {
+				seen[c.Hash] = true
+				commits = append(commits, c)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ref updates: %v", err)
+	}
+	return commits, nil
+}
+
+// lintCommits lints each commit's full message.
+func lintCommits(commits []git.Commit) []lintResult {
+	results := make([]lintResult, 0, len(commits))
+	for _, c := range commits {
+		results = append(results, lintResult{
+			ID:       git.ShortHash(c.Hash),
+			Subject:  c.Subject,
+			Problems: lintMessage(c.Message()),
+		})
+	}
+	return results
+}
+
+// lintReport lists results and their problems. Besides the --render
+// formats, it renders as GitHub annotations, JUnit and SARIF for CI.
+func lintReport(results []lintResult) report {
+	r := report{Data: results, Formats: map[string]renderer{
+		"github": renderFunc(func(w io.Writer, _ report) error { return writeGitHubAnnotations(w, results) }),
+		"junit":  renderFunc(func(w io.Writer, _ report) error { return writeJUnitReport(w, results) }),
+		"sarif":  renderFunc(func(w io.Writer, _ report) error { return writeSARIFReport(w, results) }),
+	}}
+	if len(results) == 0 {
+		r.paragraph("No commit messages to lint.")
+		return r
+	}
+	var rows [][]string
+	var problems []string
+	for _, res := range results {
+		status := "ok"
+		if len(res.Problems) > 0 {
+			status = "FAIL"
+		}
+		rows = append(rows, []string{status, res.ID, res.Subject})
+		for _, p := range res.Problems {
+			problems = append(problems, fmt.Sprintf("**%s** %s", res.ID, p))
+		}
+	}
+	r.table([]string{"Status", "Commit", "Subject"}, rows)
+	r.list(problems)
+	return r
+}
+
+// writeGitHubAnnotations emits GitHub Actions workflow commands, which the
+// runner turns into annotations on the pull request.
+func writeGitHubAnnotations(w io.Writer, results []lintResult) error {
+	escape := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	escapeProperty := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	for _, r := range results {
+		for _, p := range r.Problems {
+			title := fmt.Sprintf("Commit %s: %s", r.ID, p.Rule)
+			message := fmt.Sprintf("%s\n%s", r.Subject, p.Message)
+			if _, err := fmt.Fprintf(w, "::error file=%s,line=1,title=%s::%s\n", messageAnnotationPath, escapeProperty.Replace(title), escape.Replace(message)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport emits one test case per commit message, failing when the
+// message has problems.
+func writeJUnitReport(w io.Writer, results []lintResult) error {
+	suite := junitTestSuite{Name: "commit-messages", Tests: len(results)}
+	for _, r := range results {
+		tc := junitTestCase{Name: fmt.Sprintf("%s %s", r.ID, r.Subject), ClassName: "smart-commit.lint"}
+		for _, p := range r.Problems {
+			tc.Failures = append(tc.Failures, junitFailure{Message: p.Message, Type: p.Rule, Text: p.String()})
+		}
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeSARIFReport emits a SARIF 2.1.0 log, the format code scanning tools
+// ingest.
+func writeSARIFReport(w io.Writer, results []lintResult) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID              string            `json:"ruleId"`
+		Level               string            `json:"level"`
+		Message             message           `json:"message"`
+		Locations           []location        `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints"`
+	}
+
+	rules := []rule{}
+	seenRules := map[string]bool{}
+	sarifResults := []result{}
+	for _, r := range results {
+		for _, p := range r.Problems {
+			if !seenRules[p.Rule] {
+				seenRules[p.Rule] = true
+				rules = append(rules, rule{ID: p.Rule, ShortDescription: message{Text: p.Message}})
+			}
+			var loc location
+			loc.PhysicalLocation.ArtifactLocation.URI = messageAnnotationPath
+			loc.PhysicalLocation.Region.StartLine = 1
+			sarifResults = append(sarifResults, result{
+				RuleID:    p.Rule,
+				Level:     "error",
+				Message:   message{Text: fmt.Sprintf("Commit %s (%s): %s", r.ID, r.Subject, p.Message)},
+				Locations: []location{loc},
+				// Fingerprint by commit so the same problem is not reported twice
+				PartialFingerprints: map[string]string{"commitMessage/v1": r.ID + ":" + p.Rule},
+			})
+		}
+	}
+
+	log := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "smart-commit",
+						"version":        version,
+						"informationUri": "https://github.com/chalfel/smart-commit",
+						"rules":          rules,
+					},
+				},
+				"results": sarifResults,
+			},
+		},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
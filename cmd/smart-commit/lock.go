@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// repoLock is an exclusive per-repository lock, held while smart-commit
+// stages and commits so that two invocations (say an editor plugin and a
+// terminal) do not race on the index.
+type repoLock struct {
+	path string
+}
+
+// acquireRepoLock takes the repository's lock, a file under .git recording
+// the holder's PID. A lock left behind by a process that no longer runs is
+// taken over.
+func acquireRepoLock() (*repoLock, error) {
+	// Nothing is staged or committed that needs the lock
+	if !writesAllowed() {
+		return &repoLock{}, nil
+	}
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit.lock")
+	if err != nil {
+		return nil, fmt.Errorf("locating repository: %v", err)
+	}
+	path = strings.TrimSpace(path)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &repoLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("creating %s: %v", path, err)
+		}
+
+		data, _ := os.ReadFile(path)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if pid > 0 && processAlive(pid) {
+			return nil, fmt.Errorf("another smart-commit is running in this repository (pid %d); wait for it to finish or remove %s if it is stuck", pid, path)
+		}
+		// The holder is gone; remove its lock and try again
+		os.Remove(path)
+	}
+	return nil, fmt.Errorf("could not take the repository lock at %s", path)
+}
+
+// Release gives the lock up.
+func (l *repoLock) Release() {
+	if l.path != "" {
+		os.Remove(l.path)
+	}
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// On Windows FindProcess already fails for missing processes
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// refreshContext is set by main from --refresh-context: tickets are looked
+// up again instead of answered from the lookup cache.
+var refreshContext bool
+
+// How long a cached lookup is used. Open issues may be closed at any time,
+// closed ones are rarely reopened, and a missing one may be about to be
+// created.
+const (
+	openIssueTTL    = time.Hour
+	closedIssueTTL  = 24 * time.Hour
+	missingIssueTTL = 10 * time.Minute
+)
+
+// cachedLookup is a lookup as stored on disk.
+type cachedLookup struct {
+	Issue   *trackerIssue `json:"issue,omitempty"`
+	Fetched time.Time     `json:"fetched"`
+}
+
+// fresh reports whether the lookup can still be used.
+func (c cachedLookup) fresh() bool {
+	ttl := missingIssueTTL
+	switch {
+	case c.Issue != nil && c.Issue.Open:
+		ttl = openIssueTTL
+	case c.Issue != nil:
+		ttl = closedIssueTTL
+	}
+	return time.Since(c.Fetched) < ttl
+}
+
+// lookupCache answers issue lookups from disk while they are fresh, so
+// commits on the same branch do not ask a rate-limited API about the same
+// ticket again.
+type lookupCache struct {
+	issueTracker
+	// source tells trackers of the same kind apart, e.g. the Jira site.
+	source string
+	// credential is what the tracker is asked with: answers one token may
+	// see are not given to another.
+	credential string
+}
+
+// cacheLookups wraps t in a lookupCache for the tracker at source, asked
+// with credential.
+func cacheLookups(t issueTracker, source, credential string) issueTracker {
+	return &lookupCache{issueTracker: t, source: source, credential: credential}
+}
+
+// path is the cache file of an issue, or "" when there is no user cache
+// directory to keep it in.
+func (c *lookupCache) path(id string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	credential := sha256.Sum256([]byte(c.credential))
+	sum := sha256.Sum256([]byte(c.Name() + "\n" + c.source + "\n" + hex.EncodeToString(credential[:]) + "\n" + id))
+	return filepath.Join(dir, "smart-commit", "lookups", hex.EncodeToString(sum[:8])+".json")
+}
+
+// LookupIssue answers from the cache while it is fresh and asks the
+// tracker otherwise. When the tracker fails, a stale answer is used rather
+// than none.
+func (c *lookupCache) LookupIssue(id string) (*trackerIssue, error) {
+	path := c.path(id)
+	if path == "" {
+		return c.issueTracker.LookupIssue(id)
+	}
+	var cached *cachedLookup
+	if data, err := os.ReadFile(path); err == nil {
+		var entry cachedLookup
+		if json.Unmarshal(data, &entry) == nil {
+			cached = &entry
+		}
+	}
+	if cached != nil && cached.fresh() && !refreshContext {
+		return cached.answer()
+	}
+
+	issue, err := c.issueTracker.LookupIssue(id)
+	if err != nil && !errors.Is(err, errIssueNotFound) {
+		if cached != nil {
+			fmt.Fprintf(os.Stderr, "Warning: looking up %s in %s failed, using the answer from %s: %v\n", id, c.Name(), cached.Fetched.Format(time.RFC3339), err)
+			return cached.answer()
+		}
+		return nil, err
+	}
+	if writesAllowed() {
+		if err := saveLookup(path, cachedLookup{Issue: issue, Fetched: time.Now()}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: caching the lookup of %s: %v\n", id, err)
+		}
+	}
+	return issue, err
+}
+
+// saveLookup replaces the cache file at path atomically. Only the user can
+// read it: the answers come from private trackers.
+func saveLookup(path string, entry cachedLookup) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Directories made by earlier versions were readable by everyone
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// answer is what the tracker said: the issue, or errIssueNotFound.
+func (c cachedLookup) answer() (*trackerIssue, error) {
+	if c.Issue == nil {
+		return nil, errIssueNotFound
+	}
+	return c.Issue, nil
+}
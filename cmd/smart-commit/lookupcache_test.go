@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingTracker answers lookups from issues and counts them.
+type countingTracker struct {
+	issues  map[string]*trackerIssue
+	err     error
+	lookups int
+}
+
+func (t *countingTracker) Name() string               { return "Fake" }
+func (t *countingTracker) References(string) []string { return nil }
+
+func (t *countingTracker) LookupIssue(id string) (*trackerIssue, error) {
+	t.lookups++
+	if t.err != nil {
+		return nil, t.err
+	}
+	if issue, ok := t.issues[id]; ok {
+		return issue, nil
+	}
+	return nil, errIssueNotFound
+}
+
+func TestLookupCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	defer func() { refreshContext = false }()
+	fake := &countingTracker{issues: map[string]*trackerIssue{"ABC-1": {ID: "ABC-1", Title: "Export users", Open: true}}}
+	cached := cacheLookups(fake, "example.atlassian.net", "me@example.com:token")
+
+	tests := []struct {
+		name        string
+		id          string
+		refresh     bool
+		fail        bool
+		wantErr     error
+		wantLookups int
+	}{
+		{"first lookup", "ABC-1", false, false, nil, 1},
+		{"cached", "ABC-1", false, false, nil, 1},
+		{"refreshed", "ABC-1", true, false, nil, 2},
+		{"missing", "ABC-2", false, false, errIssueNotFound, 3},
+		{"missing, cached", "ABC-2", false, false, errIssueNotFound, 3},
+		{"tracker down, never looked up", "ABC-3", false, true, errors.New("unreachable"), 4},
+		{"tracker down, cached answer", "ABC-1", true, true, nil, 5},
+	}
+	for _, tt := range tests {
+		refreshContext = tt.refresh
+		fake.err = nil
+		if tt.fail {
+			fake.err = errors.New("unreachable")
+		}
+		issue, err := cached.LookupIssue(tt.id)
+		if (err == nil) != (tt.wantErr == nil) || (tt.wantErr == errIssueNotFound && err != errIssueNotFound) {
+			t.Errorf("%s: LookupIssue(%s) error = %v, want %v", tt.name, tt.id, err, tt.wantErr)
+		}
+		if err == nil && issue.Title != "Export users" {
+			t.Errorf("%s: LookupIssue(%s) = %+v", tt.name, tt.id, issue)
+		}
+		if fake.lookups != tt.wantLookups {
+			t.Errorf("%s: the tracker was asked %d times, want %d", tt.name, fake.lookups, tt.wantLookups)
+		}
+	}
+
+	// An entry past its TTL is looked up again
+	refreshContext, fake.err = false, nil
+	path := cached.(*lookupCache).path("ABC-2")
+	if err := saveLookup(path, cachedLookup{Fetched: time.Now().Add(-missingIssueTTL)}); err != nil {
+		t.Fatal(err)
+	}
+	cached.LookupIssue("ABC-2")
+	if fake.lookups != 6 {
+		t.Errorf("a stale entry was not looked up again (%d lookups)", fake.lookups)
+	}
+}
+
+func TestLookupCacheIsPrivate(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	fake := &countingTracker{issues: map[string]*trackerIssue{"ABC-1": {ID: "ABC-1", Title: "Export users", Open: true}}}
+
+	// Another credential does not get the answers of the first
+	cacheLookups(fake, "example.atlassian.net", "alice:token").LookupIssue("ABC-1")
+	cacheLookups(fake, "example.atlassian.net", "bob:token").LookupIssue("ABC-1")
+	if fake.lookups != 2 {
+		t.Errorf("the tracker was asked %d times for two credentials, want 2", fake.lookups)
+	}
+
+	dir := filepath.Join(cacheDir, "smart-commit", "lookups")
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("cache directory mode = %v, want 0700", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("cache entries = %v (%v), want two", entries, err)
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", entry.Name(), info.Mode().Perm())
+		}
+	}
+
+	// Without a user cache directory nothing is cached
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	cached := cacheLookups(fake, "example.atlassian.net", "alice:token")
+	cached.LookupIssue("ABC-1")
+	cached.LookupIssue("ABC-1")
+	if fake.lookups != 4 {
+		t.Errorf("the tracker was asked %d times without a cache directory, want 4", fake.lookups)
+	}
+}
@@ -0,0 +1,853 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+	"github.com/chalfel/smart-commit/pipeline"
+)
+
+// subcommands maps a subcommand name to its entry point. Running the binary
+// without a known subcommand falls through to the default commit flow.
+var subcommands = map[string]func(args []string) error{
+	"digest":      runDigest,
+	"compare":     runCompare,
+	"hotspots":    runHotspots,
+	"branches":    runBranches,
+	"serve":       runServe,
+	"action":      runAction,
+	"lint":        runLint,
+	"policy":      runPolicy,
+	"config":      runConfig,
+	"auth":        runAuth,
+	"prompt":      runPrompt,
+	"eval":        runEval,
+	"canary":      runCanaryCmd,
+	"squash":      runSquash,
+	"hook":        runHook,
+	"edit-msg":    runEditMsg,
+	"daemon":      runDaemon,
+	"changelog":   runChangelog,
+	"providers":   runProviders,
+	"release":     runRelease,
+	"cut-release": runCutRelease,
+	"version":     runVersion,
+	"pr":          runPR,
+	"index":       runIndex,
+}
+
+func main() {
+	handleInterrupts()
+	args, readOnlyFlag := takeReadOnlyFlag(os.Args[1:])
+	os.Args = append(os.Args[:1], args...)
+	if readOnlyFlag || os.Getenv("SMART_COMMIT_READ_ONLY") == "1" {
+		enableReadOnly()
+	}
+	// smart-commit continue runs the commit flow again with the arguments
+	// of the run it resumes, from the stage that run stopped at
+	var resumeFrom *checkpoint
+	if len(os.Args) > 1 && os.Args[1] == "continue" {
+		if readOnly {
+			fmt.Fprintln(os.Stderr, "Error: continue is not available with --read-only")
+			os.Exit(1)
+		}
+		cp, err := continueFlow(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if cp == nil {
+			return
+		}
+		resumeFrom = cp
+		os.Args = append(os.Args[:1], cp.Args...)
+	}
+	flowArgs := append([]string(nil), os.Args[1:]...)
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if readOnly {
+				if err := checkReadOnlyCommand(os.Args[1], os.Args[2:]); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg := config.Current()
+	rebase := flag.Bool("rebase", config.Bool(cfg.Rebase, false), "fetch the base branch and rebase onto it before pushing if it moved")
+	baseBranch := flag.String("base", cfg.Base, "base branch for --rebase (default: the repository's default branch)")
+	testCmd := flag.String("test-cmd", config.Setting("SMART_COMMIT_TEST_CMD", cfg.TestCmd), "command that must pass after staging before anything is committed")
+	testSummary := flag.Bool("test-summary", false, "include the test command's summary in the commit body")
+	clearIndexLock := flag.Bool("clear-index-lock", false, "remove a stale .git/index.lock left by a crashed git without asking")
+	provider := flag.String("provider", config.Setting("SMART_COMMIT_PROVIDER", cfg.Provider), "AI provider: "+strings.Join(generator.Names, ", ")+" (default copilot)")
+	model := flag.String("model", config.Setting("SMART_COMMIT_MODEL", cfg.Model), "model to use with the provider (default: the provider's default)")
+	yes := flag.Bool("yes", false, "commit the generated message without reviewing it")
+	noInteractive := flag.Bool("no-interactive", false, "same as --yes")
+	stagedOnly := flag.Bool("staged-only", false, "commit only what is already staged instead of staging everything")
+	pick := flag.Bool("pick", false, "choose interactively which changed files to stage")
+	lang := flag.String("lang", commitLanguage(), "language of the description, e.g. pt-BR, es or ja; the type and scope stay in English")
+	maxDiff := flag.Int("max-diff", maxDiffBudget(), "bytes of diff to send to the model; 0 sends file names only")
+	styleFlag := flag.Int("style-history", styleHistory(), "imitate the style of this many recent commits; 0 turns it off")
+	canarySpec := flag.String("canary", config.Setting("SMART_COMMIT_CANARY", cfg.Canary), "also generate with a candidate configuration and log both, e.g. provider=anthropic,prompt=3")
+	push := flag.Bool("push", config.Bool(cfg.Push, true), "push after committing")
+	noPush := flag.Bool("no-push", false, "commit without pushing; same as --push=false")
+	remote := flag.String("remote", config.Setting("SMART_COMMIT_REMOTE", cfg.Remote), "remote to push to (default: the branch's upstream)")
+	body := flag.Bool("body", config.Bool(cfg.Body, false), "add a body explaining why, with a bullet point per group of files")
+	footer := flag.Bool("footer", config.Bool(cfg.Footer, false), "add a BREAKING CHANGE footer when the change breaks compatibility")
+	emoji := flag.Bool("emoji", config.Bool(cfg.Emoji, false), "start the subject with the gitmoji for its type, e.g. ✨ for feat")
+	pushTo := flag.String("push-branch", "", "remote branch to push to (default: the branch's upstream, or the same name)")
+	setUpstream := flag.Bool("set-upstream", false, "make the branch track the branch it is pushed to")
+	var addPaths stringList
+	flag.Var(&addPaths, "add", "stage only changes matching this pathspec (repeatable)")
+	split := flag.Bool("split", false, "split the staged changes into several commits, one per logical change")
+	splitBy := flag.String("split-by", "dir", "how --split groups files: dir or ai")
+	dryRun := flag.Bool("dry-run", false, "generate and print the message without committing or pushing; nothing is staged")
+	note := flag.String("m", "", "rough message stating the intent; the model expands and formats it")
+	why := flag.String("why", "", "why the change was made, for the motivation paragraph of the body")
+	notesFile := flag.String("notes", "", "file of free-form notes to summarize into the commit body")
+	typeFlag := flag.String("type", "", "conventional commit type to use, e.g. fix; the model writes the rest")
+	scopeFlag := flag.String("scope", "", "commit scope to use, e.g. auth; the model writes the rest")
+	allowSecrets := flag.Bool("allow-secrets", false, "commit even if the staged changes look like they contain secrets")
+	allowSensitive := flag.Bool("allow-sensitive", false, "stage and commit .env files, private keys and other credential files")
+	defaults := currentSigning()
+	signoff := flag.Bool("signoff", defaults.Signoff, "add a Signed-off-by trailer (git commit --signoff)")
+	gpgSign := optionalString{Given: defaults.Sign, Value: defaults.Key}
+	flag.Var(&gpgSign, "gpg-sign", "sign the commit (--gpg-sign), or sign it with KEYID (--gpg-sign=KEYID)")
+	allowEmpty := flag.Bool("allow-empty", false, "make an empty commit with the -m message when nothing is staged")
+	timeout := flag.Int("timeout", int(requestTimeout()/time.Second), "seconds to wait for each provider request before retrying or falling back; 0 waits indefinitely")
+	resume := flag.Bool("resume", false, "commit the message an interrupted run generated for the same staged changes")
+	regenerateFlag := flag.Bool("regenerate", false, "ask the provider again instead of reusing the message generated earlier for the same changes")
+	flag.BoolVar(&refreshContext, "refresh-context", false, "look tickets up again instead of using the answers cached from earlier commits")
+	amend := flag.Bool("amend", false, "rewrite HEAD, with a message regenerated for it and the newly staged changes")
+	var fixup optionalString
+	flag.Var(&fixup, "fixup", "commit the staged changes as a fixup! of REV (--fixup REV), or of a commit picked from recent history")
+	render := flag.String("render", "", "print a summary of the commit on stdout, and progress on stderr, in this format: "+formatList(renderFormats))
+	output := flag.String("output", "", "text or json; json is the same as --render json")
+	var trailerSpecs stringList
+	flag.Var(&trailerSpecs, "trailer", "add a trailer such as \"Reviewed-by: Jane <jane@example.com>\" (repeatable)")
+	var checkCmds stringList
+	flag.Var(&checkCmds, "check", "pre-commit check command to run alongside message generation (repeatable)")
+	withFileNotes := flag.Bool("file-notes", config.Bool(cfg.FileNotes, false), "experimental: also summarize each changed file, in a git note on the commit and in --render output")
+	tui := flag.Bool("tui", false, "pick, edit and confirm the message in a full-screen view of the diff and candidate messages")
+	noVerify := flag.Bool("no-verify", false, "skip the checks configured in the checks setting")
+	provenance := flag.Bool("provenance", config.Bool(cfg.Provenance, false), "add AI-Model and AI-Prompt-Hash trailers recording what generated the message")
+	flag.Bool("read-only", readOnly, "only suggest a message for what is staged: never stage, commit, push or write to the repository (also SMART_COMMIT_READ_ONLY=1)")
+	plan := flag.String("plan", "", "JSON output of terraform plan -json or terraform show -json, to describe the resources the change affects")
+	flag.BoolVar(&verbose, "verbose", os.Getenv("SMART_COMMIT_VERBOSE") == "1", "report the tools, network, terminal and hooks found, and what the run does without the missing ones")
+	flag.Parse()
+	fixup.takeArg(flag.Args())
+	if readOnly {
+		flag.Visit(func(f *flag.Flag) {
+			for _, denied := range readOnlyDefaultFlags {
+				if f.Name == denied && f.Value.String() != "false" {
+					fmt.Fprintf(os.Stderr, "Error: --%s is not available with --read-only\n", f.Name)
+					os.Exit(1)
+				}
+			}
+		})
+		// Only suggest: what is staged, without committing
+		*dryRun, *stagedOnly, *push = true, true, false
+	}
+
+	switch *output {
+	case "", "text":
+	case "json":
+		*render = renderJSON
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --output %q: expected text or json\n", *output)
+		os.Exit(1)
+	}
+	if *render != "" {
+		if err := checkRender(*render); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *split && (*dryRun || *render != "") {
+		fmt.Fprintln(os.Stderr, "Error: --split cannot be combined with --dry-run or --render")
+		os.Exit(1)
+	}
+	if (*amend || fixup.Given) && *split || *amend && fixup.Given {
+		fmt.Fprintln(os.Stderr, "Error: --amend, --fixup and --split cannot be combined")
+		os.Exit(1)
+	}
+	if *tui && (*split || *dryRun || *yes || *noInteractive || fixup.Given || *render != "") {
+		fmt.Fprintln(os.Stderr, "Error: --tui cannot be combined with --split, --dry-run, --yes, --fixup or --render")
+		os.Exit(1)
+	}
+	if *allowEmpty && strings.TrimSpace(*note) == "" {
+		fmt.Fprintln(os.Stderr, "Error: --allow-empty needs the message as -m")
+		os.Exit(1)
+	}
+	// Outside a repository every later step would fail with raw git errors
+	exitOnState(checkRepository(*amend || fixup.Given))
+	// Signing that cannot work would only fail once everything is generated
+	signing = &commitSigning{Signoff: *signoff, Sign: gpgSign.Given && gpgSign.Value != "false"}
+	if signing.Sign {
+		signing.Key = gpgSign.Value
+	}
+	signed := false
+	if !*dryRun {
+		var err error
+		if signed, err = checkSigning(*signing); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Keep stdout for the summary; everything else goes to stderr
+	stdout := os.Stdout
+	if *render != "" {
+		os.Stdout = os.Stderr
+	}
+
+	// Select the AI provider and check that it can be used
+	providerTimeout = *timeout
+	gen, err := newGenerator(*provider, *model)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	useGenerator(gen)
+	diffBudget = *maxDiff
+	messageLanguage = *lang
+	regenerate = *regenerateFlag
+	styleCommits = *styleFlag
+	withBody, withFooter, withEmoji = body, footer, emoji
+	messageNote, messageWhy = strings.TrimSpace(*note), strings.TrimSpace(*why)
+	provenanceFlag = provenance
+	if *provenance && currentPolicy().Provenance == "forbidden" {
+		fmt.Fprintln(os.Stderr, "Warning: the commit policy forbids provenance trailers; leaving them out")
+	}
+	pinnedType, pinnedScope = strings.ToLower(strings.TrimSpace(*typeFlag)), strings.TrimSpace(*scopeFlag)
+	// The branch's rule, as for hotfix/* branches, can fix the type too
+	rule, err := currentBranchRule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if rule != nil && rule.Type != "" && pinnedType == "" {
+		pinnedType = strings.ToLower(rule.Type)
+	}
+	if pinnedType != "" && !contains(currentPolicy().Types, pinnedType) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --type %q (expected %s)\n", pinnedType, strings.Join(currentPolicy().Types, ", "))
+		os.Exit(1)
+	}
+	if *plan != "" {
+		if terraformPlan, err = readTerraformPlan(*plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *notesFile != "" {
+		if messageNotes, err = readNotes(*notesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Optional tools that are missing degrade the run instead of stopping
+	// it: without a usable provider the message is worked out from the diff
+	providerName := strings.ToLower(*provider)
+	if providerName == "" {
+		providerName = defaultProvider()
+	}
+	caps := detectCapabilities(providerName)
+	if !caps.has("provider") {
+		fmt.Fprintf(os.Stderr, "Warning: %s; writing the message from the diff alone\n", caps.detail("provider"))
+		degrade("provider", "the message is worked out from the diff instead of by %s", gen.Name())
+		useGenerator(unavailableGenerator{Generator: gen, err: errors.New(caps.detail("provider"))})
+	}
+	if *tui && !caps.has("terminal") {
+		fmt.Fprintln(os.Stderr, "Warning: --tui needs an interactive terminal; committing without review")
+		degrade("terminal", "--tui is skipped")
+		*tui = false
+	}
+	if verbose {
+		reportCapabilities(caps)
+	}
+
+	if *splitBy != "dir" && *splitBy != "ai" {
+		fmt.Fprintf(os.Stderr, "Error: unknown --split-by %q: expected dir or ai\n", *splitBy)
+		os.Exit(1)
+	}
+	interactive := !*yes && !*noInteractive && caps.has("terminal")
+	if !*yes && !*noInteractive && !interactive {
+		degrade("terminal", "the message is committed without review")
+	}
+	// Rewritten history is pushed deliberately, after a rebase or with
+	// --force, so amending and fixups only commit
+	if *noPush || *amend || fixup.Given {
+		*push = false
+	}
+	// A rebased branch is pushed with --force-with-lease
+	if rule != nil && rule.NoForcePush && *rebase {
+		fmt.Printf("Not rebasing: %s branches are never force-pushed.\n", rule.Pattern)
+		*rebase = false
+	}
+	pushOpts := pushOptions{
+		Push: *push, Rebase: *rebase, Base: *baseBranch,
+		Remote: *remote, Branch: *pushTo, SetUpstream: *setUpstream, Interactive: interactive,
+	}
+
+	// Find out before committing whether the push can happen at all
+	if !*dryRun {
+		exitOnState(checkPushState(pushOpts))
+	}
+
+	trailers, err := configuredTrailers(trailerSpecs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The canary candidate is only logged; the current configuration commits
+	current := canaryArm{Provider: strings.ToLower(*provider), Model: *model, PromptVersion: commitPromptVersion()}
+	if current.Provider == "" {
+		current.Provider = defaultProvider()
+	}
+	var candidate canaryArm
+	if *canarySpec != "" {
+		candidate, err = parseCanarySpec(*canarySpec, current)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Keep concurrent invocations from racing on the index. Error exits skip
+	// the release; the next run takes over the lock once this process is gone.
+	lock, err := acquireRepoLock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	// A stale index.lock would make staging fail midway with a raw git error
+	if err := checkIndexLock(*clearIndexLock); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// A dry run stages into a throwaway copy of the index; otherwise the
+	// index is put back as it was if the run is interrupted before committing
+	if *dryRun {
+		cleanup, err := useTemporaryIndex()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer cleanup()
+		defer onInterrupt(cleanup)()
+	} else {
+		restore, err := snapshotIndex()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer onInterrupt(restore)()
+	}
+
+	// Stage what the user asked for; everything by default, respecting
+	// sparse checkouts
+	switch {
+	case *stagedOnly:
+	case len(addPaths) > 0:
+		err = stagePathspecs(addPaths)
+	case *pick:
+		if !isTerminal(os.Stdin) {
+			fmt.Fprintln(os.Stderr, "Error: --pick needs an interactive terminal")
+			os.Exit(1)
+		}
+		err = pickAndStage()
+	default:
+		// A dry run leaves the work tree and history alone
+		err = stageAll(*allowSensitive, interactive && !*dryRun)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error adding files to git: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The rest of the flow runs stage by stage; every stage sees what the
+	// ones before it left in state
+	var (
+		empty        bool
+		checkResults <-chan []checkResult
+		canaryResult <-chan canaryArm
+		commitBody   string
+	)
+	// The library's flow, with the CLI's stages added or in place of its
+	// own; until it finishes, where it stopped is kept for smart-commit
+	// continue
+	repo := git.Repo{}
+	flow := pipeline.Default(repo, currentGenerator(), cfg)
+	if !*dryRun {
+		flow.Use(checkpoints(flowArgs))
+	}
+
+	// Parse the changes once for everything that needs them. When amending,
+	// that is the whole commit being rewritten
+	collect := pipeline.CollectChanges(repo)
+	loadChanges := func(ctx context.Context, s *pipeline.State) error {
+		s.Changes = nil
+		if err := collect(ctx, s); err != nil {
+			return err
+		}
+		if s.Amend {
+			apiBase = s.Base
+		}
+		empty = len(s.Changes.Files) == 0
+		return nil
+	}
+	// Run checks in the background so they overlap with message generation
+	startChecks := func() {
+		checks := []string(checkCmds)
+		if *testCmd != "" {
+			checks = append(checks, *testCmd)
+		}
+		if len(checks) > 0 && !*dryRun {
+			fmt.Printf("Running %d check(s) in the background...\n", len(checks))
+			checkResults = runChecksAsync(checks)
+		}
+	}
+	flow.Handle(pipeline.Collect, func(ctx context.Context, s *pipeline.State) error {
+		if err := loadChanges(ctx, s); err != nil {
+			return err
+		}
+		changes := s.Changes
+		// An empty commit is made only when asked for, with the message as given
+		if empty && (!*allowEmpty || *split || fixup.Given) {
+			// A rerun after a failed push has nothing left to commit, only to push
+			if hash, ok := unpushedCommit(); ok && pushOpts.Push && !*dryRun {
+				fmt.Printf("%s was committed by an earlier run but not pushed; retrying the push.\n", git.ShortHash(hash))
+				pushBranch(pushOpts)
+				return pipeline.ErrStop
+			}
+			exitOnState(nothingToCommit(*stagedOnly))
+		}
+		// Half-resolved merges must not slip into a commit
+		if err := checkMergeLeftovers(changes); err != nil {
+			return err
+		}
+		// Everything was staged automatically, so look for what shouldn't be
+		if err := scanStaged(changes, *allowSecrets); err != nil {
+			return err
+		}
+		if !*allowSensitive {
+			if err := checkSensitiveFiles(changes); err != nil {
+				return err
+			}
+		}
+		warnDestructiveSQL(changes)
+
+		// The configured checks must pass on what is staged before anything is
+		// generated, so broken code is never committed and pushed
+		if checks := cfg.Checks; len(checks) > 0 && !*noVerify && !*dryRun && !empty {
+			if err := runStagedChecks(checks); err != nil {
+				return fmt.Errorf("%v; nothing was committed (--no-verify skips the checks)", err)
+			}
+		}
+
+		startChecks()
+		return nil
+	})
+
+	flow.Handle(pipeline.Generate, func(ctx context.Context, s *pipeline.State) error {
+		// A fixup's message is fixed by git; only its target needs choosing
+		if fixup.Given {
+			target, err := resolveFixupTarget(fixup.Value, s.Changes, interactive)
+			if err != nil {
+				return err
+			}
+			if *dryRun {
+				fmt.Printf("\nfixup! %s\n", target.Subject)
+				return pipeline.ErrStop
+			}
+			waitForChecks(checkResults, *testCmd, false)
+			fmt.Printf("Committing fixup for %s %s\n", git.ShortHash(target.Hash), target.Subject)
+			if err := gitCommit("--fixup=" + target.Hash); err != nil {
+				return fmt.Errorf("committing changes: %v", err)
+			}
+			return pipeline.ErrStop
+		}
+
+		// Split mode commits group by group once the checks have passed
+		if *split {
+			commitBody := waitForChecks(checkResults, *testCmd, *testSummary)
+			err := commitSplit(s.Changes, splitOptions{By: *splitBy, Interactive: interactive, Trailers: trailers, LastBody: commitBody, Rule: rule})
+			if err != nil {
+				return err
+			}
+			pushBranch(pushOpts)
+			showReminder(rule)
+			return pipeline.ErrStop
+		}
+
+		// A message an interrupted run generated for exactly these changes
+		// can be committed as is
+		resumed := s.Message != ""
+		if empty {
+			if s.Message, err = addTrailers(conventional.Enforce(messageNote, "chore"), trailers); err != nil {
+				return err
+			}
+		} else if s.Message == "" && !*dryRun {
+			s.Message, resumed = resumeMessage(*resume, interactive)
+		}
+
+		if *canarySpec != "" && !*dryRun && !resumed && !empty {
+			canaryResult = runCanary(candidate, s.Changes)
+		}
+
+		if !resumed && !empty {
+			fmt.Printf("Generating commit message with %s...\n", gen.Name())
+			start := time.Now()
+			s.Message, err = suggestCommitMessage(s.Changes, strings.Join(s.Context, "\n\n"))
+			current.LatencyMS = time.Since(start).Milliseconds()
+			current.Message = s.Message
+			if err != nil {
+				current.Error = err.Error()
+				fmt.Printf("%s error: %v\n", gen.Name(), err)
+			}
+			// Confirm the scope against the ones used before when it is unclear
+			if s.Message, err = resolveScope(s.Message, s.Changes, interactive); err != nil {
+				return err
+			}
+			s.Message = checkSubjectEncoding(s.Message, interactive)
+			if s.Message, err = addTrailers(s.Message, trailers); err != nil {
+				return err
+			}
+			// Keep it until it is committed, in case this run is interrupted
+			savePendingMessage(s.Message)
+		}
+
+		if *dryRun {
+			var notes []fileNote
+			if *withFileNotes && !empty {
+				notes = runFileNotes(s.Message, s.Changes, false)
+			}
+			if *render != "" {
+				out := newCommitOutput(s.Message, s.Changes, gen)
+				out.DryRun = true
+				out.addFileNotes(notes)
+				writeReport(stdout, *render, out.report())
+			} else {
+				fmt.Printf("\n%s\n", s.Message)
+				for _, n := range notes {
+					fmt.Printf("  %s: %s\n", n.Path, n.Summary)
+				}
+			}
+			return pipeline.ErrStop
+		}
+
+		// Let the user review the message unless running unattended; an empty
+		// commit has nothing to regenerate it from
+		if *tui && !empty {
+			var excluded []string
+			if s.Message, excluded, err = tuiReview(s.Message, s.Changes, trailers); err != nil {
+				return err
+			}
+			// Files left out were unstaged; the rest is what gets committed
+			if len(excluded) > 0 {
+				if err := loadChanges(ctx, s); err != nil {
+					return err
+				}
+			}
+			savePendingMessage(s.Message)
+		} else if interactive && !empty {
+			if s.Message, err = reviewMessage(s.Message, s.Changes); err != nil {
+				return err
+			}
+			savePendingMessage(s.Message)
+		}
+		return nil
+	})
+
+	flow.Handle(pipeline.Validate, func(ctx context.Context, s *pipeline.State) error {
+		// Verify referenced tickets against the configured issue tracker
+		if err := checkTicketRequired(rule, s.Message); err != nil {
+			return err
+		}
+		if err := verifyTicketReferences(s.Message); err != nil {
+			return err
+		}
+		// Commit-on-green: wait for the checks and only continue when all pass
+		commitBody = waitForChecks(checkResults, *testCmd, *testSummary)
+		return nil
+	})
+
+	flow.Handle(pipeline.Transform, func(ctx context.Context, s *pipeline.State) error {
+		// The test summary goes before the trailers, which must stay last
+		if commitBody != "" {
+			if s.Message, err = appendBody(s.Message, commitBody); err != nil {
+				return err
+			}
+		}
+		// Keep banned content out of history, whoever wrote it
+		s.Message, err = screenMessage(s.Message)
+		return err
+	})
+
+	// Commit with the message, showing what git and its hooks print
+	flow.Handle(pipeline.Commit, pipeline.GitCommit(repo, os.Stdout, os.Stderr))
+	flow.Use(pipeline.Before(pipeline.Commit, func(ctx context.Context, s *pipeline.State) error {
+		fmt.Printf("Committing with message: %s\n", s.Message)
+		return nil
+	}), pipeline.After(pipeline.Commit, func(ctx context.Context, s *pipeline.State) error {
+		clearPendingMessage()
+		rememberMessage(s.Message, s.Changes)
+		if canaryResult != nil {
+			entry := canaryEntry{Time: time.Now(), Files: len(s.Changes.Files), Current: current, Candidate: <-canaryResult, Committed: s.Message}
+			if err := logCanary(entry); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: logging canary result: %v\n", err)
+			}
+		}
+		return nil
+	}))
+
+	flow.Handle(pipeline.Publish, func(ctx context.Context, s *pipeline.State) error {
+		// Summaries for review tooling go in a note on the new commit
+		var notes []fileNote
+		if *withFileNotes && !empty {
+			notes = runFileNotes(s.Message, s.Changes, true)
+		}
+
+		pushBranch(pushOpts)
+		showReminder(rule)
+
+		if *render != "" {
+			out := newCommitOutput(s.Message, s.Changes, gen)
+			out.addFileNotes(notes)
+			out.Commit = s.Commit
+			out.Pushed, out.Signed = *push, signed
+			writeReport(stdout, *render, out.report())
+		}
+		return nil
+	})
+
+	state := &pipeline.State{Amend: *amend, AllowEmpty: true, CommitArgs: currentSigning().args()}
+	stage := pipeline.Collect
+	if resumeFrom != nil {
+		state.Message, state.Commit = resumeFrom.Message, resumeFrom.Commit
+		stage = resumeFrom.Stage
+		// What is staged now has to pass what the stopped run checked
+		if tree, _ := stagedTree(); resumeFrom.Tree != "" && tree != resumeFrom.Tree {
+			stage = pipeline.Collect
+		}
+		if err := resumeState(stage, state, loadChanges, startChecks); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := flow.RunFrom(interrupted, stage, state); err != nil {
+		waitIfInterrupted()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !*dryRun {
+		clearCheckpoint()
+	}
+}
+
+// resumeState restores what the stages before stage left for it: the
+// commit's changes for publish, else the staged changes and, when the
+// checks are still to be waited for, the background checks.
+func resumeState(stage pipeline.Stage, s *pipeline.State, loadChanges pipeline.Handler, startChecks func()) error {
+	switch stage {
+	case pipeline.Collect:
+		return nil
+	case pipeline.Publish:
+		changes, err := git.LoadChangeSet(s.Commit + "^!")
+		if err != nil {
+			changes = &git.ChangeSet{}
+		}
+		s.Changes = changes
+		return nil
+	}
+	if err := loadChanges(interrupted, s); err != nil {
+		return err
+	}
+	if stage != pipeline.Transform && stage != pipeline.Commit {
+		startChecks()
+	}
+	return nil
+}
+
+// waitForChecks waits for the background checks and exits when any failed.
+// It returns the test summary for the commit body when testSummary is set.
+func waitForChecks(results <-chan []checkResult, testCmd string, testSummary bool) string {
+	if results == nil {
+		return ""
+	}
+	var commitBody string
+	failed := false
+	for _, result := range <-results {
+		if result.Err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "%s\n%v\n", result.Output, result.Err)
+			continue
+		}
+		fmt.Printf("Check passed: %s\n", result.Command)
+		if result.Command == testCmd && testSummary {
+			commitBody = testSummaryBody(result.Command, result.Summary)
+		}
+	}
+	if failed {
+		waitIfInterrupted()
+		fmt.Fprintln(os.Stderr, "Error: checks failed; nothing was committed")
+		os.Exit(1)
+	}
+	return commitBody
+}
+
+func executeCommand(command string, args ...string) error {
+	cmd := exec.CommandContext(interrupted, command, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := git.DefaultRunner.Run(cmd)
+	if err != nil {
+		waitIfInterrupted()
+	}
+	return err
+}
+
+func executeCommandWithOutput(command string, args ...string) (string, error) {
+	cmd := exec.CommandContext(interrupted, command, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := git.DefaultRunner.Run(cmd)
+	if err != nil {
+		waitIfInterrupted()
+		return "", fmt.Errorf("command failed: %v: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// fallbackMessage is the message used when no provider answers, worked out
+// from the diff alone. In other languages than English the description
+// only names the changed files.
+func fallbackMessage(changes *git.ChangeSet) string {
+	commitType, scope, description := generator.Heuristic(changes)
+	if languageName(commitLanguage()) != "" {
+		changedFiles := changes.Paths()
+		description = fallbackDescription(commitLanguage(), strings.Join(changedFiles[:min(len(changedFiles), 5)], ", "))
+	}
+	return conventional.WithScope(commitType+": "+description, scope)
+}
+
+// settleParts gives message the scope of the monorepo workspace changed,
+// if any, and the pinned type and scope, which win over everything.
+func settleParts(changes *git.ChangeSet, message string) string {
+	if scope := inferScope(changes); scope != "" && pinnedScope == "" {
+		message = conventional.WithScope(message, scope)
+	}
+	if pinnedType != "" {
+		message = conventional.WithType(message, pinnedType)
+	}
+	if pinnedScope != "" {
+		message = conventional.WithScope(message, pinnedScope)
+	}
+	return message
+}
+
+// suggestCommitMessage generates a conventional commit message for changes,
+// with optional extra context from the author. When the AI provider fails, a
+// basic fallback message is returned together with the provider error so
+// callers can report it.
+func suggestCommitMessage(changes *git.ChangeSet, extra string) (string, error) {
+	// A change that undoes a recent commit is described as its revert,
+	// unless the author said what it is
+	if extra == "" && messageNote == "" && pinnedType == "" {
+		if msg, ok := detectRevert(changes); ok {
+			return msg, nil
+		}
+	}
+
+	// The daemon, or a run that failed before committing, may have
+	// generated this message already
+	if extra == "" && len(retryHistory) == 0 && !regenerate {
+		msg, ok := loadPregenerated(changes)
+		metrics.countCacheLookup(ok)
+		if ok {
+			fmt.Println("Using the message generated earlier for these changes (--regenerate asks again).")
+			return formatMessage(addProvenance(msg, changes, extra))
+		}
+	}
+
+	// Ask the selected AI provider, and again with the problems pointed
+	// out while the message breaks the project's commit rules
+	opts := commitOptions(changes, extra)
+	opts.Series = seriesFor(changes)
+	commitMsg, err := generator.CommitMessage(interrupted, currentGenerator(), changes, opts)
+	for retry := 0; err == nil && retry < maxConformRetries; retry++ {
+		problems := generatedProblems(settleParts(changes, repairMessage(commitMsg)))
+		if len(problems) == 0 {
+			break
+		}
+		opts.History = append(opts.History, generator.Attempt{Message: commitMsg, Feedback: describeProblems(problems)})
+		regenerated, regenErr := generator.CommitMessage(interrupted, currentGenerator(), changes, opts)
+		if regenErr != nil {
+			break
+		}
+		commitMsg = regenerated
+	}
+	if err != nil {
+		metrics.countProviderError(currentGenerator().Name())
+		// Fallback to the author's note, or else a basic message
+		commitMsg = fallbackMessage(changes)
+		if messageNote != "" {
+			commitMsg = conventional.Enforce(messageNote, changes.CommitType())
+		}
+		if messageWhy != "" {
+			commitMsg += "\n\n" + messageWhy
+		}
+	}
+
+	commitMsg = settleParts(changes, repairMessage(commitMsg))
+	for _, add := range []func(string, *git.ChangeSet) string{addAPIChanges, addInfraChanges, addConfigChanges} {
+		commitMsg = add(commitMsg, changes)
+	}
+	if err != nil {
+		return commitMsg, err
+	}
+	// Keep it, so a rerun after a failure does not ask again; the branch's
+	// ticket and the emoji are added on the way out
+	if extra == "" && len(retryHistory) == 0 {
+		if err := savePregenerated(changes, commitMsg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: caching the message: %v\n", err)
+		}
+	}
+	commitMsg, err = formatMessage(addProvenance(commitMsg, changes, extra))
+	if err != nil {
+		return commitMsg, err
+	}
+	// Redact what the policy keeps out of messages; blocked content is
+	// refused at commit time, where it can be edited out
+	commitMsg, _ = screenContent(commitMsg, "message")
+	return commitMsg, nil
+}
+
+// min returns the smaller of a and b
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
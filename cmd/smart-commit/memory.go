@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// memoryPerScope is how many messages are remembered per scope.
+const memoryPerScope = 5
+
+// memorySeries is how many of them the prompt is given.
+const memorySeries = 3
+
+// memoryMaxAge is how long a remembered message counts as part of the
+// work going on in its scope.
+const memoryMaxAge = 14 * 24 * time.Hour
+
+// scopeMemory is the last messages committed per scope, kept in
+// .git/smart-commit/memory.json, so the next commit to the same area can
+// read as the next step of that work rather than on its own.
+type scopeMemory struct {
+	Scopes map[string][]rememberedMessage `json:"scopes"`
+}
+
+// rememberedMessage is a committed subject with the directories its
+// commit changed.
+type rememberedMessage struct {
+	Subject string    `json:"subject"`
+	Dirs    []string  `json:"dirs"`
+	Time    time.Time `json:"time"`
+}
+
+// wantScopeMemory reports whether messages are remembered, as the
+// scope_memory setting says.
+func wantScopeMemory() bool {
+	return config.Bool(config.Current().ScopeMemory, true)
+}
+
+// scopeMemoryPath is where the memory is kept.
+func scopeMemoryPath() (string, error) {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/memory.json")
+	if err != nil {
+		return "", fmt.Errorf("locating repository: %v", err)
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// loadScopeMemory reads the memory; a missing or damaged file is an empty
+// memory.
+func loadScopeMemory() scopeMemory {
+	var m scopeMemory
+	if path, err := scopeMemoryPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &m)
+		}
+	}
+	if m.Scopes == nil {
+		m.Scopes = map[string][]rememberedMessage{}
+	}
+	return m
+}
+
+// rememberMessage adds the subject of a committed message to the memory of
+// its scope. Messages without a scope are not remembered.
+func rememberMessage(message string, changes *git.ChangeSet) {
+	if !wantScopeMemory() || !writesAllowed() {
+		return
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	subject = strings.TrimSpace(subject)
+	_, scope, _, _, ok := conventional.ParseSubject(subject)
+	if !ok || scope == "" {
+		return
+	}
+	m := loadScopeMemory()
+	entries := append(m.Scopes[scope], rememberedMessage{Subject: subject, Dirs: changedDirs(changes), Time: time.Now()})
+	if len(entries) > memoryPerScope {
+		entries = entries[len(entries)-memoryPerScope:]
+	}
+	m.Scopes[scope] = entries
+	if err := saveScopeMemory(m); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: remembering the message: %v\n", err)
+	}
+}
+
+// saveScopeMemory replaces the memory atomically.
+func saveScopeMemory(m scopeMemory) error {
+	path, err := scopeMemoryPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// changedDirs lists the directories of the changed files.
+func changedDirs(changes *git.ChangeSet) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range changes.Files {
+		if dir := path.Dir(f.Path); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// seriesFor returns the subjects recently committed in the scope of
+// changes, oldest first. The scope is the pinned one, else the monorepo
+// workspace changed, else the remembered scope whose commits changed the
+// most of the same directories.
+func seriesFor(changes *git.ChangeSet) []string {
+	if !wantScopeMemory() {
+		return nil
+	}
+	m := loadScopeMemory()
+	recent := func(scope string) []rememberedMessage {
+		var entries []rememberedMessage
+		for _, e := range m.Scopes[scope] {
+			if time.Since(e.Time) <= memoryMaxAge {
+				entries = append(entries, e)
+			}
+		}
+		return entries
+	}
+
+	scope := pinnedScope
+	if scope == "" {
+		scope = inferScope(changes)
+	}
+	if scope == "" {
+		dirs := changedDirs(changes)
+		best := 0
+		for s := range m.Scopes {
+			shared := 0
+			for _, e := range recent(s) {
+				for _, d := range e.Dirs {
+					if contains(dirs, d) {
+						shared++
+					}
+				}
+			}
+			if shared > best || shared == best && shared > 0 && s < scope {
+				scope, best = s, shared
+			}
+		}
+	}
+
+	entries := recent(scope)
+	if len(entries) > memorySeries {
+		entries = entries[len(entries)-memorySeries:]
+	}
+	subjects := make([]string, len(entries))
+	for i, e := range entries {
+		subjects[i] = e.Subject
+	}
+	return subjects
+}
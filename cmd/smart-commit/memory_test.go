@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScopeMemoryInPrompt(t *testing.T) {
+	repo, env := testRepo(t)
+	var mu sync.Mutex
+	var prompts []string
+	answers := []string{"feat(api): add the users store", "feat(api): list users from the store"}
+	baseURL := serveProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct{ Content string } `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		defer mu.Unlock()
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		answer := answers[min(len(prompts), len(answers))-1]
+		writeAnswer(w, answer)
+	})
+
+	os.MkdirAll(filepath.Join(repo, "api"), 0755)
+	for i, file := range []string{"store.go", "users.go"} {
+		if err := os.WriteFile(filepath.Join(repo, "api", file), []byte("package api\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := runCLI(t, repo, env, baseURL, "--yes", "--no-push"); err != nil {
+			t.Fatalf("commit %d failed: %v\n%s", i+1, err, out)
+		}
+	}
+
+	if len(prompts) != 2 {
+		t.Fatalf("got %d prompts, want 2", len(prompts))
+	}
+	series := "Earlier commits in the same area, oldest first:\n- feat(api): add the users store"
+	if strings.Contains(prompts[0], "Earlier commits in the same area") {
+		t.Errorf("first prompt has a series:\n%s", prompts[0])
+	}
+	if !strings.Contains(prompts[1], series) {
+		t.Errorf("second prompt lacks the earlier commit in the scope:\n%s", prompts[1])
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request
+// duration histogram; generating a message takes seconds, not milliseconds.
+var durationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// serverMetrics counts what the server modes do, for Prometheus to scrape.
+type serverMetrics struct {
+	mu             sync.Mutex
+	requests       map[[3]string]int // transport, method, outcome
+	durations      map[[2]string]*histogram
+	providerErrors map[string]int
+	cacheHits      int
+	cacheMisses    int
+}
+
+type histogram struct {
+	counts []int // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	total  int
+}
+
+// metrics is what `serve --metrics` exposes. It also counts outside server
+// modes, where nobody reads it.
+var metrics = newServerMetrics()
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:       map[[3]string]int{},
+		durations:      map[[2]string]*histogram{},
+		providerErrors: map[string]int{},
+	}
+}
+
+// observeRequest records a request a transport handled: its method, its
+// outcome (a status code) and how long it took.
+func (m *serverMetrics) observeRequest(transport, method, outcome string, took time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[3]string{transport, method, outcome}]++
+	h := m.durations[[2]string{transport, method}]
+	if h == nil {
+		h = &histogram{counts: make([]int, len(durationBuckets)+1)}
+		m.durations[[2]string{transport, method}] = h
+	}
+	seconds := took.Seconds()
+	i := sort.SearchFloat64s(durationBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+	h.total++
+}
+
+// countProviderError records a failed request to a provider.
+func (m *serverMetrics) countProviderError(provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.providerErrors[provider]++
+}
+
+// countCacheLookup records whether a message was found among those
+// generated earlier.
+func (m *serverMetrics) countCacheLookup(hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMisses++
+	}
+}
+
+// write prints the metrics in the Prometheus text exposition format,
+// series sorted so scrapes are stable.
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP smart_commit_requests_total Requests handled, by transport, method and outcome.")
+	fmt.Fprintln(w, "# TYPE smart_commit_requests_total counter")
+	var lines []string
+	for k, n := range m.requests {
+		lines = append(lines, fmt.Sprintf("smart_commit_requests_total{transport=%q,method=%q,outcome=%q} %d", k[0], k[1], k[2], n))
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP smart_commit_request_duration_seconds Time taken to handle requests.")
+	fmt.Fprintln(w, "# TYPE smart_commit_request_duration_seconds histogram")
+	lines = nil
+	for k, h := range m.durations {
+		labels := fmt.Sprintf("transport=%q,method=%q", k[0], k[1])
+		var b strings.Builder
+		cumulative := 0
+		for i, bound := range durationBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "smart_commit_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, cumulative)
+		}
+		fmt.Fprintf(&b, "smart_commit_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.total)
+		fmt.Fprintf(&b, "smart_commit_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(&b, "smart_commit_request_duration_seconds_count{%s} %d", labels, h.total)
+		lines = append(lines, b.String())
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP smart_commit_provider_errors_total Failed requests to the AI provider.")
+	fmt.Fprintln(w, "# TYPE smart_commit_provider_errors_total counter")
+	lines = nil
+	for provider, n := range m.providerErrors {
+		lines = append(lines, fmt.Sprintf("smart_commit_provider_errors_total{provider=%q} %d", provider, n))
+	}
+	writeSorted(w, lines)
+
+	fmt.Fprintln(w, "# HELP smart_commit_cache_lookups_total Lookups of messages generated earlier, by result.")
+	fmt.Fprintln(w, "# TYPE smart_commit_cache_lookups_total counter")
+	fmt.Fprintf(w, "smart_commit_cache_lookups_total{result=\"hit\"} %d\n", m.cacheHits)
+	fmt.Fprintf(w, "smart_commit_cache_lookups_total{result=\"miss\"} %d\n", m.cacheMisses)
+}
+
+func writeSorted(w io.Writer, lines []string) {
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// serveMetrics serves the metrics at /metrics on addr in the background.
+// A listener that fails is reported without stopping the server.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+	fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics\n", addr)
+	go func() {
+		if err := newHTTPServer(addr, mux).ListenAndServe(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: serving metrics: %v\n", err)
+		}
+	}()
+}
+
+// statusRecorder remembers the status code an HTTP handler answers with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerMetrics(t *testing.T) {
+	m := newServerMetrics()
+	m.observeRequest("grpc", "Suggest", "OK", 300*time.Millisecond)
+	m.observeRequest("grpc", "Suggest", "Internal", 2*time.Second)
+	m.observeRequest("webhook", "push", "204", time.Second)
+	m.countProviderError("openai")
+	m.countCacheLookup(true)
+	m.countCacheLookup(false)
+	m.countCacheLookup(false)
+
+	var b strings.Builder
+	m.write(&b)
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE smart_commit_requests_total counter\n",
+		`smart_commit_requests_total{transport="grpc",method="Suggest",outcome="Internal"} 1` + "\n",
+		`smart_commit_requests_total{transport="grpc",method="Suggest",outcome="OK"} 1` + "\n",
+		`smart_commit_request_duration_seconds_bucket{transport="grpc",method="Suggest",le="0.25"} 0` + "\n",
+		`smart_commit_request_duration_seconds_bucket{transport="grpc",method="Suggest",le="0.5"} 1` + "\n",
+		`smart_commit_request_duration_seconds_bucket{transport="grpc",method="Suggest",le="2.5"} 2` + "\n",
+		`smart_commit_request_duration_seconds_bucket{transport="grpc",method="Suggest",le="+Inf"} 2` + "\n",
+		`smart_commit_request_duration_seconds_sum{transport="grpc",method="Suggest"} 2.3` + "\n",
+		`smart_commit_request_duration_seconds_bucket{transport="webhook",method="push",le="1"} 1` + "\n",
+		`smart_commit_provider_errors_total{provider="openai"} 1` + "\n",
+		`smart_commit_cache_lookups_total{result="hit"} 1` + "\n",
+		`smart_commit_cache_lookups_total{result="miss"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics lack %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `outcome="Internal"`) > strings.Index(out, `outcome="OK"`) {
+		t.Errorf("series are not sorted:\n%s", out)
+	}
+}
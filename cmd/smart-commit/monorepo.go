@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// workspace is a part of a monorepo with its own scope: a Go module, a
+// package.json workspace, or a path from the scope_map setting.
+type workspace struct {
+	Dir   string
+	Scope string
+}
+
+var (
+	workspacesMu    sync.Mutex
+	workspacesFound bool
+	repoWorkspaces  []workspace
+)
+
+// currentWorkspaces returns the repository's workspaces, deepest first, so
+// the first one containing a path is the most specific. They are found
+// once per run, or again after the configuration is reloaded.
+func currentWorkspaces() []workspace {
+	workspacesMu.Lock()
+	defer workspacesMu.Unlock()
+	if !workspacesFound {
+		repoWorkspaces, workspacesFound = findWorkspaces(), true
+	}
+	return repoWorkspaces
+}
+
+// forgetWorkspaces makes the next currentWorkspaces find them again.
+func forgetWorkspaces() {
+	workspacesMu.Lock()
+	defer workspacesMu.Unlock()
+	workspacesFound = false
+}
+
+// findWorkspaces collects the workspaces from the scope_map setting, nested
+// go.mod files and the package.json or pnpm workspaces. Configured paths win
+// over detected ones.
+func findWorkspaces() []workspace {
+	byDir := map[string]string{}
+	if idx := currentIndex(); idx != nil {
+		for dir, scope := range idx.Packages {
+			byDir[dir] = scope
+		}
+	}
+	for dir, scope := range config.Current().ScopeMap {
+		byDir[strings.Trim(path.Clean(dir), "/")] = scope
+	}
+
+	var workspaces []workspace
+	for dir, scope := range byDir {
+		if scope = scopeName(scope); scope != "" {
+			workspaces = append(workspaces, workspace{Dir: dir, Scope: scope})
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool {
+		if len(workspaces[i].Dir) != len(workspaces[j].Dir) {
+			return len(workspaces[i].Dir) > len(workspaces[j].Dir)
+		}
+		return workspaces[i].Dir < workspaces[j].Dir
+	})
+	return workspaces
+}
+
+// detectPackages finds the workspaces the manifest files of the
+// repository at root declare: directories with a go.mod, and package.json
+// workspaces. It maps their directories to their scopes.
+func detectPackages(root string, files []string) map[string]string {
+	byDir := map[string]string{}
+	patterns := packageWorkspacePatterns(root, files)
+	for _, file := range files {
+		dir := path.Dir(file)
+		if dir == "." {
+			continue
+		}
+		switch path.Base(file) {
+		case "go.mod":
+			byDir[dir] = path.Base(dir)
+		case "package.json":
+			if matchesWorkspace(dir, patterns) {
+				byDir[dir] = packageScope(filepath.Join(root, file), dir)
+			}
+		}
+	}
+	return byDir
+}
+
+// packageWorkspacePatterns reads the workspace globs of the root
+// package.json ("workspaces" as a list or as {"packages": [...]}) and of
+// pnpm-workspace.yaml.
+func packageWorkspacePatterns(root string, files []string) []string {
+	var patterns []string
+	if contains(files, "package.json") {
+		var manifest struct {
+			Workspaces json.RawMessage `json:"workspaces"`
+		}
+		if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil && json.Unmarshal(data, &manifest) == nil {
+			var list []string
+			var nested struct {
+				Packages []string `json:"packages"`
+			}
+			if json.Unmarshal(manifest.Workspaces, &list) == nil {
+				patterns = append(patterns, list...)
+			} else if json.Unmarshal(manifest.Workspaces, &nested) == nil {
+				patterns = append(patterns, nested.Packages...)
+			}
+		}
+	}
+	if contains(files, "pnpm-workspace.yaml") {
+		var pnpm struct {
+			Packages []string `yaml:"packages"`
+		}
+		if data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil && yaml.Unmarshal(data, &pnpm) == nil {
+			patterns = append(patterns, pnpm.Packages...)
+		}
+	}
+	return patterns
+}
+
+// matchesWorkspace reports whether dir matches one of the workspace globs.
+// A trailing /** matches any depth.
+func matchesWorkspace(dir string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.TrimPrefix(strings.TrimSuffix(p, "/"), "./")
+		if strings.HasPrefix(p, "!") {
+			continue
+		}
+		if base := strings.TrimSuffix(p, "/**"); base != p && strings.HasPrefix(dir, base+"/") {
+			return true
+		}
+		if ok, _ := path.Match(p, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packageScope is the scope of a package.json workspace: its package name
+// without the npm scope (@acme/auth is auth), or its directory name.
+func packageScope(file, dir string) string {
+	var manifest struct {
+		Name string `json:"name"`
+	}
+	if data, err := os.ReadFile(file); err == nil && json.Unmarshal(data, &manifest) == nil && manifest.Name != "" {
+		return path.Base(manifest.Name)
+	}
+	return path.Base(dir)
+}
+
+// nonScopeChars are the characters a conventional commit scope may not
+// contain.
+var nonScopeChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// scopeName turns a directory or package name into a valid scope.
+func scopeName(name string) string {
+	return strings.Trim(nonScopeChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// inferScope returns the scope of the workspace all changed files belong
+// to, or "" when they span several workspaces or none. Lockfiles outside
+// any workspace, updated alongside the change, do not count.
+func inferScope(changes *git.ChangeSet) string {
+	workspaces := currentWorkspaces()
+	if len(workspaces) == 0 {
+		return ""
+	}
+	scope := ""
+	for _, f := range changes.Files {
+		fileScope := ""
+		for _, w := range workspaces {
+			if strings.HasPrefix(f.Path, w.Dir+"/") {
+				fileScope = w.Scope
+				break
+			}
+		}
+		switch {
+		case fileScope == "" && f.Category == git.CategoryLockfile:
+			continue
+		case fileScope == "" || (scope != "" && fileScope != scope):
+			return ""
+		}
+		scope = fileScope
+	}
+	return scope
+}
@@ -0,0 +1,26 @@
+//go:build !airgap
+
+package main
+
+import (
+	"os"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// airGapBuild is set in binaries built with the airgap tag.
+const airGapBuild = false
+
+// networkGenerator builds the providers reached over the network, or
+// returns nil for other names.
+func networkGenerator(name, model string, key func(envName, host string) string) generator.Generator {
+	switch name {
+	case "copilot":
+		return &generator.Copilot{Host: copilotHost()}
+	case "openai":
+		return generator.NewOpenAI(os.Getenv("OPENAI_BASE_URL"), key("OPENAI_API_KEY", "https://api.openai.com"), model, providerClient)
+	case "anthropic":
+		return generator.NewAnthropic(os.Getenv("ANTHROPIC_BASE_URL"), key("ANTHROPIC_API_KEY", "https://api.anthropic.com"), model, providerClient)
+	}
+	return nil
+}
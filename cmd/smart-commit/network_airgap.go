@@ -0,0 +1,14 @@
+//go:build airgap
+
+package main
+
+import "github.com/chalfel/smart-commit/generator"
+
+// airGapBuild is set in binaries built with the airgap tag, which leave
+// out every provider reached over the network.
+const airGapBuild = true
+
+// networkGenerator has no providers in an air-gapped build.
+func networkGenerator(name, model string, key func(envName, host string) string) generator.Generator {
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// buildOutputDirs are directory names that usually hold generated files.
+var buildOutputDirs = []string{
+	"dist", "build", "out", "target", "bin", "obj", "coverage",
+	"node_modules", ".next", ".nuxt", ".cache", ".gradle",
+	".venv", "venv", ".tox", ".pytest_cache", ".terraform",
+}
+
+// artifactExtensions are extensions of generated files.
+var artifactExtensions = []string{".log", ".o", ".obj", ".a", ".so", ".dll", ".exe", ".class", ".tmp", ".out"}
+
+// minNoiseFiles is how many untracked files must share a pattern before
+// an ignore rule is suggested for it.
+const minNoiseFiles = 5
+
+// ignoreSuggestion is a .gitignore rule covering untracked files.
+type ignoreSuggestion struct {
+	Pattern string
+	Files   int
+}
+
+// suggestIgnoreRules proposes .gitignore rules for untracked files that
+// look generated: build output directories and artifact extensions shared
+// by at least minNoiseFiles files. The most common come first.
+func suggestIgnoreRules(untracked []string) []ignoreSuggestion {
+	counts := map[string]int{}
+	for _, p := range untracked {
+		dirs := strings.Split(path.Dir(p), "/")
+		pattern := ""
+		for _, dir := range dirs {
+			if contains(buildOutputDirs, dir) {
+				pattern = dir + "/"
+				break
+			}
+		}
+		if pattern == "" {
+			if ext := path.Ext(p); contains(artifactExtensions, ext) {
+				pattern = "*" + ext
+			}
+		}
+		if pattern != "" {
+			counts[pattern]++
+		}
+	}
+
+	var suggestions []ignoreSuggestion
+	for pattern, n := range counts {
+		if n >= minNoiseFiles {
+			suggestions = append(suggestions, ignoreSuggestion{Pattern: pattern, Files: n})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Files != suggestions[j].Files {
+			return suggestions[i].Files > suggestions[j].Files
+		}
+		return suggestions[i].Pattern < suggestions[j].Pattern
+	})
+	return suggestions
+}
+
+// offerIgnoreRules looks for untracked files that look generated and
+// offers to ignore them, committing the .gitignore change on its own as a
+// chore(gitignore) commit before the changes being committed.
+func offerIgnoreRules() error {
+	out, err := executeCommandWithOutput("git", "ls-files", "-z", "--full-name", "-o", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+	suggestions := suggestIgnoreRules(strings.Split(strings.TrimSuffix(out, "\x00"), "\x00"))
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	fmt.Println("Untracked files that look generated:")
+	var patterns []string
+	for _, s := range suggestions {
+		fmt.Printf("  %-16s %d files\n", s.Pattern, s.Files)
+		patterns = append(patterns, s.Pattern)
+	}
+	if !confirm("Ignore them in a separate chore(gitignore) commit?") {
+		return nil
+	}
+	if err := appendGitignore(patterns); err != nil {
+		return err
+	}
+	if err := executeCommand("git", "add", "--", ":(top).gitignore"); err != nil {
+		return err
+	}
+	message := fmt.Sprintf("chore(gitignore): ignore %s", strings.Join(patterns, ", "))
+	return gitCommit("-q", "-m", message, "--", ":(top).gitignore")
+}
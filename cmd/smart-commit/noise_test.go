@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSuggestIgnoreRules(t *testing.T) {
+	files := func(format string, n int) []string {
+		var paths []string
+		for i := 0; i < n; i++ {
+			paths = append(paths, fmt.Sprintf(format, i))
+		}
+		return paths
+	}
+	tests := []struct {
+		name      string
+		untracked []string
+		want      []ignoreSuggestion
+	}{
+		{"nothing", nil, nil},
+		{"sources", files("src/file%d.go", 10), nil},
+		{"dist", files("dist/chunk%d.js", 12), []ignoreSuggestion{{"dist/", 12}}},
+		{"nested build dirs", append(files("packages/a/dist/%d.js", 3), files("packages/b/dist/%d.js", 3)...), []ignoreSuggestion{{"dist/", 6}}},
+		{"too few", files("build/%d.o", 2), nil},
+		{"logs", append(files("logs/run%d.log", 8), files("dist/%d.js", 6)...), []ignoreSuggestion{{"*.log", 8}, {"dist/", 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestIgnoreRules(tt.untracked); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggestIgnoreRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxNotesSize is how many bytes of a notes file are sent to the model.
+const maxNotesSize = 8000
+
+// readNotes reads a free-form notes file for the model to summarize into
+// the commit body. Long notes are cut to maxNotesSize.
+func readNotes(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading notes: %v", err)
+	}
+	notes := strings.TrimSpace(string(data))
+	if notes == "" {
+		return "", fmt.Errorf("notes file %s is empty", path)
+	}
+	return truncate(notes, maxNotesSize), nil
+}
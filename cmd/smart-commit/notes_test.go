@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadNotes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if notes, err := readNotes(write("notes.md", "\n- tried offsets first\n")); err != nil || notes != "- tried offsets first" {
+		t.Errorf("readNotes() = %q, %v", notes, err)
+	}
+	if _, err := readNotes(write("empty.md", " \n")); err == nil {
+		t.Error("readNotes() accepted an empty file")
+	}
+	if _, err := readNotes(filepath.Join(dir, "missing.md")); err == nil {
+		t.Error("readNotes() accepted a missing file")
+	}
+	long, err := readNotes(write("long.md", strings.Repeat("x", maxNotesSize+100)))
+	if err != nil || !strings.HasSuffix(long, "(truncated)") || len(long) > maxNotesSize+20 {
+		t.Errorf("readNotes() on a long file = %d bytes, %v", len(long), err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// commitOutput is the result of a run printed by --render.
+type commitOutput struct {
+	Type          string        `json:"type"`
+	Scope         string        `json:"scope,omitempty"`
+	Breaking      bool          `json:"breaking"`
+	Subject       string        `json:"subject"`
+	Body          string        `json:"body,omitempty"`
+	Trailers      []string      `json:"trailers,omitempty"`
+	Message       string        `json:"message"`
+	Files         []fileSummary `json:"files"`
+	Provider      string        `json:"provider"`
+	Model         string        `json:"model,omitempty"`
+	PromptVersion int           `json:"prompt_version"`
+	DryRun        bool          `json:"dry_run"`
+	Commit        string        `json:"commit,omitempty"`
+	Pushed        bool          `json:"pushed"`
+	Signed        bool          `json:"signed"`
+}
+
+// fileSummary is a changed file in commitOutput.
+type fileSummary struct {
+	Status   string `json:"status"`
+	Path     string `json:"path"`
+	OldPath  string `json:"old_path,omitempty"`
+	Category string `json:"category"`
+	Added    int    `json:"added"`
+	Deleted  int    `json:"deleted"`
+	Binary   bool   `json:"binary,omitempty"`
+	// Summary is set by --file-notes.
+	Summary string `json:"summary,omitempty"`
+}
+
+// modelNamer is implemented by providers that call a named model.
+type modelNamer interface {
+	Model() string
+}
+
+// newCommitOutput describes message and changes for --render.
+func newCommitOutput(message string, changes *git.ChangeSet, gen generator.Generator) commitOutput {
+	out := commitOutput{Message: message, Provider: gen.Name(), PromptVersion: commitPromptVersion()}
+	if m, ok := gen.(modelNamer); ok {
+		out.Model = m.Model()
+	}
+
+	text, trailers := conventional.SplitTrailers(message)
+	subject, body, _ := strings.Cut(text, "\n")
+	out.Subject = subject
+	out.Body = strings.TrimSpace(body)
+	for _, t := range trailers {
+		out.Trailers = append(out.Trailers, t.String())
+	}
+	out.Type, out.Scope, _, out.Breaking, _ = conventional.ParseSubject(subject)
+
+	out.Files = []fileSummary{}
+	for _, f := range changes.Files {
+		out.Files = append(out.Files, fileSummary{
+			Status: f.Status, Path: f.Path, OldPath: f.OldPath, Category: f.Category,
+			Added: f.Added, Deleted: f.Deleted, Binary: f.Binary,
+		})
+	}
+	return out
+}
+
+// addFileNotes adds the summaries of --file-notes to the files.
+func (out *commitOutput) addFileNotes(notes []fileNote) {
+	for _, n := range notes {
+		for i := range out.Files {
+			if out.Files[i].Path == n.Path {
+				out.Files[i].Summary = n.Summary
+			}
+		}
+	}
+}
+
+// report is the summary --render prints: the message, the files and what
+// was done with them. The JSON renderer prints out itself.
+func (out commitOutput) report() report {
+	r := report{Data: out}
+	if out.Commit != "" {
+		r.heading(1, "Commit %s", git.ShortHash(out.Commit))
+	} else {
+		r.heading(1, "Suggested message")
+	}
+	r.paragraph("%s", out.Message)
+	columns := []string{"Status", "File", "Added", "Deleted"}
+	for _, f := range out.Files {
+		if f.Summary != "" {
+			columns = append(columns, "Summary")
+			break
+		}
+	}
+	var rows [][]string
+	for _, f := range out.Files {
+		path := f.Path
+		if f.OldPath != "" {
+			path = f.OldPath + " → " + f.Path
+		}
+		added, deleted := strconv.Itoa(f.Added), strconv.Itoa(f.Deleted)
+		if f.Binary {
+			added, deleted = "-", "-"
+		}
+		rows = append(rows, []string{f.Status, path, added, deleted, f.Summary}[:len(columns)])
+	}
+	if len(rows) > 0 {
+		r.table(columns, rows)
+	}
+	provider := out.Provider
+	if out.Model != "" {
+		provider += " (" + out.Model + ")"
+	}
+	facts := []string{fmt.Sprintf("Generated by %s with prompt v%d", provider, out.PromptVersion)}
+	if out.Signed {
+		facts = append(facts, "Signed")
+	}
+	if out.Pushed {
+		facts = append(facts, "Pushed")
+	}
+	r.list(facts)
+	return r
+}
+
+// useTemporaryIndex points git at a copy of the index for the rest of the
+// run, so a dry run can stage without touching the real index. The returned
+// function removes the copy.
+func useTemporaryIndex() (func(), error) {
+	index, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "index")
+	if err != nil {
+		return nil, fmt.Errorf("locating the index: %v", err)
+	}
+	index = strings.TrimSpace(index)
+
+	dir, err := os.MkdirTemp("", "smart-commit-")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "index")
+	// A new repository has no index yet, and git creates the copy as needed
+	if data, err := os.ReadFile(index); err == nil {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	os.Setenv("GIT_INDEX_FILE", path)
+	return func() {
+		os.Unsetenv("GIT_INDEX_FILE")
+		os.RemoveAll(dir)
+	}, nil
+}
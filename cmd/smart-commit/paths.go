@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// unquoteGitPath decodes a path as git prints it in non -z output. Unless
+// core.quotepath is off, git wraps paths containing special or non-ASCII
+// characters in double quotes and escapes them C-style, with UTF-8 bytes as
+// octal (e.g. "caf\303\251.txt"). Unquoted paths are returned as is.
+func unquoteGitPath(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	inner := s[1 : len(s)-1]
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if c != '\\' || i+1 >= len(inner) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch inner[i] {
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '0', '1', '2', '3':
+			// Three octal digits encode one raw byte
+			if i+3 <= len(inner) {
+				if v, err := strconv.ParseUint(inner[i:i+3], 8, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(inner[i])
+		default:
+			// \" and \\ stand for themselves
+			b.WriteByte(inner[i])
+		}
+	}
+	return b.String()
+}
+
+// gitPathLines splits git output listing one path per line, decoding quoted
+// paths and skipping blank lines.
+func gitPathLines(out string) []string {
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimRight(line, "\r"); strings.TrimSpace(line) != "" {
+			paths = append(paths, unquoteGitPath(line))
+		}
+	}
+	return paths
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+)
+
+// policyFile is where a policy lives: in the organization's well-known policy
+// repository (SMART_COMMIT_POLICY_REPO) and in repositories keeping a copy.
+const policyFile = ".github/smart-commit-policy.json"
+
+// policyCacheTTL is how long a fetched policy is reused before refetching.
+const policyCacheTTL = 24 * time.Hour
+
+// policy holds the commit conventions shared across an organization. Empty
+// fields keep the built-in defaults.
+type policy struct {
+	Types            []string `json:"types,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	RequiredTrailers []string `json:"required_trailers,omitempty"`
+	BannedPatterns   []string `json:"banned_patterns,omitempty"`
+	MaxSubjectLength int      `json:"max_subject_length,omitempty"`
+	// ScopeRequired, the case rules and BodyMaxLineLength come from
+	// commitlint's scope-empty, *-case and body-max-line-length rules.
+	ScopeRequired     bool      `json:"scope_required,omitempty"`
+	TypeCase          *caseRule `json:"type_case,omitempty"`
+	ScopeCase         *caseRule `json:"scope_case,omitempty"`
+	SubjectCase       *caseRule `json:"subject_case,omitempty"`
+	BodyMaxLineLength int       `json:"body_max_line_length,omitempty"`
+	// SubjectASCII allows only ASCII characters in the subject, for
+	// servers and tools that reject or mangle the others.
+	SubjectASCII bool `json:"subject_ascii,omitempty"`
+	// Provenance is "required" or "forbidden" to decide for everyone
+	// whether generated messages carry provenance trailers.
+	Provenance string `json:"provenance,omitempty"`
+	// ContentRules keep content such as personal data and internal
+	// hostnames out of prompts and commit messages.
+	ContentRules []contentRule `json:"content_rules,omitempty"`
+
+	banned []*regexp.Regexp
+}
+
+// defaultPolicy is used when no organization policy is configured: the
+// built-in conventions, narrowed by the repository's commitlint
+// configuration and then by the types, scopes, max_subject_length,
+// subject_case and subject_ascii settings.
+func defaultPolicy() *policy {
+	cfg := config.Current()
+	p := &policy{Types: conventional.DefaultTypes, MaxSubjectLength: maxSubjectLength}
+	rules, err := loadCommitlintRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring commitlint configuration: %v\n", err)
+	}
+	applyCommitlintRules(p, rules)
+	if len(cfg.Types) > 0 {
+		p.Types = cfg.Types
+	}
+	if len(cfg.Scopes) > 0 {
+		p.Scopes = cfg.Scopes
+	}
+	if cfg.MaxSubjectLength > 0 {
+		p.MaxSubjectLength = cfg.MaxSubjectLength
+	}
+	if cfg.SubjectCase != "" {
+		p.SubjectCase = &caseRule{Cases: []string{cfg.SubjectCase}}
+	}
+	p.SubjectASCII = config.Bool(cfg.SubjectASCII, false)
+	return p
+}
+
+// describeRules puts the policy's rules beyond the types in words, for the
+// commit-message prompt.
+func (p *policy) describeRules() string {
+	var rules []string
+	rules = append(rules, fmt.Sprintf("the subject line is at most %d characters", p.MaxSubjectLength))
+	if p.ScopeRequired {
+		rules = append(rules, "a scope is required")
+	}
+	if len(p.Scopes) > 0 {
+		rules = append(rules, "the scope is one of "+strings.Join(p.Scopes, ", "))
+	}
+	if p.SubjectCase != nil {
+		rules = append(rules, "the description after the colon is "+p.SubjectCase.String())
+	}
+	if p.SubjectASCII {
+		rules = append(rules, "the subject line uses ASCII characters only")
+	}
+	if p.BodyMaxLineLength > 0 {
+		rules = append(rules, fmt.Sprintf("body lines are at most %d characters", p.BodyMaxLineLength))
+	}
+	return strings.Join(rules, "; ") + "."
+}
+
+var (
+	policyMu     sync.Mutex
+	activePolicy *policy
+)
+
+// currentPolicy returns the policy commit messages are checked against,
+// loading it on first use. A policy that cannot be loaded is reported and
+// the defaults are used, so an unreachable policy source never blocks work.
+func currentPolicy() *policy {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	if activePolicy == nil {
+		p, err := loadPolicy()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: using default commit policy: %v\n", err)
+			p = defaultPolicy()
+		}
+		activePolicy = p
+	}
+	return activePolicy
+}
+
+// usePolicy makes p the policy in effect.
+func usePolicy(p *policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	activePolicy = p
+}
+
+// policySource returns where the organization policy comes from:
+// SMART_COMMIT_POLICY_URL, or the policy file of SMART_COMMIT_POLICY_REPO
+// (owner/repo). It is empty when no policy is configured.
+func policySource() string {
+	if url := os.Getenv("SMART_COMMIT_POLICY_URL"); url != "" {
+		return url
+	}
+	if repo := os.Getenv("SMART_COMMIT_POLICY_REPO"); repo != "" {
+		return "github:" + repo
+	}
+	return ""
+}
+
+// loadPolicy loads the policy in effect: the organization policy when one is
+// configured, else the repository's own policy file, else the defaults.
+func loadPolicy() (*policy, error) {
+	if policySource() != "" {
+		p, _, err := loadOrgPolicy()
+		return p, err
+	}
+	if data, err := os.ReadFile(localPolicyPath()); err == nil {
+		return parsePolicy(data)
+	}
+	return defaultPolicy(), nil
+}
+
+// localPolicyPath is the repository's own copy of the policy file.
+func localPolicyPath() string {
+	if root, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel"); err == nil && strings.TrimSpace(root) != "" {
+		return filepath.Join(strings.TrimSpace(root), policyFile)
+	}
+	return policyFile
+}
+
+// loadOrgPolicy loads the configured organization policy, from the local
+// cache while it is fresh and from its source otherwise. It also returns the
+// raw document.
+func loadOrgPolicy() (*policy, []byte, error) {
+	source := policySource()
+	if source == "" {
+		return nil, nil, fmt.Errorf("no organization policy configured (set SMART_COMMIT_POLICY_URL or SMART_COMMIT_POLICY_REPO)")
+	}
+
+	cachePath := policyCachePath(source)
+	if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < policyCacheTTL {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			p, err := parsePolicy(data)
+			return p, data, err
+		}
+	}
+
+	data, err := fetchPolicy(source)
+	if err != nil {
+		// Fall back to a stale copy rather than the defaults
+		if cached, cacheErr := os.ReadFile(cachePath); cacheErr == nil {
+			fmt.Fprintf(os.Stderr, "Warning: fetching commit policy failed, using cached copy: %v\n", err)
+			p, err := parsePolicy(cached)
+			return p, cached, err
+		}
+		return nil, nil, err
+	}
+	p, err := parsePolicy(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+		os.WriteFile(cachePath, data, 0644)
+	}
+	return p, data, nil
+}
+
+// policyCachePath is the cache file for a policy source.
+func policyCachePath(source string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, "smart-commit", "policy", hex.EncodeToString(sum[:8])+".json")
+}
+
+// fetchPolicy downloads the raw policy document from its source.
+func fetchPolicy(source string) ([]byte, error) {
+	if repo, ok := strings.CutPrefix(source, "github:"); ok {
+		var file struct {
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"`
+		}
+		if err := githubAPI("GET", fmt.Sprintf("/repos/%s/contents/%s", repo, policyFile), githubToken(), nil, &file); err != nil {
+			return nil, fmt.Errorf("fetching policy from %s: %v", repo, err)
+		}
+		if file.Encoding != "base64" {
+			return nil, fmt.Errorf("fetching policy from %s: unexpected encoding %q", repo, file.Encoding)
+		}
+		return base64.StdEncoding.DecodeString(file.Content)
+	}
+
+	req, err := http.NewRequestWithContext(interrupted, "GET", source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching policy: %v", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching policy: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching policy: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parsePolicy decodes a policy document and fills in defaults.
+func parsePolicy(data []byte) (*policy, error) {
+	p := &policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing policy: %v", err)
+	}
+	if len(p.Types) == 0 {
+		p.Types = conventional.DefaultTypes
+	}
+	if p.MaxSubjectLength == 0 {
+		p.MaxSubjectLength = maxSubjectLength
+	}
+	if p.Provenance != "" && p.Provenance != "required" && p.Provenance != "forbidden" {
+		return nil, fmt.Errorf("parsing policy: provenance is %q; expected required or forbidden", p.Provenance)
+	}
+	if err := compileContentRules(p.ContentRules); err != nil {
+		return nil, fmt.Errorf("parsing policy: %v", err)
+	}
+	for _, pattern := range p.BannedPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("parsing policy: banned pattern %q: %v", pattern, err)
+		}
+		p.banned = append(p.banned, re)
+	}
+	return p, nil
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
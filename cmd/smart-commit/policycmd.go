@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commitMsgHook is the commit-msg hook policy check --fix installs so local
+// commits are linted against the policy.
+const commitMsgHook = `#!/bin/sh
+# Installed by smart-commit policy check --fix
+exec smart-commit lint --message-file "$1"
+`
+
+// runPolicy implements `smart-commit policy`.
+func runPolicy(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return fmt.Errorf("usage: smart-commit policy check [--fix]")
+	}
+
+	fs := flag.NewFlagSet("policy check", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "update the repository's policy file and hooks to match the organization policy")
+	format := renderFlag(fs, "")
+	fs.Parse(args[1:])
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	org, orgData, err := loadOrgPolicy()
+	if err != nil {
+		return err
+	}
+
+	var drift []string
+
+	// Compare the repository's copy of the policy with the organization's
+	localPath := localPolicyPath()
+	if data, err := os.ReadFile(localPath); err != nil {
+		drift = append(drift, fmt.Sprintf("%s is missing", policyFile))
+	} else if local, err := parsePolicy(data); err != nil {
+		drift = append(drift, fmt.Sprintf("%s is invalid: %v", policyFile, err))
+	} else {
+		drift = append(drift, policyDifferences(local, org)...)
+	}
+	policyDrift := len(drift) > 0
+
+	// The commit-msg hook enforces the policy before commits are created
+	hookPath, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "hooks/commit-msg")
+	if err != nil {
+		return fmt.Errorf("locating hooks: %v", err)
+	}
+	hookPath = strings.TrimSpace(hookPath)
+	hookDrift, hookOwned := false, true
+	if data, err := os.ReadFile(hookPath); err != nil {
+		drift = append(drift, "commit-msg hook is not installed")
+		hookDrift = true
+	} else if !strings.Contains(string(data), "smart-commit lint") {
+		drift = append(drift, "commit-msg hook does not run smart-commit lint")
+		hookDrift, hookOwned = true, false
+	}
+
+	r := report{Data: map[string][]string{"drift": nonNil(drift)}}
+	if len(drift) == 0 {
+		r.paragraph("Local setup matches the organization policy.")
+		return printReport(*format, r)
+	}
+	r.paragraph("Drift from the organization policy:")
+	r.list(drift)
+	if err := writeReport(reportStdout(*format), *format, r); err != nil {
+		return err
+	}
+	if !*fix {
+		return fmt.Errorf("%d difference(s) found; run `smart-commit policy check --fix` to update", len(drift))
+	}
+
+	if policyDrift {
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(localPath, orgData, 0644); err != nil {
+			return fmt.Errorf("writing %s: %v", policyFile, err)
+		}
+		fmt.Printf("Updated %s; commit it so CI uses the same rules.\n", policyFile)
+	}
+	if hookDrift {
+		// Never overwrite a hook someone else wrote
+		if !hookOwned {
+			return fmt.Errorf("%s is a custom hook; add `smart-commit lint --message-file \"$1\"` to it by hand", hookPath)
+		}
+		if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(hookPath, []byte(commitMsgHook), 0755); err != nil {
+			return fmt.Errorf("installing commit-msg hook: %v", err)
+		}
+		fmt.Printf("Installed commit-msg hook at %s.\n", hookPath)
+	}
+	return nil
+}
+
+// policyDifferences describes every setting where local differs from org.
+func policyDifferences(local, org *policy) []string {
+	var diffs []string
+	lists := []struct {
+		name       string
+		local, org []string
+	}{
+		{"types", local.Types, org.Types},
+		{"scopes", local.Scopes, org.Scopes},
+		{"required_trailers", local.RequiredTrailers, org.RequiredTrailers},
+		{"banned_patterns", local.BannedPatterns, org.BannedPatterns},
+	}
+	for _, l := range lists {
+		var missing, extra []string
+		for _, v := range l.org {
+			if !contains(l.local, v) {
+				missing = append(missing, v)
+			}
+		}
+		for _, v := range l.local {
+			if !contains(l.org, v) {
+				extra = append(extra, v)
+			}
+		}
+		if len(missing) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%s: missing %s", l.name, strings.Join(missing, ", ")))
+		}
+		if len(extra) > 0 {
+			diffs = append(diffs, fmt.Sprintf("%s: not in organization policy: %s", l.name, strings.Join(extra, ", ")))
+		}
+	}
+	if local.MaxSubjectLength != org.MaxSubjectLength {
+		diffs = append(diffs, fmt.Sprintf("max_subject_length: %d locally, %d in organization policy", local.MaxSubjectLength, org.MaxSubjectLength))
+	}
+	if local.SubjectASCII != org.SubjectASCII {
+		diffs = append(diffs, fmt.Sprintf("subject_ascii: %t locally, %t in organization policy", local.SubjectASCII, org.SubjectASCII))
+	}
+	if local.Provenance != org.Provenance {
+		diffs = append(diffs, fmt.Sprintf("provenance: %q locally, %q in organization policy", local.Provenance, org.Provenance))
+	}
+	return diffs
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// prTemplates are where GitHub looks for a pull request template.
+var prTemplates = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// runPR implements `smart-commit pr`: a pull request title and description
+// generated from the branch's commits and diff, printed or used to create
+// or update the branch's pull request with gh.
+func runPR(args []string) error {
+	fs := flag.NewFlagSet("pr", flag.ExitOnError)
+	base := fs.String("base", "", "branch the pull request merges into (default: the repository's default branch)")
+	create := fs.Bool("create", false, "create the pull request with gh, or update the branch's open one")
+	draft := fs.Bool("draft", false, "create the pull request as a draft")
+	yes := fs.Bool("yes", false, "create or update the pull request without asking")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	if *base == "" {
+		b, err := git.DefaultBranch()
+		if err != nil {
+			return err
+		}
+		*base = b
+	}
+	branch, err := git.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if branch == *base {
+		return fmt.Errorf("%s is the base branch; switch to the branch to open a pull request for", branch)
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+
+	// Three dots: what the branch adds since it forked from the base
+	changes, err := git.LoadChangeSet(*base + "...HEAD")
+	if err != nil {
+		return err
+	}
+	commits, err := git.LoadCommits("--no-merges", *base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits on %s since %s", branch, *base)
+	}
+
+	fmt.Fprintf(os.Stderr, "Writing the pull request with %s...\n", currentGenerator().Name())
+	title, body := generatePR(*base, branch, commits, changes)
+	r := report{Data: map[string]string{"title": title, "body": body}}
+	r.heading(1, "%s", title)
+	r.paragraph("%s", body)
+	if err := writeReport(reportStdout(*format), *format, r); err != nil {
+		return err
+	}
+	if !*create {
+		return nil
+	}
+
+	number := existingPR()
+	question := "Create the pull request?"
+	if number != "" {
+		question = fmt.Sprintf("Update pull request #%s?", number)
+	}
+	if !*yes && isTerminal(os.Stdin) && isTerminal(os.Stdout) && !confirm(question) {
+		return fmt.Errorf("pull request not created")
+	}
+	if number != "" {
+		if err := runGH(body, "pr", "edit", number, "--title", title, "--body-file", "-"); err != nil {
+			return err
+		}
+		fmt.Printf("Updated pull request #%s.\n", number)
+		return nil
+	}
+
+	// gh needs the branch on the remote to open a pull request for it
+	if !hasUpstream() {
+		if err := executeCommand("git", "push", "--set-upstream", pushRemote(branch), "HEAD"); err != nil {
+			return fmt.Errorf("pushing %s: %v", branch, err)
+		}
+	}
+	ghArgs := []string{"pr", "create", "--base", *base, "--title", title, "--body-file", "-"}
+	if *draft {
+		ghArgs = append(ghArgs, "--draft")
+	}
+	return runGH(body, ghArgs...)
+}
+
+// generatePR asks the model for the pull request title and description,
+// falling back to the commit subjects when it fails.
+func generatePR(base, head string, commits []git.Commit, changes *git.ChangeSet) (title, body string) {
+	answer, err := askModel(generator.RenderPrompt(generator.PromptPullRequest, map[string]interface{}{
+		"Base": base, "Head": head, "Commits": commits, "Changes": changes.Describe(),
+		"Diff": generator.DiffContext(changes, maxDiffBudget()), "Template": prTemplate(),
+	}))
+	if err == nil {
+		title, body, _ = strings.Cut(answer, "\n")
+		title = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(title), "# "))
+		body = strings.TrimSpace(body)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+	}
+	if title == "" {
+		// The newest commit of a one-commit branch says it all
+		title = commits[0].Subject
+		if len(commits) > 1 {
+			title = fmt.Sprintf("%s: changes from %s", changes.CommitType(), head)
+		}
+	}
+	if body == "" {
+		var b strings.Builder
+		b.WriteString("## Changes\n")
+		for i := len(commits) - 1; i >= 0; i-- {
+			fmt.Fprintf(&b, "\n- %s", commits[i].Subject)
+		}
+		body = b.String()
+	}
+	return conventional.EnforceTypes(title, changes.CommitType(), currentPolicy().Types), body
+}
+
+// prTemplate returns the repository's pull request template, if it has one.
+func prTemplate() string {
+	root, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return ""
+	}
+	for _, name := range prTemplates {
+		if data, err := os.ReadFile(filepath.Join(strings.TrimSpace(root), name)); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
+
+// existingPR returns the number of the current branch's open pull request,
+// or "" when it has none.
+func existingPR() string {
+	cmd := ghCommand("pr", "view", "--json", "number,state", "--jq", `select(.state == "OPEN") | .number`)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+// runGH runs gh with args, feeding it stdin.
+func runGH(stdin string, args ...string) error {
+	cmd := ghCommand(args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = os.Stdout
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return fmt.Errorf("gh %s failed: %v: %s", strings.Join(args[:2], " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// promptCorpus is the built-in regression corpus: diffs with the properties
+// a good commit message for them must have.
+//
+//go:embed prompts/corpus/*.json
+var promptCorpus embed.FS
+
+// promptFixture is one corpus entry.
+type promptFixture struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Changes     git.ChangeSet `json:"changes"`
+	Expect      struct {
+		// Types lists the acceptable conventional commit types.
+		Types []string `json:"types"`
+		// Keywords must appear in the subject, at least one of them.
+		Keywords []string `json:"keywords"`
+		// Forbidden must not appear anywhere in the message.
+		Forbidden        []string `json:"forbidden"`
+		MaxSubjectLength int      `json:"max_subject_length"`
+	} `json:"expect"`
+}
+
+// runPrompt implements `smart-commit prompt`.
+func runPrompt(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: smart-commit prompt list | test [--version N] [--corpus DIR] [--min-pass RATIO]")
+	}
+	switch args[0] {
+	case "list":
+		for _, name := range generator.PromptNames() {
+			fmt.Printf("%-22s v%d (%d versions)\n", name, generator.LatestPromptVersion(name), len(generator.PromptVersions(name)))
+		}
+		return nil
+	case "test":
+		return runPromptTest(args[1:])
+	}
+	return fmt.Errorf("unknown prompt command %q", args[0])
+}
+
+// runPromptTest generates a message for every corpus fixture with the
+// selected provider and prompt version and checks the expected properties.
+func runPromptTest(args []string) error {
+	fs := flag.NewFlagSet("prompt test", flag.ExitOnError)
+	version := fs.Int("version", 0, "commit-message prompt version to evaluate (default: the configured one)")
+	corpusDir := fs.String("corpus", "", "directory of fixture JSON files (default: the built-in corpus)")
+	minPass := fs.Float64("min-pass", 1, "fail unless at least this fraction of fixtures pass")
+	fs.Parse(args)
+
+	if *version == 0 {
+		*version = commitPromptVersion()
+	}
+	fixtures, err := loadPromptFixtures(*corpusDir)
+	if err != nil {
+		return err
+	}
+	if len(fixtures) == 0 {
+		return fmt.Errorf("the corpus has no fixtures")
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Evaluating %s v%d with %s on %d fixtures\n\n", generator.PromptCommitMessage, *version, currentGenerator().Name(), len(fixtures))
+	passed := 0
+	for _, fx := range fixtures {
+		message, failures := evaluateFixture(fx, *version)
+		if len(failures) == 0 {
+			passed++
+			fmt.Printf("PASS %-24s %s\n", fx.Name, strings.SplitN(message, "\n", 2)[0])
+			continue
+		}
+		fmt.Printf("FAIL %-24s %s\n", fx.Name, strings.SplitN(message, "\n", 2)[0])
+		for _, f := range failures {
+			fmt.Printf("       %s\n", f)
+		}
+	}
+
+	rate := float64(passed) / float64(len(fixtures))
+	fmt.Printf("\n%d/%d fixtures passed (%.0f%%)\n", passed, len(fixtures), rate*100)
+	if rate < *minPass {
+		return fmt.Errorf("pass rate %.0f%% is below the required %.0f%%", rate*100, *minPass*100)
+	}
+	return nil
+}
+
+// loadPromptFixtures reads the corpus from dir, or the built-in corpus when
+// dir is empty.
+func loadPromptFixtures(dir string) ([]promptFixture, error) {
+	var fsys fs.FS = promptCorpus
+	root := "prompts/corpus"
+	if dir != "" {
+		fsys, root = os.DirFS(dir), "."
+	}
+	paths, err := fs.Glob(fsys, filepath.ToSlash(filepath.Join(root, "*.json")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var fixtures []promptFixture
+	for _, p := range paths {
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, err
+		}
+		var fx promptFixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return nil, fmt.Errorf("parsing fixture %s: %v", p, err)
+		}
+		if fx.Name == "" {
+			fx.Name = strings.TrimSuffix(filepath.Base(p), ".json")
+		}
+		for i := range fx.Changes.Files {
+			if fx.Changes.Files[i].Category == "" {
+				fx.Changes.Files[i].Category = git.ClassifyPath(fx.Changes.Files[i].Path)
+			}
+		}
+		fixtures = append(fixtures, fx)
+	}
+	return fixtures, nil
+}
+
+// evaluateFixture generates a message for a fixture and lists every
+// expectation it misses. The raw model answer is judged, without the
+// fallback and type enforcement the commit flow applies.
+func evaluateFixture(fx promptFixture, version int) (string, []string) {
+	prompt, err := generator.RenderPromptVersion(generator.PromptCommitMessage, version, generator.PromptData(&fx.Changes, commitOptions(&fx.Changes, "")))
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+	message, err := askModel(prompt)
+	if err != nil {
+		return "", []string{fmt.Sprintf("generation failed: %v", err)}
+	}
+
+	var failures []string
+	for _, p := range lintMessage(message) {
+		failures = append(failures, p.String())
+	}
+
+	subject := strings.SplitN(message, "\n", 2)[0]
+	commitType, _, _, _, ok := conventional.ParseSubject(subject)
+	if ok && len(fx.Expect.Types) > 0 && !contains(fx.Expect.Types, commitType) {
+		failures = append(failures, fmt.Sprintf("type %q, expected one of %s", commitType, strings.Join(fx.Expect.Types, ", ")))
+	}
+	if len(fx.Expect.Keywords) > 0 {
+		found := false
+		for _, k := range fx.Expect.Keywords {
+			found = found || strings.Contains(strings.ToLower(subject), strings.ToLower(k))
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("subject mentions none of %s", strings.Join(fx.Expect.Keywords, ", ")))
+		}
+	}
+	for _, f := range fx.Expect.Forbidden {
+		if strings.Contains(strings.ToLower(message), strings.ToLower(f)) {
+			failures = append(failures, fmt.Sprintf("message contains forbidden %q", f))
+		}
+	}
+	if fx.Expect.MaxSubjectLength > 0 && len(subject) > fx.Expect.MaxSubjectLength {
+		failures = append(failures, fmt.Sprintf("subject is %d characters, longer than %d", len(subject), fx.Expect.MaxSubjectLength))
+	}
+	return message, failures
+}
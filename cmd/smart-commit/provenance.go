@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Provenance trailers record what generated a message: the model, and a
+// hash of the prompt it was given, which the same tree and options
+// reproduce.
+const (
+	modelTrailer      = "AI-Model"
+	promptHashTrailer = "AI-Prompt-Hash"
+)
+
+// provenanceFlag is set by main from --provenance; other entry points
+// follow the provenance setting.
+var provenanceFlag *bool
+
+// wantProvenance reports whether generated messages get provenance
+// trailers: as the policy requires or forbids, else as asked.
+func wantProvenance() bool {
+	switch currentPolicy().Provenance {
+	case "required":
+		return true
+	case "forbidden":
+		return false
+	}
+	if provenanceFlag != nil {
+		return *provenanceFlag
+	}
+	return config.Bool(config.Current().Provenance, false)
+}
+
+// isProvenanceTrailer reports whether token is one of the provenance
+// trailers.
+func isProvenanceTrailer(token string) bool {
+	return strings.EqualFold(token, modelTrailer) || strings.EqualFold(token, promptHashTrailer)
+}
+
+// provenanceTrailers names the model of gen, or the provider when it has
+// no model, and the first 8 hex digits of the SHA-256 of prompt.
+func provenanceTrailers(gen generator.Generator, prompt string) []conventional.Trailer {
+	model := gen.Name()
+	if m, ok := gen.(modelNamer); ok && m.Model() != "" {
+		model = m.Model()
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	return []conventional.Trailer{
+		{Token: modelTrailer, Value: model},
+		{Token: promptHashTrailer, Value: hex.EncodeToString(sum[:])[:8]},
+	}
+}
+
+// addProvenance adds the provenance trailers to a generated message when
+// they are wanted. The hash is of the first prompt for changes, before any
+// retry pointing out problems.
+func addProvenance(message string, changes *git.ChangeSet, extra string) string {
+	if !wantProvenance() {
+		return message
+	}
+	prompt, err := generator.CommitPrompt(changes, commitOptions(changes, extra))
+	if err != nil {
+		return message
+	}
+	withTrailers, err := addTrailers(message, provenanceTrailers(currentGenerator(), prompt))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return message
+	}
+	return withTrailers
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+func TestProvenanceTrailers(t *testing.T) {
+	tests := []struct {
+		gen       generator.Generator
+		wantModel string
+	}{
+		{generator.NewOpenAI("", "key", "", nil), "gpt-4o-mini"},
+		{withRetries(generator.NewAnthropic("", "key", "claude-3-5-haiku-latest", nil)), "claude-3-5-haiku-latest"},
+		{&generator.Copilot{}, "GitHub Copilot CLI"},
+	}
+	for _, tt := range tests {
+		trailers := provenanceTrailers(tt.gen, "prompt")
+		if len(trailers) != 2 || trailers[0].Token != "AI-Model" || trailers[0].Value != tt.wantModel {
+			t.Errorf("provenanceTrailers(%s) = %v, want AI-Model: %s", tt.gen.Name(), trailers, tt.wantModel)
+		}
+		// The hash is that of the prompt, so the same prompt reproduces it
+		if got := trailers[1]; got.Token != "AI-Prompt-Hash" || got.Value != "cf07194e" {
+			t.Errorf("provenanceTrailers(%s) hash = %v, want AI-Prompt-Hash: cf07194e", tt.gen.Name(), got)
+		}
+	}
+}
+
+func TestCommitFlowProvenance(t *testing.T) {
+	model := "gpt-4o-mini"
+	if airGapBuild {
+		model = "llama3.1"
+	}
+	tests := []struct {
+		name, policy string
+		args         []string
+		want         string
+	}{
+		{"asked for", "", []string{"--provenance"}, `^feat: add users\n\nAI-Model: ` + model + `\nAI-Prompt-Hash: [0-9a-f]{8}$`},
+		{"not asked for", "", nil, `^feat: add users$`},
+		{"required by policy", `{"provenance": "required"}`, nil, `^feat: add users\n\nAI-Model: ` + model + `\nAI-Prompt-Hash: [0-9a-f]{8}$`},
+		{"forbidden by policy", `{"provenance": "forbidden"}`, []string{"--provenance"}, `^feat: add users$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, env := testRepo(t)
+			if tt.policy != "" {
+				os.MkdirAll(filepath.Join(repo, ".github"), 0755)
+				os.WriteFile(filepath.Join(repo, ".github", "smart-commit-policy.json"), []byte(tt.policy), 0644)
+				runGit(t, repo, env, "add", ".")
+				runGit(t, repo, env, "commit", "-q", "-m", "chore: add policy")
+			}
+			os.WriteFile(filepath.Join(repo, "users.go"), []byte("package api\n"), 0644)
+			out, err := runCLI(t, repo, env, fakeProvider(t, "feat: add users"), append([]string{"--yes", "--no-push"}, tt.args...)...)
+			if err != nil {
+				t.Fatalf("smart-commit: %v\n%s", err, out)
+			}
+			got := strings.TrimSpace(runGit(t, repo, env, "log", "-1", "--format=%B"))
+			if !regexp.MustCompile(tt.want).MatchString(got) {
+				t.Errorf("commit message = %q, want it to match %q\n%s", got, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestLintProvenanceForbidden(t *testing.T) {
+	previous := activePolicy
+	defer usePolicy(previous)
+	p, err := parsePolicy([]byte(`{"provenance": "forbidden"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usePolicy(p)
+	problems := lintMessage("feat: add users\n\nAI-Model: gpt-4o-mini\nAI-Prompt-Hash: cf07194e")
+	if len(problems) != 2 || problems[0].Rule != "provenance-forbidden" {
+		t.Errorf("lintMessage() = %v, want two provenance-forbidden problems", problems)
+	}
+	if _, err := parsePolicy([]byte(`{"provenance": "sometimes"}`)); err == nil {
+		t.Error("parsePolicy accepted provenance \"sometimes\"")
+	}
+}
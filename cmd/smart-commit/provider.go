@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// newGenerator builds the named provider from the environment. An empty
+// model selects the provider's default.
+func newGenerator(name, model string) (generator.Generator, error) {
+	return newGeneratorWithKey(name, model, "")
+}
+
+// newGeneratorWithKey is newGenerator with the API key given rather than
+// read from the environment, unless it is empty.
+func newGeneratorWithKey(name, model, key string) (generator.Generator, error) {
+	keyOr := func(envName, host string) string {
+		if key != "" {
+			return key
+		}
+		return apiKey(envName, host)
+	}
+	name = strings.ToLower(name)
+	if name == "" {
+		name = defaultProvider()
+	}
+	if name == "ollama" {
+		return generator.NewOllama(os.Getenv("OLLAMA_HOST"), model, providerClient), nil
+	}
+	if airGapped && contains(generator.Names, name) {
+		return nil, fmt.Errorf("provider %s is not available in air-gapped mode (expected %s)", name, strings.Join(availableProviders(), ", "))
+	}
+	if gen := networkGenerator(name, model, keyOr); gen != nil {
+		return gen, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q (expected %s)", name, strings.Join(availableProviders(), ", "))
+}
+
+// withMaxLatency wraps gen so that, with max_latency set, the fallback
+// provider is raced against it once it has kept us waiting that long.
+func withMaxLatency(gen generator.Generator) generator.Generator {
+	cfg := config.Current()
+	if cfg.MaxLatency <= 0 {
+		return gen
+	}
+	name := cfg.FallbackProvider
+	if name == "" {
+		name = "ollama"
+	}
+	fallback, err := newGenerator(name, cfg.FallbackModel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring fallback_provider: %v\n", err)
+		return gen
+	}
+	if fallback.Name() == gen.Name() && cfg.FallbackModel == "" {
+		return gen
+	}
+	after := time.Duration(cfg.MaxLatency) * time.Second
+	return &generator.Racing{Primary: gen, Fallback: fallback, After: after, OnRace: func() {
+		fmt.Fprintf(os.Stderr, "%s has not answered in %s; also asking %s.\n", gen.Name(), after, fallback.Name())
+	}}
+}
+
+// copilotHost is the host Copilot runs against: the GitHub host in use
+// when it is not github.com.
+func copilotHost() string {
+	if host := githubHost(); host != "github.com" {
+		return host
+	}
+	return ""
+}
+
+// apiKey reads a provider API key from the environment (encrypted values
+// allowed), falling back to the git credential helpers for the API host.
+func apiKey(envName, host string) string {
+	if key := secretEnv(envName); key != "" {
+		return key
+	}
+	_, key, _ := gitCredential(host)
+	return key
+}
+
+// activeGenerator is the provider selected for this run; see currentGenerator.
+// generatorMu guards it, as a reload may replace it while a server runs.
+var (
+	generatorMu     sync.Mutex
+	activeGenerator generator.Generator
+)
+
+// currentGenerator returns the selected provider. Unless main selected one
+// from its flags, it comes from SMART_COMMIT_PROVIDER and SMART_COMMIT_MODEL
+// or the configuration files.
+func currentGenerator() generator.Generator {
+	generatorMu.Lock()
+	defer generatorMu.Unlock()
+	if activeGenerator == nil {
+		gen, err := configuredGenerator(config.Current())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; using %s\n", err, defaultProvider())
+			gen, _ = newGenerator(defaultProvider(), "")
+		}
+		activeGenerator = withScreening(withRetries(withMaxLatency(gen)))
+	}
+	return activeGenerator
+}
+
+// configuredGenerator builds the provider c selects, unless
+// SMART_COMMIT_PROVIDER and SMART_COMMIT_MODEL override it.
+func configuredGenerator(c *config.Config) (generator.Generator, error) {
+	return newGenerator(config.Setting("SMART_COMMIT_PROVIDER", c.Provider), config.Setting("SMART_COMMIT_MODEL", c.Model))
+}
+
+// useGenerator makes gen the selected provider.
+func useGenerator(gen generator.Generator) {
+	generatorMu.Lock()
+	defer generatorMu.Unlock()
+	activeGenerator = withScreening(withRetries(withMaxLatency(gen)))
+}
+
+// checkProvider verifies that the selected provider can be used.
+func checkProvider() error {
+	return currentGenerator().Check()
+}
+
+// generatorFor returns the provider of requests made with ctx: the
+// tenant's for a serve tenant's request, else the selected one.
+func generatorFor(ctx context.Context) generator.Generator {
+	if t := tenantFrom(ctx); t != nil {
+		return t.gen
+	}
+	return currentGenerator()
+}
+
+// askModel sends a free-form prompt to the selected provider and returns its
+// answer.
+func askModel(prompt string) (string, error) {
+	return askModelContext(interrupted, prompt)
+}
+
+// askModelContext is askModel for a request made with ctx, answered by its
+// provider.
+func askModelContext(ctx context.Context, prompt string) (string, error) {
+	gen := generatorFor(ctx)
+	answer, err := gen.Generate(ctx, prompt)
+	if err != nil {
+		metrics.countProviderError(gen.Name())
+		return "", err
+	}
+	return generator.CleanOutput(answer), nil
+}
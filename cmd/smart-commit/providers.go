@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// providerHealth is one row of `smart-commit providers status`.
+type providerHealth struct {
+	Name     string        `json:"name"`
+	Model    string        `json:"model"`
+	Selected bool          `json:"selected"`
+	State    string        `json:"status"`
+	Latency  time.Duration `json:"latency_ns,omitempty"`
+	Quota    string        `json:"quota,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
+}
+
+// runProviders implements `smart-commit providers status`: every built-in
+// provider is pinged, showing whether its credentials work, how fast it
+// answers and how much of its rate limit is left.
+func runProviders(args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return fmt.Errorf("usage: smart-commit providers status")
+	}
+	fs := flag.NewFlagSet("providers status", flag.ExitOnError)
+	format := renderFlag(fs, "")
+	fs.Parse(args[1:])
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	selected := strings.ToLower(config.Setting("SMART_COMMIT_PROVIDER", config.Current().Provider))
+	if selected == "" {
+		selected = defaultProvider()
+	}
+	names := availableProviders()
+	rows := make([]providerHealth, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		gen, err := newGenerator(name, "")
+		if name == selected {
+			gen, err = currentGenerator(), nil
+			if s, ok := gen.(*generator.Screened); ok {
+				gen = s.Generator
+			}
+			if r, ok := gen.(*generator.Retrying); ok {
+				gen = r.Generator
+			}
+			if r, ok := gen.(*generator.Racing); ok {
+				gen = r.Primary
+			}
+		}
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func(i int, name string, gen generator.Generator) {
+			defer wg.Done()
+			rows[i] = checkProviderHealth(name, gen)
+			rows[i].Selected = name == selected
+		}(i, name, gen)
+	}
+	wg.Wait()
+
+	r := report{Data: rows}
+	var table [][]string
+	var details []string
+	for _, row := range rows {
+		mark, latency, quota := "", "-", row.Quota
+		if row.Selected {
+			mark = "*"
+		}
+		if row.Latency > 0 {
+			latency = row.Latency.Round(time.Millisecond).String()
+		}
+		if quota == "" {
+			quota = "-"
+		}
+		table = append(table, []string{mark, row.Name, row.Model, row.State, latency, quota})
+		if row.Detail != "" {
+			details = append(details, fmt.Sprintf("**%s**: %s", row.Name, row.Detail))
+		}
+	}
+	r.table([]string{"", "Provider", "Model", "Status", "Latency", "Quota"}, table)
+	r.list(details)
+	return printReport(*format, r)
+}
+
+// checkProviderHealth pings gen and classifies the outcome: ok, not
+// configured (no credentials or not installed), auth failed, or error.
+func checkProviderHealth(name string, gen generator.Generator) providerHealth {
+	h := providerHealth{Name: name, Model: "-"}
+	if m, ok := gen.(modelNamer); ok {
+		h.Model = m.Model()
+	}
+	if err := gen.Check(); err != nil {
+		h.State, h.Detail = "not configured", err.Error()
+		return h
+	}
+	pinger, ok := gen.(generator.Pinger)
+	if !ok {
+		h.State = "ok"
+		return h
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	status, err := pinger.Ping(ctx)
+	var statusErr *generator.StatusError
+	switch {
+	case err == nil:
+		h.State, h.Latency, h.Quota = "ok", status.Latency, status.Quota
+	case errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden):
+		h.State, h.Detail = "auth failed", err.Error()
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusTooManyRequests:
+		h.State, h.Detail = "rate limited", err.Error()
+	default:
+		h.State, h.Detail = "error", err.Error()
+	}
+	return h
+}
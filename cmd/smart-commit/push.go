@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// pushOptions controls what happens after a commit.
+type pushOptions struct {
+	Push   bool
+	Rebase bool
+	Base   string
+	// Remote and Branch name where to push; empty means the branch's
+	// upstream.
+	Remote string
+	Branch string
+	// SetUpstream makes the pushed branch track its remote counterpart.
+	SetUpstream bool
+	// Interactive allows asking before setting an upstream.
+	Interactive bool
+}
+
+// pushBranch pushes the new commits unless push is off, optionally rebasing
+// onto the base branch first. It exits on failure.
+func pushBranch(opts pushOptions) {
+	if !opts.Push {
+		fmt.Println("Changes committed.")
+		return
+	}
+	// Until the push succeeds, a rerun only needs to push
+	noteUnpushed()
+
+	pushArgs, err := pushArguments(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: changes committed but not pushed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Optionally bring the branch up to date with its base before pushing
+	if opts.Rebase {
+		rebased, err := rebaseOntoBase(opts.Base)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// A rebased branch no longer fast-forwards its remote counterpart
+		if rebased {
+			pushArgs = append(pushArgs[:1], append([]string{"--force-with-lease"}, pushArgs[1:]...)...)
+		}
+	}
+
+	// Push changes
+	if err := executeCommand("git", pushArgs...); err != nil {
+		fmt.Fprintf(os.Stderr, "Error pushing changes: %v\n", err)
+		os.Exit(1)
+	}
+	clearUnpushed()
+	fmt.Println("Changes pushed successfully!")
+}
+
+// pushArguments builds the git push command line. A plain `git push` is
+// used when the branch has an upstream; otherwise the branch is pushed to
+// the remote under its own name, which needs --set-upstream (or a yes when
+// asked) so the push does not fail or quietly go somewhere unexpected.
+func pushArguments(opts pushOptions) ([]string, error) {
+	if opts.Remote == "" && opts.Branch == "" && (hasUpstream() || autoSetupRemote()) {
+		if opts.SetUpstream {
+			return []string{"push", "--set-upstream"}, nil
+		}
+		return []string{"push"}, nil
+	}
+
+	branch, err := git.CurrentBranch()
+	if err != nil {
+		return nil, err
+	}
+	remote := opts.Remote
+	if remote == "" {
+		remote = pushRemote(branch)
+	}
+	target := opts.Branch
+	if target == "" {
+		target = branch
+	}
+	args := []string{"push"}
+
+	// Only an upstream-less branch needs one set; an explicit target is
+	// pushed as asked
+	if opts.Remote == "" && opts.Branch == "" && !opts.SetUpstream {
+		if !opts.Interactive {
+			return nil, fmt.Errorf("branch %s has no upstream; rerun with --set-upstream to push it to %s/%s, or push it yourself", branch, remote, target)
+		}
+		answer, err := promptLine(fmt.Sprintf("Branch %s has no upstream. Push it to %s/%s and track it? [Y/n] ", branch, remote, target))
+		if err != nil || (answer != "" && !strings.HasPrefix(strings.ToLower(answer), "y")) {
+			return nil, fmt.Errorf("branch %s has no upstream", branch)
+		}
+		opts.SetUpstream = true
+	}
+	if opts.SetUpstream {
+		args = append(args, "--set-upstream")
+	}
+	return append(args, remote, "HEAD:refs/heads/"+target), nil
+}
+
+// hasUpstream reports whether the current branch tracks a remote branch.
+func hasUpstream() bool {
+	_, err := executeCommandWithOutput("git", "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	return err == nil
+}
+
+// autoSetupRemote reports whether git is configured to set the upstream on
+// its own when pushing a new branch.
+func autoSetupRemote() bool {
+	out, err := executeCommandWithOutput("git", "config", "--type=bool", "push.autoSetupRemote")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// pushRemote is the remote git would push branch to: its pushRemote, the
+// repository's pushDefault, or origin.
+func pushRemote(branch string) string {
+	for _, key := range []string{"branch." + branch + ".pushRemote", "remote.pushDefault"} {
+		if out, err := executeCommandWithOutput("git", "config", key); err == nil && strings.TrimSpace(out) != "" {
+			return strings.TrimSpace(out)
+		}
+	}
+	return "origin"
+}
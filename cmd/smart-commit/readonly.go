@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// readOnly is set by --read-only or SMART_COMMIT_READ_ONLY: nothing may
+// change the repository, its index or refs, or push anywhere. It is
+// enforced where commands start, see readOnlyRunner, rather than by each
+// subcommand.
+var readOnly bool
+
+// readOnlyCommands are the subcommands allowed under --read-only, with the
+// flags that would make them write, which are refused. A nil list allows
+// every flag.
+var readOnlyCommands = map[string][]string{
+	"changelog": {"file"},
+	"lint":      {"output", "pre-receive"},
+	"compare":   nil,
+	"hotspots":  nil,
+	"policy":    {"fix"},
+	"version":   nil,
+	"index":     nil,
+}
+
+// readOnlyDefaultFlags are the flags of the default flow refused under
+// --read-only; the flow itself only suggests a message for what is staged.
+var readOnlyDefaultFlags = []string{
+	"add", "pick", "split", "amend", "fixup", "tui", "resume", "allow-empty",
+	"clear-index-lock", "file-notes", "push", "rebase", "canary",
+}
+
+// takeReadOnlyFlag removes --read-only from args wherever it is given, so
+// it works before or after a subcommand, and reports whether it is set.
+func takeReadOnlyFlag(args []string) ([]string, bool) {
+	var rest []string
+	set := false
+	for i, arg := range args {
+		if arg == "--" {
+			return append(rest, args[i:]...), set
+		}
+		name, value, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && name == "read-only" {
+			set = value != "false"
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return rest, set
+}
+
+// enableReadOnly turns read-only mode on for the rest of the run.
+func enableReadOnly() {
+	if readOnly {
+		return
+	}
+	readOnly = true
+	// git status and friends otherwise refresh the index as they go
+	os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+	git.DefaultRunner = readOnlyRunner{next: git.DefaultRunner}
+}
+
+// checkReadOnlyCommand refuses a subcommand, or flags of one, that would
+// write under --read-only.
+func checkReadOnlyCommand(name string, args []string) error {
+	denied, ok := readOnlyCommands[name]
+	if !ok {
+		return fmt.Errorf("%s is not available with --read-only", name)
+	}
+	if name == "changelog" && !flagGiven(args, "stdout") {
+		return fmt.Errorf("changelog writes CHANGELOG.md; use --stdout with --read-only")
+	}
+	for _, f := range denied {
+		if flagGiven(args, f) {
+			return fmt.Errorf("%s --%s is not available with --read-only", name, f)
+		}
+	}
+	return nil
+}
+
+// flagGiven reports whether args set the flag name to anything but false.
+func flagGiven(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && flag == name && !(hasValue && value == "false") {
+			return true
+		}
+	}
+	return false
+}
+
+// writesAllowed reports whether smart-commit may write its own files, such
+// as caches and locks under .git.
+func writesAllowed() bool {
+	return !readOnly
+}
+
+// readOnlyRunner refuses every command that could change the repository or
+// push: only git and gh subcommands known to only read get through.
+type readOnlyRunner struct {
+	next git.Runner
+}
+
+func (r readOnlyRunner) Run(cmd *exec.Cmd) error {
+	if err := readOnlyCommand(cmd.Args); err != nil {
+		return err
+	}
+	return r.next.Run(cmd)
+}
+
+// readOnlyGit are the git subcommands that only read, whatever their
+// arguments.
+var readOnlyGit = map[string]bool{
+	"rev-parse": true, "diff": true, "diff-tree": true, "diff-index": true, "diff-files": true,
+	"log": true, "show": true, "status": true, "cat-file": true, "ls-files": true, "ls-tree": true,
+	"ls-remote": true, "for-each-ref": true, "rev-list": true, "merge-base": true, "describe": true,
+	"blame": true, "shortlog": true, "name-rev": true, "var": true, "check-ignore": true,
+	"check-attr": true, "grep": true, "show-ref": true, "cherry": true, "range-diff": true,
+	"version": true, "count-objects": true, "credential": true,
+}
+
+// readOnlyCommand says why args may not run under --read-only, or nil.
+func readOnlyCommand(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	refuse := fmt.Errorf("refusing to run %q with --read-only", strings.Join(args, " "))
+	name := strings.TrimSuffix(filepath.Base(args[0]), ".exe")
+	switch name {
+	case "ps", "tasklist":
+		return nil
+	case "git":
+		// --output makes even diff and log write a file
+		if flagGiven(args[1:], "output") {
+			return refuse
+		}
+		sub, rest := gitSubcommand(args[1:])
+		if readOnlyGit[sub] {
+			if sub == "credential" && (len(rest) == 0 || rest[0] != "fill") {
+				return refuse
+			}
+			return nil
+		}
+		if readOnlyGitUse(sub, rest) {
+			return nil
+		}
+	case "gh":
+		if len(args) > 1 && readOnlyGH(args[1], args[2:]) {
+			return nil
+		}
+	}
+	return refuse
+}
+
+// gitSubcommand skips git's own options, such as -C dir and -c key=value.
+func gitSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-C" || arg == "-c" || arg == "--git-dir" || arg == "--work-tree" || arg == "--namespace":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg, args[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// readOnlyGitUse reports whether a git subcommand that can write is used
+// only to read: listing branches, tags, remotes, stashes and notes,
+// reading config and symbolic refs, hashing without writing.
+func readOnlyGitUse(sub string, args []string) bool {
+	has := func(flags ...string) bool {
+		for _, a := range args {
+			for _, f := range flags {
+				if a == f || strings.HasPrefix(a, f+"=") {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	positional := 0
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			positional++
+		}
+	}
+	first := ""
+	if len(args) > 0 {
+		first = args[0]
+	}
+	switch sub {
+	case "branch":
+		return !has("-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy", "-f", "--force",
+			"-u", "--set-upstream-to", "--unset-upstream", "--edit-description") &&
+			(positional == 0 || has("-l", "--list", "--merged", "--no-merged", "--contains", "--no-contains", "--points-at"))
+	case "tag":
+		return !has("-d", "--delete", "-a", "-s", "-u", "-f", "--force", "-m", "-F") &&
+			(positional == 0 || has("-l", "--list", "--merged", "--no-merged", "--contains", "--no-contains", "--points-at"))
+	case "config":
+		return has("--get", "--get-all", "--get-regexp", "--get-urlmatch", "-l", "--list") ||
+			!has("--unset", "--unset-all", "--add", "--replace-all", "--rename-section", "--remove-section", "-e", "--edit") && positional == 1
+	case "remote":
+		return first == "" || first == "-v" || first == "--verbose" || first == "get-url" || first == "show"
+	case "symbolic-ref":
+		return !has("-d", "--delete") && positional <= 1
+	case "notes":
+		return first == "list" || first == "show" || (first == "--ref" || strings.HasPrefix(first, "--ref=")) && readOnlyNotes(args)
+	case "stash":
+		return first == "list" || first == "show"
+	case "worktree":
+		return first == "list"
+	case "hash-object":
+		return !has("-w")
+	}
+	return false
+}
+
+// readOnlyNotes reports whether git notes --ref REF ... lists or shows.
+func readOnlyNotes(args []string) bool {
+	for i, a := range args {
+		if a == "list" || a == "show" {
+			return true
+		}
+		if !strings.HasPrefix(a, "-") && (i == 0 || args[i-1] != "--ref") {
+			return false
+		}
+	}
+	return false
+}
+
+// readOnlyGH reports whether a gh command only reads: the Copilot provider,
+// auth status and token, viewing and listing, and API GET requests.
+func readOnlyGH(sub string, args []string) bool {
+	switch sub {
+	case "copilot", "version":
+		return true
+	case "auth":
+		return len(args) > 0 && (args[0] == "status" || args[0] == "token")
+	case "pr", "issue", "repo", "run", "release":
+		return len(args) > 0 && (args[0] == "view" || args[0] == "list" || args[0] == "status" || args[0] == "diff" || args[0] == "checks")
+	case "api":
+		for i, a := range args {
+			switch {
+			case a == "-f" || a == "-F" || a == "--field" || a == "--raw-field" || a == "--input",
+				strings.HasPrefix(a, "--field=") || strings.HasPrefix(a, "--raw-field=") || strings.HasPrefix(a, "--input="):
+				return false
+			case a == "-X" || a == "--method":
+				if i+1 >= len(args) || !strings.EqualFold(args[i+1], "GET") {
+					return false
+				}
+			case strings.HasPrefix(a, "--method=") || strings.HasPrefix(a, "-X") && len(a) > 2:
+				if m := strings.TrimPrefix(strings.TrimPrefix(a, "--method="), "-X"); !strings.EqualFold(m, "GET") {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"git", "diff", "--cached"}, true},
+		{[]string{"git", "-C", "sub", "log", "-1"}, true},
+		{[]string{"git", "diff", "--output=patch.diff"}, false},
+		{[]string{"git", "rev-parse", "--show-toplevel"}, true},
+		{[]string{"git", "config", "--get", "user.name"}, true},
+		{[]string{"git", "config", "user.name"}, true},
+		{[]string{"git", "config", "user.name", "Jane"}, false},
+		{[]string{"git", "branch", "--show-current"}, true},
+		{[]string{"git", "branch", "--merged", "main"}, true},
+		{[]string{"git", "branch", "-D", "old"}, false},
+		{[]string{"git", "branch", "feature"}, false},
+		{[]string{"git", "remote", "get-url", "origin"}, true},
+		{[]string{"git", "remote", "add", "fork", "url"}, false},
+		{[]string{"git", "notes", "--ref", "smart-commit-files", "show", "HEAD"}, true},
+		{[]string{"git", "notes", "--ref", "smart-commit-files", "add", "-m", "x"}, false},
+		{[]string{"git", "credential", "fill"}, true},
+		{[]string{"git", "credential", "approve"}, false},
+		{[]string{"git", "hash-object", "-w", "file"}, false},
+		{[]string{"git", "add", "-A"}, false},
+		{[]string{"git", "commit", "-m", "x"}, false},
+		{[]string{"git", "push"}, false},
+		{[]string{"git", "fetch"}, false},
+		{[]string{"gh", "copilot", "suggest"}, true},
+		{[]string{"gh", "api", "repos/o/r/pulls"}, true},
+		{[]string{"gh", "api", "-X", "POST", "repos/o/r/issues"}, false},
+		{[]string{"gh", "api", "repos/o/r/issues", "-f", "title=x"}, false},
+		{[]string{"gh", "pr", "view"}, true},
+		{[]string{"gh", "pr", "create"}, false},
+		{[]string{"sh", "-c", "make test"}, false},
+		{[]string{"/usr/bin/smart-commit", "hook"}, false},
+	}
+	for _, tt := range tests {
+		if err := readOnlyCommand(tt.args); (err == nil) != tt.ok {
+			t.Errorf("readOnlyCommand(%q) = %v, want allowed %v", tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestTakeReadOnlyFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		rest string
+		set  bool
+	}{
+		{[]string{"--read-only", "changelog", "--stdout"}, "changelog --stdout", true},
+		{[]string{"changelog", "-read-only"}, "changelog", true},
+		{[]string{"--dry-run", "--read-only=false"}, "--dry-run", false},
+		{[]string{"lint", "--", "--read-only"}, "lint -- --read-only", false},
+	}
+	for _, tt := range tests {
+		rest, set := takeReadOnlyFlag(tt.args)
+		if strings.Join(rest, " ") != tt.rest || set != tt.set {
+			t.Errorf("takeReadOnlyFlag(%q) = %q, %v, want %q, %v", tt.args, rest, set, tt.rest, tt.set)
+		}
+	}
+}
+
+func TestCommitFlowReadOnly(t *testing.T) {
+	repo, env := testRepo(t)
+	os.WriteFile(filepath.Join(repo, "README.md"), []byte("# Test\n"), 0644)
+	runGit(t, repo, env, "add", "README.md")
+	before := runGit(t, repo, env, "rev-parse", "HEAD")
+
+	out, err := runCLI(t, repo, env, fakeProvider(t, "docs: add readme"), "--read-only", "--yes")
+	if err != nil {
+		t.Fatalf("smart-commit --read-only failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "docs: add readme") {
+		t.Errorf("output lacks the suggested message:\n%s", out)
+	}
+	if after := runGit(t, repo, env, "rev-parse", "HEAD"); after != before {
+		t.Errorf("HEAD moved from %s to %s", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".git", "smart-commit")); !os.IsNotExist(err) {
+		t.Errorf("read-only run wrote .git/smart-commit: %v", err)
+	}
+
+	for _, args := range [][]string{{"--read-only", "--split"}, {"--read-only", "config", "set", "push", "false"}, {"changelog", "--read-only"}} {
+		if out, err := runCLI(t, repo, env, "", args...); err == nil || !strings.Contains(out, "read-only") {
+			t.Errorf("smart-commit %q = %v, want a read-only error\n%s", args, err, out)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// rebaseOntoBase fetches base from origin and, when it moved past the point
+// the current branch forked from, rebases onto it and re-runs the pre-commit
+// hook against the result. It reports whether history was rewritten.
+func rebaseOntoBase(base string) (bool, error) {
+	if base == "" {
+		b, err := git.DefaultBranch()
+		if err != nil {
+			return false, err
+		}
+		base = b
+	}
+	upstream := "origin/" + base
+
+	fmt.Printf("Fetching %s...\n", upstream)
+	if err := executeCommand("git", "fetch", "origin", base); err != nil {
+		return false, fmt.Errorf("fetching %s: %v", upstream, err)
+	}
+
+	forkPoint, err := executeCommandWithOutput("git", "merge-base", "HEAD", upstream)
+	if err != nil {
+		return false, fmt.Errorf("finding merge base with %s: %v", upstream, err)
+	}
+	baseTip, err := executeCommandWithOutput("git", "rev-parse", upstream)
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %v", upstream, err)
+	}
+	if strings.TrimSpace(forkPoint) == strings.TrimSpace(baseTip) {
+		fmt.Printf("Already up to date with %s.\n", upstream)
+		return false, nil
+	}
+
+	// Trial-merge first so a conflicting rebase is reported before it starts
+	conflicts, err := predictConflicts(upstream)
+	if err != nil {
+		return false, err
+	}
+	if len(conflicts) > 0 {
+		printConflictReport(upstream, describeConflicts(upstream, conflicts, checkProvider() == nil))
+		if !isTerminal(os.Stdin) || !confirm("Attempt the rebase anyway?") {
+			return false, fmt.Errorf("rebase onto %s skipped because of predicted conflicts", upstream)
+		}
+	}
+
+	fmt.Printf("%s has moved, rebasing...\n", upstream)
+	if err := executeCommand("git", "rebase", upstream); err != nil {
+		// Leave the branch exactly as it was rather than mid-rebase
+		executeCommand("git", "rebase", "--abort")
+		return false, fmt.Errorf("rebase onto %s failed and was aborted; resolve conflicts manually: %v", upstream, err)
+	}
+
+	// The rebased tree has never been checked by the hooks, so run them again
+	if err := executeCommand("git", "hook", "run", "--ignore-missing", "pre-commit"); err != nil {
+		return true, fmt.Errorf("pre-commit hook failed after rebase; not pushing: %v", err)
+	}
+	return true, nil
+}
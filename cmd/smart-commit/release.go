@@ -0,0 +1,285 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// semverTag matches a release tag such as v1.4.2 or 1.4.2.
+var semverTag = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)$`)
+
+// semver is a release version, with the prefix its tag is written with.
+type semver struct {
+	Prefix              string
+	Major, Minor, Patch int
+}
+
+// parseSemver parses a release tag; ok is false for other tags.
+func parseSemver(tag string) (v semver, ok bool) {
+	m := semverTag.FindStringSubmatch(tag)
+	if m == nil {
+		return semver{}, false
+	}
+	v.Prefix = m[1]
+	v.Major, _ = strconv.Atoi(m[2])
+	v.Minor, _ = strconv.Atoi(m[3])
+	v.Patch, _ = strconv.Atoi(m[4])
+	return v, true
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+}
+
+// less reports whether v is an older version than o.
+func (v semver) less(o semver) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	return v.Patch < o.Patch
+}
+
+// bump returns the next version for a major, minor or patch release.
+func (v semver) bump(kind string) semver {
+	switch kind {
+	case "major":
+		return semver{Prefix: v.Prefix, Major: v.Major + 1}
+	case "minor":
+		return semver{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor + 1}
+	}
+	return semver{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// releaseBump works out the release commits call for: major for a breaking
+// change, minor for a feature, patch for a fix or performance improvement,
+// and "" when there is nothing to release. reason says why.
+func releaseBump(commits []git.Commit) (kind, reason string) {
+	var breaking, features, fixes int
+	for _, c := range commits {
+		switch {
+		case c.Breaking:
+			breaking++
+		case c.Type == "feat":
+			features++
+		case c.Type == "fix" || c.Type == "perf":
+			fixes++
+		}
+	}
+	var parts []string
+	for _, n := range []struct {
+		count       int
+		one, plural string
+	}{{breaking, "breaking change", "breaking changes"}, {features, "feature", "features"}, {fixes, "fix", "fixes"}} {
+		if n.count == 1 {
+			parts = append(parts, "1 "+n.one)
+		} else if n.count > 1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n.count, n.plural))
+		}
+	}
+	reason = strings.Join(parts, ", ")
+	switch {
+	case breaking > 0:
+		return "major", reason
+	case features > 0:
+		return "minor", reason
+	case fixes > 0:
+		return "patch", reason
+	}
+	return "", ""
+}
+
+// latestRelease returns the newest release tag HEAD contains, or ok false
+// when there is none yet.
+func latestRelease() (v semver, tag string, ok bool) {
+	out, err := executeCommandWithOutput("git", "tag", "--merged", "HEAD", "--sort=-v:refname")
+	if err != nil {
+		return semver{}, "", false
+	}
+	for _, tag := range strings.Fields(out) {
+		if v, ok := parseSemver(tag); ok {
+			return v, tag, true
+		}
+	}
+	return semver{}, "", false
+}
+
+// releasePlan is the next release worked out from the commits since the
+// latest one.
+type releasePlan struct {
+	Current semver
+	Tag     string // of the latest release; empty when there is none
+	Since   string // the tag, or "the first commit"
+	Commits []git.Commit
+	Next    semver
+	Kind    string
+	Reason  string
+}
+
+// planRelease works out the next release, of the kind bump asks for when it
+// is not empty. Without commits since the latest release, Commits is empty
+// and there is no next version.
+func planRelease(bump string) (*releasePlan, error) {
+	if bump != "" && bump != "major" && bump != "minor" && bump != "patch" {
+		return nil, fmt.Errorf("unknown --bump %q: expected major, minor or patch", bump)
+	}
+	p := &releasePlan{Since: "the first commit"}
+	current, tag, released := latestRelease()
+	revRange := "HEAD"
+	if released {
+		revRange, p.Since, p.Tag = tag+"..HEAD", tag, tag
+	} else {
+		current.Prefix = "v"
+	}
+	p.Current = current
+	commits, err := git.LoadCommits("--no-merges", revRange)
+	if err != nil {
+		return nil, err
+	}
+	if p.Commits = commits; len(commits) == 0 {
+		return p, nil
+	}
+
+	p.Kind, p.Reason = releaseBump(commits)
+	if bump != "" {
+		p.Kind, p.Reason = bump, "--bump "+bump
+	}
+	if p.Kind == "" {
+		return nil, fmt.Errorf("no features, fixes or breaking changes since %s; pass --bump to release anyway", p.Since)
+	}
+	p.Next = current.bump(p.Kind)
+	return p, nil
+}
+
+// releaseSummary is what release and version report: the current and the
+// next version, and the release notes or version files.
+type releaseSummary struct {
+	Current string `json:"current,omitempty"`
+	Since   string `json:"since,omitempty"`
+	Next    string `json:"next,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Notes   string `json:"notes,omitempty"`
+	// Files maps each version file to the version it holds.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// summary describes the plan; without commits there is no next version.
+func (p *releasePlan) summary() releaseSummary {
+	s := releaseSummary{Current: p.Tag, Since: p.Since}
+	if len(p.Commits) > 0 {
+		s.Next, s.Kind, s.Reason = p.Next.String(), p.Kind, p.Reason
+	}
+	return s
+}
+
+// report shows the current and the next version, then the notes.
+func (s releaseSummary) report() report {
+	r := report{Data: s}
+	if s.Next == "" {
+		r.paragraph("No commits since %s; nothing to release.", s.Since)
+		return r
+	}
+	var versions []string
+	if s.Current != "" {
+		versions = append(versions, "Current version: **"+s.Current+"**")
+	}
+	reason := s.Reason
+	if s.Kind != "" {
+		reason = s.Kind + " release: " + s.Reason
+	}
+	versions = append(versions, fmt.Sprintf("Next version: **%s** (%s)", s.Next, reason))
+	r.list(versions)
+	if s.Notes != "" {
+		r.paragraph("%s", strings.TrimRight(s.Notes, "\n"))
+	}
+	return r
+}
+
+// runRelease implements `smart-commit release`: it works out the next
+// version from the commits since the last release tag and creates an
+// annotated tag for it, with release notes.
+func runRelease(args []string) error {
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	bump := fs.String("bump", "", "release this kind of version instead of the computed one: major, minor or patch")
+	dryRun := fs.Bool("dry-run", false, "show the next version and the release notes without tagging")
+	push := fs.Bool("push", false, "push the tag after creating it")
+	remote := fs.String("remote", "", "remote to push the tag to (default: where the branch pushes)")
+	noAI := fs.Bool("no-ai", false, "leave the AI-written summary out of the release notes")
+	yes := fs.Bool("yes", false, "tag without asking for confirmation")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	plan, err := planRelease(*bump)
+	if err != nil {
+		return err
+	}
+	if len(plan.Commits) == 0 {
+		return printReport(*format, plan.summary().report())
+	}
+	next, commits := plan.Next, plan.Commits
+	if git.RefExists("refs/tags/" + next.String()) {
+		return fmt.Errorf("tag %s already exists", next)
+	}
+
+	summary := ""
+	if !*dryRun && !*noAI {
+		if err := checkProvider(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Writing release notes with %s...\n", currentGenerator().Name())
+		summary, err = askModel(generator.RenderPrompt(generator.PromptReleaseSummary, map[string]interface{}{"Version": next.String(), "Commits": commits}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), err)
+		}
+	}
+	notes := releaseNotes(next.String(), summary, commits)
+	s := plan.summary()
+	s.Notes = notes
+	if err := writeReport(reportStdout(*format), *format, s.report()); err != nil {
+		return err
+	}
+	if *dryRun {
+		return nil
+	}
+	if !*yes && isTerminal(os.Stdin) && isTerminal(os.Stdout) && !confirm(fmt.Sprintf("Create tag %s?", next)) {
+		return fmt.Errorf("release aborted")
+	}
+
+	// Verbatim, or git would strip the Markdown headings as comments
+	if err := executeCommand("git", "tag", "-a", "--cleanup=verbatim", "-m", notes, next.String()); err != nil {
+		return fmt.Errorf("creating tag %s: %v", next, err)
+	}
+	fmt.Printf("Tagged %s.\n", next)
+	if !*push {
+		return nil
+	}
+	if *remote == "" {
+		branch, _ := git.CurrentBranch()
+		*remote = pushRemote(branch)
+	}
+	if err := executeCommand("git", "push", *remote, "refs/tags/"+next.String()); err != nil {
+		return fmt.Errorf("pushing %s to %s: %v", next, *remote, err)
+	}
+	fmt.Printf("Pushed %s to %s.\n", next, *remote)
+	return nil
+}
+
+// releaseNotes is the message of the release tag: the version, the summary
+// when there is one, and the commits grouped like a changelog section.
+func releaseNotes(version, summary string, commits []git.Commit) string {
+	_, body, _ := strings.Cut(renderChangelogSummary(version, summary, commits), "\n")
+	return version + "\n" + strings.TrimRight(body, "\n") + "\n"
+}
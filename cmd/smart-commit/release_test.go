@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestReleaseBump(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  []git.Commit
+		wantKind string
+		want     string
+	}{
+		{"fix", []git.Commit{{Type: "fix"}, {Type: "docs"}}, "patch", "v1.4.3"},
+		{"perf", []git.Commit{{Type: "perf"}}, "patch", "v1.4.3"},
+		{"feature", []git.Commit{{Type: "fix"}, {Type: "feat"}}, "minor", "v1.5.0"},
+		{"breaking", []git.Commit{{Type: "feat"}, {Type: "refactor", Breaking: true}}, "major", "v2.0.0"},
+		{"nothing to release", []git.Commit{{Type: "chore"}, {Type: "docs"}}, "", ""},
+	}
+	current, _ := parseSemver("v1.4.2")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, _ := releaseBump(tt.commits)
+			if kind != tt.wantKind {
+				t.Fatalf("releaseBump() = %q, want %q", kind, tt.wantKind)
+			}
+			if kind != "" {
+				if got := current.bump(kind).String(); got != tt.want {
+					t.Errorf("next version = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		tag  string
+		ok   bool
+		want string
+	}{
+		{"v1.2.3", true, "v1.2.3"},
+		{"0.10.0", true, "0.10.0"},
+		{"v1.2", false, ""},
+		{"v1.2.3-rc.1", false, ""},
+		{"release-1", false, ""},
+	}
+	for _, tt := range tests {
+		v, ok := parseSemver(tt.tag)
+		if ok != tt.ok || ok && v.String() != tt.want {
+			t.Errorf("parseSemver(%q) = %v, %v; want %q, %v", tt.tag, v, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCutRelease(t *testing.T) {
+	repo, env := testRepo(t)
+	remote := t.TempDir()
+	runGit(t, remote, env, "init", "-q", "--bare")
+	runGit(t, repo, env, "remote", "add", "origin", remote)
+	os.WriteFile(filepath.Join(repo, "version.go"), []byte("package main\n\nconst Version = \"1.3.0\"\n"), 0644)
+	os.WriteFile(filepath.Join(repo, ".smartcommit.yml"), []byte("version_files:\n  - path: version.go\n    pattern: 'Version = \"([^\"]+)\"'\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "chore: add version")
+	runGit(t, repo, env, "tag", "v1.3.0")
+	os.WriteFile(filepath.Join(repo, "feature.go"), []byte("package main\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "feat: add feature")
+
+	baseURL := fakeProvider(t, "chore(release): prepare 1.4.0")
+	if out, err := runCLI(t, repo, env, baseURL, "cut-release", "1.2"); err == nil || !strings.Contains(out, "v1.2.0 is not newer than the latest release v1.3.0") {
+		t.Fatalf("cut-release 1.2: %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, repo, env, baseURL, "cut-release", "--yes", "1.4"); err != nil {
+		t.Fatalf("cut-release: %v\n%s", err, out)
+	}
+	if branch := strings.TrimSpace(runGit(t, repo, env, "branch", "--show-current")); branch != "release/1.4" {
+		t.Errorf("branch = %q, want release/1.4", branch)
+	}
+	if got := runGit(t, repo, env, "log", "-1", "--format=%s"); strings.TrimSpace(got) != "chore(release): prepare 1.4.0" {
+		t.Errorf("commit subject = %q", got)
+	}
+	if got := runGit(t, repo, env, "show", "HEAD:version.go"); !strings.Contains(got, `Version = "1.4.0"`) {
+		t.Errorf("version.go = %q", got)
+	}
+	if got := runGit(t, repo, env, "show", "HEAD:CHANGELOG.md"); !strings.Contains(got, "## [v1.4.0]") || !strings.Contains(got, "Add feature") {
+		t.Errorf("CHANGELOG.md = %q", got)
+	}
+	if got := runGit(t, remote, env, "branch", "--list", "release/1.4"); !strings.Contains(got, "release/1.4") {
+		t.Errorf("release/1.4 was not pushed")
+	}
+}
+
+func TestVersionApply(t *testing.T) {
+	repo, env := testRepo(t)
+	os.WriteFile(filepath.Join(repo, "package.json"), []byte("{\n  \"name\": \"demo\",\n  \"version\": \"1.3.0\"\n}\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "pyproject.toml"), []byte("[project]\nname = \"demo\"\nversion = \"1.3.0\"\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "chore: add manifests")
+	runGit(t, repo, env, "tag", "v1.3.0")
+	os.WriteFile(filepath.Join(repo, "fix.go"), []byte("package main\n"), 0644)
+	runGit(t, repo, env, "add", ".")
+	runGit(t, repo, env, "commit", "-q", "-m", "fix: handle empty input")
+	os.WriteFile(filepath.Join(repo, "wip.go"), []byte("package main\n"), 0644)
+	runGit(t, repo, env, "add", "wip.go")
+
+	out, err := runCLI(t, repo, env, "", "version", "--apply", "--render", "plain")
+	if err != nil || !strings.Contains(out, "Next version: v1.3.1 (patch release") || !strings.Contains(out, "package.json    1.3.0") {
+		t.Fatalf("version --apply: %v\n%s", err, out)
+	}
+	if got := runGit(t, repo, env, "show", "--name-only", "--format=%s", "HEAD"); strings.TrimSpace(got) != "chore(release): v1.3.1\n\npackage.json\npyproject.toml" {
+		t.Errorf("release commit = %q", got)
+	}
+	if got := runGit(t, repo, env, "show", "HEAD:pyproject.toml"); !strings.Contains(got, "version = \"1.3.1\"") {
+		t.Errorf("pyproject.toml = %q", got)
+	}
+	if staged := runGit(t, repo, env, "diff", "--cached", "--name-only"); strings.TrimSpace(staged) != "wip.go" {
+		t.Errorf("staged after the release commit = %q, want wip.go", staged)
+	}
+}
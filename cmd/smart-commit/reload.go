@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// configCheckInterval is how often serve and daemon look for changed
+// configuration files.
+const configCheckInterval = 2 * time.Second
+
+// configWatcher tells when files change, by their modification times; a
+// file appearing or going away is a change too.
+type configWatcher struct {
+	files []string
+	stamp map[string]time.Time
+}
+
+func newConfigWatcher(files []string) *configWatcher {
+	w := &configWatcher{files: files}
+	w.stamp = w.stamps()
+	return w
+}
+
+func (w *configWatcher) stamps() map[string]time.Time {
+	stamp := map[string]time.Time{}
+	for _, f := range w.files {
+		if info, err := os.Stat(f); err == nil {
+			stamp[f] = info.ModTime()
+		}
+	}
+	return stamp
+}
+
+// changed reports whether any file changed since the last call.
+func (w *configWatcher) changed() bool {
+	now := w.stamps()
+	same := len(now) == len(w.stamp)
+	for f, t := range now {
+		same = same && w.stamp[f].Equal(t)
+	}
+	w.stamp = now
+	return !same
+}
+
+// configFiles are the files whose changes a running server applies: the
+// configuration files and the repository's policy file, as of the
+// directory it started in.
+func configFiles() []string {
+	return append(config.Paths(), localPolicyPath())
+}
+
+// watchConfig reloads the configuration in the background whenever its
+// files change. A reload that fails is logged and the server keeps the
+// settings it has.
+func watchConfig() {
+	w := newConfigWatcher(configFiles())
+	go func() {
+		for {
+			time.Sleep(configCheckInterval)
+			if !w.changed() {
+				continue
+			}
+			if err := reloadConfig(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Reloaded configuration; using %s\n", currentGenerator().Name())
+		}
+	}()
+}
+
+// reloadConfig applies the configuration and policy files as they are now:
+// the provider, the policy and the scope map. Everything is checked before
+// anything is applied, so a file that does not parse or names an unknown
+// provider leaves the previous settings in effect.
+func reloadConfig() error {
+	c, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("keeping the previous configuration: %v", err)
+	}
+	gen, err := configuredGenerator(c)
+	if err != nil {
+		return fmt.Errorf("keeping the previous configuration: %v", err)
+	}
+	p, err := loadPolicy()
+	if err != nil {
+		return fmt.Errorf("keeping the previous configuration: policy: %v", err)
+	}
+	config.Use(c)
+	useGenerator(gen)
+	usePolicy(p)
+	forgetWorkspaces()
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+func TestConfigWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	w := newConfigWatcher([]string{file})
+	steps := []struct {
+		name   string
+		change func()
+		want   bool
+	}{
+		{"nothing yet", func() {}, false},
+		{"created", func() { os.WriteFile(file, []byte("push: false\n"), 0644) }, true},
+		{"unchanged", func() {}, false},
+		{"edited", func() { os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)) }, true},
+		{"removed", func() { os.Remove(file) }, true},
+	}
+	for _, s := range steps {
+		s.change()
+		if got := w.changed(); got != s.want {
+			t.Errorf("%s: changed() = %v, want %v", s.name, got, s.want)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	repo, env := testRepo(t)
+	for _, kv := range env {
+		if k, v, _ := strings.Cut(kv, "="); k == "HOME" || k == "XDG_CONFIG_HOME" || k == "GIT_CONFIG_NOSYSTEM" {
+			t.Setenv(k, v)
+		}
+	}
+	t.Setenv("SMART_COMMIT_PROVIDER", "")
+	wd, _ := os.Getwd()
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	previous := config.Current()
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		config.Use(previous)
+		generatorMu.Lock()
+		activeGenerator = nil
+		generatorMu.Unlock()
+		usePolicy(nil)
+		forgetWorkspaces()
+	})
+
+	file := filepath.Join(repo, config.RepoFile)
+	os.WriteFile(file, []byte("provider: ollama\nremote: upstream\n"), 0644)
+	if err := reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig: %v", err)
+	}
+	if got := config.Current().Remote; got != "upstream" {
+		t.Errorf("remote = %q, want upstream", got)
+	}
+
+	for _, bad := range []string{"remote: [\n", "provider: nonesuch\n", "no_such_key: 1\n"} {
+		os.WriteFile(file, []byte(bad), 0644)
+		if err := reloadConfig(); err == nil {
+			t.Errorf("reloadConfig(%q) succeeded, want an error", bad)
+		}
+		if got := config.Current().Remote; got != "upstream" {
+			t.Errorf("after %q: remote = %q, want the previous upstream", bad, got)
+		}
+	}
+}
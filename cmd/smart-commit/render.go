@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats accepted by --render.
+const (
+	renderPlain    = "plain"
+	renderRich     = "rich"
+	renderJSON     = "json"
+	renderMarkdown = "markdown"
+)
+
+// report is what a subcommand prints, kept apart from how it is printed so
+// every command can be rendered in every format. Text may use Markdown's
+// inline marks, **strong**, _emphasis_ and `code`: the markdown renderer
+// keeps them, the rich one turns them into terminal styles and the others
+// drop them.
+type report struct {
+	Blocks []block `json:"blocks"`
+	// Data, when set, is what the JSON renderer prints instead of the
+	// blocks, for results with more structure than their text shows.
+	Data interface{} `json:"-"`
+	// Formats are renderers for formats only this report offers, such as
+	// lint's SARIF, by their --render name.
+	Formats map[string]renderer `json:"-"`
+}
+
+// block is one part of a report: a heading (Heading is its level, from 1),
+// a paragraph, a list or a table. Table cells are data, shown as they are.
+type block struct {
+	Heading int        `json:"heading,omitempty"`
+	Text    string     `json:"text,omitempty"`
+	Items   []string   `json:"items,omitempty"`
+	Columns []string   `json:"columns,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+}
+
+func (r *report) heading(level int, format string, args ...interface{}) {
+	r.Blocks = append(r.Blocks, block{Heading: level, Text: fmt.Sprintf(format, args...)})
+}
+
+func (r *report) paragraph(format string, args ...interface{}) {
+	r.Blocks = append(r.Blocks, block{Text: fmt.Sprintf(format, args...)})
+}
+
+// list adds a bulleted list; an empty one adds nothing.
+func (r *report) list(items []string) {
+	if len(items) > 0 {
+		r.Blocks = append(r.Blocks, block{Items: items})
+	}
+}
+
+func (r *report) table(columns []string, rows [][]string) {
+	r.Blocks = append(r.Blocks, block{Columns: columns, Rows: rows})
+}
+
+// renderer prints reports in one format.
+type renderer interface {
+	Render(w io.Writer, r report) error
+}
+
+// renderFunc adapts a function to the renderer interface, for the formats
+// of a single report.
+type renderFunc func(w io.Writer, r report) error
+
+func (f renderFunc) Render(w io.Writer, r report) error {
+	return f(w, r)
+}
+
+// renderFormats lists the formats every report can be rendered in.
+var renderFormats = []string{renderPlain, renderRich, renderJSON, renderMarkdown}
+
+// renderers are the formats of --render.
+var renderers = map[string]renderer{
+	renderPlain:    textRenderer{},
+	renderRich:     textRenderer{rich: true},
+	renderJSON:     jsonRenderer{},
+	renderMarkdown: markdownRenderer{},
+}
+
+// renderFlag adds --render to fs. Its default is SMART_COMMIT_RENDER, else
+// natural, else rich on a color terminal and plain elsewhere; commands
+// whose output is a document, such as digest, pass markdown as natural.
+// extra lists the formats the command's reports add.
+func renderFlag(fs *flag.FlagSet, natural string, extra ...string) *string {
+	format := os.Getenv("SMART_COMMIT_RENDER")
+	switch {
+	case format != "":
+	case natural != "":
+		format = natural
+	case isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb":
+		format = renderRich
+	default:
+		format = renderPlain
+	}
+	return fs.String("render", format, "output format: "+formatList(append(renderFormats, extra...)))
+}
+
+// checkRender reports an unknown --render format, for commands to fail
+// before doing any work. extra lists the formats the command's reports add.
+func checkRender(format string, extra ...string) error {
+	if _, ok := renderers[format]; !ok && !contains(extra, format) {
+		return fmt.Errorf("unknown --render %q (expected %s)", format, formatList(append(renderFormats, extra...)))
+	}
+	return nil
+}
+
+// formatList joins formats as "a, b or c".
+func formatList(formats []string) string {
+	if len(formats) < 2 {
+		return strings.Join(formats, "")
+	}
+	return strings.Join(formats[:len(formats)-1], ", ") + " or " + formats[len(formats)-1]
+}
+
+// writeReport renders r to w in format.
+func writeReport(w io.Writer, format string, r report) error {
+	if f, ok := r.Formats[format]; ok {
+		return f.Render(w, r)
+	}
+	if err := checkRender(format); err != nil {
+		return err
+	}
+	return renderers[format].Render(w, r)
+}
+
+// markdown renders r as Markdown, for documents sent elsewhere, such as
+// comments and protocol results.
+func (r report) markdown() string {
+	var b strings.Builder
+	markdownRenderer{}.Render(&b, r)
+	return b.String()
+}
+
+// printReport renders r to stdout in format.
+func printReport(format string, r report) error {
+	return writeReport(os.Stdout, format, r)
+}
+
+// reportStdout is for commands that go on to do things after printing
+// their report: it returns stdout for the report and, when the report is
+// JSON, sends everything else printed to stderr so stdout holds the
+// document alone.
+func reportStdout(format string) io.Writer {
+	out := os.Stdout
+	if format == renderJSON {
+		os.Stdout = os.Stderr
+	}
+	return out
+}
+
+// markdownRenderer prints reports as Markdown, blocks separated by blank
+// lines.
+type markdownRenderer struct{}
+
+func (markdownRenderer) Render(w io.Writer, r report) error {
+	var b strings.Builder
+	for i, bl := range r.Blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch {
+		case bl.Heading > 0:
+			fmt.Fprintf(&b, "%s %s\n", strings.Repeat("#", bl.Heading), bl.Text)
+		case bl.Columns != nil:
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(bl.Columns, " | "))
+			fmt.Fprintf(&b, "|%s\n", strings.Repeat(" --- |", len(bl.Columns)))
+			for _, row := range bl.Rows {
+				cells := mapStrings(row, func(s string) string { return strings.ReplaceAll(s, "|", `\|`) })
+				fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+			}
+		case bl.Items != nil:
+			for _, item := range bl.Items {
+				fmt.Fprintf(&b, "- %s\n", item)
+			}
+		default:
+			b.WriteString(bl.Text + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsonRenderer prints a report's data, or else its blocks with the inline
+// marks dropped, as indented JSON.
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(w io.Writer, r report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if r.Data != nil {
+		return enc.Encode(r.Data)
+	}
+	plain := report{Blocks: []block{}}
+	for _, bl := range r.Blocks {
+		bl.Text = inlineMarks(bl.Text, nil)
+		bl.Items = mapStrings(bl.Items, func(s string) string { return inlineMarks(s, nil) })
+		plain.Blocks = append(plain.Blocks, bl)
+	}
+	return enc.Encode(plain)
+}
+
+// textRenderer prints reports for people: tables in aligned columns and
+// lists as bullets. Rich output styles headings and inline marks for a
+// terminal; plain output has no escape sequences.
+type textRenderer struct {
+	rich bool
+}
+
+// Terminal styles of rich output.
+const (
+	styleBold      = "\x1b[1m"
+	styleItalic    = "\x1b[3m"
+	styleUnderline = "\x1b[4m"
+	styleCode      = "\x1b[36m"
+	styleReset     = "\x1b[0m"
+)
+
+func (t textRenderer) Render(w io.Writer, r report) error {
+	var b strings.Builder
+	for i, bl := range r.Blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		switch {
+		case bl.Heading > 0:
+			b.WriteString(t.heading(bl.Heading, inlineMarks(bl.Text, nil)) + "\n")
+		case bl.Columns != nil:
+			t.writeTable(&b, bl.Columns, bl.Rows)
+		case bl.Items != nil:
+			bullet := "-"
+			if t.rich {
+				bullet = "•"
+			}
+			for _, item := range bl.Items {
+				fmt.Fprintf(&b, "%s %s\n", bullet, t.inline(item))
+			}
+		default:
+			b.WriteString(t.inline(bl.Text) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// heading styles a heading: underlined and bold at the top level, bold
+// below it.
+func (t textRenderer) heading(level int, text string) string {
+	switch {
+	case !t.rich:
+		return text
+	case level == 1:
+		return styleBold + styleUnderline + text + styleReset
+	}
+	return styleBold + text + styleReset
+}
+
+// inline applies or drops the inline marks of text.
+func (t textRenderer) inline(text string) string {
+	if !t.rich {
+		return inlineMarks(text, nil)
+	}
+	return inlineMarks(text, func(mark, s string) string {
+		switch mark {
+		case "**":
+			return styleBold + s + styleReset
+		case "_":
+			return styleItalic + s + styleReset
+		}
+		return styleCode + s + styleReset
+	})
+}
+
+// writeTable prints a table in columns two spaces apart, with a header row
+// in upper case (bold when rich).
+func (t textRenderer) writeTable(b *strings.Builder, columns []string, rows [][]string) {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToUpper(c)
+	}
+	all := append([][]string{header}, rows...)
+	widths := make([]int, len(columns))
+	for _, row := range all {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); i < len(widths) && n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for r, row := range all {
+		var line strings.Builder
+		for i, cell := range row {
+			shown := cell
+			if r == 0 && t.rich && cell != "" {
+				shown = styleBold + cell + styleReset
+			}
+			line.WriteString(shown)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+}
+
+// inlineMarks finds the inline marks of text, `code`, **strong** and
+// _emphasis_, and replaces each marked span with style(mark, span), or
+// with the bare span when style is nil. Underscores inside words, as in
+// snake_case, are not marks.
+func inlineMarks(text string, style func(mark, s string) string) string {
+	if style == nil {
+		style = func(_, s string) string { return s }
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		mark := ""
+		switch {
+		case text[i] == '`':
+			mark = "`"
+		case strings.HasPrefix(text[i:], "**"):
+			mark = "**"
+		case text[i] == '_' && (i == 0 || !isWordByte(text[i-1])):
+			mark = "_"
+		}
+		if mark != "" {
+			if end := closingMark(text, i+len(mark), mark); end > i+len(mark) {
+				b.WriteString(style(mark, text[i+len(mark):end]))
+				i = end + len(mark)
+				continue
+			}
+		}
+		b.WriteByte(text[i])
+		i++
+	}
+	return b.String()
+}
+
+// closingMark returns where the mark opened before start is closed, or -1.
+// An emphasis mark only closes at the end of a word.
+func closingMark(text string, start int, mark string) int {
+	for j := start; j < len(text); j++ {
+		if !strings.HasPrefix(text[j:], mark) {
+			continue
+		}
+		if mark == "_" && j+1 < len(text) && isWordByte(text[j+1]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// isWordByte reports whether c can be part of a word.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// mapStrings applies fn to every string of list.
+func mapStrings(list []string, fn func(string) string) []string {
+	if list == nil {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = fn(s)
+	}
+	return out
+}
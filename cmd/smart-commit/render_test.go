@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	var r report
+	r.heading(1, "Hotspots")
+	r.paragraph("Files with **most** fixes in `main`")
+	r.table([]string{"Risk", "File"}, [][]string{{"0.90", "a|b.go"}, {"0.10", "c.go"}})
+	r.list([]string{"_snake_case_ stays", "done"})
+	r.list(nil)
+
+	tests := []struct {
+		format, want string
+	}{
+		{renderPlain, "Hotspots\n\nFiles with most fixes in main\n\nRISK  FILE\n0.90  a|b.go\n0.10  c.go\n\n- snake_case stays\n- done\n"},
+		{renderMarkdown, "# Hotspots\n\nFiles with **most** fixes in `main`\n\n| Risk | File |\n| --- | --- |\n| 0.90 | a\\|b.go |\n| 0.10 | c.go |\n\n- _snake_case_ stays\n- done\n"},
+		{renderRich, "\x1b[1m\x1b[4mHotspots\x1b[0m\n\nFiles with \x1b[1mmost\x1b[0m fixes in \x1b[36mmain\x1b[0m\n\n\x1b[1mRISK\x1b[0m  \x1b[1mFILE\x1b[0m\n0.90  a|b.go\n0.10  c.go\n\n• \x1b[3msnake_case\x1b[0m stays\n• done\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := writeReport(&b, tt.format, r); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s:\n%q\nwant\n%q", tt.format, b.String(), tt.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := writeReport(&b, renderJSON, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"text": "Files with most fixes in main"`) {
+		t.Errorf("json: inline marks were not dropped:\n%s", b.String())
+	}
+	b.Reset()
+	r.Data = map[string]int{"files": 2}
+	writeReport(&b, renderJSON, r)
+	if b.String() != "{\n  \"files\": 2\n}\n" {
+		t.Errorf("json: got %q, want the report's data", b.String())
+	}
+	if err := writeReport(&b, "html", r); err == nil {
+		t.Error("an unknown format was accepted")
+	}
+}
+
+func TestInlineMarks(t *testing.T) {
+	mark := func(m, s string) string { return "<" + m + ">" + s }
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"run `go test` now", "run <`>go test now"},
+		{"a **bold** move", "a <**>bold move"},
+		{"an _emphasis_ here", "an <_>emphasis here"},
+		{"snake_case_name stays", "snake_case_name stays"},
+		{"_lead_ and snake_case", "<_>lead and snake_case"},
+		{"unclosed `code and **bold", "unclosed `code and **bold"},
+		{"empty `` marks", "empty `` marks"},
+	}
+	for _, tt := range tests {
+		if got := inlineMarks(tt.in, mark); got != tt.want {
+			t.Errorf("inlineMarks(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	results := []lintResult{
+		{ID: "abc1234", Subject: "feat: add x"},
+		{ID: "def5678", Subject: "Added y", Problems: []lintProblem{{Line: 1, Rule: "format", Message: "subject is not conventional"}}},
+	}
+	r := lintReport(results)
+	tests := []struct {
+		format, want string
+	}{
+		{renderPlain, "STATUS  COMMIT   SUBJECT\nok      abc1234  feat: add x\nFAIL    def5678  Added y\n\n- def5678 line 1: subject is not conventional (format)\n"},
+		{"github", "::error file=.github,line=1,title=Commit def5678%3A format::Added y%0Asubject is not conventional\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := writeReport(&b, tt.format, r); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s:\n%q\nwant\n%q", tt.format, b.String(), tt.want)
+		}
+	}
+	var b strings.Builder
+	if err := writeReport(&b, "sarif", r); err != nil || !strings.Contains(b.String(), `"ruleId": "format"`) {
+		t.Errorf("sarif: %v\n%s", err, b.String())
+	}
+
+	if err := checkRender("sarif", lintFormats...); err != nil {
+		t.Errorf("checkRender(sarif) with lint's formats: %v", err)
+	}
+	if err := checkRender("sarif"); err == nil || !strings.Contains(err.Error(), "plain, rich, json or markdown") {
+		t.Errorf("checkRender(sarif) = %v, want it refused", err)
+	}
+	if err := writeReport(&b, "sarif", report{}); err == nil {
+		t.Error("a report without SARIF was rendered as SARIF")
+	}
+}
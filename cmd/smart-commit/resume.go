@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// regenerate is set by main from --regenerate: the provider is asked again
+// even when a message was generated earlier for the same changes.
+var regenerate bool
+
+// pendingMessage is a generated message that has not been committed yet,
+// with the staged tree it was generated for.
+type pendingMessage struct {
+	Tree    string    `json:"tree"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// pendingMessagePath is where the message waiting to be committed is kept.
+func pendingMessagePath() (string, error) {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/pending.json")
+	if err != nil {
+		return "", fmt.Errorf("locating repository: %v", err)
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// stagedTree identifies the staged state: the tree the index would commit.
+func stagedTree() (string, error) {
+	tree, err := executeCommandWithOutput("git", "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("reading the index: %v", err)
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// savePendingMessage keeps message for the staged changes until it is
+// committed, so an interrupted run can be resumed. The file is replaced
+// atomically: a run killed while writing leaves the previous one.
+func savePendingMessage(message string) {
+	if !writesAllowed() {
+		return
+	}
+	path, err := pendingMessagePath()
+	if err != nil {
+		return
+	}
+	tree, err := stagedTree()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(pendingMessage{Tree: tree, Message: message, Time: time.Now()})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saving the message for --resume: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saving the message for --resume: %v\n", err)
+	}
+}
+
+// clearPendingMessage forgets the pending message once it is committed.
+func clearPendingMessage() {
+	if path, err := pendingMessagePath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// resumeMessage returns the pending message when it was generated for
+// exactly what is staged now and the user wants it: always with --resume,
+// after asking when interactive. Otherwise a new message is generated.
+func resumeMessage(resume, interactive bool) (string, bool) {
+	path, err := pendingMessagePath()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	var pending pendingMessage
+	if err != nil || json.Unmarshal(data, &pending) != nil || pending.Message == "" {
+		if resume {
+			fmt.Println("No saved message to resume; generating a new one.")
+		}
+		return "", false
+	}
+	tree, err := stagedTree()
+	if err != nil || tree != pending.Tree {
+		if resume {
+			fmt.Println("The staged changes differ from those the saved message was generated for; generating a new one.")
+		}
+		return "", false
+	}
+
+	subject, _, _ := strings.Cut(pending.Message, "\n")
+	switch {
+	case resume:
+		return pending.Message, true
+	case interactive:
+		fmt.Printf("A message generated %s for these staged changes was not committed:\n    %s\n", pending.Time.Format("Jan 2 15:04"), subject)
+		if confirm("Reuse it?") {
+			return pending.Message, true
+		}
+	default:
+		fmt.Printf("A message generated for these staged changes was not committed; pass --resume to use it: %s\n", subject)
+	}
+	return "", false
+}
+
+// unpushedPath is where the commit a run made but did not get to push is
+// noted.
+func unpushedPath() (string, error) {
+	path, err := executeCommandWithOutput("git", "rev-parse", "--git-path", "smart-commit/unpushed")
+	if err != nil {
+		return "", fmt.Errorf("locating repository: %v", err)
+	}
+	return strings.TrimSpace(path), nil
+}
+
+// noteUnpushed notes HEAD as committed and not pushed yet, until
+// clearUnpushed, so a rerun after a failed push only pushes.
+func noteUnpushed() {
+	path, err := unpushedPath()
+	if err != nil {
+		return
+	}
+	head, err := executeCommandWithOutput("git", "rev-parse", "HEAD")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		os.WriteFile(path, []byte(strings.TrimSpace(head)+"\n"), 0644)
+	}
+}
+
+// clearUnpushed forgets the unpushed commit once it is pushed.
+func clearUnpushed() {
+	if path, err := unpushedPath(); err == nil {
+		os.Remove(path)
+	}
+}
+
+// unpushedCommit returns HEAD when an earlier run committed it and failed
+// to push it. A commit made or rewritten since then does not count.
+func unpushedCommit() (string, bool) {
+	path, err := unpushedPath()
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	head, err := executeCommandWithOutput("git", "rev-parse", "HEAD")
+	if err != nil || strings.TrimSpace(head) != strings.TrimSpace(string(data)) {
+		return "", false
+	}
+	return strings.TrimSpace(head), true
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// revertLookback is how many recent commits are checked when looking for
+// the commit a change reverts.
+const revertLookback = 50
+
+// detectRevert looks for a recent commit that changes is the exact inverse
+// of and returns a conventional revert message for it.
+func detectRevert(changes *git.ChangeSet) (string, bool) {
+	added, deleted := changes.Stats()
+	if len(changes.Files) == 0 || added+deleted == 0 {
+		return "", false
+	}
+
+	// Line counts rule out almost every commit without diffing it
+	out, err := executeCommandWithOutput("git", "log", "--no-merges", "-n", strconv.Itoa(revertLookback), "--format=%x00%H", "--numstat")
+	if err != nil {
+		return "", false
+	}
+	for _, record := range strings.Split(out, "\x00") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		if len(lines) < 2 {
+			continue
+		}
+		hash := lines[0]
+		commitAdded, commitDeleted := 0, 0
+		for _, line := range lines[1:] {
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			a, _ := strconv.Atoi(parts[0])
+			d, _ := strconv.Atoi(parts[1])
+			commitAdded += a
+			commitDeleted += d
+		}
+		if commitAdded != deleted || commitDeleted != added {
+			continue
+		}
+
+		inverse, err := git.LoadChangeSet(hash, hash+"^")
+		if err != nil || !sameChanges(changes, inverse) {
+			continue
+		}
+		commits, err := git.LoadCommits("-n", "1", hash)
+		if err != nil || len(commits) == 0 {
+			continue
+		}
+		return fmt.Sprintf("revert: %s\n\nThis reverts commit %s.", commits[0].Subject, hash), true
+	}
+	return "", false
+}
+
+// sameChanges reports whether two change sets touch the same paths with the
+// same added and removed lines. Context lines are ignored, as surrounding
+// code may have moved on since.
+func sameChanges(a, b *git.ChangeSet) bool {
+	if len(a.Files) != len(b.Files) {
+		return false
+	}
+	byPath := map[string]git.FileChange{}
+	for _, f := range b.Files {
+		byPath[f.Path] = f
+	}
+	for _, f := range a.Files {
+		other, ok := byPath[f.Path]
+		if !ok || f.Added != other.Added || f.Deleted != other.Deleted || f.Binary != other.Binary {
+			return false
+		}
+		if changedLines(f.Hunks) != changedLines(other.Hunks) {
+			return false
+		}
+	}
+	return true
+}
+
+// changedLines joins the added and removed lines of hunks.
+func changedLines(hunks []git.Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		for _, line := range h.Lines {
+			if line != "" && (line[0] == '+' || line[0] == '-') {
+				b.WriteString(line)
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return b.String()
+}
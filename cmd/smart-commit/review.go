@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// errReviewAborted is returned when the user aborts the review.
+var errReviewAborted = errors.New("commit aborted; your changes remain staged")
+
+// maxRetries bounds how many times a message can be regenerated in one
+// review, as every retry makes the prompt longer.
+const maxRetries = 5
+
+// reviewMessage shows the proposed message and lets the user accept it, edit
+// it in their editor, retry with feedback, or abort. It returns the message
+// to commit.
+func reviewMessage(message string, changes *git.ChangeSet) (string, error) {
+	defer func() { retryHistory = nil }()
+	for {
+		fmt.Printf("\nProposed commit message:\n\n")
+		for _, line := range strings.Split(message, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Println()
+
+		answer, err := promptLine("[a]ccept, [e]dit, [r]etry with feedback, a[b]ort? ")
+		if err != nil {
+			return "", errReviewAborted
+		}
+		// Feedback can follow r, or be typed instead of an answer
+		verb, feedback, _ := strings.Cut(strings.TrimSpace(answer), " ")
+		feedback = strings.TrimSpace(feedback)
+		switch verb = strings.ToLower(verb); verb {
+		case "", "a", "accept", "y", "yes":
+			return message, nil
+
+		case "e", "edit":
+			edited, err := editMessage(message)
+			if err != nil {
+				return "", err
+			}
+			if edited == "" {
+				return "", fmt.Errorf("empty commit message; %v", errReviewAborted)
+			}
+			message = edited
+
+		case "b", "abort", "q", "quit", "n", "no":
+			return "", errReviewAborted
+
+		default:
+			retry := verb == "r" || verb == "retry" || verb == "regenerate"
+			if !retry && feedback == "" {
+				fmt.Println("Please answer a, e, r or b, or type what should change.")
+				continue
+			}
+			if !retry {
+				feedback = strings.TrimSpace(answer)
+			}
+			if len(retryHistory) == maxRetries {
+				fmt.Printf("Retried %d times already; edit the message instead.\n", maxRetries)
+				continue
+			}
+			if feedback == "" {
+				if feedback, err = promptLine("What should change? (optional): "); err != nil {
+					return "", errReviewAborted
+				}
+			}
+			// The model sees every attempt so far with the feedback on it
+			retryHistory = append(retryHistory, generator.Attempt{Message: message, Feedback: feedback})
+			fmt.Printf("Regenerating with %s (retry %d of %d)...\n", currentGenerator().Name(), len(retryHistory), maxRetries)
+			regenerated, err := suggestCommitMessage(changes, "")
+			if err != nil {
+				fmt.Printf("%s error: %v\n", currentGenerator().Name(), err)
+			}
+			// Keep the trailers of the previous message, including ones
+			// added while editing
+			_, kept := conventional.SplitTrailers(message)
+			if message, err = addTrailers(regenerated, kept); err != nil {
+				return "", err
+			}
+		}
+	}
+}
+
+// editMessage opens message in the user's editor, resolved like git does
+// (GIT_EDITOR, core.editor, VISUAL, EDITOR), and returns the result with
+// comment lines and surrounding blank lines removed.
+func editMessage(message string) (string, error) {
+	editor, err := executeCommandWithOutput("git", "var", "GIT_EDITOR")
+	if err != nil {
+		return "", fmt.Errorf("finding editor: %v", err)
+	}
+	editor = strings.TrimSpace(editor)
+
+	f, err := os.CreateTemp("", "SMART_COMMIT_EDITMSG-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "%s\n\n# Edit the commit message. Lines starting with '#' are ignored,\n# and an empty message aborts the commit.\n", message)
+	f.Close()
+
+	cmd := shellCommand(editor + " " + shellQuote(f.Name()))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return "", fmt.Errorf("editor %q failed: %v", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
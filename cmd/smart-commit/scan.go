@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// defaultMaxFileSize is the largest file committed without complaint, 5 MB.
+const defaultMaxFileSize = 5 << 20
+
+// allowMarker on a line tells the scan a match there is not a secret.
+const allowMarker = "smart-commit:allow"
+
+// secretRule recognizes one kind of credential in an added line.
+type secretRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// MinEntropy, when set, requires the first group to look random, in
+	// bits per character, to tell real values from placeholders.
+	MinEntropy float64
+}
+
+// secretRules are the credentials the scan looks for, most specific first.
+var secretRules = []secretRule{
+	{Name: "private key", Pattern: regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`)},
+	{Name: "AWS access key", Pattern: regexp.MustCompile(`\b((AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{Name: "AWS secret key", Pattern: regexp.MustCompile(`(?i)aws.{0,20}(?:secret|key).{0,20}?['"=:]\s*['"]?([0-9a-zA-Z/+]{40})\b`), MinEntropy: 4},
+	{Name: "GitHub token", Pattern: regexp.MustCompile(`\b((gh[pousr]_[A-Za-z0-9]{36,})|(github_pat_[A-Za-z0-9_]{60,}))\b`)},
+	{Name: "Slack token", Pattern: regexp.MustCompile(`\b(xox[baprs]-[A-Za-z0-9-]{10,})\b`)},
+	{Name: "Stripe key", Pattern: regexp.MustCompile(`\b((sk|rk)_live_[0-9a-zA-Z]{24,})\b`)},
+	{Name: "Google API key", Pattern: regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`)},
+	{Name: "OpenAI or Anthropic key", Pattern: regexp.MustCompile(`\b(sk-(ant-|proj-)?[A-Za-z0-9_-]{32,})\b`), MinEntropy: 3.5},
+	{Name: "token", Pattern: regexp.MustCompile(`(?i)(?:api[_-]?key|secret|token|passw(?:or)?d|credentials?)["']?\s*[:=]+\s*["']([^\s"']{16,})["']`), MinEntropy: 3.5},
+}
+
+// entropy is the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var h float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// findSecret returns the name of the rule that matches line and the value
+// it found.
+func findSecret(line string) (rule, value string, ok bool) {
+	if strings.Contains(line, allowMarker) {
+		return "", "", false
+	}
+	for _, r := range secretRules {
+		m := r.Pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value = m[0]
+		if len(m) > 1 && m[1] != "" {
+			value = m[1]
+		}
+		if r.MinEntropy > 0 && entropy(value) < r.MinEntropy {
+			continue
+		}
+		return r.Name, value, true
+	}
+	return "", "", false
+}
+
+// redact keeps enough of a secret to find it again.
+func redact(value string) string {
+	if len(value) <= 8 {
+		return "..."
+	}
+	return value[:4] + "..." + value[len(value)-2:]
+}
+
+// matchesPathPattern reports whether p matches a gitignore-style pattern: a
+// pattern without a slash matches the file name at any depth, a trailing
+// "/" or "/**" matches everything below a directory, and anything else is
+// matched against the whole path.
+func matchesPathPattern(p, pattern string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if dir := strings.TrimSuffix(strings.TrimSuffix(pattern, "**"), "/"); dir != pattern && !strings.ContainsAny(dir, "*?[") {
+		return strings.HasPrefix(p, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(p))
+		return ok
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+// scanIgnored reports whether the scan_ignore setting exempts p.
+func scanIgnored(p string) bool {
+	for _, pattern := range config.Current().ScanIgnore {
+		if matchesPathPattern(p, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxFileSize is the size limit for committed files; 0 means none.
+func maxFileSize() int {
+	if max := config.Current().MaxFileSize; max != nil && *max >= 0 {
+		return *max
+	}
+	return defaultMaxFileSize
+}
+
+// stagedSecrets finds likely credentials in the lines the change set adds.
+func stagedSecrets(changes *git.ChangeSet) []stagedProblem {
+	var problems []stagedProblem
+	for _, f := range changes.Files {
+		if f.Status == "D" || scanIgnored(f.Path) {
+			continue
+		}
+		for _, h := range f.Hunks {
+			line := h.NewStart
+			for _, l := range h.Lines {
+				if l == "" || l[0] == '-' || l[0] == '\\' {
+					continue
+				}
+				if l[0] == '+' {
+					if rule, value, ok := findSecret(l[1:]); ok {
+						problems = append(problems, stagedProblem{Path: f.Path, Line: line, Message: fmt.Sprintf("possible %s %q", rule, redact(value))})
+					}
+				}
+				line++
+			}
+		}
+	}
+	return problems
+}
+
+// stagedSizes returns the size of the staged version of each path, read
+// from the index in one git call.
+func stagedSizes(paths []string) (map[string]int, error) {
+	var in strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&in, ":%s\n", p)
+	}
+	cmd := exec.CommandContext(interrupted, "git", "cat-file", "--batch-check=%(objectsize)")
+	cmd.Stdin = strings.NewReader(in.String())
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return nil, fmt.Errorf("reading staged file sizes: %v", err)
+	}
+	sizes := map[string]int{}
+	for i, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if i < len(paths) {
+			if n, err := strconv.Atoi(line); err == nil {
+				sizes[paths[i]] = n
+			}
+		}
+	}
+	return sizes, nil
+}
+
+// largeFiles finds staged files over the size limit.
+func largeFiles(changes *git.ChangeSet) ([]stagedProblem, error) {
+	limit := maxFileSize()
+	if limit == 0 {
+		return nil, nil
+	}
+	var paths []string
+	for _, f := range changes.Files {
+		if f.Status != "D" && !scanIgnored(f.Path) {
+			paths = append(paths, f.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	sizes, err := stagedSizes(paths)
+	if err != nil {
+		return nil, err
+	}
+	var problems []stagedProblem
+	for _, p := range paths {
+		if size := sizes[p]; size > limit {
+			problems = append(problems, stagedProblem{Path: p, Message: fmt.Sprintf("%s, over the %s limit", formatSize(size), formatSize(limit))})
+		}
+	}
+	return problems, nil
+}
+
+// formatSize renders a byte count for people.
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// scanStaged runs the safety pass over the staged changes and fails with a
+// report of likely secrets and oversized files. allowSecrets skips the
+// secret scan.
+func scanStaged(changes *git.ChangeSet, allowSecrets bool) error {
+	var secrets []stagedProblem
+	if !allowSecrets {
+		secrets = stagedSecrets(changes)
+	}
+	large, err := largeFiles(changes)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 && len(large) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("the staged changes did not pass the safety scan:\n")
+	if len(secrets) > 0 {
+		b.WriteString("Likely secrets:\n")
+		for _, p := range secrets {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	if len(large) > 0 {
+		b.WriteString("Large files:\n")
+		for _, p := range large {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	b.WriteString("unstage them with git restore --staged <file>")
+	if len(secrets) > 0 {
+		fmt.Fprintf(&b, ", mark false positives with a %q comment or pass --allow-secrets", allowMarker)
+	}
+	b.WriteString(", or exempt paths with the scan_ignore setting")
+	return fmt.Errorf("%s", b.String())
+}
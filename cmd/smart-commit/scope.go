@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// scopeHistory is how many commits are mined for the scopes a repository
+// uses.
+const scopeHistory = 500
+
+// maxScopeChoices is how many scopes the picker offers.
+const maxScopeChoices = 8
+
+// scopeCandidate is a scope used before in the repository, ranked by how
+// often commits with that scope touched the paths being changed.
+type scopeCandidate struct {
+	Scope string
+	// Score weighs past commits with this scope by how closely the paths
+	// they touched match the changed paths.
+	Score int
+	// Uses is how many mined commits used the scope.
+	Uses int
+}
+
+// rankScopes returns the scope vocabulary, most relevant to changes first:
+// the scopes the commit policy allows when it lists them, else the scopes
+// used in recent history.
+func rankScopes(changes *git.ChangeSet) ([]scopeCandidate, error) {
+	history, err := scopeHistoryCommits()
+	if err != nil {
+		return nil, err
+	}
+
+	// How often each scope touched each directory
+	dirUses := map[string]map[string]int{}
+	uses := map[string]int{}
+	for _, c := range history {
+		_, scope, _, _, ok := conventional.ParseSubject(c.Subject)
+		if !ok || scope == "" {
+			continue
+		}
+		uses[scope]++
+		if dirUses[scope] == nil {
+			dirUses[scope] = map[string]int{}
+		}
+		for _, dir := range c.Dirs {
+			dirUses[scope][dir]++
+		}
+	}
+
+	// Allowed scopes are offered even when history has not used them yet
+	allowed := currentPolicy().Scopes
+	for _, scope := range allowed {
+		if _, ok := uses[scope]; !ok {
+			uses[scope] = 0
+		}
+	}
+	var ranked []scopeCandidate
+	for scope, n := range uses {
+		if len(allowed) > 0 && !contains(allowed, scope) {
+			continue
+		}
+		c := scopeCandidate{Scope: scope, Uses: n}
+		// Deeper shared directories say more than a shared top level
+		for _, f := range changes.Files {
+			for depth, dir := range parentDirs(f.Path) {
+				c.Score += dirUses[scope][dir] * (depth + 1)
+			}
+		}
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].Uses != ranked[j].Uses {
+			return ranked[i].Uses > ranked[j].Uses
+		}
+		return ranked[i].Scope < ranked[j].Scope
+	})
+	return ranked, nil
+}
+
+// scopeHistoryCommits returns the latest scopeHistory commits, from the
+// repository index when it can be read.
+func scopeHistoryCommits() ([]indexedCommit, error) {
+	if idx := currentIndex(); idx != nil {
+		return idx.History, nil
+	}
+	return indexHistory(repoIndex{}, "HEAD")
+}
+
+// parentDirs returns the directories containing file, outermost first.
+func parentDirs(file string) []string {
+	dir := path.Dir(file)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	var dirs []string
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+	}
+	return dirs
+}
+
+// scopeIsAmbiguous reports whether the scope of a generated message should
+// be confirmed: the model made it up, or another scope from history fits
+// the changed paths clearly better.
+func scopeIsAmbiguous(scope string, ranked []scopeCandidate) bool {
+	if scope == "" || len(ranked) == 0 {
+		return false
+	}
+	for _, c := range ranked {
+		if c.Scope == scope {
+			return c.Score*2 < ranked[0].Score
+		}
+	}
+	return true
+}
+
+// resolveScope keeps the message's scope within the vocabulary. A scope
+// outside it is first sent back to the model along with the vocabulary;
+// when the scope is still unknown or another one fits the changed paths
+// clearly better, the user picks one interactively. Unattended, an unknown
+// scope is replaced by the most relevant known one, or dropped.
+func resolveScope(message string, changes *git.ChangeSet, interactive bool) (string, error) {
+	_, scope, _, _, ok := conventional.ParseSubject(strings.SplitN(message, "\n", 2)[0])
+	if !ok || scope == "" || scope == pinnedScope || scope == inferScope(changes) {
+		return message, nil
+	}
+	// Without history (a new repository) there is nothing to pick from
+	ranked, err := rankScopes(changes)
+	if err != nil || len(ranked) == 0 {
+		return message, nil
+	}
+	enforce := !config.Bool(config.Current().AllowNewScopes, false)
+
+	if enforce && !isKnownScope(scope, ranked) {
+		var names []string
+		for _, c := range ranked {
+			names = append(names, c.Scope)
+		}
+		fmt.Printf("Scope %q is not in use here; regenerating with the known scopes...\n", scope)
+		extra := fmt.Sprintf("Use one of these scopes, or no scope: %s. Do not use %q.", strings.Join(names, ", "), scope)
+		if regenerated, err := suggestCommitMessage(changes, extra); err == nil {
+			message = regenerated
+			_, scope, _, _, _ = conventional.ParseSubject(strings.SplitN(message, "\n", 2)[0])
+		}
+	}
+	if !scopeIsAmbiguous(scope, ranked) {
+		return message, nil
+	}
+
+	chosen := scope
+	switch {
+	case interactive:
+		if chosen, err = pickScope(scope, ranked); err != nil {
+			return "", err
+		}
+	case isKnownScope(scope, ranked):
+	case ranked[0].Score > 0:
+		chosen = ranked[0].Scope
+	case enforce:
+		chosen = ""
+	}
+	return conventional.WithScope(message, chosen), nil
+}
+
+// isKnownScope reports whether scope is among the ranked scopes.
+func isKnownScope(scope string, ranked []scopeCandidate) bool {
+	for _, c := range ranked {
+		if c.Scope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// pickScope asks the user to choose a scope from the most relevant ones. An
+// empty answer keeps the generated scope; 0 drops the scope.
+func pickScope(scope string, ranked []scopeCandidate) (string, error) {
+	if isKnownScope(scope, ranked) {
+		fmt.Printf("\nOther scopes fit these paths better than %q:\n", scope)
+	} else {
+		fmt.Printf("\nThe scope %q has not been used in this repository before. Scopes used before, most relevant first:\n", scope)
+	}
+	choices := ranked[:min(len(ranked), maxScopeChoices)]
+	for i, c := range choices {
+		fmt.Printf("  %d) %-16s %d commit(s)\n", i+1, c.Scope, c.Uses)
+	}
+	fmt.Println("  0) no scope")
+	for {
+		answer, err := promptLine(fmt.Sprintf("Scope [Enter keeps %q]: ", scope))
+		if err != nil {
+			return "", errReviewAborted
+		}
+		if answer == "" {
+			return scope, nil
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 0 || n > len(choices) {
+			fmt.Printf("Enter a number from 0 to %d.\n", len(choices))
+			continue
+		}
+		if n == 0 {
+			return "", nil
+		}
+		return choices[n-1].Scope, nil
+	}
+}
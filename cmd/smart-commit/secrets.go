@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted values look like ENC[v1,<base64>], in the spirit of sops, so they
+// can sit in shell profiles, CI variables and dotfiles next to plain values.
+const (
+	encryptedPrefix = "ENC[v1,"
+	encryptedSuffix = "]"
+)
+
+// secretKeyPath is where the local encryption key is kept.
+func secretKeyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smart-commit", "secret.key"), nil
+}
+
+// loadSecretKey returns the 256-bit key used for encrypted values, from
+// SMART_COMMIT_SECRET_KEY (hex) or the key file. With create set, a missing
+// key file is generated.
+func loadSecretKey(create bool) ([]byte, error) {
+	if env := os.Getenv("SMART_COMMIT_SECRET_KEY"); env != "" {
+		return decodeSecretKey(env)
+	}
+
+	path, err := secretKeyPath()
+	if err != nil {
+		return nil, fmt.Errorf("locating key file: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return decodeSecretKey(string(data))
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("reading key file: %v", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("writing key file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Generated encryption key at %s; keep it safe, encrypted values cannot be recovered without it.\n", path)
+	return key, nil
+}
+
+func decodeSecretKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 64 hex characters")
+	}
+	return key, nil
+}
+
+// isEncrypted reports whether value is an encrypted value.
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// encryptSecret seals plaintext with AES-256-GCM under the local key.
+func encryptSecret(plaintext string) (string, error) {
+	key, err := loadSecretKey(true)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed) + encryptedSuffix, nil
+}
+
+// decryptSecret opens a value produced by encryptSecret.
+func decryptSecret(value string) (string, error) {
+	if !isEncrypted(value) {
+		return "", fmt.Errorf("value is not encrypted (expected %s...%s)", encryptedPrefix, encryptedSuffix)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(encryptedPrefix) : len(value)-len(encryptedSuffix)])
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted value: %v", err)
+	}
+	key, err := loadSecretKey(false)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is truncated")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: wrong key or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretEnv reads a credential from the environment, decrypting it when it
+// holds an encrypted value. A value that cannot be decrypted is reported and
+// treated as unset.
+func secretEnv(name string) string {
+	value := os.Getenv(name)
+	if !isEncrypted(value) {
+		return value
+	}
+	plaintext, err := decryptSecret(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", name, err)
+		return ""
+	}
+	return plaintext
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// sensitiveFiles are files holding credentials that are never staged or
+// committed without --allow-sensitive, as patterns for matchesPathPattern.
+var sensitiveFiles = []string{
+	".env", ".env.*", "*.env",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+	"*.pfx", "*.p12", "*.jks", "*.keystore",
+	".aws/credentials",
+	"application_default_credentials.json", "service-account*.json",
+	".azure/accessTokens.json", ".azure/azureProfile.json",
+	".docker/config.json", ".kube/config", "kubeconfig",
+	".netrc", ".pgpass", ".git-credentials", ".pypirc",
+	"terraform.tfstate", "terraform.tfstate.backup",
+}
+
+// sensitiveExceptions look like sensitive files but are safe to share.
+var sensitiveExceptions = []string{
+	".env.example", ".env.sample", ".env.template", ".env.dist", "*.pub",
+}
+
+// isSensitive reports whether p is a credential file: one of the built-in
+// sensitive files or the sensitive_files setting.
+func isSensitive(p string) bool {
+	for _, pattern := range sensitiveExceptions {
+		if matchesPathPattern(p, pattern) {
+			return false
+		}
+	}
+	for _, pattern := range append(sensitiveFiles, config.Current().SensitiveFiles...) {
+		if matchesPathPattern(p, pattern) || (strings.Contains(pattern, "/") && strings.HasSuffix(p, "/"+pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdBackSensitive reports the sensitive files among paths and returns
+// them, so staging can leave them out.
+func holdBackSensitive(paths []string) []string {
+	var held []string
+	for _, path := range paths {
+		if isSensitive(path) {
+			held = append(held, path)
+		}
+	}
+	if len(held) > 0 {
+		fmt.Println("Not staging sensitive files (pass --allow-sensitive to include them):")
+		for _, path := range held {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	return held
+}
+
+// checkSensitiveFiles fails with the list of sensitive files in the staged
+// changes, such as ones staged by hand before running smart-commit.
+func checkSensitiveFiles(changes *git.ChangeSet) error {
+	var problems []stagedProblem
+	for _, f := range changes.Files {
+		if f.Status != "D" && isSensitive(f.Path) {
+			problems = append(problems, stagedProblem{Path: f.Path, Message: "may contain credentials"})
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("the staged changes contain sensitive files:\n")
+	for _, p := range problems {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+	b.WriteString("unstage them with git restore --staged <file>, or pass --allow-sensitive to commit them")
+	return fmt.Errorf("%s", b.String())
+}
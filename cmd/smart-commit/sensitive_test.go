@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestIsSensitive(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".env", true},
+		{"services/api/.env.production", true},
+		{".env.example", false},
+		{"deploy/prod.env", true},
+		{"id_rsa", true},
+		{"keys/id_ed25519", true},
+		{"keys/id_ed25519.pub", false},
+		{"certs/client.pfx", true},
+		{".aws/credentials", true},
+		{"home/.aws/credentials", true},
+		{"credentials.go", false},
+		{"environment.go", false},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := isSensitive(tt.path); got != tt.want {
+			t.Errorf("isSensitive(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
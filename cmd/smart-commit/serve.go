@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chalfel/smart-commit/client"
+	"github.com/chalfel/smart-commit/git"
+	"github.com/chalfel/smart-commit/protocol"
+	"github.com/chalfel/smart-commit/server"
+)
+
+// version is the smart-commit release, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// runServe implements `smart-commit serve`, the long-running mode editor
+// plugins and other tools integrate with.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	stdio := fs.Bool("stdio", false, "speak the JSON-RPC protocol over stdin/stdout")
+	grpcAddr := fs.String("grpc", "", "serve the gRPC API on this address (e.g. :50051, on the loopback interface unless --tenants is given)")
+	webhookAddr := fs.String("webhook", "", "receive GitHub webhooks on this address (e.g. :8080) and comment on pushes and pull requests")
+	webhookSecret := fs.String("webhook-secret", secretEnv("SMART_COMMIT_WEBHOOK_SECRET"), "secret used to verify webhook signatures")
+	tenantsFile := fs.String("tenants", os.Getenv("SMART_COMMIT_TENANTS"), "file of team tokens, providers and rate limits; --grpc and --webhook then require a tenant token")
+	metricsAddr := fs.String("metrics", "", "serve Prometheus metrics at /metrics on this address (e.g. :9090)")
+	fs.Parse(args)
+
+	transports := 0
+	for _, set := range []bool{*stdio, *grpcAddr != "", *webhookAddr != ""} {
+		if set {
+			transports++
+		}
+	}
+	if transports > 1 {
+		return fmt.Errorf("choose one transport: --stdio, --grpc or --webhook")
+	}
+
+	var tenants []*tenant
+	if *tenantsFile != "" {
+		if *stdio {
+			return fmt.Errorf("--tenants applies to --grpc and --webhook; --stdio serves one user")
+		}
+		var err error
+		if tenants, err = loadTenants(*tenantsFile); err != nil {
+			return err
+		}
+	}
+
+	// Configuration changes apply without a restart
+	if transports == 1 {
+		watchConfig()
+	}
+	if transports == 1 && *metricsAddr != "" {
+		serveMetrics(*metricsAddr)
+	}
+
+	switch {
+	case *stdio:
+		// Messages printed along the way must not corrupt the protocol
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		return newStdioServer().Serve(os.Stdin, out)
+	case *grpcAddr != "":
+		return serveGRPC(*grpcAddr, tenants)
+	case *webhookAddr != "":
+		return serveWebhook(*webhookAddr, *webhookSecret, tenants)
+	}
+	return fmt.Errorf("serve needs a transport; use --stdio, --grpc ADDR or --webhook ADDR")
+}
+
+// errNothingStaged is the suggest error for a repository with nothing staged.
+var errNothingStaged = errors.New("no staged changes")
+
+// newStdioServer returns the protocol server editor plugins talk to:
+// suggesting a message for what is staged and comparing branches.
+// Requests naming a repository are answered by a process started in it.
+func newStdioServer() *server.Server {
+	s := server.New(version)
+	s.Observe = func(method string, code int, took time.Duration) {
+		outcome := "ok"
+		if code != 0 {
+			outcome = strconv.Itoa(code)
+		}
+		// Methods nobody implements would grow the metrics without end
+		if code == protocol.ErrMethodNotFound {
+			method = "unknown"
+		}
+		metrics.observeRequest("stdio", method, outcome, took)
+	}
+	s.Handle(protocol.MethodSuggest, protocol.CapabilitySuggest, func(raw json.RawMessage) (interface{}, error) {
+		var params protocol.SuggestParams
+		if err := server.Params(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Dir != "" {
+			c, err := inRepository(params.Dir, nil)
+			if err != nil {
+				return nil, err
+			}
+			defer c.Close()
+			return c.Suggest("")
+		}
+		changes, err := git.LoadChangeSet("--cached")
+		if err != nil {
+			return nil, err
+		}
+		if len(changes.Files) == 0 {
+			return nil, errNothingStaged
+		}
+		message, genErr := suggestCommitMessage(changes, "")
+		if genErr != nil {
+			fmt.Fprintf(os.Stderr, "%s error: %v\n", currentGenerator().Name(), genErr)
+		}
+		return protocol.SuggestResult{Message: message, Fallback: genErr != nil}, nil
+	})
+	s.Handle(protocol.MethodCompare, protocol.CapabilityCompare, func(raw json.RawMessage) (interface{}, error) {
+		var params protocol.CompareParams
+		if err := server.Params(raw, &params); err != nil {
+			return nil, err
+		}
+		if params.Base == "" || params.Head == "" {
+			return nil, &protocol.Error{Code: protocol.ErrInvalidParams, Message: "base and head are required"}
+		}
+		for _, ref := range []string{params.Base, params.Head} {
+			if _, err := (git.Repo{Dir: params.Dir}).ResolveCommit(ref); err != nil {
+				return nil, &protocol.Error{Code: protocol.ErrInvalidParams, Message: err.Error()}
+			}
+		}
+		if params.Dir != "" {
+			c, err := inRepository(params.Dir, nil)
+			if err != nil {
+				return nil, err
+			}
+			defer c.Close()
+			markdown, err := c.Compare("", params.Base, params.Head)
+			return protocol.CompareResult{Markdown: markdown}, err
+		}
+		r, err := buildComparison(params.Base, params.Head, checkProvider() == nil)
+		return protocol.CompareResult{Markdown: r.markdown()}, err
+	})
+	return s
+}
+
+// inRepository starts a `smart-commit serve --stdio` process of its own in
+// dir with env (the server's when nil) to answer a request, so that
+// concurrent requests about different repositories or for different tenants
+// share no working directory, configuration or credentials.
+func inRepository(dir string, env []string) (*client.Client, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return client.Start(client.Options{Binary: exe, Dir: dir, Env: env, Stderr: os.Stderr, ClientName: "smart-commit serve"})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/protocol"
+)
+
+func TestStdioCompareRevisions(t *testing.T) {
+	repo, _ := testRepo(t)
+	written := filepath.Join(t.TempDir(), "written")
+	tests := []struct {
+		name       string
+		base, head string
+	}{
+		{"option as base", "--output=" + written, "HEAD"},
+		{"option as head", "HEAD", "--output=" + written},
+		{"unknown head", "HEAD", "nonesuch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := json.Marshal(protocol.CompareParams{Dir: repo, Base: tt.base, Head: tt.head})
+			in := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersions":[1],"capabilities":["compare"]}}` + "\n" +
+				`{"jsonrpc":"2.0","id":2,"method":"compare","params":` + string(params) + `}` + "\n"
+			var out bytes.Buffer
+			if err := newStdioServer().Serve(strings.NewReader(in), &out); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			var resp protocol.Response
+			json.Unmarshal([]byte(lines[len(lines)-1]), &resp)
+			if resp.Error == nil || resp.Error.Code != protocol.ErrInvalidParams {
+				t.Errorf("compare %s...%s = %s, want invalid params", tt.base, tt.head, lines[len(lines)-1])
+			}
+		})
+	}
+	if _, err := os.Stat(written); err == nil {
+		t.Errorf("a ref wrote %s", written)
+	}
+}
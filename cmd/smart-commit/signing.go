@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// commitSigning is how the commits of a run are signed off and signed.
+type commitSigning struct {
+	Signoff bool
+	// Sign passes --gpg-sign, with Key when set. git signs with GPG, SSH
+	// or X.509 depending on gpg.format.
+	Sign bool
+	Key  string
+}
+
+// signing is set by main from --signoff and --gpg-sign; nil means the
+// signoff, gpg_sign and signing_key settings.
+var signing *commitSigning
+
+// currentSigning returns the signing options of this run.
+func currentSigning() commitSigning {
+	if signing != nil {
+		return *signing
+	}
+	cfg := config.Current()
+	return commitSigning{
+		Signoff: config.Bool(cfg.Signoff, false),
+		Sign:    config.Bool(cfg.GPGSign, false) || cfg.SigningKey != "",
+		Key:     cfg.SigningKey,
+	}
+}
+
+// args are the git commit options for s.
+func (s commitSigning) args() []string {
+	var args []string
+	if s.Signoff {
+		args = append(args, "--signoff")
+	}
+	if s.Sign && s.Key != "" {
+		args = append(args, "--gpg-sign="+s.Key)
+	} else if s.Sign {
+		args = append(args, "--gpg-sign")
+	}
+	return args
+}
+
+// gitCommit runs git commit with args and the run's signing options. git
+// signs on its own when commit.gpgsign is set; nothing here turns that off.
+func gitCommit(args ...string) error {
+	return executeCommand("git", append(append([]string{"commit"}, currentSigning().args()...), args...)...)
+}
+
+// checkSigning finds out before anything is generated whether commits that
+// will be signed, because s asks for it or the repository's commit.gpgsign
+// requires it, can be: that the signing program is installed and, for SSH,
+// that there is a key. It reports whether commits will be signed.
+func checkSigning(s commitSigning) (bool, error) {
+	out, err := executeCommandWithOutput("git", "config", "--type=bool", "commit.gpgsign")
+	required := err == nil && strings.TrimSpace(out) == "true"
+	if !s.Sign && !required {
+		return false, nil
+	}
+	why := "--gpg-sign"
+	if !s.Sign {
+		why = "commit.gpgsign"
+	}
+
+	format := gitConfigValue("gpg.format")
+	if format == "" {
+		format = "openpgp"
+	}
+	program := gitConfigValue("gpg." + format + ".program")
+	if format == "openpgp" && program == "" {
+		program = gitConfigValue("gpg.program")
+	}
+	if program == "" {
+		program = map[string]string{"openpgp": "gpg", "x509": "gpgsm", "ssh": "ssh-keygen"}[format]
+	}
+	if program == "" {
+		return true, fmt.Errorf("commits are signed (%s), but gpg.format %q is unknown", why, format)
+	}
+	if _, err := exec.LookPath(program); err != nil {
+		return true, fmt.Errorf("commits are signed (%s) with %s, which is not installed; install it or set gpg.%s.program", why, program, format)
+	}
+	if format == "ssh" && s.Key == "" && gitConfigValue("user.signingkey") == "" && gitConfigValue("gpg.ssh.defaultKeyCommand") == "" {
+		return true, fmt.Errorf("commits are signed (%s) with SSH, but no key is configured; set user.signingkey or signing_key", why)
+	}
+	return true, nil
+}
+
+// gitConfigValue returns a git configuration value, or "" when unset.
+func gitConfigValue(key string) string {
+	out, err := executeCommandWithOutput("git", "config", key)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// commitGroup is a set of staged files that go into one commit of a split.
+type commitGroup struct {
+	Name  string
+	Files []git.FileChange
+}
+
+// splitOptions carries the commit flow settings a split applies to every
+// commit.
+type splitOptions struct {
+	By          string
+	Interactive bool
+	Trailers    []conventional.Trailer
+	// LastBody is added to the last commit only, e.g. the test summary for
+	// the fully staged state the checks ran on.
+	LastBody string
+	// Rule is the current branch's rule, if any.
+	Rule *config.BranchRule
+}
+
+// commitSplit groups the staged changes, lets the user confirm the grouping
+// and creates one commit per group, oldest first. If it stops early the
+// remaining changes are left staged.
+func commitSplit(changes *git.ChangeSet, opts splitOptions) error {
+	var groups []commitGroup
+	if opts.By == "ai" {
+		var err error
+		groups, err = groupWithModel(changes)
+		if err != nil {
+			fmt.Printf("%s error: %v; grouping by directory\n", currentGenerator().Name(), err)
+			groups = groupByDirectory(changes.Files)
+		}
+	} else {
+		groups = groupByDirectory(changes.Files)
+	}
+
+	if opts.Interactive {
+		var err error
+		groups, err = confirmGroups(groups)
+		if err != nil {
+			return err
+		}
+	} else {
+		printGroups(groups)
+	}
+	if len(groups) == 1 {
+		fmt.Println("Nothing to split; creating a single commit.")
+	}
+
+	// Commit the groups one at a time from the fully staged tree
+	staged, err := executeCommandWithOutput("git", "write-tree")
+	if err != nil {
+		return fmt.Errorf("saving the staged changes: %v", err)
+	}
+	staged = strings.TrimSpace(staged)
+	restore := func() {
+		executeCommand("git", "read-tree", staged)
+	}
+
+	for i, g := range groups {
+		if err := executeCommand("git", "reset", "-q"); err != nil {
+			restore()
+			return fmt.Errorf("preparing commit %d: %v", i+1, err)
+		}
+		restoreArgs := append([]string{"restore", "--staged", "--source=" + staged, "--"}, g.pathspecs()...)
+		if err := executeCommand("git", restoreArgs...); err != nil {
+			restore()
+			return fmt.Errorf("staging group %q: %v", g.Name, err)
+		}
+		groupChanges, err := git.LoadChangeSet("--cached")
+		if err != nil {
+			restore()
+			return err
+		}
+
+		fmt.Printf("\n[%d/%d] Generating commit message for %s with %s...\n", i+1, len(groups), g.Name, currentGenerator().Name())
+		message, err := suggestCommitMessage(groupChanges, "")
+		if err != nil {
+			fmt.Printf("%s error: %v\n", currentGenerator().Name(), err)
+		}
+		if message, err = resolveScope(message, groupChanges, opts.Interactive); err != nil {
+			restore()
+			return err
+		}
+		if message, err = addTrailers(message, opts.Trailers); err != nil {
+			restore()
+			return err
+		}
+		if opts.Interactive {
+			if message, err = reviewMessage(message, groupChanges); err != nil {
+				restore()
+				return err
+			}
+		}
+		if err := checkTicketRequired(opts.Rule, message); err != nil {
+			restore()
+			return err
+		}
+		if err := verifyTicketReferences(message); err != nil {
+			restore()
+			return err
+		}
+		if i == len(groups)-1 && opts.LastBody != "" {
+			if message, err = appendBody(message, opts.LastBody); err != nil {
+				restore()
+				return err
+			}
+		}
+
+		fmt.Printf("Committing with message: %s\n", message)
+		if err := gitCommit("-m", message); err != nil {
+			restore()
+			return fmt.Errorf("committing group %q: %v", g.Name, err)
+		}
+		rememberMessage(message, groupChanges)
+	}
+	return nil
+}
+
+// pathspecs returns literal pathspecs for every path of the group, including
+// the source of renames so the deletion lands in the same commit.
+func (g commitGroup) pathspecs() []string {
+	var specs []string
+	for _, f := range g.Files {
+		specs = append(specs, ":(top,literal)"+f.Path)
+		if f.Renamed() {
+			specs = append(specs, ":(top,literal)"+f.OldPath)
+		}
+	}
+	return specs
+}
+
+// groupByDirectory puts documentation, CI and build changes in a group each
+// and the other files in one group per directory, with tests joining the
+// directory they test.
+func groupByDirectory(files []git.FileChange) []commitGroup {
+	index := map[string]int{}
+	var groups []commitGroup
+	for _, f := range files {
+		name := ""
+		switch f.Category {
+		case git.CategoryDocs:
+			name = "documentation"
+		case git.CategoryCI:
+			name = "CI"
+		case git.CategoryBuild, git.CategoryLockfile:
+			name = "build and dependencies"
+		default:
+			name = path.Dir(f.Path) + "/"
+			if name == "./" {
+				name = "repository root"
+			}
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, commitGroup{Name: name})
+		}
+		groups[i].Files = append(groups[i].Files, f)
+	}
+	return groups
+}
+
+// groupNumbers matches the file numbers in the model's grouping answer.
+var groupNumbers = regexp.MustCompile(`\d+`)
+
+// groupWithModel asks the AI provider to group the files into logical
+// commits. Files the answer leaves out are grouped by directory.
+func groupWithModel(changes *git.ChangeSet) ([]commitGroup, error) {
+	var list strings.Builder
+	for i, f := range changes.Files {
+		fmt.Fprintf(&list, "%d. %s (%s, +%d/-%d)\n", i+1, f.ChangedFile, f.Category, f.Added, f.Deleted)
+	}
+	answer, err := askModel(generator.RenderPrompt(generator.PromptSplitGroups, map[string]string{
+		"Files": list.String(),
+		"Diff":  generator.DiffContext(changes, maxDiffBudget()),
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := map[int]bool{}
+	var groups []commitGroup
+	for _, line := range strings.Split(answer, "\n") {
+		var g commitGroup
+		for _, n := range groupNumbers.FindAllString(line, -1) {
+			i, _ := strconv.Atoi(n)
+			if i < 1 || i > len(changes.Files) || assigned[i-1] {
+				continue
+			}
+			assigned[i-1] = true
+			g.Files = append(g.Files, changes.Files[i-1])
+		}
+		if len(g.Files) > 0 {
+			g.Name = fmt.Sprintf("group %d", len(groups)+1)
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("could not read a grouping from the answer")
+	}
+
+	var rest []git.FileChange
+	for i, f := range changes.Files {
+		if !assigned[i] {
+			rest = append(rest, f)
+		}
+	}
+	return append(groups, groupByDirectory(rest)...), nil
+}
+
+// printGroups lists the planned commits.
+func printGroups(groups []commitGroup) {
+	fmt.Printf("\nSplitting into %d commit(s):\n", len(groups))
+	for i, g := range groups {
+		fmt.Printf("  %d) %s\n", i+1, g.Name)
+		for _, f := range g.Files {
+			fmt.Printf("       %s\n", f.ChangedFile)
+		}
+	}
+	fmt.Println()
+}
+
+// confirmGroups shows the grouping and lets the user accept it, merge groups
+// or abort.
+func confirmGroups(groups []commitGroup) ([]commitGroup, error) {
+	for {
+		printGroups(groups)
+		answer, err := promptLine("[a]ccept, [m]erge groups (e.g. m 1 3), a[b]ort? ")
+		if err != nil {
+			return nil, errReviewAborted
+		}
+		fields := strings.Fields(strings.ToLower(answer))
+		if len(fields) == 0 {
+			return groups, nil
+		}
+		switch fields[0] {
+		case "a", "accept", "y", "yes":
+			return groups, nil
+		case "b", "abort", "q", "quit", "n", "no":
+			return nil, errReviewAborted
+		case "m", "merge":
+			chosen, err := parseSelection(strings.Join(fields[1:], " "), len(groups))
+			if err != nil || len(chosen) < 2 {
+				fmt.Println("Name at least two groups to merge, e.g. m 1 3.")
+				continue
+			}
+			groups = mergeGroups(groups, chosen)
+		default:
+			fmt.Println("Please answer a, m or b.")
+		}
+	}
+}
+
+// mergeGroups merges the chosen groups into the first of them.
+func mergeGroups(groups []commitGroup, chosen []int) []commitGroup {
+	sort.Ints(chosen)
+	target := chosen[0]
+	merged := map[int]bool{}
+	var names []string
+	for _, i := range chosen {
+		merged[i] = true
+		names = append(names, groups[i].Name)
+		if i != target {
+			groups[target].Files = append(groups[target].Files, groups[i].Files...)
+		}
+	}
+	groups[target].Name = strings.Join(names, " + ")
+
+	var result []commitGroup
+	for i, g := range groups {
+		if i == target || !merged[i] {
+			result = append(result, g)
+		}
+	}
+	return result
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// describeSQLChanges lists the SQL statements the changes add or edit, by
+// kind, for the prompt.
+func describeSQLChanges(changes *git.ChangeSet) string {
+	statements := generator.SQLChanges(changes)
+	if len(statements) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("SQL changes (schema changes are usually feat or fix with scope db, data changes chore):\n")
+	for _, c := range statements {
+		fmt.Fprintf(&b, "- %s in %s: %s\n", c.Kind, c.Path, c.Description)
+	}
+	return b.String()
+}
+
+// warnDestructiveSQL warns about DROP and TRUNCATE statements in the staged
+// SQL, which lose data when they run.
+func warnDestructiveSQL(changes *git.ChangeSet) {
+	var b strings.Builder
+	for _, c := range generator.SQLChanges(changes) {
+		if c.Destructive {
+			fmt.Fprintf(&b, "  %s:%d: %s\n", c.Path, c.Line, c.Statement)
+		}
+	}
+	if b.Len() > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: the staged SQL drops or truncates data:\n%s", b.String())
+	}
+}
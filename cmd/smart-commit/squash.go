@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// runSquash implements `smart-commit squash`: squash the current branch's
+// commits since it forked from the base branch into one commit with a
+// generated message, keeping the trailers of the original commits.
+func runSquash(args []string) error {
+	fs := flag.NewFlagSet("squash", flag.ExitOnError)
+	base := fs.String("base", "", "branch to squash onto (default: the repository's default branch)")
+	yes := fs.Bool("yes", false, "commit the generated message without reviewing it")
+	fs.Parse(args)
+
+	if *base == "" {
+		b, err := git.DefaultBranch()
+		if err != nil {
+			return err
+		}
+		*base = b
+	}
+	if err := checkProvider(); err != nil {
+		return err
+	}
+	lock, err := acquireRepoLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Staged changes would silently end up in the squashed commit
+	if err := executeCommand("git", "diff", "--cached", "--quiet"); err != nil {
+		return fmt.Errorf("there are staged changes; commit or unstage them before squashing")
+	}
+
+	forkPoint, err := executeCommandWithOutput("git", "merge-base", "HEAD", *base)
+	if err != nil {
+		return fmt.Errorf("finding merge base with %s: %v", *base, err)
+	}
+	forkPoint = strings.TrimSpace(forkPoint)
+	head, err := executeCommandWithOutput("git", "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	head = strings.TrimSpace(head)
+
+	commits, err := git.LoadCommits("--reverse", forkPoint+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if len(commits) < 2 {
+		fmt.Printf("%d commit(s) since %s; nothing to squash.\n", len(commits), *base)
+		return nil
+	}
+	changes, err := git.LoadChangeSet(forkPoint, "HEAD")
+	if err != nil {
+		return err
+	}
+
+	// The original subjects give the model the intent behind the diff
+	var history strings.Builder
+	history.WriteString("This squashes these commits:")
+	for _, c := range commits {
+		fmt.Fprintf(&history, "\n- %s", c.Subject)
+	}
+	fmt.Printf("Generating a message for %d commits with %s...\n", len(commits), currentGenerator().Name())
+	message, err := suggestCommitMessage(changes, history.String())
+	if err != nil {
+		fmt.Printf("%s error: %v\n", currentGenerator().Name(), err)
+	}
+	if message, err = addTrailers(message, squashTrailers(commits)); err != nil {
+		return err
+	}
+	if !*yes && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		if message, err = reviewMessage(message, changes); err != nil {
+			return err
+		}
+	}
+
+	if err := executeCommand("git", "reset", "--soft", forkPoint); err != nil {
+		return fmt.Errorf("resetting to %s: %v", git.ShortHash(forkPoint), err)
+	}
+	if err := gitCommit("-m", message); err != nil {
+		// Put the branch back as it was
+		executeCommand("git", "reset", "--soft", head)
+		return fmt.Errorf("committing: %v", err)
+	}
+	fmt.Printf("Squashed %d commits. The previous tip was %s; push with --force-with-lease.\n", len(commits), git.ShortHash(head))
+	return nil
+}
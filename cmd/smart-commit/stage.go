@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// stageAll stages every change under the working directory, like
+// `git add .`. In sparse checkouts only paths inside the sparse cone are
+// staged, and entries marked skip-worktree are never touched, so staging
+// cannot pull excluded paths back into the index. Editor and OS junk is
+// left out, and so are sensitive files unless allowSensitive is set; when
+// interactive, the user is offered to ignore the junk for good, and to
+// ignore untracked build output in a commit of its own.
+func stageAll(allowSensitive, interactive bool) error {
+	if interactive {
+		if err := offerIgnoreRules(); err != nil {
+			return err
+		}
+	}
+	candidates, err := stageablePaths()
+	if err != nil {
+		return err
+	}
+	heldBack, err := holdBackJunk(candidates, interactive)
+	if err != nil {
+		return err
+	}
+	if !allowSensitive {
+		heldBack = append(heldBack, holdBackSensitive(candidates)...)
+	}
+	held := map[string]bool{}
+	for _, path := range heldBack {
+		held[path] = true
+	}
+
+	sparse, _ := executeCommandWithOutput("git", "config", "--bool", "core.sparseCheckout")
+	if strings.TrimSpace(sparse) != "true" {
+		args := []string{"add", "--", "."}
+		for _, path := range heldBack {
+			args = append(args, ":(top,exclude,literal)"+path)
+		}
+		return executeCommand("git", args...)
+	}
+
+	// Modified, deleted and untracked paths, named from the repository root
+	out, err := executeCommandWithOutput("git", "ls-files", "-z", "--full-name", "-m", "-d", "-o", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+	skipped, err := skipWorktreePaths()
+	if err != nil {
+		return err
+	}
+	cone := sparseCone()
+
+	var pathspecs []string
+	seen := map[string]bool{}
+	for _, path := range strings.Split(out, "\x00") {
+		if path == "" || seen[path] || skipped[path] || held[path] || !inSparseCone(cone, path) {
+			continue
+		}
+		seen[path] = true
+		pathspecs = append(pathspecs, ":(top,literal)"+path)
+	}
+	if len(pathspecs) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(interrupted, "git", "add", "-A", "--pathspec-from-file=-", "--pathspec-file-nul")
+	cmd.Stdin = strings.NewReader(strings.Join(pathspecs, "\x00"))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return git.DefaultRunner.Run(cmd)
+}
+
+// stageablePaths lists the modified and untracked files under the working
+// directory that staging everything would add, relative to the repository
+// root. Deletions are left out, as removing a file is never held back.
+func stageablePaths() ([]string, error) {
+	out, err := executeCommandWithOutput("git", "ls-files", "-z", "--full-name", "-m", "-o", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	deletedOut, err := executeCommandWithOutput("git", "ls-files", "-z", "--full-name", "-d")
+	if err != nil {
+		return nil, err
+	}
+	deleted := map[string]bool{}
+	for _, path := range strings.Split(deletedOut, "\x00") {
+		deleted[path] = true
+	}
+
+	var paths []string
+	seen := map[string]bool{}
+	for _, path := range strings.Split(out, "\x00") {
+		if path == "" || seen[path] || deleted[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// skipWorktreePaths returns the index entries with the skip-worktree bit set.
+func skipWorktreePaths() (map[string]bool, error) {
+	out, err := executeCommandWithOutput("git", "ls-files", "-z", "-t", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+	skipped := map[string]bool{}
+	for _, entry := range strings.Split(out, "\x00") {
+		if path, ok := strings.CutPrefix(entry, "S "); ok {
+			skipped[path] = true
+		}
+	}
+	return skipped, nil
+}
+
+// sparseCone returns the directories of a cone-mode sparse checkout, or nil
+// when the checkout is not in cone mode (its patterns are then enforced
+// through skip-worktree bits alone).
+func sparseCone() []string {
+	coneMode, _ := executeCommandWithOutput("git", "config", "--bool", "core.sparseCheckoutCone")
+	if strings.TrimSpace(coneMode) != "true" {
+		return nil
+	}
+	out, err := executeCommandWithOutput("git", "sparse-checkout", "list")
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.Trim(strings.TrimSpace(line), "/"); line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs
+}
+
+// inSparseCone reports whether path is inside the cone: files at the root,
+// under a cone directory, or directly inside one of its parents.
+func inSparseCone(cone []string, path string) bool {
+	if cone == nil {
+		return true
+	}
+	dir := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir = path[:i]
+	}
+	if dir == "" {
+		return true
+	}
+	for _, c := range cone {
+		if dir == c || strings.HasPrefix(dir, c+"/") || strings.HasPrefix(c, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// stagePathspecs stages the changes matching the given pathspecs, including
+// deletions and untracked files.
+func stagePathspecs(pathspecs []string) error {
+	return executeCommand("git", append([]string{"add", "-A", "--"}, pathspecs...)...)
+}
+
+// workingTreeEntry is a file with changes that could be staged.
+type workingTreeEntry struct {
+	// Status is the two-letter porcelain status, e.g. " M" or "??".
+	Status string
+	Path   string
+}
+
+// unstagedEntries lists files with unstaged or untracked changes, with paths
+// relative to the repository root.
+func unstagedEntries() ([]workingTreeEntry, error) {
+	out, err := executeCommandWithOutput("git", "status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	var entries []workingTreeEntry
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 4 {
+			continue
+		}
+		e := workingTreeEntry{Status: field[:2], Path: field[3:]}
+		// Renames and copies are followed by their source path
+		if e.Status[0] == 'R' || e.Status[0] == 'C' {
+			i++
+		}
+		// Only entries with worktree changes have something left to stage
+		if e.Status[1] != ' ' {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// pickAndStage lists the files with unstaged changes and stages the ones the
+// user picks by number.
+func pickAndStage() error {
+	entries, err := unstagedEntries()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No unstaged changes to pick from.")
+		return nil
+	}
+
+	fmt.Println("Changed files:")
+	for i, e := range entries {
+		fmt.Printf("  %2d) %s %s\n", i+1, e.Status, e.Path)
+	}
+	answer, err := promptLine("Files to stage (e.g. 1 3-5, a for all, empty for none): ")
+	if err != nil {
+		return err
+	}
+	chosen, err := parseSelection(answer, len(entries))
+	if err != nil {
+		return err
+	}
+	if len(chosen) == 0 {
+		return nil
+	}
+
+	var pathspecs []string
+	for _, i := range chosen {
+		pathspecs = append(pathspecs, ":(top,literal)"+entries[i].Path)
+	}
+	return stagePathspecs(pathspecs)
+}
+
+// parseSelection parses a list of 1-based numbers and ranges ("1 3-5,7"),
+// or "a" for everything, into 0-based indexes.
+func parseSelection(answer string, n int) ([]int, error) {
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	var chosen []int
+	if answer == "a" || answer == "all" {
+		for i := 0; i < n; i++ {
+			chosen = append(chosen, i)
+		}
+		return chosen, nil
+	}
+
+	seen := map[int]bool{}
+	for _, item := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+		from, to, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", item)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid selection %q", item)
+			}
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("selection %q is out of range 1-%d", item, n)
+		}
+		for i := start; i <= end; i++ {
+			if !seen[i] {
+				seen[i] = true
+				chosen = append(chosen, i-1)
+			}
+		}
+	}
+	return chosen, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes for the states in which there is nothing to do or the
+// repository is not ready, so scripts can tell them from failures (1).
+const (
+	exitNothingToCommit = 3
+	exitNotARepository  = 4
+	exitDetachedHead    = 5
+	exitNoUpstream      = 6
+)
+
+// stateError is a repository state that stops the run before anything is
+// changed, with the exit code reporting it.
+type stateError struct {
+	Code    int
+	Message string
+}
+
+func (e *stateError) Error() string { return e.Message }
+
+// checkRepository makes sure the run happens inside a work tree, and that
+// there is a commit to rewrite when amending or fixing up.
+func checkRepository(rewrite bool) error {
+	inside, err := executeCommandWithOutput("git", "rev-parse", "--is-inside-work-tree")
+	if err != nil || strings.TrimSpace(inside) != "true" {
+		return &stateError{exitNotARepository, "not inside a git work tree; run smart-commit in a repository, or create one with git init"}
+	}
+	if rewrite {
+		if _, err := executeCommandWithOutput("git", "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+			return &stateError{exitNothingToCommit, "the repository has no commits yet; there is nothing to amend or fix up"}
+		}
+	}
+	return nil
+}
+
+// checkPushState finds out before committing whether the commit can be
+// pushed the way opts asks: from a branch, to a remote, with an upstream
+// or permission to set one. Without these the push would fail after the
+// commit was made.
+func checkPushState(opts pushOptions) error {
+	if !opts.Push {
+		return nil
+	}
+	if _, err := executeCommandWithOutput("git", "symbolic-ref", "--quiet", "HEAD"); err != nil {
+		return &stateError{exitDetachedHead, "HEAD is detached, so there is no branch to push; check out a branch, or pass --no-push to commit anyway"}
+	}
+	remotes, err := executeCommandWithOutput("git", "remote")
+	if err == nil && strings.TrimSpace(remotes) == "" && opts.Remote == "" {
+		return &stateError{exitNoUpstream, "the repository has no remote to push to; add one with git remote add, or pass --no-push"}
+	}
+	if opts.Remote == "" && opts.Branch == "" && !opts.SetUpstream && !opts.Interactive && !hasUpstream() && !autoSetupRemote() {
+		branch, _ := executeCommandWithOutput("git", "branch", "--show-current")
+		branch = strings.TrimSpace(branch)
+		return &stateError{exitNoUpstream, fmt.Sprintf("no upstream configured for %s; pass --set-upstream to push it to %s/%s, or --no-push", branch, pushRemote(branch), branch)}
+	}
+	return nil
+}
+
+// nothingToCommit explains why nothing is staged: a clean work tree, or
+// changes that --staged-only leaves unstaged.
+func nothingToCommit(stagedOnly bool) error {
+	status, err := executeCommandWithOutput("git", "status", "--porcelain")
+	if err == nil && strings.TrimSpace(status) == "" {
+		return &stateError{exitNothingToCommit, "nothing to commit, working tree clean"}
+	}
+	if stagedOnly {
+		return &stateError{exitNothingToCommit, "nothing is staged; stage changes with git add, or drop --staged-only"}
+	}
+	return &stateError{exitNothingToCommit, "nothing is staged; the changed files are ignored or were held back"}
+}
+
+// exitOnState reports err and exits, with its state's exit code when it
+// has one. A nil err does nothing.
+func exitOnState(err error) {
+	if err == nil {
+		return
+	}
+	code := 1
+	var state *stateError
+	if errors.As(err, &state) {
+		code = state.Code
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(code)
+}
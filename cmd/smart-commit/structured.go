@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+	"gopkg.in/yaml.v3"
+)
+
+// largeDataLines is how many changed lines make the line diff of a JSON or
+// YAML file noise worth replacing with a structural diff.
+const largeDataLines = 200
+
+// maxStructuralChanges bounds the changed keys listed per file, and
+// maxCellLines the lines shown per notebook cell.
+const (
+	maxStructuralChanges = 40
+	maxCellLines         = 10
+)
+
+// structuralDiffs returns, by path, structural diffs of the staged
+// notebooks and large JSON and YAML files for the prompt to show instead of
+// their line diffs: the cells added, removed or edited rather than lines of
+// JSON, and the keys that changed rather than thousands of moved lines.
+// Files that do not parse keep their line diff.
+func structuralDiffs(changes *git.ChangeSet) map[string]string {
+	diffs := map[string]string{}
+	for _, f := range changes.Files {
+		ext := strings.ToLower(path.Ext(f.Path))
+		notebook := ext == ".ipynb"
+		data := (ext == ".json" || ext == ".yaml" || ext == ".yml") && f.Added+f.Deleted >= largeDataLines
+		if f.Binary || f.Category == git.CategoryLockfile || !notebook && !data {
+			continue
+		}
+		before, after := stagedVersions(f)
+		var diff string
+		var ok bool
+		if notebook {
+			diff, ok = notebookDiff(before, after)
+		} else {
+			diff, ok = dataDiff(before, after)
+		}
+		if ok {
+			diffs[f.Path] = diff
+		}
+	}
+	return diffs
+}
+
+// stagedVersions returns a file as of apiBase and as staged; either is
+// empty when the file does not exist there.
+func stagedVersions(f git.FileChange) (before, after string) {
+	oldPath := f.Path
+	if f.OldPath != "" {
+		oldPath = f.OldPath
+	}
+	if f.Status != "A" && git.RefExists(apiBase) {
+		before, _ = git.Output("show", apiBase+":"+oldPath)
+	}
+	if f.Status != "D" {
+		after, _ = git.Output("show", ":"+f.Path)
+	}
+	return before, after
+}
+
+// notebookCell is a cell of a Jupyter notebook.
+type notebookCell struct {
+	ID, Type, Source string
+	// Outputs holds the outputs and execution count, only to compare them.
+	Outputs string
+}
+
+// readNotebook parses a Jupyter notebook; ok is false when src is not one.
+// An empty src is an empty notebook.
+func readNotebook(src string) (cells []notebookCell, metadata interface{}, ok bool) {
+	if src == "" {
+		return nil, nil, true
+	}
+	var doc struct {
+		Cells []struct {
+			ID             string          `json:"id"`
+			CellType       string          `json:"cell_type"`
+			Source         json.RawMessage `json:"source"`
+			Outputs        json.RawMessage `json:"outputs"`
+			ExecutionCount json.RawMessage `json:"execution_count"`
+		} `json:"cells"`
+		Metadata interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(src), &doc); err != nil || doc.Cells == nil {
+		return nil, nil, false
+	}
+	for _, c := range doc.Cells {
+		// The source is a string or a list of lines
+		var text string
+		if json.Unmarshal(c.Source, &text) != nil {
+			var lines []string
+			json.Unmarshal(c.Source, &lines)
+			text = strings.Join(lines, "")
+		}
+		cells = append(cells, notebookCell{ID: c.ID, Type: c.CellType, Source: text, Outputs: string(c.Outputs) + string(c.ExecutionCount)})
+	}
+	return cells, doc.Metadata, true
+}
+
+// notebookDiff describes how a notebook changed cell by cell: the source
+// of cells added and the lines edited in others, cells removed, and how
+// many cells have new outputs. ok is false when either version does not
+// parse as a notebook.
+func notebookDiff(before, after string) (string, bool) {
+	oldCells, oldMeta, ok1 := readNotebook(before)
+	newCells, newMeta, ok2 := readNotebook(after)
+	if !ok1 || !ok2 {
+		return "", false
+	}
+	// Cells are matched by id where the notebook format has them, else by
+	// their type and source
+	byID := true
+	for _, c := range append(append([]notebookCell(nil), oldCells...), newCells...) {
+		byID = byID && c.ID != ""
+	}
+	key := func(c notebookCell) string {
+		if byID {
+			return c.ID
+		}
+		return c.Type + "\x00" + c.Source
+	}
+	oldKeys, newKeys := make([]string, len(oldCells)), make([]string, len(newCells))
+	for i, c := range oldCells {
+		oldKeys[i] = key(c)
+	}
+	for i, c := range newCells {
+		newKeys[i] = key(c)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "notebook: %d → %d cells\n", len(oldCells), len(newCells))
+	outputs := 0
+	edited := func(i, j int) {
+		o, n := oldCells[i], newCells[j]
+		if o.Source != n.Source {
+			fmt.Fprintf(&b, "cell %d (%s) edited:\n", j+1, n.Type)
+			var changed []string
+			for _, line := range diffLines(sourceLines(o.Source), sourceLines(n.Source)) {
+				if line[0] != ' ' {
+					changed = append(changed, line)
+				}
+			}
+			writeCellLines(&b, changed)
+		}
+		if o.Outputs != n.Outputs {
+			outputs++
+		}
+	}
+	added := func(j int) {
+		fmt.Fprintf(&b, "cell %d (%s) added:\n", j+1, newCells[j].Type)
+		var lines []string
+		for _, line := range sourceLines(newCells[j].Source) {
+			lines = append(lines, "+"+line)
+		}
+		writeCellLines(&b, lines)
+	}
+	removed := func(i int) {
+		first := ""
+		for _, line := range sourceLines(oldCells[i].Source) {
+			if strings.TrimSpace(line) != "" {
+				first = ": " + shortValue(strings.TrimSpace(line))
+				break
+			}
+		}
+		fmt.Fprintf(&b, "cell %d (%s) removed%s\n", i+1, oldCells[i].Type, first)
+	}
+	// Between matched cells, a removed and an added cell of the same type
+	// are one cell edited
+	gap := func(oldFrom, oldTo, newFrom, newTo int) {
+		for oldFrom < oldTo && newFrom < newTo && oldCells[oldFrom].Type == newCells[newFrom].Type {
+			edited(oldFrom, newFrom)
+			oldFrom, newFrom = oldFrom+1, newFrom+1
+		}
+		for ; oldFrom < oldTo; oldFrom++ {
+			removed(oldFrom)
+		}
+		for ; newFrom < newTo; newFrom++ {
+			added(newFrom)
+		}
+	}
+	i, j := 0, 0
+	for _, p := range matchSequences(oldKeys, newKeys) {
+		gap(i, p[0], j, p[1])
+		edited(p[0], p[1])
+		i, j = p[0]+1, p[1]+1
+	}
+	gap(i, len(oldCells), j, len(newCells))
+	if outputs > 0 {
+		fmt.Fprintf(&b, "outputs of %d cell(s) changed\n", outputs)
+	}
+	var meta []string
+	if oldMeta != nil && newMeta != nil {
+		structuralChanges(oldMeta, newMeta, "metadata", &meta)
+	}
+	for _, m := range meta {
+		b.WriteString(m + "\n")
+	}
+	return b.String(), true
+}
+
+// sourceLines splits the source of a cell into lines.
+func sourceLines(src string) []string {
+	if src == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+}
+
+// writeCellLines writes diff lines of a cell, indented, up to maxCellLines.
+func writeCellLines(b *strings.Builder, lines []string) {
+	for i, line := range lines {
+		if i == maxCellLines {
+			fmt.Fprintf(b, "  ... %d more line(s)\n", len(lines)-i)
+			break
+		}
+		b.WriteString("  " + line + "\n")
+	}
+}
+
+// dataDiff describes how a JSON or YAML document changed key by key; a
+// change that only reformats it says so. ok is false when either version
+// does not parse.
+func dataDiff(before, after string) (string, bool) {
+	old, ok1 := readData(before)
+	now, ok2 := readData(after)
+	if !ok1 || !ok2 {
+		return "", false
+	}
+	// A file added or removed whole adds or removes its top-level keys
+	if _, isMap := now.(map[string]interface{}); isMap && old == nil {
+		old = map[string]interface{}{}
+	}
+	if _, isMap := old.(map[string]interface{}); isMap && now == nil {
+		now = map[string]interface{}{}
+	}
+	var changed []string
+	structuralChanges(old, now, "", &changed)
+	if len(changed) == 0 {
+		return "the data is unchanged; only its formatting changed\n", true
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d key(s) changed:\n", len(changed))
+	for i, c := range changed {
+		if i == maxStructuralChanges {
+			fmt.Fprintf(&b, "and %d more\n", len(changed)-i)
+			break
+		}
+		if strings.HasPrefix(c, ":") || strings.HasPrefix(c, " ") {
+			// A change at the top of the document has no path
+			c = "(document)" + c
+		}
+		b.WriteString(c + "\n")
+	}
+	return b.String(), true
+}
+
+// readData parses a JSON or YAML file: its document, or the list of its
+// documents when it has several. An empty src is an empty document.
+func readData(src string) (interface{}, bool) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(strings.NewReader(src))
+	for {
+		var doc interface{}
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false
+		}
+		docs = append(docs, doc)
+	}
+	switch len(docs) {
+	case 0:
+		return nil, true
+	case 1:
+		return docs[0], true
+	}
+	return docs, true
+}
+
+// maxMatchCells bounds the table matchSequences fills; longer sequences
+// are not matched at all.
+const maxMatchCells = 4_000_000
+
+// matchSequences returns the pairs of indexes of a longest common
+// subsequence of a and b.
+func matchSequences(a, b []string) [][2]int {
+	if len(a)*len(b) > maxMatchCells {
+		return nil
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// diffLines is a line diff of a and b: every line prefixed with ' ', '-'
+// or '+'.
+func diffLines(a, b []string) []string {
+	var out []string
+	i, j := 0, 0
+	for _, p := range append(matchSequences(a, b), [2]int{len(a), len(b)}) {
+		for ; i < p[0]; i++ {
+			out = append(out, "-"+a[i])
+		}
+		for ; j < p[1]; j++ {
+			out = append(out, "+"+b[j])
+		}
+		if p[0] < len(a) {
+			out = append(out, " "+a[i])
+			i, j = i+1, j+1
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNotebookDiff(t *testing.T) {
+	before := `{"cells": [
+		{"cell_type": "markdown", "source": ["# Sales\n"]},
+		{"cell_type": "code", "source": ["import pandas as pd\n", "df = pd.read_csv('sales.csv')"], "outputs": [], "execution_count": 1},
+		{"cell_type": "code", "source": "df.plot()", "outputs": [{"output_type": "display_data"}], "execution_count": 2},
+		{"cell_type": "markdown", "source": "Old notes"}
+	], "metadata": {"kernelspec": {"name": "python3"}}}`
+	after := `{"cells": [
+		{"cell_type": "markdown", "source": ["# Sales\n"]},
+		{"cell_type": "code", "source": ["import pandas as pd\n", "df = pd.read_csv('sales-2024.csv')"], "outputs": [], "execution_count": 1},
+		{"cell_type": "code", "source": "df.describe()", "outputs": [], "execution_count": 2},
+		{"cell_type": "code", "source": "df.plot()", "outputs": [{"output_type": "display_data", "data": {}}], "execution_count": 5}
+	], "metadata": {"kernelspec": {"name": "python3.11"}}}`
+	got, ok := notebookDiff(before, after)
+	if !ok {
+		t.Fatal("notebookDiff did not parse the notebooks")
+	}
+	want := `notebook: 4 → 4 cells
+cell 2 (code) edited:
+  -df = pd.read_csv('sales.csv')
+  +df = pd.read_csv('sales-2024.csv')
+cell 3 (code) added:
+  +df.describe()
+cell 4 (markdown) removed: Old notes
+outputs of 1 cell(s) changed
+metadata.kernelspec.name: python3 → python3.11
+`
+	if got != want {
+		t.Errorf("notebookDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, ok := notebookDiff(before, "<<<<<<< HEAD"); ok {
+		t.Error("notebookDiff parsed a notebook with conflict markers")
+	}
+}
+
+func TestDataDiff(t *testing.T) {
+	tests := []struct {
+		name, before, after, want string
+	}{
+		{
+			"reformatted",
+			`{"name": "app", "ports": [80, 443]}`,
+			"{\n  \"ports\": [\n    80,\n    443\n  ],\n  \"name\": \"app\"\n}\n",
+			"the data is unchanged; only its formatting changed\n",
+		},
+		{
+			"keys changed",
+			`{"name": "app", "limits": {"cpu": 1}, "ports": [80]}`,
+			"name: app\nlimits:\n  cpu: 2\n  memory: 512Mi\nports: [80, 443]\n",
+			"3 key(s) changed:\nlimits.cpu: 1 → 2\nlimits.memory added\nports: 1 → 2 items\n",
+		},
+		{
+			"added",
+			``,
+			`{"name": "app", "version": 2}`,
+			"2 key(s) changed:\nname added\nversion added\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dataDiff(tt.before, tt.after)
+			if !ok || got != tt.want {
+				t.Errorf("dataDiff() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines(strings.Fields("a b c d"), strings.Fields("a c x d e"))
+	want := []string{" a", "-b", " c", "+x", " d", "+e"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("diffLines() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// tenant is a team sharing a server: requests carrying its token use its
+// provider and credentials and count against its rate limit.
+type tenant struct {
+	Name string `yaml:"name"`
+	// TokenEnv names the variable holding the tenant's API token, which
+	// also signs its webhook deliveries.
+	TokenEnv string `yaml:"token_env"`
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+	// APIKeyEnv names the variable holding the provider API key; without
+	// it the server's own key is used.
+	APIKeyEnv         string `yaml:"api_key_env"`
+	RequestsPerMinute int    `yaml:"requests_per_minute"`
+	// Dirs are the directories on the server holding the tenant's
+	// repositories; gRPC requests may only name repositories in them.
+	Dirs []string `yaml:"dirs"`
+	// Repositories are the GitHub repositories, as owner/name patterns
+	// such as payments/*, whose webhook deliveries the tenant receives.
+	Repositories []string `yaml:"repositories"`
+	// GitHubTokenEnv names the variable holding the token the webhook bot
+	// comments on the tenant's repositories with.
+	GitHubTokenEnv string `yaml:"github_token_env"`
+
+	token       string
+	key         string
+	githubToken string
+	gen         generator.Generator
+	limiter     *rateLimiter
+}
+
+// loadTenants reads a tenants file:
+//
+//	tenants:
+//	  - name: payments
+//	    token_env: PAYMENTS_TOKEN
+//	    provider: anthropic
+//	    api_key_env: PAYMENTS_ANTHROPIC_API_KEY
+//	    requests_per_minute: 30
+//	    dirs: [/srv/git/payments]
+//	    repositories: [acme/payments-*]
+//	    github_token_env: PAYMENTS_GITHUB_TOKEN
+//
+// Tokens and keys are read from the environment, encrypted values allowed,
+// so the file itself holds no secrets.
+func loadTenants(filename string) ([]*tenant, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading tenants: %v", err)
+	}
+	var file struct {
+		Tenants []*tenant `yaml:"tenants"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+	if len(file.Tenants) == 0 {
+		return nil, fmt.Errorf("%s lists no tenants", filename)
+	}
+	seen := map[string]string{}
+	for _, t := range file.Tenants {
+		if t.Name == "" || t.TokenEnv == "" {
+			return nil, fmt.Errorf("%s: every tenant needs a name and a token_env", filename)
+		}
+		if t.token = secretEnv(t.TokenEnv); t.token == "" {
+			return nil, fmt.Errorf("tenant %s: %s is not set", t.Name, t.TokenEnv)
+		}
+		if other, ok := seen[t.token]; ok {
+			return nil, fmt.Errorf("tenants %s and %s share a token", other, t.Name)
+		}
+		seen[t.token] = t.Name
+		if t.APIKeyEnv != "" {
+			if t.key = secretEnv(t.APIKeyEnv); t.key == "" {
+				return nil, fmt.Errorf("tenant %s: %s is not set", t.Name, t.APIKeyEnv)
+			}
+		}
+		if t.GitHubTokenEnv != "" {
+			if t.githubToken = secretEnv(t.GitHubTokenEnv); t.githubToken == "" {
+				return nil, fmt.Errorf("tenant %s: %s is not set", t.Name, t.GitHubTokenEnv)
+			}
+		}
+		for i, dir := range t.Dirs {
+			resolved, err := resolveDir(dir)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: %v", t.Name, err)
+			}
+			t.Dirs[i] = resolved
+		}
+		for _, pattern := range t.Repositories {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("tenant %s: invalid repository pattern %q", t.Name, pattern)
+			}
+		}
+		gen, err := newGeneratorWithKey(t.Provider, t.Model, t.key)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", t.Name, err)
+		}
+		t.gen = withScreening(withRetries(withMaxLatency(gen)))
+		t.limiter = newRateLimiter(t.RequestsPerMinute)
+	}
+	return file.Tenants, nil
+}
+
+// findTenant returns the tenant a token belongs to, or nil.
+func findTenant(tenants []*tenant, token string) *tenant {
+	for _, t := range tenants {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) == 1 {
+			return t
+		}
+	}
+	return nil
+}
+
+// tenantKey is the context key of the tenant a request is made for.
+type tenantKey struct{}
+
+// withTenant returns a copy of ctx for requests made on behalf of t: they
+// use its provider and credentials.
+func withTenant(ctx context.Context, t *tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// tenantFrom returns the tenant ctx was made for, or nil.
+func tenantFrom(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantKey{}).(*tenant)
+	return t
+}
+
+// admit counts a request against t's rate limit, or refuses it.
+func (t *tenant) admit() error {
+	if !t.limiter.allow(time.Now()) {
+		return errRateLimited{t}
+	}
+	return nil
+}
+
+// allowsDir reports whether dir, a repository a request names (the
+// server's working directory when empty), lies in one of t's directories.
+func (t *tenant) allowsDir(dir string) bool {
+	resolved, err := resolveDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range t.Dirs {
+		if resolved == allowed || strings.HasPrefix(resolved, allowed+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRepository reports whether fullName, a GitHub owner/name, matches
+// one of t's repositories.
+func (t *tenant) allowsRepository(fullName string) bool {
+	for _, pattern := range t.Repositories {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(fullName)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// environ is the environment of the processes that answer t's requests:
+// the server's, with t's provider, model and API key in the variables
+// smart-commit reads them from, and without any tenant's secrets.
+func (t *tenant) environ(tenants []*tenant) []string {
+	secret := map[string]bool{"SMART_COMMIT_TENANTS": true}
+	for _, other := range tenants {
+		for _, name := range []string{other.TokenEnv, other.APIKeyEnv, other.GitHubTokenEnv} {
+			if name != "" {
+				secret[name] = true
+			}
+		}
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); !secret[name] {
+			env = append(env, kv)
+		}
+	}
+
+	provider := strings.ToLower(t.Provider)
+	if provider == "" {
+		provider = defaultProvider()
+	}
+	env = append(env, "SMART_COMMIT_PROVIDER="+provider)
+	if t.Model != "" {
+		env = append(env, "SMART_COMMIT_MODEL="+t.Model)
+	}
+	if keyEnv := providerKeyEnv[provider]; keyEnv != "" && t.key != "" {
+		env = append(env, keyEnv+"="+t.key)
+	}
+	return env
+}
+
+// providerKeyEnv names the variable each provider reads its API key from.
+var providerKeyEnv = map[string]string{"openai": "OPENAI_API_KEY", "anthropic": "ANTHROPIC_API_KEY"}
+
+// resolveDir is the absolute path of dir with symbolic links resolved, so
+// that neither .. nor links lead out of a tenant's directories.
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// errRateLimited is returned for requests over a tenant's rate limit.
+type errRateLimited struct{ t *tenant }
+
+func (e errRateLimited) Error() string {
+	return fmt.Sprintf("tenant %s is over its limit of %d requests per minute", e.t.Name, e.t.RequestsPerMinute)
+}
+
+// rateLimiter is a token bucket holding up to a minute's worth of
+// requests. A zero limit allows everything.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, tokens: float64(perMinute)}
+}
+
+// allow takes a token for a request made at now, if one is left.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l.perMinute <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Minutes() * float64(l.perMinute)
+		if l.tokens > float64(l.perMinute) {
+			l.tokens = float64(l.perMinute)
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
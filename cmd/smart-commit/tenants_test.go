@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	start := time.Unix(0, 0)
+	l := newRateLimiter(2)
+	steps := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{time.Second, true},
+		{2 * time.Second, false},
+		{40 * time.Second, true},
+		{41 * time.Second, false},
+		{3 * time.Minute, true},
+		{3 * time.Minute, true},
+		{3 * time.Minute, false},
+	}
+	for _, s := range steps {
+		if got := l.allow(start.Add(s.after)); got != s.want {
+			t.Errorf("allow after %s = %v, want %v", s.after, got, s.want)
+		}
+	}
+	if unlimited := newRateLimiter(0); !unlimited.allow(start) || !unlimited.allow(start) {
+		t.Error("a zero limit refused a request")
+	}
+}
+
+func TestLoadTenants(t *testing.T) {
+	t.Setenv("PAYMENTS_TOKEN", "p-token")
+	t.Setenv("SEARCH_TOKEN", "s-token")
+	t.Setenv("PAYMENTS_KEY", "sk-payments")
+	t.Setenv("EMPTY_TOKEN", "")
+	tests := []struct {
+		name, file, err string
+	}{
+		{"valid", "tenants:\n  - name: payments\n    token_env: PAYMENTS_TOKEN\n    provider: ollama\n    api_key_env: PAYMENTS_KEY\n    requests_per_minute: 10\n  - name: search\n    token_env: SEARCH_TOKEN\n", ""},
+		{"no tenants", "tenants: []\n", "lists no tenants"},
+		{"unset token", "tenants:\n  - name: x\n    token_env: EMPTY_TOKEN\n", "EMPTY_TOKEN is not set"},
+		{"shared token", "tenants:\n  - name: a\n    token_env: SEARCH_TOKEN\n  - name: b\n    token_env: SEARCH_TOKEN\n", "share a token"},
+		{"unknown provider", "tenants:\n  - name: a\n    token_env: SEARCH_TOKEN\n    provider: nonesuch\n", "unknown provider"},
+		{"unknown field", "tenants:\n  - name: a\n    token: plain\n", "field token not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.yml")
+			os.WriteFile(path, []byte(tt.file), 0644)
+			tenants, err := loadTenants(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("loadTenants() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := findTenant(tenants, "s-token"); got == nil || got.Name != "search" {
+				t.Errorf("findTenant(s-token) = %v, want search", got)
+			}
+			if got := findTenant(tenants, "nope"); got != nil {
+				t.Errorf("findTenant(nope) = %s, want none", got.Name)
+			}
+			if got := findTenant(tenants, ""); got != nil {
+				t.Errorf("findTenant(\"\") = %s, want none", got.Name)
+			}
+			ctx := withTenant(context.Background(), tenants[0])
+			if got := generatorFor(ctx); got != tenants[0].gen {
+				t.Errorf("generatorFor() = %s, not the tenant's provider", got.Name())
+			}
+			if got := generatorFor(context.Background()); got == tenants[0].gen {
+				t.Error("generatorFor() without a tenant returned the tenant's provider")
+			}
+		})
+	}
+}
+
+func TestTenantScope(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"payments/api", "paymentsx", "search"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	os.Symlink(filepath.Join(root, "search"), filepath.Join(root, "payments", "link"))
+	payments, _ := resolveDir(filepath.Join(root, "payments"))
+	tn := &tenant{Name: "payments", Dirs: []string{payments}, Repositories: []string{"Acme/payments-*"}}
+
+	dirs := []struct {
+		dir  string
+		want bool
+	}{
+		{"payments", true},
+		{"payments/api", true},
+		{"payments/api/../../search", false},
+		{"paymentsx", false},
+		{"payments/link", false},
+		{"missing", false},
+	}
+	for _, d := range dirs {
+		if got := tn.allowsDir(filepath.Join(root, d.dir)); got != d.want {
+			t.Errorf("allowsDir(%s) = %v, want %v", d.dir, got, d.want)
+		}
+	}
+
+	repos := []struct {
+		repo string
+		want bool
+	}{
+		{"acme/payments-api", true},
+		{"ACME/Payments-web", true},
+		{"acme/search", false},
+		{"other/payments-api", false},
+		{"", false},
+	}
+	for _, r := range repos {
+		if got := tn.allowsRepository(r.repo); got != r.want {
+			t.Errorf("allowsRepository(%q) = %v, want %v", r.repo, got, r.want)
+		}
+	}
+}
+
+func TestTenantEnviron(t *testing.T) {
+	t.Setenv("PAYMENTS_TOKEN", "p-token")
+	t.Setenv("SEARCH_KEY", "sk-search")
+	t.Setenv("SMART_COMMIT_TENANTS", "tenants.yml")
+	t.Setenv("KEEP_ME", "1")
+	payments := &tenant{TokenEnv: "PAYMENTS_TOKEN", Provider: "Anthropic", Model: "claude", key: "sk-payments"}
+	search := &tenant{TokenEnv: "SEARCH_TOKEN", APIKeyEnv: "SEARCH_KEY"}
+
+	env := strings.Join(payments.environ([]*tenant{payments, search}), "\n") + "\n"
+	for _, want := range []string{"KEEP_ME=1\n", "SMART_COMMIT_PROVIDER=anthropic\n", "SMART_COMMIT_MODEL=claude\n", "ANTHROPIC_API_KEY=sk-payments\n"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("environ() lacks %q", want)
+		}
+	}
+	for _, secret := range []string{"p-token", "sk-search", "SMART_COMMIT_TENANTS="} {
+		if strings.Contains(env, secret) {
+			t.Errorf("environ() leaks %q", secret)
+		}
+	}
+}
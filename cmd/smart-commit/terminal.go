@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdinReader is shared by all interactive prompts so buffered input is not
+// lost between questions.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// isTerminal reports whether f is attached to an interactive terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// promptLine prints question and returns the trimmed line the user typed.
+func promptLine(question string) (string, error) {
+	fmt.Print(question)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// confirm asks a yes/no question, defaulting to no.
+func confirm(question string) bool {
+	answer, err := promptLine(question + " [y/N] ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// trackerIssue is the part of an issue the tool cares about.
+type trackerIssue struct {
+	ID    string
+	Title string
+	Open  bool
+}
+
+// errIssueNotFound is returned by trackers when an issue does not exist.
+var errIssueNotFound = errors.New("issue not found")
+
+// issueTracker looks up issues in an external tracker.
+type issueTracker interface {
+	// Name identifies the tracker in messages.
+	Name() string
+	// References returns the ticket IDs in text that belong to this tracker.
+	References(text string) []string
+	// LookupIssue fetches an issue, returning errIssueNotFound if it does not exist.
+	LookupIssue(id string) (*trackerIssue, error)
+}
+
+// trackerFromEnv builds the tracker selected by SMART_COMMIT_TRACKER, with
+// its lookups cached. It returns nil when no tracker is configured.
+func trackerFromEnv() (issueTracker, error) {
+	switch kind := strings.ToLower(os.Getenv("SMART_COMMIT_TRACKER")); kind {
+	case "":
+		return nil, nil
+	case "jira":
+		baseURL := os.Getenv("JIRA_BASE_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("JIRA_BASE_URL must be set to use the jira tracker")
+		}
+		t := &jiraTracker{
+			baseURL: strings.TrimRight(baseURL, "/"),
+			email:   os.Getenv("JIRA_EMAIL"),
+			token:   secretEnv("JIRA_API_TOKEN"),
+		}
+		if t.token == "" {
+			if username, password, ok := gitCredential(t.baseURL); ok {
+				t.token = password
+				if t.email == "" {
+					t.email = username
+				}
+			}
+		}
+		return cacheLookups(t, t.baseURL, t.email+":"+t.token), nil
+	case "linear":
+		token := secretEnv("LINEAR_API_KEY")
+		if token == "" {
+			_, token, _ = gitCredential("https://api.linear.app")
+		}
+		if token == "" {
+			return nil, fmt.Errorf("LINEAR_API_KEY must be set (or stored in a git credential helper for api.linear.app) to use the linear tracker")
+		}
+		return cacheLookups(&linearTracker{token: token}, "api.linear.app", token), nil
+	case "github":
+		owner, repo, err := githubRepoFromRemote()
+		if err != nil {
+			return nil, err
+		}
+		token := githubToken()
+		return cacheLookups(&githubTracker{owner: owner, repo: repo, token: token}, githubHost()+"/"+owner+"/"+repo, token), nil
+	default:
+		return nil, fmt.Errorf("unknown SMART_COMMIT_TRACKER %q (expected jira, linear or github)", kind)
+	}
+}
+
+// verifyTicketReferences checks that every ticket referenced in message exists
+// and is open in the configured tracker. It is a no-op when no tracker is set.
+func verifyTicketReferences(message string) error {
+	tracker, err := trackerFromEnv()
+	if err != nil || tracker == nil {
+		return err
+	}
+
+	for _, id := range tracker.References(message) {
+		issue, err := tracker.LookupIssue(id)
+		if errors.Is(err, errIssueNotFound) {
+			return fmt.Errorf("ticket %s does not exist in %s; check for a typo", id, tracker.Name())
+		}
+		// An unreachable tracker is no reason to hold the commit back
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			fmt.Fprintf(os.Stderr, "Warning: %s is unreachable; not verifying ticket references: %v\n", tracker.Name(), err)
+			degrade("network", "ticket references are not verified against %s", tracker.Name())
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("looking up %s in %s: %v", id, tracker.Name(), err)
+		}
+		if !issue.Open {
+			return fmt.Errorf("ticket %s (%s) is closed in %s", id, issue.Title, tracker.Name())
+		}
+	}
+	return nil
+}
+
+// keyReference matches tracker keys such as ABC-123.
+var keyReference = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b`)
+
+// numberReference matches GitHub-style #123 references.
+var numberReference = regexp.MustCompile(`#(\d+)\b`)
+
+// getJSON performs an API request and decodes a JSON response into out,
+// mapping 404 to errIssueNotFound.
+func getJSON(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errIssueNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jiraTracker looks up issues through the Jira REST API.
+type jiraTracker struct {
+	baseURL string
+	email   string
+	token   string
+}
+
+func (t *jiraTracker) Name() string { return "Jira" }
+
+func (t *jiraTracker) References(text string) []string {
+	return uniqueStrings(keyReference.FindAllString(text, -1))
+}
+
+func (t *jiraTracker) LookupIssue(id string) (*trackerIssue, error) {
+	req, err := http.NewRequestWithContext(interrupted, "GET", t.baseURL+"/rest/api/2/issue/"+url.PathEscape(id)+"?fields=summary,status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if t.token != "" {
+		req.SetBasicAuth(t.email, t.token)
+	}
+
+	var resp struct {
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	return &trackerIssue{
+		ID:    id,
+		Title: resp.Fields.Summary,
+		Open:  resp.Fields.Status.StatusCategory.Key != "done",
+	}, nil
+}
+
+// linearTracker looks up issues through the Linear GraphQL API.
+type linearTracker struct {
+	token string
+}
+
+func (t *linearTracker) Name() string { return "Linear" }
+
+func (t *linearTracker) References(text string) []string {
+	return uniqueStrings(keyReference.FindAllString(text, -1))
+}
+
+func (t *linearTracker) LookupIssue(id string) (*trackerIssue, error) {
+	query, err := json.Marshal(map[string]interface{}{
+		"query":     `query($id: String!) { issue(id: $id) { identifier title state { type } } }`,
+		"variables": map[string]string{"id": id},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(interrupted, "POST", "https://api.linear.app/graphql", bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", t.token)
+
+	var resp struct {
+		Data struct {
+			Issue *struct {
+				Title string `json:"title"`
+				State struct {
+					Type string `json:"type"`
+				} `json:"state"`
+			} `json:"issue"`
+		} `json:"data"`
+	}
+	if err := getJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	// Linear answers unknown identifiers with a null issue and an error entry
+	if resp.Data.Issue == nil {
+		return nil, errIssueNotFound
+	}
+	state := resp.Data.Issue.State.Type
+	return &trackerIssue{
+		ID:    id,
+		Title: resp.Data.Issue.Title,
+		Open:  state != "completed" && state != "canceled",
+	}, nil
+}
+
+// githubTracker looks up issues of the repository's GitHub remote.
+type githubTracker struct {
+	owner string
+	repo  string
+	token string
+}
+
+func (t *githubTracker) Name() string { return "GitHub" }
+
+func (t *githubTracker) References(text string) []string {
+	var refs []string
+	for _, m := range numberReference.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, m[1])
+	}
+	return uniqueStrings(refs)
+}
+
+func (t *githubTracker) LookupIssue(id string) (*trackerIssue, error) {
+	var resp struct {
+		Title string `json:"title"`
+		State string `json:"state"`
+	}
+	if err := githubAPI("GET", fmt.Sprintf("/repos/%s/%s/issues/%s", t.owner, t.repo, id), t.token, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &trackerIssue{ID: "#" + id, Title: resp.Title, Open: resp.State == "open"}, nil
+}
+
+// githubToken returns a GitHub API token for the host in use from the
+// environment, a device login, the gh CLI, or a git credential helper.
+func githubToken() string {
+	// As with gh, other hosts' tokens have their own variables; github.com's
+	// are never sent anywhere else. GitHub Actions on other hosts names the
+	// host its GITHUB_TOKEN is for.
+	host := githubHost()
+	names := []string{"GITHUB_TOKEN", "GH_TOKEN"}
+	if host != "github.com" {
+		names = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+		if normalizeHost(os.Getenv("GITHUB_SERVER_URL")) == host {
+			names = append(names, "GITHUB_TOKEN")
+		}
+	}
+	for _, name := range names {
+		if token := secretEnv(name); token != "" {
+			return token
+		}
+	}
+	if token := providerToken("github"); token != "" {
+		return token
+	}
+	if token, err := executeCommandWithOutput("gh", "auth", "token", "--hostname", host); err == nil && strings.TrimSpace(token) != "" {
+		return strings.TrimSpace(token)
+	}
+	_, token, _ := gitCredential("https://" + host)
+	return token
+}
+
+// githubRepoFromRemote returns the owner and name of the GitHub
+// repository being worked with; see githubRemote.
+func githubRepoFromRemote() (string, string, error) {
+	remote, err := githubRemote()
+	if err != nil {
+		return "", "", err
+	}
+	return remote.Owner, remote.Repo, nil
+}
+
+// uniqueStrings returns values without duplicates, keeping the first
+// occurrence order.
+func uniqueStrings(values []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// breakingFooter matches BREAKING CHANGE footers, which git does not take
+// for trailers, and their hyphenated form, which it does.
+var breakingFooter = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+
+// configuredTrailers returns the trailers from the trailers setting followed
+// by those given on the command line.
+func configuredTrailers(specs []string) ([]conventional.Trailer, error) {
+	var trailers []conventional.Trailer
+	for _, spec := range append(append([]string{}, config.Current().Trailers...), specs...) {
+		t, err := conventional.ParseTrailer(spec)
+		if err != nil {
+			return nil, err
+		}
+		trailers = append(trailers, t)
+	}
+	return trailers, nil
+}
+
+// addTrailers appends trailers to message with git interpret-trailers, so
+// they land in the existing trailer block when there is one, follow the
+// repository's trailer.* settings, and are not repeated.
+func addTrailers(message string, trailers []conventional.Trailer) (string, error) {
+	if len(trailers) == 0 {
+		return message, nil
+	}
+	// git passes BREAKING CHANGE through as a trailer only when hyphenated
+	spaced := strings.Contains(message, "BREAKING CHANGE:")
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent"}
+	for _, t := range trailers {
+		if t.Token == "BREAKING CHANGE" {
+			spaced = true
+			t.Token = "BREAKING-CHANGE"
+		}
+		args = append(args, "--trailer", t.String())
+	}
+	cmd := exec.CommandContext(interrupted, "git", args...)
+	cmd.Stdin = strings.NewReader(breakingFooter.ReplaceAllString(message, "BREAKING-CHANGE:") + "\n")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return "", fmt.Errorf("adding trailers: %v", err)
+	}
+	result := strings.TrimRight(out.String(), "\n")
+	if spaced {
+		result = breakingFooter.ReplaceAllString(result, "BREAKING CHANGE:")
+	}
+	return result, nil
+}
+
+// appendBody adds a paragraph to the end of a message's text, keeping its
+// trailer block last.
+func appendBody(message, body string) (string, error) {
+	text, trailers := conventional.SplitTrailers(message)
+	return addTrailers(text+"\n\n"+body, trailers)
+}
+
+// squashTrailers collects the trailers of the commits being squashed, given
+// oldest first, so Co-authored-by, Signed-off-by and the like survive the
+// squash. Repeated trailers are kept once, and only the first Change-Id is
+// kept, as Gerrit rejects commits with several.
+func squashTrailers(commits []git.Commit) []conventional.Trailer {
+	seen := map[string]bool{}
+	var merged []conventional.Trailer
+	for _, c := range commits {
+		_, trailers := conventional.SplitTrailers(c.Message())
+		for _, t := range trailers {
+			key := strings.ToLower(t.Token) + ":" + t.Value
+			if strings.EqualFold(t.Token, "Change-Id") {
+				key = "change-id"
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// subjectRules are the lint rules about what servers accept in a subject
+// rather than how it reads: its encoding, characters and size in bytes.
+var subjectRules = []string{"subject-encoding", "subject-ascii", "subject-max-length"}
+
+// transliterations spell non-ASCII letters and punctuation in ASCII.
+// Accented Latin letters lose their accents; Cyrillic follows the common
+// passport romanization.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ą': "a", 'ă': "a", 'ā': "a",
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Ą': "A", 'Ă': "A", 'Ā': "A",
+	'æ': "ae", 'Æ': "AE", 'ç': "c", 'ć': "c", 'č': "c", 'Ç': "C", 'Ć': "C", 'Č': "C",
+	'ď': "d", 'đ': "d", 'Ď': "D", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ę': "e", 'ě': "e", 'ē': "e",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ę': "E", 'Ě': "E", 'Ē': "E",
+	'ğ': "g", 'Ğ': "G", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ı': "i", 'ī': "i",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'İ': "I", 'Ī': "I",
+	'ł': "l", 'Ł': "L", 'ñ': "n", 'ń': "n", 'ň': "n", 'Ñ': "N", 'Ń': "N", 'Ň': "N",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ő': "o", 'ō': "o",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ő': "O", 'Ō': "O",
+	'œ': "oe", 'Œ': "OE", 'ř': "r", 'Ř': "R", 'ś': "s", 'š': "s", 'ş': "s", 'Ś': "S", 'Š': "S", 'Ş': "S",
+	'ß': "ss", 'ť': "t", 'Ť': "T", 'þ': "th", 'Þ': "TH",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ů': "u", 'ű': "u", 'ū': "u",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ů': "U", 'Ű': "U", 'Ū': "U",
+	'ý': "y", 'ÿ': "y", 'Ý': "Y", 'ź': "z", 'ż': "z", 'ž': "z", 'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'ё': "e", 'є': "ie", 'ж': "zh",
+	'з': "z", 'и': "i", 'і': "i", 'ї': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "shch", 'ъ': "ie", 'ы': "y", 'ь': "", 'э': "e", 'ю': "iu", 'я': "ia",
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Ґ': "G", 'Д': "D", 'Е': "E", 'Ё': "E", 'Є': "Ie", 'Ж': "Zh",
+	'З': "Z", 'И': "I", 'І': "I", 'Ї': "I", 'Й': "I", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O",
+	'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh",
+	'Щ': "Shch", 'Ъ': "Ie", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Iu", 'Я': "Ia",
+
+	'‘': "'", '’': "'", '‚': "'", '“': `"`, '”': `"`, '„': `"`, '«': `"`, '»': `"`,
+	'–': "-", '—': "-", '…': "...", '\u00a0': " ", '→': "->", '×': "x",
+}
+
+// germanTransliterations spell umlauts the way German does without them.
+var germanTransliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'Ä': "Ae", 'Ö': "Oe", 'Ü': "Ue",
+}
+
+// isASCII reports whether s has ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// transliterate spells s, written in lang, in ASCII. Invalid bytes and
+// control characters are dropped. ok is false when some characters have
+// no ASCII spelling, as in Chinese or Japanese.
+func transliterate(s, lang string) (ascii string, ok bool) {
+	code, _ := languageCode(lang)
+	var b strings.Builder
+	ok = true
+	for _, r := range strings.ToValidUTF8(s, "") {
+		if r < utf8.RuneSelf {
+			if r >= ' ' && r != 0x7f {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		if t, found := germanTransliterations[r]; found && code == "de" {
+			b.WriteString(t)
+		} else if t, found := transliterations[r]; found {
+			b.WriteString(t)
+		} else {
+			ok = false
+		}
+	}
+	return b.String(), ok
+}
+
+// subjectProblems lints message for the problems of subjectRules.
+func subjectProblems(message string) []lintProblem {
+	var problems []lintProblem
+	for _, p := range lintMessage(message) {
+		if contains(subjectRules, p.Rule) {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// checkSubjectEncoding offers an ASCII spelling of the subject when a
+// message in another language than English breaks what the servers
+// accept: invalid UTF-8, non-ASCII characters where subject_ascii is set,
+// or more bytes than the subject length limit. The author is asked before
+// it is used; unattended runs use it and say so.
+func checkSubjectEncoding(message string, interactive bool) string {
+	if languageName(commitLanguage()) == "" {
+		return message
+	}
+	problems := subjectProblems(message)
+	if len(problems) == 0 {
+		return message
+	}
+	var reasons []string
+	for _, p := range problems {
+		reasons = append(reasons, p.Message)
+	}
+
+	subject, rest, hasRest := strings.Cut(message, "\n")
+	ascii, ok := transliterate(subject, commitLanguage())
+	alternative := ascii
+	if hasRest {
+		alternative += "\n" + rest
+	}
+	if !ok || len(subjectProblems(alternative)) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s, and no ASCII spelling of it fixes that; shorten it when reviewing\n", strings.Join(reasons, "; "))
+		return message
+	}
+
+	if !interactive {
+		fmt.Printf("Using an ASCII spelling of the subject (%s): %s\n", strings.Join(reasons, "; "), ascii)
+		return alternative
+	}
+	fmt.Printf("The subject may be rejected: %s.\nASCII spelling: %s\n", strings.Join(reasons, "; "), ascii)
+	answer, err := promptLine("Use it? [Y/n] ")
+	if err != nil || (answer != "" && !strings.HasPrefix(strings.ToLower(answer), "y")) {
+		return message
+	}
+	return alternative
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in, lang, want string
+		ok             bool
+	}{
+		{"feat: adiciona exportação de usuários", "pt-BR", "feat: adiciona exportacao de usuarios", true},
+		{"fix: Größe der Übersicht korrigieren", "de", "fix: Groesse der Uebersicht korrigieren", true},
+		{"fix: Größe der Übersicht korrigieren", "sv", "fix: Grosse der Ubersicht korrigieren", true},
+		{"feat(api): добавить экспорт", "ru", "feat(api): dobavit eksport", true},
+		{"docs: “quoted” — dashes…", "fr", `docs: "quoted" - dashes...`, true},
+		{"feat: 追加 export", "ja", "feat:  export", false},
+		{"fix: bad \xff byte\x07", "es", "fix: bad  byte", true},
+	}
+	for _, tt := range tests {
+		got, ok := transliterate(tt.in, tt.lang)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("transliterate(%q, %q) = %q, %t, want %q, %t", tt.in, tt.lang, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckSubjectEncoding(t *testing.T) {
+	previous := activePolicy
+	defer usePolicy(previous)
+	defer func(lang string) { messageLanguage = lang }(messageLanguage)
+
+	tests := []struct {
+		name, policy, lang, message, want string
+	}{
+		{"english", `{"subject_ascii": true}`, "en", "feat: add café menu", "feat: add café menu"},
+		{"fits", `{}`, "pt-BR", "feat: adiciona exportação", "feat: adiciona exportação"},
+		{"ascii required", `{"subject_ascii": true}`, "pt-BR", "feat: adiciona exportação\n\nCorpo.", "feat: adiciona exportacao\n\nCorpo."},
+		{"too many bytes", `{"max_subject_length": 28}`, "pt-BR", "feat: exportação de usuários", "feat: exportacao de usuarios"},
+		{"no ascii spelling", `{"subject_ascii": true}`, "zh", "feat: 添加导出", "feat: 添加导出"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePolicy([]byte(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			usePolicy(p)
+			messageLanguage = tt.lang
+			if got := checkSubjectEncoding(tt.message, false); got != tt.want {
+				t.Errorf("checkSubjectEncoding(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
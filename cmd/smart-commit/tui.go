@@ -0,0 +1,526 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// tuiCandidates is how many candidate messages the TUI offers.
+const tuiCandidates = 3
+
+// tuiHelp lists the keys of the TUI on its status line.
+const tuiHelp = "↑↓ file  space include/exclude  PgUp/PgDn scroll  ←→ message  e edit  r regenerate  f feedback  enter commit  q abort"
+
+// tuiModel is the state of the TUI: the staged files, which of them are
+// left out of the commit, the diff scroll position and the candidate
+// messages. It is driven by update with one key at a time and drawn by
+// view, so it runs without a terminal in tests.
+type tuiModel struct {
+	files      []git.FileChange
+	excluded   map[string]bool
+	cursor     int
+	scroll     int
+	page       int
+	candidates []string
+	current    int
+
+	// editing is set while the current candidate is edited in place
+	editing  bool
+	edit     [][]rune
+	row, col int
+
+	// asking is set while feedback on the candidates is typed
+	asking   bool
+	feedback []rune
+
+	// regenerate generates new candidates for the files left in, with
+	// the author's feedback on the ones shown
+	regenerate func(changes *git.ChangeSet, feedback string) ([]string, error)
+	pending    bool
+	status     string
+	done       bool
+	aborted    bool
+}
+
+// newTUIModel starts the TUI on changes with the given candidates.
+func newTUIModel(changes *git.ChangeSet, candidates []string, regenerate func(*git.ChangeSet, string) ([]string, error)) *tuiModel {
+	return &tuiModel{files: changes.Files, excluded: map[string]bool{}, page: 10, candidates: candidates, regenerate: regenerate}
+}
+
+// included is the change set of the files left in the commit.
+func (m *tuiModel) included() *git.ChangeSet {
+	cs := &git.ChangeSet{}
+	for _, f := range m.files {
+		if !m.excluded[f.Path] {
+			cs.Files = append(cs.Files, f)
+		}
+	}
+	return cs
+}
+
+// message is the candidate shown.
+func (m *tuiModel) message() string {
+	if len(m.candidates) == 0 {
+		return ""
+	}
+	return m.candidates[m.current]
+}
+
+// update handles one key, as named by parseKeys.
+func (m *tuiModel) update(key string) {
+	if m.editing {
+		m.updateEdit(key)
+		return
+	}
+	if m.asking {
+		m.updateFeedback(key)
+		return
+	}
+	m.status = ""
+	switch key {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor, m.scroll = m.cursor-1, 0
+		}
+	case "down", "j":
+		if m.cursor < len(m.files)-1 {
+			m.cursor, m.scroll = m.cursor+1, 0
+		}
+	case "pgdown", "d":
+		if last := len(m.diffLines()) - m.page; m.scroll+m.page <= last {
+			m.scroll += m.page
+		} else if last > 0 {
+			m.scroll = last
+		}
+	case "pgup", "u":
+		if m.scroll -= m.page; m.scroll < 0 {
+			m.scroll = 0
+		}
+	case "right", "n", "tab":
+		if len(m.candidates) > 0 {
+			m.current = (m.current + 1) % len(m.candidates)
+		}
+	case "left", "p":
+		if len(m.candidates) > 0 {
+			m.current = (m.current + len(m.candidates) - 1) % len(m.candidates)
+		}
+	case " ":
+		if len(m.files) == 0 {
+			return
+		}
+		path := m.files[m.cursor].Path
+		if !m.excluded[path] && len(m.included().Files) == 1 {
+			m.status = "At least one file must stay in the commit."
+			return
+		}
+		m.excluded[path] = !m.excluded[path]
+		m.status = "Press r to regenerate the messages for the files left in."
+	case "e":
+		m.editing, m.row, m.col = true, 0, 0
+		m.edit = nil
+		for _, line := range strings.Split(m.message(), "\n") {
+			m.edit = append(m.edit, []rune(line))
+		}
+	case "r":
+		if m.regenerate != nil {
+			m.pending = true
+			m.status = "Regenerating..."
+		}
+	case "f":
+		if m.regenerate != nil {
+			m.asking, m.feedback = true, nil
+		}
+	case "enter", "c":
+		if strings.TrimSpace(m.message()) == "" {
+			m.status = "The message is empty; edit it or regenerate."
+			return
+		}
+		m.done = true
+	case "q", "esc", "ctrl-c":
+		m.aborted = true
+	}
+}
+
+// updateEdit handles a key while editing: typing inserts, enter splits the
+// line, the arrows move and esc keeps the edited message.
+func (m *tuiModel) updateEdit(key string) {
+	line := m.edit[m.row]
+	switch key {
+	case "esc":
+		var lines []string
+		for _, l := range m.edit {
+			lines = append(lines, string(l))
+		}
+		if len(m.candidates) == 0 {
+			m.candidates = []string{""}
+		}
+		m.candidates[m.current] = strings.TrimSpace(strings.Join(lines, "\n"))
+		m.editing = false
+	case "ctrl-c":
+		m.editing, m.aborted = false, true
+	case "enter":
+		rest := append([]rune{}, line[m.col:]...)
+		m.edit[m.row] = line[:m.col]
+		m.edit = append(m.edit[:m.row+1], append([][]rune{rest}, m.edit[m.row+1:]...)...)
+		m.row, m.col = m.row+1, 0
+	case "backspace":
+		if m.col > 0 {
+			m.edit[m.row] = append(line[:m.col-1], line[m.col:]...)
+			m.col--
+		} else if m.row > 0 {
+			m.col = len(m.edit[m.row-1])
+			m.edit[m.row-1] = append(m.edit[m.row-1], line...)
+			m.edit = append(m.edit[:m.row], m.edit[m.row+1:]...)
+			m.row--
+		}
+	case "left":
+		if m.col > 0 {
+			m.col--
+		}
+	case "right":
+		if m.col < len(line) {
+			m.col++
+		}
+	case "up", "down":
+		if key == "up" && m.row > 0 {
+			m.row--
+		} else if key == "down" && m.row < len(m.edit)-1 {
+			m.row++
+		}
+		if m.col > len(m.edit[m.row]) {
+			m.col = len(m.edit[m.row])
+		}
+	default:
+		if r, _ := utf8.DecodeRuneInString(key); utf8.RuneLen(r) == len(key) && r >= ' ' {
+			m.edit[m.row] = append(line[:m.col], append([]rune{r}, line[m.col:]...)...)
+			m.col++
+		}
+	}
+}
+
+// updateFeedback handles a key while feedback is typed: enter regenerates
+// the candidates with it and esc drops it.
+func (m *tuiModel) updateFeedback(key string) {
+	switch key {
+	case "enter":
+		m.asking = false
+		if strings.TrimSpace(string(m.feedback)) != "" {
+			m.pending = true
+			m.status = "Regenerating..."
+		}
+	case "esc":
+		m.asking, m.feedback = false, nil
+	case "ctrl-c":
+		m.asking, m.aborted = false, true
+	case "backspace":
+		if len(m.feedback) > 0 {
+			m.feedback = m.feedback[:len(m.feedback)-1]
+		}
+	default:
+		if r, _ := utf8.DecodeRuneInString(key); utf8.RuneLen(r) == len(key) && r >= ' ' {
+			m.feedback = append(m.feedback, r)
+		}
+	}
+}
+
+// runRegenerate replaces the candidates with ones generated for the files
+// left in, when update asked for it.
+func (m *tuiModel) runRegenerate() {
+	m.pending = false
+	candidates, err := m.regenerate(m.included(), strings.TrimSpace(string(m.feedback)))
+	m.feedback = nil
+	if len(candidates) > 0 {
+		m.candidates, m.current = candidates, 0
+	}
+	m.status = ""
+	if err != nil {
+		m.status = fmt.Sprintf("%s error: %v", currentGenerator().Name(), err)
+	}
+}
+
+// diffLines is the diff of the file under the cursor.
+func (m *tuiModel) diffLines() []string {
+	if len(m.files) == 0 {
+		return nil
+	}
+	f := m.files[m.cursor]
+	lines := []string{f.String()}
+	if f.Binary {
+		return append(lines, "Binary file")
+	}
+	for _, h := range f.Hunks {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("@@ -%d,%d +%d,%d @@ %s", h.OldStart, h.OldLines, h.NewStart, h.NewLines, h.Header)))
+		lines = append(lines, h.Lines...)
+	}
+	return lines
+}
+
+// view draws the TUI as height lines of width columns: the files and the
+// diff of the selected one side by side, the message below them and a
+// status line.
+func (m *tuiModel) view(width, height int) []string {
+	msgHeight := height / 3
+	if msgHeight < 4 {
+		msgHeight = 4
+	}
+	paneHeight := height - msgHeight - 3
+	if paneHeight < 1 {
+		paneHeight = 1
+	}
+	m.page = paneHeight
+	left := width / 3
+	if left > 32 {
+		left = 32
+	}
+
+	in := len(m.included().Files)
+	lines := []string{reverse(fit(fmt.Sprintf(" smart-commit: %d of %d files in the commit", in, len(m.files)), width))}
+	diff := m.diffLines()
+	// The file list scrolls to keep the cursor in view
+	top := 0
+	if m.cursor >= paneHeight {
+		top = m.cursor - paneHeight + 1
+	}
+	for i := 0; i < paneHeight; i++ {
+		cell := ""
+		if f := top + i; f < len(m.files) {
+			mark := "[x] "
+			if m.excluded[m.files[f].Path] {
+				mark = "[ ] "
+			}
+			cell = fit(mark+m.files[f].Path, left)
+			if f == m.cursor {
+				cell = reverse(cell)
+			}
+		} else {
+			cell = fit("", left)
+		}
+		line := ""
+		if j := m.scroll + i; j < len(diff) {
+			line = colorDiffLine(fit(diff[j], width-left-3))
+		}
+		lines = append(lines, cell+" │ "+line)
+	}
+
+	title := fmt.Sprintf("Message %d/%d", m.current+1, len(m.candidates))
+	if m.editing {
+		title = "Editing (esc to finish)"
+	}
+	rule := "── " + title + " "
+	if n := width - utf8.RuneCountInString(rule); n > 0 {
+		rule += strings.Repeat("─", n)
+	}
+	lines = append(lines, rule)
+	var message []string
+	if m.editing {
+		for r, l := range m.edit {
+			text := string(l)
+			if r == m.row {
+				before, after := string(l[:m.col]), string(l[m.col:])
+				cursor := " "
+				if after != "" {
+					r, size := utf8.DecodeRuneInString(after)
+					cursor, after = string(r), after[size:]
+				}
+				text = before + reverse(cursor) + after
+			}
+			message = append(message, text)
+		}
+	} else {
+		for _, line := range strings.Split(m.message(), "\n") {
+			message = append(message, fit(line, width))
+		}
+	}
+	for i := 0; i < msgHeight; i++ {
+		if i < len(message) {
+			lines = append(lines, message[i])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+	status := tuiHelp
+	if m.status != "" {
+		status = m.status
+	}
+	if m.asking {
+		status = "What should change? " + string(m.feedback) + reverse(" ")
+	}
+	return append(lines, fit(status, width))
+}
+
+// fit cuts s to width columns or pads it with spaces to that width.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "\t", "    ")
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// reverse shows s in reverse video.
+func reverse(s string) string {
+	return "\x1b[7m" + s + "\x1b[0m"
+}
+
+// colorDiffLine colors added lines green and removed lines red.
+func colorDiffLine(s string) string {
+	switch {
+	case strings.HasPrefix(s, "+"):
+		return "\x1b[32m" + s + "\x1b[0m"
+	case strings.HasPrefix(s, "-"):
+		return "\x1b[31m" + s + "\x1b[0m"
+	case strings.HasPrefix(s, "@@"):
+		return "\x1b[36m" + s + "\x1b[0m"
+	}
+	return s
+}
+
+// parseKeys names the keys in a chunk of terminal input: "up", "pgdown",
+// "enter", "esc", "ctrl-c" and the like, or the character typed.
+func parseKeys(b []byte) []string {
+	sequences := map[string]string{
+		"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+		"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+		"[5~": "pgup", "[6~": "pgdown",
+	}
+	var keys []string
+	for len(b) > 0 {
+		switch b[0] {
+		case 0x1b:
+			matched := false
+			for seq, name := range sequences {
+				if strings.HasPrefix(string(b[1:]), seq) {
+					keys, b, matched = append(keys, name), b[1+len(seq):], true
+					break
+				}
+			}
+			if !matched {
+				keys, b = append(keys, "esc"), b[1:]
+			}
+			continue
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case '\t':
+			keys = append(keys, "tab")
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys, b = append(keys, string(r)), b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// runTUI runs m full screen until the message is confirmed or the TUI is
+// left.
+func runTUI(m *tuiModel) error {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("starting the TUI: %v", err)
+	}
+	defer term.Restore(fd, state)
+	// The alternate screen keeps the shell's scrollback as it was
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	buf := make([]byte, 64)
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print("\x1b[H" + strings.Join(m.view(width, height), "\x1b[K\r\n") + "\x1b[J")
+		if m.pending {
+			m.runRegenerate()
+			continue
+		}
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			if m.update(key); m.done || m.aborted {
+				return nil
+			}
+		}
+	}
+}
+
+// tuiReview lets the user pick, edit and confirm the commit message in the
+// TUI, starting from message and alternatives to it, and leave files out
+// of the commit. It returns the message and the files it unstaged.
+func tuiReview(message string, changes *git.ChangeSet, trailers []conventional.Trailer) (string, []string, error) {
+	defer func() { retryHistory = nil }()
+	// Each candidate is generated with the earlier ones turned down, so
+	// they differ, after the ones the author gave feedback on
+	generate := func(cs *git.ChangeSet, candidates []string, history []generator.Attempt) ([]string, error) {
+		retryHistory = history
+		for _, c := range candidates {
+			retryHistory = append(retryHistory, generator.Attempt{Message: c, Feedback: "Offer a different alternative."})
+		}
+		for len(candidates) < tuiCandidates {
+			msg, err := suggestCommitMessage(cs, "")
+			if err != nil {
+				return candidates, err
+			}
+			if msg, err = addTrailers(msg, trailers); err != nil {
+				return candidates, err
+			}
+			candidates = append(candidates, msg)
+			retryHistory = append(retryHistory, generator.Attempt{Message: msg, Feedback: "Offer a different alternative."})
+		}
+		return candidates, nil
+	}
+	fmt.Printf("Generating alternatives with %s...\n", currentGenerator().Name())
+	candidates, err := generate(changes, []string{message}, nil)
+	if err != nil {
+		fmt.Printf("%s error: %v\n", currentGenerator().Name(), err)
+	}
+	var m *tuiModel
+	m = newTUIModel(changes, candidates, func(cs *git.ChangeSet, feedback string) ([]string, error) {
+		var history []generator.Attempt
+		if feedback != "" {
+			for _, c := range m.candidates {
+				history = append(history, generator.Attempt{Message: c, Feedback: feedback})
+			}
+		}
+		return generate(cs, nil, history)
+	})
+	if err := runTUI(m); err != nil {
+		return "", nil, err
+	}
+	if m.aborted {
+		return "", nil, errReviewAborted
+	}
+
+	var excluded []string
+	for _, f := range m.files {
+		if m.excluded[f.Path] {
+			excluded = append(excluded, f.Path)
+		}
+	}
+	if len(excluded) > 0 {
+		if err := executeCommand("git", append([]string{"reset", "-q", "--"}, topPathspecs(excluded)...)...); err != nil {
+			return "", nil, fmt.Errorf("unstaging %s: %v", strings.Join(excluded, ", "), err)
+		}
+	}
+	return m.message(), excluded, nil
+}
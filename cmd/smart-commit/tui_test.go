@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"\x1b[A\x1b[B", []string{"up", "down"}},
+		{"\x1b[6~\x1bOD", []string{"pgdown", "left"}},
+		{"\x1b", []string{"esc"}},
+		{"é \r\x7f\x03", []string{"é", " ", "enter", "backspace", "ctrl-c"}},
+	}
+	for _, tt := range tests {
+		if got := parseKeys([]byte(tt.input)); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("parseKeys(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTUIModel(t *testing.T) {
+	changes := &git.ChangeSet{Files: []git.FileChange{
+		{ChangedFile: git.ChangedFile{Status: "M", Path: "api/users.go"}, Hunks: []git.Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 2, Lines: []string{" package api", "+// Users"}}}},
+		{ChangedFile: git.ChangedFile{Status: "A", Path: "notes.txt"}},
+	}}
+	var regenerated *git.ChangeSet
+	var feedback string
+	m := newTUIModel(changes, []string{"feat(api): add users", "docs: add notes"}, func(cs *git.ChangeSet, f string) ([]string, error) {
+		regenerated, feedback = cs, f
+		if f != "" {
+			return []string{"feat(api): document users\n\nWhy"}, nil
+		}
+		return []string{"feat(api): document users"}, nil
+	})
+
+	m.update("right")
+	if m.message() != "docs: add notes" {
+		t.Fatalf("after right, message = %q", m.message())
+	}
+	// Leave notes.txt out and regenerate for the rest
+	m.update("down")
+	m.update(" ")
+	m.update("r")
+	if !m.pending {
+		t.Fatal("r did not ask for new candidates")
+	}
+	m.runRegenerate()
+	if len(regenerated.Files) != 1 || regenerated.Files[0].Path != "api/users.go" || m.message() != "feat(api): document users" {
+		t.Fatalf("regenerated for %v: %q", regenerated.Files, m.message())
+	}
+	// Regenerate with feedback, or drop it with esc
+	for _, key := range []string{"f", "x", "esc"} {
+		m.update(key)
+	}
+	if m.pending || m.asking {
+		t.Fatal("esc did not drop the feedback")
+	}
+	for _, key := range []string{"f", "w", "h", "y", "x", "backspace", "enter"} {
+		m.update(key)
+	}
+	if !m.pending {
+		t.Fatal("feedback did not ask for new candidates")
+	}
+	m.runRegenerate()
+	if feedback != "why" || m.message() != "feat(api): document users\n\nWhy" {
+		t.Fatalf("regenerated with feedback %q: %q", feedback, m.message())
+	}
+	m.candidates = []string{"feat(api): document users"}
+
+	// The last file in the commit cannot be left out
+	m.update("up")
+	m.update(" ")
+	if m.excluded["api/users.go"] || !strings.Contains(m.status, "At least one file") {
+		t.Errorf("excluded the last file: %v, status %q", m.excluded, m.status)
+	}
+
+	// Edit in place: append to the subject and add a body
+	m.update("e")
+	for _, key := range []string{"right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "right", "enter", "enter", "W", "h", "y", "backspace", "o", "esc"} {
+		m.update(key)
+	}
+	if want := "feat(api): document users\n\nWho"; m.message() != want {
+		t.Errorf("edited message = %q, want %q", m.message(), want)
+	}
+
+	view := m.view(80, 12)
+	if len(view) != 12 || !strings.Contains(view[0], "1 of 2 files in the commit") || !strings.Contains(view[4], "+// Users") {
+		t.Errorf("view =\n%s", strings.Join(view, "\n"))
+	}
+	m.update("enter")
+	if !m.done {
+		t.Error("enter did not confirm")
+	}
+}
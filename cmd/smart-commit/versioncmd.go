@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runVersion implements `smart-commit version`: it shows the current and
+// the next version and what the version files hold, and with --apply
+// writes the next version into them and commits that as the release
+// commit, for `smart-commit release` to tag.
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	bump := fs.String("bump", "", "release this kind of version instead of the computed one: major, minor or patch")
+	set := fs.String("set", "", "use this version instead of the computed one, e.g. 2.0.0")
+	apply := fs.Bool("apply", false, "write the next version into the version files and commit them")
+	noCommit := fs.Bool("no-commit", false, "with --apply, leave the version files changed but uncommitted")
+	format := renderFlag(fs, "")
+	fs.Parse(args)
+	if err := checkRender(*format); err != nil {
+		return err
+	}
+
+	var next semver
+	var s releaseSummary
+	if *set != "" {
+		v, err := parseReleaseVersion(*set)
+		if err != nil {
+			return err
+		}
+		current, tag, released := latestRelease()
+		if v.Prefix == "" {
+			v.Prefix = "v"
+			if released {
+				v.Prefix = current.Prefix
+			}
+		}
+		if released {
+			s.Current = tag
+		}
+		s.Next, s.Reason = v.String(), "--set"
+		next = v
+	} else {
+		plan, err := planRelease(*bump)
+		if err != nil {
+			return err
+		}
+		if len(plan.Commits) == 0 {
+			return printReport(*format, plan.summary().report())
+		}
+		s = plan.summary()
+		next = plan.Next
+	}
+	version := strings.TrimPrefix(next.String(), "v")
+
+	files := versionFiles()
+	r := s.report()
+	if len(files) == 0 {
+		r.paragraph("No version files: list them as `version_files` in .smartcommit.yml.")
+		return printReport(*format, r)
+	}
+	versions, err := readVersionFiles(files)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	s.Files = map[string]string{}
+	for i, f := range files {
+		rows = append(rows, []string{f.Path, versions[i]})
+		s.Files[f.Path] = versions[i]
+	}
+	r.table([]string{"File", "Version"}, rows)
+	r.Data = s
+	if err := writeReport(reportStdout(*format), *format, r); err != nil {
+		return err
+	}
+	if !*apply {
+		return nil
+	}
+
+	changed, err := bumpVersionFiles(files, version, false)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Printf("The version files are at %s already.\n", version)
+		return nil
+	}
+	fmt.Printf("Set version %s in %s.\n", version, strings.Join(changed, ", "))
+	if *noCommit {
+		return nil
+	}
+	// Only the version files go into the release commit, whatever else is
+	// staged
+	message := "chore(release): " + next.String()
+	if err := gitCommit(append([]string{"-m", message, "--"}, topPathspecs(changed)...)...); err != nil {
+		return fmt.Errorf("committing the version files: %v", err)
+	}
+	fmt.Printf("Committed %q; tag it with smart-commit release.\n", message)
+	return nil
+}
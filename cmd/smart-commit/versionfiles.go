@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+// defaultVersionKeys are where well-known manifests keep the version, tried
+// in order, for version files configured with neither a key nor a pattern.
+var defaultVersionKeys = map[string][]string{
+	"package.json":   {"version"},
+	"composer.json":  {"version"},
+	"pyproject.toml": {"project.version", "tool.poetry.version"},
+	"Cargo.toml":     {"package.version"},
+}
+
+// versionFiles are the version files of the repository: the version_files
+// setting, or else those of the well-known manifests at the top of the work
+// tree that hold a version.
+func versionFiles() []config.VersionFile {
+	if files := config.Current().VersionFiles; len(files) > 0 {
+		return files
+	}
+	root := versionRoot()
+	var names []string
+	for name := range defaultVersionKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var files []config.VersionFile
+	for _, name := range names {
+		f := config.VersionFile{Path: name}
+		if data, err := os.ReadFile(filepath.Join(root, name)); err == nil {
+			if _, err := versionSpans(f, data); err == nil {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// versionRoot is the top of the work tree, which version file paths are
+// relative to.
+func versionRoot() string {
+	if out, err := executeCommandWithOutput("git", "rev-parse", "--show-toplevel"); err == nil && strings.TrimSpace(out) != "" {
+		return strings.TrimSpace(out)
+	}
+	return "."
+}
+
+// topPathspecs makes paths relative to the top of the work tree usable as
+// pathspecs from any directory.
+func topPathspecs(paths []string) []string {
+	specs := make([]string, len(paths))
+	for i, p := range paths {
+		specs[i] = ":(top)" + p
+	}
+	return specs
+}
+
+// readVersionFiles returns the version each configured version file holds,
+// in order.
+func readVersionFiles(files []config.VersionFile) ([]string, error) {
+	root := versionRoot()
+	var versions []string
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(root, f.Path))
+		if err != nil {
+			return nil, fmt.Errorf("version file %s: %v", f.Path, err)
+		}
+		spans, err := versionSpans(f, data)
+		if err != nil {
+			return nil, fmt.Errorf("version file %s: %v", f.Path, err)
+		}
+		versions = append(versions, string(data[spans[0][0]:spans[0][1]]))
+	}
+	return versions, nil
+}
+
+// bumpVersionFiles writes version into the configured version files, with
+// paths relative to the top of the work tree, and returns the ones it
+// changed. With dryRun set nothing is written.
+func bumpVersionFiles(files []config.VersionFile, version string, dryRun bool) ([]string, error) {
+	root := versionRoot()
+	var changed []string
+	for _, f := range files {
+		path := filepath.Join(root, f.Path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("version file %s: %v", f.Path, err)
+		}
+		updated, err := setFileVersion(f, data, version)
+		if err != nil {
+			return nil, fmt.Errorf("version file %s: %v", f.Path, err)
+		}
+		if bytes.Equal(updated, data) {
+			continue
+		}
+		if !dryRun {
+			if err := os.WriteFile(path, updated, 0644); err != nil {
+				return nil, fmt.Errorf("writing %s: %v", f.Path, err)
+			}
+		}
+		changed = append(changed, f.Path)
+	}
+	return changed, nil
+}
+
+// setFileVersion returns data, the content of version file f, with version
+// in place of the one it holds. Everything else is kept as it was.
+func setFileVersion(f config.VersionFile, data []byte, version string) ([]byte, error) {
+	spans, err := versionSpans(f, data)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	last := 0
+	for _, s := range spans {
+		b.Write(data[last:s[0]])
+		b.WriteString(version)
+		last = s[1]
+	}
+	b.Write(data[last:])
+	return b.Bytes(), nil
+}
+
+// versionSpans locates the version in data, the content of version file f:
+// the byte ranges to replace, at least one.
+func versionSpans(f config.VersionFile, data []byte) ([][2]int, error) {
+	if f.Pattern != "" {
+		return patternSpans(f.Pattern, data)
+	}
+	keys := defaultVersionKeys[path.Base(filepath.ToSlash(f.Path))]
+	if f.Key != "" {
+		keys = []string{f.Key}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("needs a pattern or a key")
+	}
+	find := tomlValueSpan
+	switch strings.ToLower(path.Ext(f.Path)) {
+	case ".json":
+		find = jsonValueSpan
+	case ".toml":
+	default:
+		return nil, fmt.Errorf("a key can only be used with JSON and TOML files; use a pattern")
+	}
+	for _, key := range keys {
+		if start, end, ok := find(data, strings.Split(key, ".")); ok {
+			return [][2]int{{start, end}}, nil
+		}
+	}
+	return nil, fmt.Errorf("no string value at %s", strings.Join(keys, " or "))
+}
+
+// patternSpans returns the first group of every match of pattern in data.
+func patternSpans(pattern string, data []byte) ([][2]int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %v", pattern, err)
+	}
+	if re.NumSubexp() < 1 {
+		return nil, fmt.Errorf("pattern %q has no group for the version", pattern)
+	}
+	var spans [][2]int
+	for _, m := range re.FindAllSubmatchIndex(data, -1) {
+		if m[2] >= 0 {
+			spans = append(spans, [2]int{m[2], m[3]})
+		}
+	}
+	if len(spans) == 0 {
+		return nil, fmt.Errorf("pattern %q matches nothing", pattern)
+	}
+	return spans, nil
+}
+
+// jsonValueSpan returns where the string at key, a path of object keys, is
+// in the JSON document data, quotes excluded.
+func jsonValueSpan(data []byte, key []string) (start, end int, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	type frame struct {
+		object    bool
+		key       string
+		expectKey bool
+	}
+	var stack []frame
+	// matches reports whether the value being read is at key
+	matches := func() bool {
+		if len(stack) != len(key) {
+			return false
+		}
+		for i, f := range stack {
+			if !f.object || f.key != key[i] {
+				return false
+			}
+		}
+		return true
+	}
+	// valueDone readies the enclosing object for its next key
+	valueDone := func() {
+		if n := len(stack); n > 0 && stack[n-1].object {
+			stack[n-1].expectKey = true
+		}
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, frame{object: true, expectKey: true})
+			case '[':
+				stack = append(stack, frame{})
+			default:
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+		case string:
+			if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].expectKey {
+				stack[n-1].key, stack[n-1].expectKey = t, false
+				continue
+			}
+			if matches() {
+				// The offset is past the closing quote; versions hold no
+				// quotes, so the opening one is the last before it
+				end := int(dec.InputOffset()) - 1
+				return bytes.LastIndexByte(data[:end], '"') + 1, end, true
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
+
+var (
+	tomlTable = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?\s*$`)
+	tomlValue = regexp.MustCompile(`^\s*([A-Za-z0-9_.-]+)\s*=\s*["']([^"']*)["']`)
+)
+
+// tomlValueSpan returns where the string at key, a dotted path of tables
+// and a key, is in the TOML document data, quotes excluded. It reads plain
+// [table] headers and bare or dotted keys, which is how manifests keep
+// their version.
+func tomlValueSpan(data []byte, key []string) (start, end int, ok bool) {
+	want := strings.Join(key, ".")
+	table, offset := "", 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if m := tomlTable.FindSubmatch(line); m != nil {
+			table = string(m[1])
+		} else if strings.HasPrefix(strings.TrimSpace(string(line)), "[[") {
+			// Arrays of tables never hold the version
+			table = "\x00"
+		} else if m := tomlValue.FindSubmatchIndex(line); m != nil {
+			name := string(line[m[2]:m[3]])
+			if table != "" {
+				name = table + "." + name
+			}
+			if name == want {
+				return offset + m[4], offset + m[5], true
+			}
+		}
+		offset += len(line)
+	}
+	return 0, 0, false
+}
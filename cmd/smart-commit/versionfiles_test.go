@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/chalfel/smart-commit/config"
+)
+
+func TestSetFileVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    config.VersionFile
+		content string
+		want    string
+		wantErr bool
+	}{
+		{"go constant", config.VersionFile{Path: "version.go", Pattern: `Version = "([^"]+)"`}, "package main\n\nconst Version = \"1.3.2\"\n", "package main\n\nconst Version = \"1.4.0\"\n", false},
+		{"every match", config.VersionFile{Path: "Dockerfile", Pattern: `[=:](\d+\.\d+\.\d+)`}, "version=1.3.2\nimage:1.3.2\n", "version=1.4.0\nimage:1.4.0\n", false},
+		{"no match", config.VersionFile{Path: "app.yml", Pattern: `version: (\S+)`}, "name: demo\n", "", true},
+		{"no group", config.VersionFile{Path: "app.yml", Pattern: `version: \S+`}, "version: 1.3.2\n", "", true},
+		{"package.json", config.VersionFile{Path: "web/package.json"},
+			"{\n  \"name\": \"web\",\n  \"dependencies\": {\"version\": \"9.9.9\"},\n  \"version\": \"1.3.2\"\n}\n",
+			"{\n  \"name\": \"web\",\n  \"dependencies\": {\"version\": \"9.9.9\"},\n  \"version\": \"1.4.0\"\n}\n", false},
+		{"JSON key", config.VersionFile{Path: "manifest.json", Key: "app.version"},
+			"{\"files\": [\"a\", {\"version\": \"0\"}], \"app\": {\"version\":\"1.3.2\"}}",
+			"{\"files\": [\"a\", {\"version\": \"0\"}], \"app\": {\"version\":\"1.4.0\"}}", false},
+		{"pyproject.toml with poetry", config.VersionFile{Path: "pyproject.toml"},
+			"[tool.black]\nversion = \"23\"\n\n[tool.poetry] # metadata\nname = \"demo\"\nversion = \"1.3.2\"\n",
+			"[tool.black]\nversion = \"23\"\n\n[tool.poetry] # metadata\nname = \"demo\"\nversion = \"1.4.0\"\n", false},
+		{"Cargo.toml", config.VersionFile{Path: "Cargo.toml"},
+			"[package]\nname = \"demo\"\nversion = \"1.3.2\"\n\n[[bin]]\nversion = \"0\"\n",
+			"[package]\nname = \"demo\"\nversion = \"1.4.0\"\n\n[[bin]]\nversion = \"0\"\n", false},
+		{"missing key", config.VersionFile{Path: "package.json"}, "{\"name\": \"web\"}", "", true},
+		{"key in another format", config.VersionFile{Path: "setup.cfg", Key: "metadata.version"}, "[metadata]\nversion = 1.3.2\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setFileVersion(tt.file, []byte(tt.content), "1.4.0")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setFileVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("setFileVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// webhookPushEvent is the part of a GitHub push event payload the bot uses.
+type webhookPushEvent struct {
+	Ref        string `json:"ref"`
+	Deleted    bool   `json:"deleted"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	HeadCommit *struct {
+		ID string `json:"id"`
+	} `json:"head_commit"`
+	Commits []struct {
+		ID       string   `json:"id"`
+		Message  string   `json:"message"`
+		Added    []string `json:"added"`
+		Removed  []string `json:"removed"`
+		Modified []string `json:"modified"`
+	} `json:"commits"`
+}
+
+// webhookPullRequestEvent is the part of a GitHub pull_request event payload
+// the bot uses.
+type webhookPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title string `json:"title"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// webhookHandler receives GitHub webhooks and answers pushes and pull
+// requests with AI summaries and changelog previews posted as comments.
+type webhookHandler struct {
+	secret  string
+	token   string
+	tenants []*tenant
+}
+
+// serveWebhook listens on addr for GitHub webhook deliveries. With tenants,
+// each delivery must be signed with one's token instead of secret, come from
+// one of its repositories and is commented on with its GitHub token; without
+// either, deliveries cannot be authenticated and the bot does not start.
+func serveWebhook(addr, secret string, tenants []*tenant) error {
+	if secret == "" && tenants == nil {
+		return fmt.Errorf("--webhook needs --webhook-secret (or SMART_COMMIT_WEBHOOK_SECRET) or --tenants, so forged deliveries are refused")
+	}
+	for _, t := range tenants {
+		if len(t.Repositories) == 0 || t.githubToken == "" {
+			return fmt.Errorf("tenant %s: --webhook needs repositories and a github_token_env for every tenant", t.Name)
+		}
+	}
+	var token string
+	if tenants == nil {
+		if token = githubToken(); token == "" {
+			return fmt.Errorf("a GitHub token (GITHUB_TOKEN or GH_TOKEN) is required to post comments")
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", &webhookHandler{secret: secret, token: token, tenants: tenants})
+	fmt.Fprintf(os.Stderr, "Receiving GitHub webhooks on %s/webhook\n", addr)
+	return newHTTPServer(addr, mux).ListenAndServe()
+}
+
+// Timeouts of the HTTP listeners, so slow or idle clients cannot hold
+// connections open forever. Webhook payloads and metrics scrapes are small.
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpReadTimeout       = 30 * time.Second
+	httpIdleTimeout       = 2 * time.Minute
+)
+
+// newHTTPServer is an http.Server for handler on addr with the timeouts
+// set.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+}
+
+// ServeHTTP handles a delivery, recording it in the server metrics by its
+// event.
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.serve(rec, r)
+	event := r.Header.Get("X-GitHub-Event")
+	if event != "ping" && event != "push" && event != "pull_request" {
+		event = "other"
+	}
+	metrics.observeRequest("webhook", event, strconv.Itoa(rec.status), time.Since(start))
+}
+
+// serve authenticates a delivery and accepts it. GitHub gives up on
+// deliveries after ten seconds, so the summary and comment are made in the
+// background once the delivery is accepted.
+func (h *webhookHandler) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "reading body", http.StatusBadRequest)
+		return
+	}
+	signature := r.Header.Get("X-Hub-Signature-256")
+	var t *tenant
+	if h.tenants != nil {
+		// The tenant is the one whose token signed the delivery
+		for _, candidate := range h.tenants {
+			if validWebhookSignature(candidate.token, signature, payload) {
+				t = candidate
+				break
+			}
+		}
+		if t == nil {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	} else if !validWebhookSignature(h.secret, signature, payload) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, token := context.Context(interrupted), h.token
+	if t != nil {
+		ctx, token = withTenant(interrupted, t), t.githubToken
+	}
+	event := r.Header.Get("X-GitHub-Event")
+	job, err := h.job(ctx, token, event, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if job == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if t != nil {
+		// A tenant's token must not reach other teams' repositories
+		var delivery struct {
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+		}
+		json.Unmarshal(payload, &delivery)
+		if !t.allowsRepository(delivery.Repository.FullName) {
+			http.Error(w, fmt.Sprintf("%s is not a repository of tenant %s", delivery.Repository.FullName, t.Name), http.StatusForbidden)
+			return
+		}
+		if err := t.admit(); err != nil {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		if err := job(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error handling %s event: %v\n", event, err)
+		}
+	}()
+}
+
+// job returns what an authenticated delivery calls for, commenting with token
+// and asking the provider of ctx, or nil when the event needs no comment.
+func (h *webhookHandler) job(ctx context.Context, token, event string, payload []byte) (func() error, error) {
+	switch event {
+	case "push":
+		var e webhookPushEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("invalid payload")
+		}
+		if e.Deleted || e.HeadCommit == nil || len(e.Commits) == 0 {
+			return nil, nil
+		}
+		return func() error { return handlePush(ctx, token, e) }, nil
+	case "pull_request":
+		var e webhookPullRequestEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, fmt.Errorf("invalid payload")
+		}
+		if e.Action != "opened" && e.Action != "synchronize" && e.Action != "reopened" {
+			return nil, nil
+		}
+		return func() error { return handlePullRequest(ctx, token, e) }, nil
+	}
+	// Pings and other events are acknowledged and ignored
+	return nil, nil
+}
+
+// handlePush comments on the head commit of a push with a summary of the
+// pushed commits and a changelog preview.
+func handlePush(ctx context.Context, token string, e webhookPushEvent) error {
+	var commits []git.Commit
+	var list strings.Builder
+	for _, pc := range e.Commits {
+		commits = append(commits, commitFromMessage(pc.ID, pc.Message))
+		fmt.Fprintf(&list, "- %s (added: %s; modified: %s; removed: %s)\n",
+			strings.SplitN(pc.Message, "\n", 2)[0],
+			strings.Join(pc.Added, ", "), strings.Join(pc.Modified, ", "), strings.Join(pc.Removed, ", "))
+	}
+
+	prompt := generator.RenderPrompt(generator.PromptPushSummary, map[string]string{"Ref": e.Ref, "Commits": list.String()})
+	body := webhookComment(ctx, prompt, commits)
+	path := fmt.Sprintf("/repos/%s/commits/%s/comments", e.Repository.FullName, e.HeadCommit.ID)
+	return githubAPI("POST", path, token, map[string]string{"body": body}, nil)
+}
+
+// handlePullRequest comments on newly opened or updated pull requests with a
+// summary of their commits and a changelog preview.
+func handlePullRequest(ctx context.Context, token string, e webhookPullRequestEvent) error {
+	commits, err := pullRequestCommits(e.Repository.FullName, e.Number, token)
+	if err != nil {
+		return err
+	}
+
+	var list strings.Builder
+	for _, c := range commits {
+		fmt.Fprintf(&list, "- %s\n", c.Subject)
+	}
+
+	prompt := generator.RenderPrompt(generator.PromptPullSummary, map[string]string{"Title": e.PullRequest.Title, "Commits": list.String()})
+	body := webhookComment(ctx, prompt, commits)
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", e.Repository.FullName, e.Number)
+	return githubAPI("POST", path, token, map[string]string{"body": body}, nil)
+}
+
+// webhookComment renders the comment body: an AI summary when the provider
+// of ctx answers, followed by the changelog preview.
+func webhookComment(ctx context.Context, prompt string, commits []git.Commit) string {
+	var b strings.Builder
+	if summary, err := askModelContext(ctx, prompt); err == nil && summary != "" {
+		b.WriteString("## Summary\n\n")
+		b.WriteString(summary)
+		b.WriteString("\n\n")
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", generatorFor(ctx).Name(), err)
+	}
+	b.WriteString(renderChangelog("Changelog preview", commits))
+	b.WriteString("\n<sub>Posted by smart-commit</sub>\n")
+	return b.String()
+}
+
+// validWebhookSignature checks GitHub's X-Hub-Signature-256 header. Without a
+// secret no delivery is valid.
+func validWebhookSignature(secret, header string, payload []byte) bool {
+	if secret == "" {
+		return false
+	}
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	sign := func(secret, payload string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(payload))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	deleted := `{"ref": "refs/heads/old", "deleted": true}`
+	closed := `{"action": "closed", "number": 3}`
+
+	tests := []struct {
+		name, secret, event, payload, signature string
+		want                                    int
+	}{
+		{"ping", "s3cret", "ping", `{}`, sign("s3cret", `{}`), http.StatusNoContent},
+		{"deleted branch", "s3cret", "push", deleted, sign("s3cret", deleted), http.StatusNoContent},
+		{"closed pull request", "s3cret", "pull_request", closed, sign("s3cret", closed), http.StatusNoContent},
+		{"malformed", "s3cret", "push", `{`, sign("s3cret", `{`), http.StatusBadRequest},
+		{"unsigned", "s3cret", "push", deleted, "", http.StatusUnauthorized},
+		{"wrong secret", "s3cret", "push", deleted, sign("other", deleted), http.StatusUnauthorized},
+		{"no secret", "", "push", deleted, sign("", deleted), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		h := &webhookHandler{secret: tt.secret}
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.payload))
+		req.Header.Set("X-GitHub-Event", tt.event)
+		req.Header.Set("X-Hub-Signature-256", tt.signature)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	if err := serveWebhook("127.0.0.1:0", "", nil); err == nil || !strings.Contains(err.Error(), "--webhook-secret") {
+		t.Errorf("serveWebhook without a secret = %v, want a refusal", err)
+	}
+
+	// A tenant's deliveries are answered only for its own repositories
+	payments := &tenant{Name: "payments", token: "p-token", Repositories: []string{"acme/payments-*"}, limiter: newRateLimiter(0)}
+	foreign := `{"ref": "refs/heads/main", "repository": {"full_name": "acme/search"}, "head_commit": {"id": "abc"}, "commits": [{"id": "abc", "message": "fix: x"}]}`
+	h := &webhookHandler{tenants: []*tenant{payments}}
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(foreign))
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", sign("p-token", foreign))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("delivery from another team's repository: status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if err := serveWebhook("127.0.0.1:0", "", []*tenant{{Name: "search", token: "s-token"}}); err == nil || !strings.Contains(err.Error(), "github_token_env") {
+		t.Errorf("serveWebhook with a tenant without repositories = %v, want a refusal", err)
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	srv := newHTTPServer(":0", http.NotFoundHandler())
+	if srv.ReadHeaderTimeout <= 0 || srv.ReadTimeout <= 0 || srv.IdleTimeout <= 0 {
+		t.Errorf("newHTTPServer() timeouts = %v, %v, %v, want all set", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.IdleTimeout)
+	}
+}
@@ -0,0 +1,642 @@
+// Package config reads and writes smart-commit's settings: the global
+// configuration file and the repository's .smartcommit.yml.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// RepoFile is the per-repository configuration file at the top of the
+// work tree.
+const RepoFile = ".smartcommit.yml"
+
+// TicketPlacements are the values of the branch_ticket setting.
+var TicketPlacements = []string{"footer", "subject", "off"}
+
+// EmojiPlacements are the values of the emoji_placement setting.
+var EmojiPlacements = []string{"before", "after"}
+
+// Config holds the settings read from the configuration files. Zero values
+// mean "not set": the built-in default applies.
+type Config struct {
+	Provider         string            `yaml:"provider,omitempty"`
+	Model            string            `yaml:"model,omitempty"`
+	Types            []string          `yaml:"types,omitempty"`
+	Scopes           []string          `yaml:"scopes,omitempty"`
+	MaxSubjectLength int               `yaml:"max_subject_length,omitempty"`
+	SubjectCase      string            `yaml:"subject_case,omitempty"`
+	SubjectASCII     *bool             `yaml:"subject_ascii,omitempty"`
+	ScopeMap         map[string]string `yaml:"scope_map,omitempty"`
+	AllowNewScopes   *bool             `yaml:"allow_new_scopes,omitempty"`
+	Push             *bool             `yaml:"push,omitempty"`
+	Remote           string            `yaml:"remote,omitempty"`
+	Rebase           *bool             `yaml:"rebase,omitempty"`
+	Base             string            `yaml:"base,omitempty"`
+	TestCmd          string            `yaml:"test_cmd,omitempty"`
+	Checks           []string          `yaml:"checks,omitempty"`
+	PromptVersion    int               `yaml:"prompt_version,omitempty"`
+	PromptTemplate   string            `yaml:"prompt_template,omitempty"`
+	Language         string            `yaml:"language,omitempty"`
+	Body             *bool             `yaml:"body,omitempty"`
+	BranchTicket     string            `yaml:"branch_ticket,omitempty"`
+	TicketPattern    string            `yaml:"ticket_pattern,omitempty"`
+	TicketTemplate   string            `yaml:"ticket_template,omitempty"`
+	Footer           *bool             `yaml:"footer,omitempty"`
+	MaxDiff          *int              `yaml:"max_diff,omitempty"`
+	StyleHistory     int               `yaml:"style_history,omitempty"`
+	Canary           string            `yaml:"canary,omitempty"`
+	Trailers         []string          `yaml:"trailers,omitempty"`
+	MaxFileSize      *int              `yaml:"max_file_size,omitempty"`
+	ScanIgnore       []string          `yaml:"scan_ignore,omitempty"`
+	SensitiveFiles   []string          `yaml:"sensitive_files,omitempty"`
+	JunkFiles        []string          `yaml:"junk_files"`
+	HookAsync        *bool             `yaml:"hook_async,omitempty"`
+	Emoji            *bool             `yaml:"emoji,omitempty"`
+	EmojiMap         map[string]string `yaml:"emoji_map,omitempty"`
+	EmojiPlacement   string            `yaml:"emoji_placement,omitempty"`
+	MaxLatency       int               `yaml:"max_latency,omitempty"`
+	FallbackProvider string            `yaml:"fallback_provider,omitempty"`
+	FallbackModel    string            `yaml:"fallback_model,omitempty"`
+	GitHubHost       string            `yaml:"github_host,omitempty"`
+	Timeout          *int              `yaml:"timeout,omitempty"`
+	Retries          *int              `yaml:"retries,omitempty"`
+	BranchRules      []BranchRule      `yaml:"branch_rules,omitempty"`
+	Signoff          *bool             `yaml:"signoff,omitempty"`
+	GPGSign          *bool             `yaml:"gpg_sign,omitempty"`
+	SigningKey       string            `yaml:"signing_key,omitempty"`
+	VersionFiles     []VersionFile     `yaml:"version_files,omitempty"`
+	APIChanges       *bool             `yaml:"api_changes,omitempty"`
+	InfraChanges     *bool             `yaml:"infra_changes,omitempty"`
+	ConfigChanges    *bool             `yaml:"config_changes,omitempty"`
+	FileNotes        *bool             `yaml:"file_notes,omitempty"`
+	Provenance       *bool             `yaml:"provenance,omitempty"`
+	ScopeMemory      *bool             `yaml:"scope_memory,omitempty"`
+}
+
+// BranchRule changes the commit flow on branches matching Pattern, a glob
+// such as hotfix/* (release/** also matches nested names). The first
+// matching rule applies. Branch rules are edited in the file; `config set`
+// does not handle them.
+type BranchRule struct {
+	Pattern string `yaml:"pattern"`
+	// Type is the commit type used on matching branches, as with --type.
+	Type string `yaml:"type,omitempty"`
+	// RequireTicket refuses commit messages that reference no ticket.
+	RequireTicket bool `yaml:"require_ticket,omitempty"`
+	// NoForcePush keeps --rebase from rewriting the pushed branch, which
+	// would need a force push.
+	NoForcePush bool `yaml:"no_force_push,omitempty"`
+	// Reminder is shown after committing, e.g. to update the changelog.
+	Reminder string `yaml:"reminder,omitempty"`
+}
+
+// VersionFile is a file that records the project's version, such as a
+// version.go or package.json, updated when a release is cut. Version files
+// are edited in the file; `config set` does not handle them.
+type VersionFile struct {
+	Path string `yaml:"path"`
+	// Pattern is a regular expression whose first group matches the
+	// version, without a v.
+	Pattern string `yaml:"pattern,omitempty"`
+	// Key is the dotted path to the version in a JSON or TOML file, such
+	// as tool.poetry.version. package.json, pyproject.toml and Cargo.toml
+	// need neither Key nor Pattern.
+	Key string `yaml:"key,omitempty"`
+}
+
+// Key describes a setting by its key in the file. Kind is string, int,
+// bool, list or map.
+type Key struct {
+	Name, Kind, Help string
+}
+
+// Keys describes the settings `smart-commit config` can get and set,
+// by their key in the file.
+var Keys = []Key{
+	{"provider", "string", "AI provider: " + strings.Join(generator.Names, ", ")},
+	{"model", "string", "model to use with the provider"},
+	{"types", "list", "allowed conventional commit types"},
+	{"scopes", "list", "allowed commit scopes"},
+	{"max_subject_length", "int", "longest subject line allowed"},
+	{"subject_case", "string", "case of the description, as in commitlint: lower-case, sentence-case, ..."},
+	{"subject_ascii", "bool", "only allow ASCII characters in the subject, for servers that reject others"},
+	{"scope_map", "map", "scopes for paths, e.g. services/auth=auth"},
+	{"allow_new_scopes", "bool", "let the model use scopes outside the vocabulary"},
+	{"push", "bool", "push after committing"},
+	{"remote", "string", "remote to push to"},
+	{"rebase", "bool", "rebase onto the base branch before pushing"},
+	{"base", "string", "base branch for rebase"},
+	{"test_cmd", "string", "command that must pass before committing"},
+	{"checks", "list", "commands that must pass against the staged tree before a message is generated"},
+	{"prompt_version", "int", "built-in commit-message prompt version"},
+	{"prompt_template", "string", "custom commit-message prompt (text/template)"},
+	{"language", "string", "language to write commit message descriptions in, as a name or a code such as pt-BR"},
+	{"body", "bool", "add a body explaining why to generated messages"},
+	{"footer", "bool", "add a BREAKING CHANGE footer to generated messages"},
+	{"branch_ticket", "string", "where to reference the branch's ticket: footer, subject or off"},
+	{"ticket_pattern", "string", "regular expression finding the ticket in branch names"},
+	{"ticket_template", "string", "how the branch's ticket is written (text/template)"},
+	{"max_diff", "int", "bytes of diff to send to the model"},
+	{"style_history", "int", "recent commits whose style generated messages imitate; 0 turns it off"},
+	{"canary", "string", "candidate configuration for canary mode"},
+	{"trailers", "list", "trailers added to every commit message"},
+	{"max_file_size", "int", "largest file in bytes that may be committed; 0 allows any size"},
+	{"scan_ignore", "list", "paths the secret and file size scan skips, e.g. testdata/**"},
+	{"junk_files", "list", "editor and OS files never staged automatically; empty stages them like any file"},
+	{"hook_async", "bool", "have the prepare-commit-msg hook write a placeholder at once and the generated message when it arrives"},
+	{"sensitive_files", "list", "more paths never staged or committed, on top of .env*, id_rsa* and other credential files"},
+	{"emoji", "bool", "start subjects with the gitmoji for their type"},
+	{"emoji_map", "map", "emoji for each type, on top of the gitmoji defaults, e.g. deps=⬆️"},
+	{"emoji_placement", "string", "where the emoji goes: before the type or after the colon"},
+	{"max_latency", "int", "seconds to wait for the provider before racing fallback_provider; 0 waits"},
+	{"fallback_provider", "string", "cheaper or local provider raced after max_latency (default: ollama)"},
+	{"fallback_model", "string", "model to use with fallback_provider"},
+	{"github_host", "string", "GitHub Enterprise Server host for API calls, gh and Copilot (default: detected from the remote); global configuration only"},
+	{"timeout", "int", "seconds to wait for each provider request; 0 waits indefinitely"},
+	{"retries", "int", "times to retry a provider request after a network error, timeout, rate limit or server error"},
+	{"signoff", "bool", "add a Signed-off-by trailer to every commit (git commit --signoff)"},
+	{"gpg_sign", "bool", "sign every commit (git commit --gpg-sign); commit.gpgsign is honored either way"},
+	{"signing_key", "string", "key to sign commits with, instead of user.signingkey"},
+	{"api_changes", "bool", "list changes to the exported API of Go packages and to proto and OpenAPI contracts in the body and flag breaking ones"},
+	{"infra_changes", "bool", "list the Terraform resources and Kubernetes objects a change creates, updates or destroys in the body"},
+	{"config_changes", "bool", "list the settings a change makes to YAML, TOML and .env config files in the body, with secrets redacted"},
+	{"file_notes", "bool", "experimental: summarize each changed file in a git note on the commit and in --output json"},
+	{"provenance", "bool", "add AI-Model and AI-Prompt-Hash trailers to generated messages"},
+	{"scope_memory", "bool", "remember the last messages committed per scope and show them to the model, so commits to the same area read as a series"},
+}
+
+var (
+	configOnce   sync.Once
+	activeConfig atomic.Pointer[Config]
+)
+
+// Current returns the merged configuration, loading it on first use.
+// A file that cannot be read is reported and skipped.
+func Current() *Config {
+	configOnce.Do(func() {
+		c := &Config{}
+		for _, path := range Paths() {
+			f, err := ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", path, err)
+				continue
+			}
+			c.Merge(f.trusted(path))
+		}
+		activeConfig.Store(c)
+	})
+	return activeConfig.Load()
+}
+
+// Load reads and merges the configuration files without making them
+// current. Unlike the first load, a file that cannot be read is an error.
+func Load() (*Config, error) {
+	return LoadDir("")
+}
+
+// LoadDir is Load for the repository in dir rather than the current
+// directory.
+func LoadDir(dir string) (*Config, error) {
+	c := &Config{}
+	for _, path := range pathsIn(dir) {
+		f, err := ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		c.Merge(f.trusted(path))
+	}
+	return c, nil
+}
+
+// Use makes c the current configuration, as when the files change while a
+// server runs.
+func Use(c *Config) {
+	Current()
+	activeConfig.Store(c)
+}
+
+// Paths lists the configuration files in increasing precedence: the
+// global file, then the repository's.
+func Paths() []string {
+	return pathsIn("")
+}
+
+// pathsIn lists the configuration files for the repository in dir.
+func pathsIn(dir string) []string {
+	var paths []string
+	if global, err := GlobalPath(); err == nil {
+		paths = append(paths, global)
+	}
+	if repo, err := repoPathIn(dir); err == nil {
+		paths = append(paths, repo)
+	}
+	return paths
+}
+
+// GlobalPath is the user's configuration file.
+func GlobalPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "smart-commit", "config.yml"), nil
+}
+
+// RepoPath is the current repository's configuration file.
+func RepoPath() (string, error) {
+	return repoPathIn("")
+}
+
+// repoPathIn is the configuration file of the repository in dir.
+func repoPathIn(dir string) (string, error) {
+	root, err := git.Repo{Dir: dir}.Output("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(strings.TrimSpace(root), RepoFile), nil
+}
+
+// ReadFile parses one configuration file. A missing file is empty.
+func ReadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GlobalOnly reports whether key may only be set in the global file or the
+// environment. A repository's file is as trustworthy as whoever it was
+// cloned from, so it cannot choose where credentials are sent.
+func GlobalOnly(key string) bool {
+	return key == "github_host"
+}
+
+// trusted returns c, read from path, without the settings only the global
+// file may make.
+func (c *Config) trusted(path string) *Config {
+	if global, err := GlobalPath(); err == nil && path == global {
+		return c
+	}
+	if c.GitHubHost != "" {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring github_host in %s; set it in the global configuration or SMART_COMMIT_GITHUB_HOST\n", path)
+		c.GitHubHost = ""
+	}
+	return c
+}
+
+// Merge overrides c with every setting present in o.
+func (c *Config) Merge(o *Config) {
+	if o.Provider != "" {
+		c.Provider = o.Provider
+	}
+	if o.Model != "" {
+		c.Model = o.Model
+	}
+	if len(o.Types) > 0 {
+		c.Types = o.Types
+	}
+	if len(o.Scopes) > 0 {
+		c.Scopes = o.Scopes
+	}
+	if o.MaxSubjectLength != 0 {
+		c.MaxSubjectLength = o.MaxSubjectLength
+	}
+	if o.SubjectCase != "" {
+		c.SubjectCase = o.SubjectCase
+	}
+	if o.SubjectASCII != nil {
+		c.SubjectASCII = o.SubjectASCII
+	}
+	if len(o.ScopeMap) > 0 {
+		c.ScopeMap = o.ScopeMap
+	}
+	if o.AllowNewScopes != nil {
+		c.AllowNewScopes = o.AllowNewScopes
+	}
+	if o.Push != nil {
+		c.Push = o.Push
+	}
+	if o.Remote != "" {
+		c.Remote = o.Remote
+	}
+	if o.Rebase != nil {
+		c.Rebase = o.Rebase
+	}
+	if o.Base != "" {
+		c.Base = o.Base
+	}
+	if o.TestCmd != "" {
+		c.TestCmd = o.TestCmd
+	}
+	if len(o.Checks) > 0 {
+		c.Checks = o.Checks
+	}
+	if o.PromptVersion != 0 {
+		c.PromptVersion = o.PromptVersion
+	}
+	if o.PromptTemplate != "" {
+		c.PromptTemplate = o.PromptTemplate
+	}
+	if o.Language != "" {
+		c.Language = o.Language
+	}
+	if o.Body != nil {
+		c.Body = o.Body
+	}
+	if o.Footer != nil {
+		c.Footer = o.Footer
+	}
+	if o.BranchTicket != "" {
+		c.BranchTicket = o.BranchTicket
+	}
+	if o.TicketPattern != "" {
+		c.TicketPattern = o.TicketPattern
+	}
+	if o.TicketTemplate != "" {
+		c.TicketTemplate = o.TicketTemplate
+	}
+	if o.MaxDiff != nil {
+		c.MaxDiff = o.MaxDiff
+	}
+	if o.StyleHistory != 0 {
+		c.StyleHistory = o.StyleHistory
+	}
+	if o.Canary != "" {
+		c.Canary = o.Canary
+	}
+	if len(o.Trailers) > 0 {
+		c.Trailers = o.Trailers
+	}
+	if o.MaxFileSize != nil {
+		c.MaxFileSize = o.MaxFileSize
+	}
+	if len(o.ScanIgnore) > 0 {
+		c.ScanIgnore = o.ScanIgnore
+	}
+	if len(o.SensitiveFiles) > 0 {
+		c.SensitiveFiles = o.SensitiveFiles
+	}
+	if o.JunkFiles != nil {
+		c.JunkFiles = o.JunkFiles
+	}
+	if o.HookAsync != nil {
+		c.HookAsync = o.HookAsync
+	}
+	if o.Emoji != nil {
+		c.Emoji = o.Emoji
+	}
+	if len(o.EmojiMap) > 0 {
+		c.EmojiMap = o.EmojiMap
+	}
+	if o.EmojiPlacement != "" {
+		c.EmojiPlacement = o.EmojiPlacement
+	}
+	if o.MaxLatency != 0 {
+		c.MaxLatency = o.MaxLatency
+	}
+	if o.FallbackProvider != "" {
+		c.FallbackProvider = o.FallbackProvider
+	}
+	if o.FallbackModel != "" {
+		c.FallbackModel = o.FallbackModel
+	}
+	if o.GitHubHost != "" {
+		c.GitHubHost = o.GitHubHost
+	}
+	if o.Timeout != nil {
+		c.Timeout = o.Timeout
+	}
+	if o.Retries != nil {
+		c.Retries = o.Retries
+	}
+	if len(o.BranchRules) > 0 {
+		c.BranchRules = o.BranchRules
+	}
+	if o.Signoff != nil {
+		c.Signoff = o.Signoff
+	}
+	if o.GPGSign != nil {
+		c.GPGSign = o.GPGSign
+	}
+	if o.SigningKey != "" {
+		c.SigningKey = o.SigningKey
+	}
+	if len(o.VersionFiles) > 0 {
+		c.VersionFiles = o.VersionFiles
+	}
+	if o.APIChanges != nil {
+		c.APIChanges = o.APIChanges
+	}
+	if o.InfraChanges != nil {
+		c.InfraChanges = o.InfraChanges
+	}
+	if o.ConfigChanges != nil {
+		c.ConfigChanges = o.ConfigChanges
+	}
+	if o.FileNotes != nil {
+		c.FileNotes = o.FileNotes
+	}
+	if o.Provenance != nil {
+		c.Provenance = o.Provenance
+	}
+	if o.ScopeMemory != nil {
+		c.ScopeMemory = o.ScopeMemory
+	}
+}
+
+// Setting returns the value of an environment variable, or the configured
+// value when the variable is unset. Flags use it for their defaults, so the
+// order is flag, environment, repository file, global file.
+func Setting(envName, configured string) string {
+	if v := os.Getenv(envName); v != "" {
+		return v
+	}
+	return configured
+}
+
+// Bool returns a configured flag value or def when it is not set.
+func Bool(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// IsKey reports whether key is a known setting.
+func IsKey(key string) bool {
+	for _, k := range Keys {
+		if k.Name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Value renders a setting of c for `config get`.
+func Value(c *Config, key string) (string, error) {
+	node := yaml.Node{}
+	if err := node.Encode(c); err != nil {
+		return "", err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		v := node.Content[i+1]
+		if v.Kind == yaml.SequenceNode {
+			var items []string
+			for _, item := range v.Content {
+				items = append(items, item.Value)
+			}
+			return strings.Join(items, ","), nil
+		}
+		if v.Kind == yaml.MappingNode {
+			var pairs []string
+			for j := 0; j+1 < len(v.Content); j += 2 {
+				pairs = append(pairs, v.Content[j].Value+"="+v.Content[j+1].Value)
+			}
+			return strings.Join(pairs, ","), nil
+		}
+		return v.Value, nil
+	}
+	return "", nil
+}
+
+// Set writes key=value to the configuration file at path, keeping
+// the rest of the file, comments included.
+func Set(path, key, value string) error {
+	kind := ""
+	for _, k := range Keys {
+		if k.Name == key {
+			kind = k.Kind
+		}
+	}
+	if kind == "" {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if (key == "provider" || key == "fallback_provider") && !contains(generator.Names, strings.ToLower(value)) {
+		return fmt.Errorf("unknown provider %q (expected %s)", value, strings.Join(generator.Names, ", "))
+	}
+	if key == "branch_ticket" && !contains(TicketPlacements, value) {
+		return fmt.Errorf("unknown branch_ticket %q (expected %s)", value, strings.Join(TicketPlacements, ", "))
+	}
+	if key == "emoji_placement" && !contains(EmojiPlacements, value) {
+		return fmt.Errorf("unknown emoji_placement %q (expected %s)", value, strings.Join(EmojiPlacements, ", "))
+	}
+	if key == "ticket_pattern" {
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid ticket_pattern: %v", err)
+		}
+	}
+
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	switch kind {
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be a number", key)
+		}
+		valueNode.Tag = "!!int"
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+		valueNode.Tag, valueNode.Value = "!!bool", strconv.FormatBool(b)
+	case "list":
+		valueNode = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				valueNode.Content = append(valueNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+		}
+	case "map":
+		valueNode = &yaml.Node{Kind: yaml.MappingNode}
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%s must be a list of key=value pairs", key)
+			}
+			valueNode.Content = append(valueNode.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(k)},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(v)})
+		}
+	default:
+		valueNode.Tag = "!!str"
+	}
+
+	doc := yaml.Node{Kind: yaml.DocumentNode}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("parsing %s: expected a mapping at the top level", path)
+	}
+
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			valueNode.HeadComment, valueNode.LineComment = root.Content[i+1].HeadComment, root.Content[i+1].LineComment
+			root.Content[i+1] = valueNode
+			replaced = true
+		}
+	}
+	if !replaced {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, valueNode)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// contains reports whether list holds s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
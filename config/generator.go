@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chalfel/smart-commit/generator"
+)
+
+// SelectedProvider is the provider name and model c selects, unless
+// SMART_COMMIT_PROVIDER and SMART_COMMIT_MODEL override them. Tools built
+// on the library pass them to generator.New; config does not build the
+// provider itself, so binaries that leave out the network providers, such
+// as airgap builds, do not link them.
+func (c *Config) SelectedProvider() (name, model string) {
+	return Setting("SMART_COMMIT_PROVIDER", c.Provider), Setting("SMART_COMMIT_MODEL", c.Model)
+}
+
+// CommitOptions are the generator options the settings select for commit
+// messages: the diff budget (SMART_COMMIT_MAX_DIFF overrides max_diff),
+// the language (SMART_COMMIT_LANG overrides language), body, footer, types
+// and prompt.
+func (c *Config) CommitOptions() generator.Options {
+	budget := generator.DefaultDiffBudget
+	if n, err := strconv.Atoi(os.Getenv("SMART_COMMIT_MAX_DIFF")); err == nil && n >= 0 {
+		budget = n
+	} else if c.MaxDiff != nil && *c.MaxDiff >= 0 {
+		budget = *c.MaxDiff
+	}
+	return generator.Options{
+		DiffBudget:     budget,
+		Language:       Setting("SMART_COMMIT_LANG", c.Language),
+		Body:           Bool(c.Body, false),
+		Footer:         Bool(c.Footer, false),
+		Types:          c.Types,
+		PromptVersion:  c.PromptVersion,
+		PromptTemplate: c.PromptTemplate,
+	}
+}
+
+// RequestTimeout is how long to wait for a provider request:
+// SMART_COMMIT_TIMEOUT, the timeout setting, or a minute. Zero waits
+// indefinitely.
+func (c *Config) RequestTimeout() time.Duration {
+	seconds := 60
+	if n, err := strconv.Atoi(os.Getenv("SMART_COMMIT_TIMEOUT")); err == nil && n >= 0 {
+		seconds = n
+	} else if c.Timeout != nil && *c.Timeout >= 0 {
+		seconds = *c.Timeout
+	}
+	return time.Duration(seconds) * time.Second
+}
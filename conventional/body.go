@@ -0,0 +1,69 @@
+package conventional
+
+import (
+	"regexp"
+	"strings"
+)
+
+// listItem matches the marker of a bulleted or numbered list item.
+var listItem = regexp.MustCompile(`^([-*]|\d+[.)]) `)
+
+// WrapBody wraps each paragraph of a body at width columns. List items are
+// wrapped with their continuation lines indented under the text; indented
+// lines and tables are taken as preformatted and left alone.
+func WrapBody(body string, width int) string {
+	var out, para []string
+	indent := ""
+	flush := func() {
+		if len(para) > 0 {
+			out = append(out, wrapLines(strings.Join(para, " "), indent, width)...)
+		}
+		para, indent = nil, ""
+	}
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			out = append(out, "")
+		case listItem.MatchString(trimmed):
+			flush()
+			para = []string{trimmed}
+			indent = strings.Repeat(" ", len(listItem.FindString(trimmed)))
+		case indent != "" && strings.HasPrefix(line, " "):
+			para = append(para, trimmed)
+		case strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(trimmed, "|"):
+			flush()
+			out = append(out, line)
+		default:
+			if indent != "" {
+				flush()
+			}
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// wrapLines breaks text into lines of at most width columns, indenting all
+// but the first by indent. Words longer than a line are not split.
+func wrapLines(text, indent string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line += word
+		case len(line)+1+len(word) > width:
+			lines = append(lines, line)
+			line = indent + word
+		default:
+			line += " " + word
+		}
+	}
+	if strings.TrimSpace(line) != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
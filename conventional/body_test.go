@@ -0,0 +1,20 @@
+package conventional
+
+import "testing"
+
+func TestWrapBody(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"short", "Fixes it.", "Fixes it."},
+		{"wrapped", "aaaa bbbb cccc dddd", "aaaa bbbb\ncccc dddd"},
+		{"list item", "- aaaa bbbb cccc", "- aaaa bbbb\n  cccc"},
+		{"preformatted", "    aaaa bbbb cccc dddd", "    aaaa bbbb cccc dddd"},
+		{"paragraphs", "aaaa\n\nbbbb", "aaaa\n\nbbbb"},
+	}
+	for _, tt := range tests {
+		if got := WrapBody(tt.body, 12); got != tt.want {
+			t.Errorf("%s: WrapBody(%q, 12) = %q, want %q", tt.name, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package conventional parses, normalizes and formats commit messages that
+// follow the Conventional Commits specification.
+package conventional
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultTypes are the conventional commit types accepted by default.
+var DefaultTypes = []string{"feat", "fix", "docs", "style", "refactor", "test", "chore", "perf", "ci", "build", "revert"}
+
+// Subject matches a conventional commit subject line and captures its
+// type, optional scope, breaking marker and description.
+var Subject = regexp.MustCompile(`^([a-zA-Z]+)(\(([^)]+)\))?(!)?: (.+)$`)
+
+// ParseSubject splits a commit subject into its conventional parts. ok is
+// false when the subject does not follow the conventional format.
+func ParseSubject(subject string) (commitType, scope, description string, breaking, ok bool) {
+	m := Subject.FindStringSubmatch(strings.TrimSpace(subject))
+	if m == nil {
+		return "", "", subject, false, false
+	}
+	return strings.ToLower(m[1]), m[3], m[5], m[4] == "!", true
+}
+
+// Enforce ensures the message follows conventional commit format, using
+// fallbackType when the message has no type
+func Enforce(message string, fallbackType string) string {
+	return EnforceTypes(message, fallbackType, DefaultTypes)
+}
+
+// EnforceTypes is Enforce for a project with its own list of types: a
+// message with any other type is treated as having none.
+func EnforceTypes(message string, fallbackType string, types []string) string {
+	// Regular expression for conventional commit format
+	quoted := make([]string, len(types))
+	for i, t := range types {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	conventionalFormat := regexp.MustCompile(`^(` + strings.Join(quoted, "|") + `)(\([a-z0-9-]+\))?!?: .+`)
+
+	// If message already follows the format, return it
+	if conventionalFormat.MatchString(message) {
+		return message
+	}
+
+	// Otherwise, use the type determined from the changes
+	commitType := fallbackType
+
+	// Extract first sentence to use as description
+	description := message
+	if idx := strings.Index(message, "."); idx > 0 {
+		description = message[:idx]
+	}
+	description = strings.TrimSpace(description)
+
+	// First letter should be lowercase
+	if len(description) > 0 {
+		description = strings.ToLower(description[:1]) + description[1:]
+	}
+
+	return fmt.Sprintf("%s: %s", commitType, description)
+}
+
+// DetermineType tries to determine an appropriate commit type based on a
+// description of the changes
+func DetermineType(changes string) string {
+	lowerChanges := strings.ToLower(changes)
+
+	// Default type
+	commitType := "chore"
+
+	// Try to determine type based on file patterns and change descriptions
+	if strings.Contains(lowerChanges, "test") || strings.Contains(lowerChanges, "_test.go") {
+		commitType = "test"
+	} else if strings.Contains(lowerChanges, "fix") || strings.Contains(lowerChanges, "bug") {
+		commitType = "fix"
+	} else if strings.Contains(lowerChanges, "feat") || strings.Contains(lowerChanges, "add") ||
+		strings.Contains(lowerChanges, "new") {
+		commitType = "feat"
+	} else if strings.Contains(lowerChanges, "doc") || strings.Contains(lowerChanges, "readme") {
+		commitType = "docs"
+	} else if strings.Contains(lowerChanges, "refactor") {
+		commitType = "refactor"
+	} else if strings.Contains(lowerChanges, "style") || strings.Contains(lowerChanges, "format") {
+		commitType = "style"
+	}
+
+	return commitType
+}
+
+// WithScope replaces the scope of a conventional commit message; an empty
+// scope removes it.
+func WithScope(message, scope string) string {
+	return rewriteSubject(message, func(commitType, _ string) (string, string) { return commitType, scope })
+}
+
+// WithType replaces the type of a conventional commit message.
+func WithType(message, commitType string) string {
+	return rewriteSubject(message, func(_, scope string) (string, string) { return commitType, scope })
+}
+
+// rewriteSubject replaces the type and scope of a conventional commit
+// message with what rewrite returns for the current ones. Messages that do
+// not follow the format are returned unchanged.
+func rewriteSubject(message string, rewrite func(commitType, scope string) (string, string)) string {
+	subject, rest, _ := strings.Cut(message, "\n")
+	commitType, scope, description, breaking, ok := ParseSubject(subject)
+	if !ok {
+		return message
+	}
+	commitType, scope = rewrite(commitType, scope)
+	subject = commitType
+	if scope != "" {
+		subject += "(" + scope + ")"
+	}
+	if breaking {
+		subject += "!"
+	}
+	subject += ": " + description
+	if rest != "" {
+		return subject + "\n" + rest
+	}
+	return subject
+}
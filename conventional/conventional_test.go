@@ -0,0 +1,135 @@
+package conventional
+
+import "testing"
+
+func TestEnforce(t *testing.T) {
+	tests := []struct {
+		name, message, fallback, want string
+	}{
+		{"already conventional", "feat(api): add users endpoint", "chore", "feat(api): add users endpoint"},
+		{"breaking marker", "feat!: drop v1 routes", "chore", "feat!: drop v1 routes"},
+		{"breaking with scope", "fix(db)!: change column type", "chore", "fix(db)!: change column type"},
+		{"plain sentence", "Add users endpoint", "feat", "feat: add users endpoint"},
+		{"first sentence only", "Fix the nil panic. It happened on startup.", "fix", "fix: fix the nil panic"},
+		{"unknown type", "update: bump deps", "build", "build: update: bump deps"},
+		{"uppercase scope", "feat(API): add users", "chore", "chore: feat(API): add users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Enforce(tt.message, tt.fallback); got != tt.want {
+				t.Errorf("Enforce(%q, %q) = %q, want %q", tt.message, tt.fallback, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceTypes(t *testing.T) {
+	types := []string{"feat", "fix", "deps"}
+	tests := []struct {
+		message, want string
+	}{
+		{"deps: bump yaml to v3", "deps: bump yaml to v3"},
+		{"feat(api): add users", "feat(api): add users"},
+		{"chore: tidy up", "fix: chore: tidy up"},
+	}
+	for _, tt := range tests {
+		if got := EnforceTypes(tt.message, "fix", types); got != tt.want {
+			t.Errorf("EnforceTypes(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestDetermineType(t *testing.T) {
+	tests := []struct {
+		changes, want string
+	}{
+		{"M parser_test.go", "test"},
+		{"M bugfix/handler.go", "fix"},
+		{"A features/export.go", "feat"},
+		{"M docs/guide.txt", "docs"},
+		{"M README", "docs"},
+		{"M refactoring/plan.go", "refactor"},
+		{"M formatter.go", "style"},
+		{"M main.go", "chore"},
+	}
+	for _, tt := range tests {
+		if got := DetermineType(tt.changes); got != tt.want {
+			t.Errorf("DetermineType(%q) = %q, want %q", tt.changes, got, tt.want)
+		}
+	}
+}
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject                        string
+		commitType, scope, description string
+		breaking, ok                   bool
+	}{
+		{"feat(api): add users", "feat", "api", "add users", false, true},
+		{"Fix!: drop flag", "fix", "", "drop flag", true, true},
+		{"refactor(core)!: split package", "refactor", "core", "split package", true, true},
+		{"Add users", "", "", "Add users", false, false},
+		{"feat:missing space", "", "", "feat:missing space", false, false},
+	}
+	for _, tt := range tests {
+		commitType, scope, description, breaking, ok := ParseSubject(tt.subject)
+		if commitType != tt.commitType || scope != tt.scope || description != tt.description || breaking != tt.breaking || ok != tt.ok {
+			t.Errorf("ParseSubject(%q) = %q, %q, %q, %v, %v", tt.subject, commitType, scope, description, breaking, ok)
+		}
+	}
+}
+
+func TestWithType(t *testing.T) {
+	tests := []struct {
+		message, commitType, want string
+	}{
+		{"feat: add users", "fix", "fix: add users"},
+		{"feat(api)!: drop users\n\nBody.", "refactor", "refactor(api)!: drop users\n\nBody."},
+		{"not conventional", "fix", "not conventional"},
+	}
+	for _, tt := range tests {
+		if got := WithType(tt.message, tt.commitType); got != tt.want {
+			t.Errorf("WithType(%q, %q) = %q, want %q", tt.message, tt.commitType, got, tt.want)
+		}
+	}
+}
+
+func TestWithScope(t *testing.T) {
+	tests := []struct {
+		message, scope, want string
+	}{
+		{"feat: add users", "api", "feat(api): add users"},
+		{"feat(web): add users", "api", "feat(api): add users"},
+		{"feat(web)!: drop users\n\nBody.", "", "feat!: drop users\n\nBody."},
+		{"not conventional", "api", "not conventional"},
+	}
+	for _, tt := range tests {
+		if got := WithScope(tt.message, tt.scope); got != tt.want {
+			t.Errorf("WithScope(%q, %q) = %q, want %q", tt.message, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestWithEmoji(t *testing.T) {
+	tests := []struct {
+		name, message string
+		after         bool
+		want          string
+	}{
+		{"before", "feat(api): add users", false, "✨ feat(api): add users"},
+		{"after", "feat(api): add users", true, "feat(api): ✨ add users"},
+		{"breaking", "fix!: drop v1\n\nBody.", false, "🐛 fix!: drop v1\n\nBody."},
+		{"replaces emoji", "🎉 feat: add users", false, "✨ feat: add users"},
+		{"replaces shortcode after", "feat: :tada: add users", true, "feat: ✨ add users"},
+		{"moves emoji", "✨ feat: add users", true, "feat: ✨ add users"},
+		{"unmapped type", "deps: bump yaml", false, "deps: bump yaml"},
+		{"not conventional", "Add users", false, "Add users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithEmoji(tt.message, Gitmoji, tt.after); got != tt.want {
+				t.Errorf("WithEmoji(%q, %v) = %q, want %q", tt.message, tt.after, got, tt.want)
+			}
+		})
+	}
+}
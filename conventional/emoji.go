@@ -0,0 +1,75 @@
+package conventional
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Gitmoji maps the conventional types to their gitmoji (https://gitmoji.dev).
+var Gitmoji = map[string]string{
+	"feat":     "✨",
+	"fix":      "🐛",
+	"docs":     "📝",
+	"style":    "🎨",
+	"refactor": "♻️",
+	"test":     "✅",
+	"chore":    "🔧",
+	"perf":     "⚡️",
+	"ci":       "👷",
+	"build":    "📦️",
+	"revert":   "⏪️",
+}
+
+// gitmojiCode matches a gitmoji shortcode such as ":sparkles:".
+var gitmojiCode = regexp.MustCompile(`^:[a-z0-9_+-]+:`)
+
+// SplitEmoji splits the emoji or gitmoji shortcode s starts with, if any,
+// from the rest of s, dropping the spaces between them.
+func SplitEmoji(s string) (emoji, rest string) {
+	if code := gitmojiCode.FindString(s); code != "" {
+		return code, strings.TrimLeftFunc(s[len(code):], unicode.IsSpace)
+	}
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isEmoji(r) {
+			break
+		}
+		i += size
+	}
+	return s[:i], strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+}
+
+// isEmoji reports whether r is a pictograph, or the variation selector and
+// joiner that follow one.
+func isEmoji(r rune) bool {
+	return r >= 0x1F000 || (r >= 0x2600 && r <= 0x27BF) || r == 0x2B50 || r == 0xFE0F || r == 0x200D
+}
+
+// WithEmoji marks the subject of a conventional commit message with the
+// emoji emojis maps its type to, before the type or, when after is set,
+// after the colon. An emoji the subject already has is replaced; messages
+// that do not follow the format or whose type has no emoji are returned
+// unchanged.
+func WithEmoji(message string, emojis map[string]string, after bool) string {
+	subject, rest, hasRest := strings.Cut(message, "\n")
+	_, stripped := SplitEmoji(strings.TrimSpace(subject))
+	commitType, _, description, _, ok := ParseSubject(stripped)
+	if !ok || emojis[commitType] == "" {
+		return message
+	}
+	emoji := emojis[commitType]
+	_, bare := SplitEmoji(description)
+	prefix := strings.TrimSuffix(stripped, description)
+	if after {
+		subject = prefix + emoji + " " + bare
+	} else {
+		subject = emoji + " " + prefix + bare
+	}
+	if hasRest {
+		return subject + "\n" + rest
+	}
+	return subject
+}
@@ -0,0 +1,21 @@
+package conventional
+
+import "regexp"
+
+// TicketPattern matches issue references in commit messages: tracker keys
+// such as ABC-123 (Jira, Linear) and GitHub-style #123 references.
+var TicketPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b|#\d+\b`)
+
+// ExtractTickets returns the distinct ticket references found in text, in
+// order of appearance.
+func ExtractTickets(text string) []string {
+	var tickets []string
+	seen := map[string]bool{}
+	for _, ticket := range TicketPattern.FindAllString(text, -1) {
+		if !seen[ticket] {
+			seen[ticket] = true
+			tickets = append(tickets, ticket)
+		}
+	}
+	return tickets
+}
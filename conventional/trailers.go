@@ -0,0 +1,76 @@
+package conventional
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Trailer is one "Token: value" line of a commit message's trailer block,
+// such as "Reviewed-by: Jane <jane@example.com>".
+type Trailer struct {
+	Token string
+	Value string
+}
+
+func (t Trailer) String() string {
+	return t.Token + ": " + t.Value
+}
+
+// TrailerLine matches a trailer as git interpret-trailers reads it, plus
+// the conventional BREAKING CHANGE footer, the one token with a space.
+var TrailerLine = regexp.MustCompile(`^(BREAKING CHANGE|[A-Za-z0-9][A-Za-z0-9-]*)[ \t]*:[ \t]*(.*)$`)
+
+// ParseTrailer parses a trailer given as "Token: value" or "Token=value",
+// the forms git commit --trailer accepts.
+func ParseTrailer(spec string) (Trailer, error) {
+	sep := strings.IndexAny(spec, ":=")
+	if sep < 0 {
+		return Trailer{}, fmt.Errorf("invalid trailer %q: expected Token: value", spec)
+	}
+	t := Trailer{Token: strings.TrimSpace(spec[:sep]), Value: strings.TrimSpace(spec[sep+1:])}
+	if !TrailerLine.MatchString(t.Token + ":") {
+		return Trailer{}, fmt.Errorf("invalid trailer token %q: use letters, digits and hyphens", t.Token)
+	}
+	if t.Value == "" {
+		return Trailer{}, fmt.Errorf("trailer %q has no value", t.Token)
+	}
+	return t, nil
+}
+
+// SplitTrailers separates a message into its text and its trailer block:
+// the last paragraph, when every line of it is a trailer or the indented
+// continuation of one. The subject is never a trailer block.
+func SplitTrailers(message string) (string, []Trailer) {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	start := len(lines)
+	for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+		start--
+	}
+	if start == 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+
+	var trailers []Trailer
+	for _, line := range lines[start:] {
+		if (line[0] == ' ' || line[0] == '\t') && len(trailers) > 0 {
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		m := TrailerLine.FindStringSubmatch(line)
+		if m == nil {
+			return strings.Join(lines, "\n"), nil
+		}
+		trailers = append(trailers, Trailer{Token: m[1], Value: m[2]})
+	}
+	return strings.TrimRight(strings.Join(lines[:start], "\n"), "\n"), trailers
+}
@@ -0,0 +1,56 @@
+package conventional
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTrailers(t *testing.T) {
+	tests := []struct {
+		name, message, text string
+		trailers            []Trailer
+	}{
+		{"subject only", "feat: add users", "feat: add users", nil},
+		{"subject is never a trailer block", "Refs: ABC-1", "Refs: ABC-1", nil},
+		{
+			"trailer block", "feat: add users\n\nBody text.\n\nRefs: ABC-1\nSigned-off-by: Jane <jane@example.com>",
+			"feat: add users\n\nBody text.",
+			[]Trailer{{"Refs", "ABC-1"}, {"Signed-off-by", "Jane <jane@example.com>"}},
+		},
+		{
+			"breaking change and continuation", "feat!: drop v1\n\nBREAKING CHANGE: v1 routes are gone,\n  use v2",
+			"feat!: drop v1",
+			[]Trailer{{"BREAKING CHANGE", "v1 routes are gone, use v2"}},
+		},
+		{"last paragraph is prose", "fix: nil panic\n\nThis fixes: the panic", "fix: nil panic\n\nThis fixes: the panic", nil},
+		{"comments dropped", "fix: x\n\nRefs: #12\n# Please enter the commit message", "fix: x", []Trailer{{"Refs", "#12"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, trailers := SplitTrailers(tt.message)
+			if text != tt.text || !reflect.DeepEqual(trailers, tt.trailers) {
+				t.Errorf("SplitTrailers(%q) = %q, %v; want %q, %v", tt.message, text, trailers, tt.text, tt.trailers)
+			}
+		})
+	}
+}
+
+func TestParseTrailer(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Trailer
+		wantErr bool
+	}{
+		{"Reviewed-by: Jane <jane@example.com>", Trailer{"Reviewed-by", "Jane <jane@example.com>"}, false},
+		{"Refs=ABC-1", Trailer{"Refs", "ABC-1"}, false},
+		{"no separator", Trailer{}, true},
+		{"Bad Token: x", Trailer{}, true},
+		{"Refs:", Trailer{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrailer(tt.spec)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTrailer(%q) = %v, %v; want %v, error %v", tt.spec, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
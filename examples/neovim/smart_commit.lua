@@ -0,0 +1,96 @@
+-- Minimal Neovim integration for smart-commit.
+--
+-- Starts `smart-commit serve --stdio`, negotiates the protocol and inserts a
+-- suggested commit message at the top of the current gitcommit buffer.
+--
+-- Usage: put this file on your runtimepath (e.g. lua/smart_commit.lua) and
+--   require("smart_commit").setup()
+-- then run :SmartCommitSuggest inside a commit message buffer.
+
+local M = {}
+
+local PROTOCOL_VERSIONS = { 1 }
+
+local job = nil
+local next_id = 0
+local pending = {}
+local partial = ""
+
+local function send(method, params, callback)
+  next_id = next_id + 1
+  pending[next_id] = callback
+  local request = { jsonrpc = "2.0", id = next_id, method = method, params = params }
+  vim.fn.chansend(job, vim.json.encode(request) .. "\n")
+end
+
+-- Responses are newline-delimited JSON; a chunk may end mid-line.
+local function on_stdout(_, data)
+  data[1] = partial .. data[1]
+  partial = table.remove(data)
+  for _, line in ipairs(data) do
+    if line ~= "" then
+      local response = vim.json.decode(line)
+      local callback = pending[response.id]
+      pending[response.id] = nil
+      if callback then
+        callback(response.error, response.result)
+      end
+    end
+  end
+end
+
+local function start(on_ready)
+  if job then
+    on_ready()
+    return
+  end
+  job = vim.fn.jobstart({ "smart-commit", "serve", "--stdio" }, {
+    cwd = vim.fn.getcwd(),
+    on_stdout = on_stdout,
+    on_exit = function()
+      job = nil
+    end,
+  })
+  send("initialize", {
+    clientName = "neovim",
+    protocolVersions = PROTOCOL_VERSIONS,
+    capabilities = { "suggest" },
+  }, function(err, result)
+    if err then
+      vim.notify("smart-commit: " .. err.message, vim.log.levels.ERROR)
+      return
+    end
+    if not vim.tbl_contains(result.capabilities, "suggest") then
+      vim.notify("smart-commit: server does not support suggest", vim.log.levels.ERROR)
+      return
+    end
+    on_ready()
+  end)
+end
+
+function M.suggest()
+  local buf = vim.api.nvim_get_current_buf()
+  start(function()
+    send("suggest", { dir = vim.fn.getcwd() }, function(err, result)
+      if err then
+        vim.notify("smart-commit: " .. err.message, vim.log.levels.ERROR)
+        return
+      end
+      local lines = vim.split(result.message, "\n")
+      vim.api.nvim_buf_set_lines(buf, 0, 0, false, lines)
+    end)
+  end)
+end
+
+function M.setup()
+  vim.api.nvim_create_user_command("SmartCommitSuggest", M.suggest, {})
+  vim.api.nvim_create_autocmd("VimLeavePre", {
+    callback = function()
+      if job then
+        send("shutdown", nil, nil)
+      end
+    end,
+  })
+end
+
+return M
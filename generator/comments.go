@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// CommentDelta is an added or removed comment that hints at the intent of a
+// change: a TODO-style marker, or a doc comment on a declaration.
+type CommentDelta struct {
+	Path string
+	// Kind is "todo" or "doc".
+	Kind string
+	// Added is false for removed comments.
+	Added bool
+	Text  string
+	// Symbol is the declaration a doc comment belongs to.
+	Symbol string
+}
+
+func (d CommentDelta) String() string {
+	verb := "removed"
+	if d.Added {
+		verb = "added"
+	}
+	if d.Kind == "doc" {
+		return fmt.Sprintf("%s doc comment on `%s` in %s: %s", verb, d.Symbol, d.Path, d.Text)
+	}
+	return fmt.Sprintf("%s %s in %s", verb, d.Text, d.Path)
+}
+
+// Limits that keep comment context short in prompts.
+const (
+	maxCommentDeltas = 20
+	maxSymbolLength  = 80
+)
+
+var (
+	// commentStart matches the comment markers of common languages.
+	commentStart = regexp.MustCompile(`^\s*(//+|#|/\*+|\*(\s|/|$)|--|;+|<!--)\s?`)
+	// todoMarker matches a TODO-style note inside a comment.
+	todoMarker = regexp.MustCompile(`\b(TODO|FIXME|XXX|HACK)\b`)
+	// declaration matches the start of a declaration a doc comment documents.
+	declaration = regexp.MustCompile(`^\s*(export\s+|pub\s+|public\s+|private\s+|protected\s+|static\s+|async\s+)*(func|type|var|const|class|def|interface|struct|enum|fn|function|module)\b`)
+)
+
+// CommentDeltas lists the TODO-style markers and doc comments the change set
+// adds or removes in source and test files.
+func CommentDeltas(cs *git.ChangeSet) []CommentDelta {
+	var deltas []CommentDelta
+	for _, f := range cs.Files {
+		if f.Category != git.CategorySource && f.Category != git.CategoryTest {
+			continue
+		}
+		for _, h := range f.Hunks {
+			deltas = append(deltas, hunkCommentDeltas(f.Path, h)...)
+		}
+	}
+	return deltas
+}
+
+// hunkCommentDeltas finds the comment deltas of one hunk. A doc comment is a
+// run of comment lines directly followed by a declaration; its changed lines
+// are reported together.
+func hunkCommentDeltas(path string, h git.Hunk) []CommentDelta {
+	var deltas []CommentDelta
+	var run []string
+	for _, line := range h.Lines {
+		if line == "" || line[0] == '\\' {
+			continue
+		}
+		sign, text := line[0], line[1:]
+
+		if loc := commentStart.FindStringIndex(text); loc != nil {
+			comment := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(text[loc[1]:], "*/"), "-->"))
+			if sign != ' ' && todoMarker.MatchString(comment) {
+				deltas = append(deltas, CommentDelta{Path: path, Kind: "todo", Added: sign == '+', Text: comment})
+				continue
+			}
+			run = append(run, line)
+			continue
+		}
+
+		if declaration.MatchString(text) {
+			deltas = append(deltas, docCommentDeltas(path, strings.TrimSpace(text), run)...)
+		}
+		run = nil
+	}
+	return deltas
+}
+
+// docCommentDeltas reports the changed lines of a doc comment run, joining
+// the added and the removed lines into one delta each.
+func docCommentDeltas(path, symbol string, run []string) []CommentDelta {
+	var added, removed []string
+	for _, line := range run {
+		text := strings.TrimSpace(commentStart.ReplaceAllString(line[1:], ""))
+		switch line[0] {
+		case '+':
+			added = append(added, text)
+		case '-':
+			removed = append(removed, text)
+		}
+	}
+	symbol, _, _ = strings.Cut(symbol, "{")
+	symbol = strings.TrimSpace(symbol)
+	if len(symbol) > maxSymbolLength {
+		symbol = symbol[:maxSymbolLength] + "..."
+	}
+	var deltas []CommentDelta
+	if len(removed) > 0 {
+		deltas = append(deltas, CommentDelta{Path: path, Kind: "doc", Symbol: symbol, Text: strings.Join(removed, " ")})
+	}
+	if len(added) > 0 {
+		deltas = append(deltas, CommentDelta{Path: path, Kind: "doc", Added: true, Symbol: symbol, Text: strings.Join(added, " ")})
+	}
+	return deltas
+}
+
+// DescribeCommentDeltas renders the deltas as prompt context, one per line,
+// up to maxCommentDeltas of them.
+func DescribeCommentDeltas(deltas []CommentDelta) string {
+	var b strings.Builder
+	for i, d := range deltas {
+		if i == maxCommentDeltas {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(deltas)-i)
+			break
+		}
+		fmt.Fprintf(&b, "- %s\n", d)
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// DefaultDiffBudget is how many bytes of diff are sent to the model by
+// default, roughly 3000 tokens.
+const DefaultDiffBudget = 12000
+
+// categoryWeight ranks how much a file category tells the model about the
+// intent of a change.
+var categoryWeight = map[string]int{
+	git.CategorySource: 4,
+	git.CategoryTest:   3,
+	git.CategoryBuild:  2,
+	git.CategoryCI:     2,
+	git.CategoryConfig: 2,
+	git.CategoryDocs:   1,
+}
+
+// hunkCandidate is a hunk competing for a place in the diff budget.
+type hunkCandidate struct {
+	file, hunk int
+	text       string
+	score      int
+}
+
+// DiffContext renders the change set as a unified diff of at most budget
+// bytes. Lockfiles, generated and vendored code and binaries are never
+// included; for the rest, the hunks with the most changed lines in the most
+// telling files are kept first. It returns "" when nothing fits, leaving the
+// model with the file list alone.
+func DiffContext(cs *git.ChangeSet, budget int) string {
+	return StructuredDiffContext(cs, budget, nil)
+}
+
+// StructuredDiffContext is DiffContext with structural diffs, by path,
+// standing in for the hunks of files whose line diff is mostly noise, such
+// as notebooks.
+func StructuredDiffContext(cs *git.ChangeSet, budget int, structural map[string]string) string {
+	if budget <= 0 {
+		return ""
+	}
+
+	var candidates []hunkCandidate
+	for i, f := range cs.Files {
+		weight, ok := categoryWeight[f.Category]
+		if !ok || f.Binary {
+			continue
+		}
+		if text, ok := structural[f.Path]; ok {
+			text = cutLines(text, budget/2)
+			candidates = append(candidates, hunkCandidate{file: i, hunk: -1, text: text, score: weight * strings.Count(text, "\n")})
+			continue
+		}
+		for j, h := range f.Hunks {
+			text := renderHunk(h, budget/2)
+			candidates = append(candidates, hunkCandidate{file: i, hunk: j, text: text, score: weight * hunkSignal(h)})
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	// Greedily keep the best hunks that fit, accounting for file headers
+	chosen := map[[2]int]string{}
+	headers := map[int]bool{}
+	used := 0
+	for _, c := range candidates {
+		if c.score == 0 {
+			break
+		}
+		size := len(c.text)
+		if !headers[c.file] {
+			size += len(fileHeader(cs.Files[c.file]))
+		}
+		if used+size > budget {
+			continue
+		}
+		used += size
+		headers[c.file] = true
+		chosen[[2]int{c.file, c.hunk}] = c.text
+	}
+	if len(chosen) == 0 {
+		return ""
+	}
+
+	// Print the kept hunks in diff order and say what was left out
+	var b strings.Builder
+	var omitted []string
+	for i, f := range cs.Files {
+		if !headers[i] {
+			omitted = append(omitted, f.Path)
+			continue
+		}
+		b.WriteString(fileHeader(f))
+		if text, ok := chosen[[2]int{i, -1}]; ok {
+			b.WriteString(text)
+			continue
+		}
+		skipped := 0
+		for j := range f.Hunks {
+			if text, ok := chosen[[2]int{i, j}]; ok {
+				b.WriteString(text)
+			} else {
+				skipped++
+			}
+		}
+		if skipped > 0 {
+			fmt.Fprintf(&b, "... %d more hunk(s) omitted\n", skipped)
+		}
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&b, "Diff omitted for: %s\n", strings.Join(omitted, ", "))
+	}
+	return b.String()
+}
+
+// fileHeader introduces a file's hunks in DiffContext.
+func fileHeader(f git.FileChange) string {
+	return fmt.Sprintf("--- %s\n", f.ChangedFile)
+}
+
+// renderHunk formats a hunk, cutting its body to about limit bytes.
+func renderHunk(h git.Hunk, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Header != "" {
+		b.WriteString(" " + h.Header)
+	}
+	b.WriteString("\n")
+	for i, line := range h.Lines {
+		if b.Len()+len(line) > limit {
+			fmt.Fprintf(&b, "... %d more line(s)\n", len(h.Lines)-i)
+			break
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// cutLines cuts text, a list of lines, to about limit bytes.
+func cutLines(text string, limit int) string {
+	lines := strings.SplitAfter(strings.TrimRight(text, "\n")+"\n", "\n")
+	lines = lines[:len(lines)-1]
+	var b strings.Builder
+	for i, line := range lines {
+		if b.Len()+len(line) > limit {
+			fmt.Fprintf(&b, "... %d more line(s)\n", len(lines)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// hunkSignal counts a hunk's meaningful changed lines: additions and
+// removals that are not blank.
+func hunkSignal(h git.Hunk) int {
+	n := 0
+	for _, line := range h.Lines {
+		if len(line) > 0 && (line[0] == '+' || line[0] == '-') && strings.TrimSpace(line[1:]) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestDiffContext(t *testing.T) {
+	hunk := func(lines ...string) git.Hunk {
+		return git.Hunk{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: len(lines), Lines: lines}
+	}
+	cs := &git.ChangeSet{Files: []git.FileChange{
+		{ChangedFile: git.ChangedFile{Status: "M", Path: "go.sum"}, Category: git.CategoryLockfile, Hunks: []git.Hunk{hunk("+lockfile line")}},
+		{ChangedFile: git.ChangedFile{Status: "M", Path: "README.md"}, Category: git.CategoryDocs, Hunks: []git.Hunk{hunk("+docs line")}},
+		{ChangedFile: git.ChangedFile{Status: "M", Path: "main.go"}, Category: git.CategorySource, Hunks: []git.Hunk{hunk("+source line one", "+source line two")}},
+	}}
+
+	tests := []struct {
+		name          string
+		budget        int
+		want, notWant []string
+	}{
+		{"no budget", 0, nil, []string{"main.go"}},
+		{"everything fits", 1000, []string{"--- M main.go", "+source line two", "--- M README.md", "+docs line", "Diff omitted for: go.sum"}, []string{"lockfile line"}},
+		{"source first", 100, []string{"+source line one", "Diff omitted for: go.sum, README.md"}, []string{"+docs line"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffContext(cs, tt.budget)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("DiffContext(%d) does not contain %q:\n%s", tt.budget, want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("DiffContext(%d) contains %q:\n%s", tt.budget, notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuredDiffContext(t *testing.T) {
+	cs := &git.ChangeSet{Files: []git.FileChange{
+		{ChangedFile: git.ChangedFile{Status: "M", Path: "sales.ipynb"}, Category: git.CategorySource, Hunks: []git.Hunk{
+			{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1, Lines: []string{`-   "execution_count": 1,`, `+   "execution_count": 2,`}},
+		}},
+	}}
+	got := StructuredDiffContext(cs, 1000, map[string]string{"sales.ipynb": "cell 2 (code) edited:\n  +df.plot()\n"})
+	want := "--- M sales.ipynb\ncell 2 (code) edited:\n  +df.plot()\n"
+	if got != want {
+		t.Errorf("StructuredDiffContext() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+// Package generator asks AI providers for commit messages, summaries and
+// explanations, and renders the prompts it sends them.
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Generator is an AI backend that turns a prompt into text: commit
+// messages, summaries and explanations all go through it.
+type Generator interface {
+	// Name is the provider's display name.
+	Name() string
+	// Check reports whether the provider is usable (installed, configured,
+	// reachable) with an actionable error when it is not.
+	Check() error
+	// Generate returns the model's answer to prompt. It gives up, with an
+	// error wrapping ctx.Err(), when ctx is done.
+	Generate(ctx context.Context, prompt string) (string, error)
+}
+
+// Pinger is implemented by providers that can be probed for health with a
+// minimal request.
+type Pinger interface {
+	// Ping checks that the provider answers and accepts its credentials.
+	Ping(ctx context.Context) (Status, error)
+}
+
+// Status is what a successful Ping learned about a provider.
+type Status struct {
+	Latency time.Duration
+	// Quota describes the rate limit left, when the provider reports it.
+	Quota string
+}
+
+// Names lists the built-in providers.
+var Names = []string{"copilot", "openai", "anthropic", "ollama"}
+
+// New returns the built-in provider name, configured from the standard
+// environment variables: OPENAI_API_KEY and OPENAI_BASE_URL,
+// ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL, and OLLAMA_HOST. An empty
+// name selects copilot and an empty model the provider's default; a nil
+// client uses http.DefaultClient.
+func New(name, model string, client *http.Client) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "", "copilot":
+		return &Copilot{}, nil
+	case "openai":
+		return NewOpenAI(os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY"), model, client), nil
+	case "anthropic":
+		return NewAnthropic(os.Getenv("ANTHROPIC_BASE_URL"), os.Getenv("ANTHROPIC_API_KEY"), model, client), nil
+	case "ollama":
+		return NewOllama(os.Getenv("OLLAMA_HOST"), model, client), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q (expected %s)", name, strings.Join(Names, ", "))
+}
+
+// SystemPrompt frames every request to chat-style APIs. Copilot CLI has
+// no system prompt, so it receives the bare prompt.
+const SystemPrompt = "You are a tool embedded in a git workflow. Reply with exactly the requested text: no preamble, no explanations, no Markdown code fences."
+
+// CleanOutput strips the code fences chat models like to wrap answers in.
+func CleanOutput(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") && strings.HasSuffix(s, "```") && len(s) >= 6 {
+		s = strings.TrimSuffix(s, "```")
+		if i := strings.Index(s, "\n"); i >= 0 {
+			s = s[i+1:]
+		} else {
+			s = strings.TrimPrefix(s, "```")
+		}
+	}
+	return strings.TrimSpace(s)
+}
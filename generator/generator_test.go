@@ -0,0 +1,331 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// fakeGenerator answers every prompt with answer, or fails with err, and
+// records the prompts it was given.
+type fakeGenerator struct {
+	answer  string
+	err     error
+	prompts []string
+}
+
+func (g *fakeGenerator) Name() string { return "fake" }
+
+func (g *fakeGenerator) Check() error { return nil }
+
+func (g *fakeGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	g.prompts = append(g.prompts, prompt)
+	return g.answer, g.err
+}
+
+func TestCleanOutput(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"feat: add users\n", "feat: add users"},
+		{"```\nfeat: add users\n```", "feat: add users"},
+		{"```text\nfeat: add users\n\nBody.\n```", "feat: add users\n\nBody."},
+		{"```feat: add users```", "feat: add users"},
+		{"use `x` here", "use `x` here"},
+	}
+	for _, tt := range tests {
+		if got := CleanOutput(tt.in); got != tt.want {
+			t.Errorf("CleanOutput(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// testChanges is a change set adding one source file.
+func testChanges() *git.ChangeSet {
+	return &git.ChangeSet{Files: []git.FileChange{{
+		ChangedFile: git.ChangedFile{Status: "A", Path: "api/users.go"},
+		Added:       2,
+		Category:    git.CategorySource,
+		Hunks: []git.Hunk{{NewStart: 1, NewLines: 2, Lines: []string{
+			"+package api", "+// TODO: paginate the users list",
+		}}},
+	}}}
+}
+
+func TestCommitMessage(t *testing.T) {
+	tests := []struct {
+		name, answer, want string
+	}{
+		{"conventional", "feat(api): add users endpoint", "feat(api): add users endpoint"},
+		{"fenced", "```\nfix: handle empty list\n```", "fix: handle empty list"},
+		{"no type", "Add the users endpoint.", "feat: add the users endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &fakeGenerator{answer: tt.answer}
+			got, err := CommitMessage(context.Background(), g, testChanges(), Options{DiffBudget: DefaultDiffBudget})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("CommitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitMessagePinned(t *testing.T) {
+	g := &fakeGenerator{answer: "feat(web)!: add users endpoint"}
+	got, err := CommitMessage(context.Background(), g, testChanges(), Options{Type: "fix", Scope: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "fix(api)!: add users endpoint" {
+		t.Errorf("CommitMessage() = %q, want the pinned type and scope", got)
+	}
+	if !strings.Contains(g.prompts[0], "The type is fix. The scope is api.") {
+		t.Errorf("prompt does not mention the pinned type and scope:\n%s", g.prompts[0])
+	}
+}
+
+func TestCommitMessageError(t *testing.T) {
+	g := &fakeGenerator{err: errors.New("rate limited")}
+	if _, err := CommitMessage(context.Background(), g, testChanges(), Options{}); err == nil || err.Error() != "rate limited" {
+		t.Errorf("CommitMessage() error = %v, want the provider's error", err)
+	}
+}
+
+func TestCommitPrompt(t *testing.T) {
+	opts := Options{DiffBudget: DefaultDiffBudget, Note: "list users", Why: "admins need it", Notes: "tried offsets first", Extra: "see ABC-1", Body: true, Series: []string{"refactor(api): extract the users store"}}
+	prompt, err := CommitPrompt(testChanges(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Added files (1 file in api, +2/-0):\n- api/users.go (source, +2/-0)", "+package api", "added TODO: paginate the users list in api/users.go", "list users", "admins need it", "tried offsets first", "see ABC-1", "write a body", "Earlier commits in the same area, oldest first:\n- refactor(api): extract the users store"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not contain %q:\n%s", want, prompt)
+		}
+	}
+
+	opts = Options{PromptTemplate: "Files: {{.Changes}}{{.Extra}}", Extra: "!"}
+	if prompt, err := CommitPrompt(testChanges(), opts); err != nil || prompt != "Files: A api/users.go (source, +2/-0)\n!" {
+		t.Errorf("CommitPrompt() with a template = %q, %v", prompt, err)
+	}
+	if _, err := CommitPrompt(testChanges(), Options{PromptTemplate: "{{.Changes"}); err == nil {
+		t.Error("CommitPrompt() accepted a broken template")
+	}
+}
+
+func TestCommitPromptHistory(t *testing.T) {
+	opts := Options{History: []Attempt{
+		{Message: "chore: update files", Feedback: "mention the cache invalidation fix"},
+		{Message: "fix: invalidate cache\n\nAlso reformat.", Feedback: ""},
+	}}
+	prompt, err := CommitPrompt(testChanges(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Earlier attempts:\n\nAttempt 1:\nchore: update files\nAuthor: mention the cache invalidation fix\n" +
+		"\nAttempt 2:\nfix: invalidate cache\n\nAlso reformat.\nAuthor: (no comment; try a different wording)\n"
+	if !strings.HasSuffix(prompt, want) || !strings.Contains(prompt, "addresses all of the feedback") {
+		t.Errorf("prompt does not end with the attempts:\n%s", prompt)
+	}
+}
+
+func TestRenderPromptVersion(t *testing.T) {
+	for _, name := range PromptNames() {
+		for _, version := range PromptVersions(name) {
+			if _, err := RenderPromptVersion(name, version, map[string]string{}); err != nil {
+				t.Errorf("%s v%d: %v", name, version, err)
+			}
+		}
+	}
+	if _, err := RenderPromptVersion(PromptCommitMessage, 999, nil); err == nil {
+		t.Error("RenderPromptVersion accepted an unknown version")
+	}
+}
+
+func TestCopilotPromptOnStdin(t *testing.T) {
+	saved := git.DefaultRunner
+	defer func() { git.DefaultRunner = saved }()
+	var args []string
+	var stdin string
+	git.DefaultRunner = git.RunnerFunc(func(cmd *exec.Cmd) error {
+		args = cmd.Args
+		data, err := io.ReadAll(cmd.Stdin)
+		stdin = string(data)
+		io.WriteString(cmd.Stdout, "feat: add users\n")
+		return err
+	})
+
+	prompt := `Changed files: "it's" $(rm -rf /) | cat`
+	answer, err := (&Copilot{}).Generate(context.Background(), prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "feat: add users" {
+		t.Errorf("Generate() = %q", answer)
+	}
+	if strings.Join(args, " ") != "gh copilot suggest" {
+		t.Errorf("ran %q, want gh copilot suggest without a shell", args)
+	}
+	if stdin != prompt {
+		t.Errorf("stdin = %q, want the prompt unchanged", stdin)
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		headers   map[string]string
+		wantQuota string
+		wantCode  int
+	}{
+		{"quota", http.StatusOK, map[string]string{"x-ratelimit-remaining-requests": "499", "x-ratelimit-limit-requests": "500", "x-ratelimit-remaining-tokens": "19000"}, "499/500 requests, 19000 tokens left", 0},
+		{"no quota headers", http.StatusOK, nil, "", 0},
+		{"bad key", http.StatusUnauthorized, nil, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for name, value := range tt.headers {
+					w.Header().Set(name, value)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, `{"choices": []}`)
+			}))
+			defer server.Close()
+
+			status, err := NewOpenAI(server.URL, "key", "", nil).Ping(context.Background())
+			var statusErr *StatusError
+			if tt.wantCode != 0 {
+				if !errors.As(err, &statusErr) || statusErr.Code != tt.wantCode {
+					t.Fatalf("Ping() error = %v, want status %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Quota != tt.wantQuota {
+				t.Errorf("Quota = %q, want %q", status.Quota, tt.wantQuota)
+			}
+		})
+	}
+}
+
+// slowGenerator answers after delay.
+type slowGenerator struct {
+	fakeGenerator
+	delay time.Duration
+}
+
+func (g *slowGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	select {
+	case <-time.After(g.delay):
+		return g.answer, g.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestRacing(t *testing.T) {
+	tests := []struct {
+		name             string
+		primary, backup  *slowGenerator
+		want             string
+		wantErr, wantRan bool
+	}{
+		{"primary in time", &slowGenerator{fakeGenerator{answer: "feat: primary"}, 0}, &slowGenerator{fakeGenerator{answer: "feat: backup"}, 0}, "feat: primary", false, false},
+		{"fallback wins", &slowGenerator{fakeGenerator{answer: "feat: primary"}, time.Second}, &slowGenerator{fakeGenerator{answer: "feat: backup"}, 0}, "feat: backup", false, true},
+		{"slow primary beats failing fallback", &slowGenerator{fakeGenerator{answer: "feat: primary"}, 100 * time.Millisecond}, &slowGenerator{fakeGenerator{err: errors.New("down")}, 0}, "feat: primary", false, true},
+		{"empty answer is not usable", &slowGenerator{fakeGenerator{answer: "feat: primary"}, 100 * time.Millisecond}, &slowGenerator{fakeGenerator{answer: " "}, 0}, "feat: primary", false, true},
+		{"both fail", &slowGenerator{fakeGenerator{err: errors.New("timeout")}, 50 * time.Millisecond}, &slowGenerator{fakeGenerator{err: errors.New("down")}, 0}, "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raced := false
+			g := &Racing{Primary: tt.primary, Fallback: tt.backup, After: 20 * time.Millisecond, OnRace: func() { raced = true }}
+			got, err := g.Generate(context.Background(), "prompt")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Generate() = %q, %v; want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+			if raced != tt.wantRan {
+				t.Errorf("raced = %v, want %v", raced, tt.wantRan)
+			}
+		})
+	}
+}
+
+// flakyGenerator fails with errs in turn, then answers.
+type flakyGenerator struct {
+	fakeGenerator
+	errs []error
+}
+
+func (g *flakyGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	g.prompts = append(g.prompts, prompt)
+	if len(g.errs) > 0 {
+		err := g.errs[0]
+		g.errs = g.errs[1:]
+		return "", err
+	}
+	return g.answer, nil
+}
+
+func TestRetrying(t *testing.T) {
+	unavailable := &StatusError{Code: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+	unauthorized := &StatusError{Code: http.StatusUnauthorized, Status: "401 Unauthorized"}
+	tests := []struct {
+		name         string
+		errs         []error
+		want         string
+		wantAttempts int
+	}{
+		{"first try", nil, "feat: add users", 1},
+		{"server error then answer", []error{unavailable, unavailable}, "feat: add users", 3},
+		{"rate limited", []error{&StatusError{Code: http.StatusTooManyRequests}}, "feat: add users", 2},
+		{"timeout", []error{fmt.Errorf("openai: %w", context.DeadlineExceeded)}, "feat: add users", 2},
+		{"out of retries", []error{unavailable, unavailable, unavailable}, "", 3},
+		{"not transient", []error{unauthorized}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := &flakyGenerator{fakeGenerator{answer: "feat: add users"}, tt.errs}
+			g := &Retrying{Generator: gen, Retries: 2, Backoff: time.Millisecond}
+			got, err := g.Generate(context.Background(), "prompt")
+			if got != tt.want || (err != nil) != (tt.want == "") {
+				t.Errorf("Generate() = %q, %v; want %q", got, err, tt.want)
+			}
+			if len(gen.prompts) != tt.wantAttempts {
+				t.Errorf("made %d attempts, want %d", len(gen.prompts), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryingTimeout(t *testing.T) {
+	gen := &slowGenerator{fakeGenerator{answer: "feat: add users"}, time.Second}
+	g := &Retrying{Generator: gen, Retries: 1, Backoff: time.Millisecond, Timeout: 10 * time.Millisecond}
+	start := time.Now()
+	_, err := g.Generate(context.Background(), "prompt")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Generate() error = %v after %s, want a timeout within two attempts", err, time.Since(start))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Generate(ctx, "prompt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Generate() with a canceled context = %v, want context.Canceled", err)
+	}
+}
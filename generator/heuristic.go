@@ -0,0 +1,421 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Heuristic describes changes without asking a model, for when none
+// answers. The type follows from what kind of files changed and how: new
+// source files are a feat, tests only a test, dependency manifests a build,
+// renames and deletions a refactor, new guards in existing code a fix, and
+// SQL follows the statements it adds.
+// The scope is the directory the files share, and the description says
+// what was done to which files, functions or dependencies.
+func Heuristic(changes *git.ChangeSet) (commitType, scope, description string) {
+	var files, minor []git.FileChange
+	for _, f := range changes.Files {
+		if f.Category == git.CategoryLockfile || f.Category == git.CategoryGenerated {
+			minor = append(minor, f)
+		} else {
+			files = append(files, f)
+		}
+	}
+	// Lockfiles and generated code only count when nothing else changed
+	if len(files) == 0 {
+		files = minor
+	}
+	if len(files) == 0 {
+		return "chore", "", "update files"
+	}
+	scope = heuristicScope(files)
+
+	only := func(match func(git.FileChange) bool) bool {
+		for _, f := range files {
+			if !match(f) {
+				return false
+			}
+		}
+		return true
+	}
+	category := func(categories ...string) func(git.FileChange) bool {
+		return func(f git.FileChange) bool {
+			for _, c := range categories {
+				if f.Category == c {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	status := func(s string) func(git.FileChange) bool {
+		return func(f git.FileChange) bool { return strings.HasPrefix(f.Status, s) }
+	}
+	some := func(match func(git.FileChange) bool) bool {
+		return !only(func(f git.FileChange) bool { return !match(f) })
+	}
+	var added, deleted int
+	for _, f := range files {
+		added += f.Added
+		deleted += f.Deleted
+	}
+
+	switch {
+	case only(status("R")) && added+deleted <= 2*len(files):
+		return "refactor", scope, describeRenames(files)
+	case only(category(git.CategoryTest)):
+		verb := "update"
+		if only(status("A")) {
+			verb = "add"
+		} else if only(status("D")) {
+			verb = "remove"
+		}
+		return "test", scope, verb + " tests for " + fileNames(files, true)
+	case only(category(git.CategoryDocs)):
+		return "docs", scope, verbFor(files) + " " + fileNames(files, false)
+	case only(category(git.CategoryCI)):
+		return "ci", scope, verbFor(files) + " " + fileNames(files, false)
+	case only(func(f git.FileChange) bool {
+		return isManifest(f.Path) || category(git.CategoryBuild, git.CategoryLockfile)(f)
+	}):
+		if deps := dependencyChanges(files); deps != "" {
+			return "build", scope, deps
+		}
+		return "build", scope, verbFor(files) + " " + fileNames(files, false)
+	case only(func(f git.FileChange) bool { return strings.ToLower(path.Ext(f.Path)) == ".sql" }):
+		if commitType, description, ok := sqlHeuristic(files); ok {
+			return commitType, "db", description
+		}
+	}
+
+	var source []git.FileChange
+	for _, f := range files {
+		if f.Category == git.CategorySource {
+			source = append(source, f)
+		}
+	}
+	if len(source) == 0 {
+		return "chore", scope, verbFor(files) + " " + fileNames(files, false)
+	}
+	switch {
+	case some(func(f git.FileChange) bool { return f.Status == "A" && f.Category == git.CategorySource }):
+		var created []git.FileChange
+		for _, f := range source {
+			if f.Status == "A" {
+				created = append(created, f)
+			}
+		}
+		return "feat", scope, "add " + fileNames(created, true)
+	case only(status("D")):
+		return "refactor", scope, "remove " + fileNames(files, true)
+	}
+	if decls := newDeclarations(source); len(decls) > 0 {
+		return "feat", scope, "add " + joinNames(decls) + " to " + fileNames(source, true)
+	}
+	if guard, where := newGuard(source); guard != "" {
+		return "fix", scope, guard + " in " + where
+	}
+	if deleted > 2*added {
+		return "refactor", scope, "simplify " + fileNames(source, true)
+	}
+	return "chore", scope, "update " + fileNames(source, true)
+}
+
+// genericDirs are directory names that say nothing about what is in them,
+// so they make no scope.
+var genericDirs = map[string]bool{
+	"src": true, "lib": true, "pkg": true, "internal": true, "cmd": true, "app": true,
+	"source": true, "main": true, "java": true, "kotlin": true, "python": true, "go": true,
+	"test": true, "tests": true, "spec": true, "docs": true, "doc": true,
+	".github": true, "workflows": true, "com": true, "org": true,
+}
+
+var nonScopeChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// heuristicScope is the deepest meaningful directory all files are in, or
+// "" when they only share the top of the repository.
+func heuristicScope(files []git.FileChange) string {
+	common := path.Dir(files[0].Path)
+	for _, f := range files[1:] {
+		for common != "." && f.Path != common && !strings.HasPrefix(f.Path, common+"/") {
+			common = path.Dir(common)
+		}
+	}
+	parts := strings.Split(common, "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] != "." && !genericDirs[strings.ToLower(parts[i])] {
+			return strings.Trim(nonScopeChars.ReplaceAllString(strings.ToLower(parts[i]), "-"), "-")
+		}
+	}
+	return ""
+}
+
+// verbFor is how a description starts for files: add when they are all
+// new, remove when they are all gone and update otherwise.
+func verbFor(files []git.FileChange) string {
+	added, deleted := true, true
+	for _, f := range files {
+		added = added && f.Status == "A"
+		deleted = deleted && f.Status == "D"
+	}
+	switch {
+	case added:
+		return "add"
+	case deleted:
+		return "remove"
+	}
+	return "update"
+}
+
+// fileNames names files in a description: by base name, or for source
+// files by the name without extension or test suffix, such as tokenizer
+// for tokenizer_test.go. Beyond three names, they are counted.
+func fileNames(files []git.FileChange, stems bool) string {
+	var names []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		name := path.Base(f.Path)
+		if stems {
+			name = fileStem(name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) > 3 {
+		return fmt.Sprintf("%d files", len(names))
+	}
+	return joinNames(names)
+}
+
+// fileStem is a file name without its extension and test affixes.
+func fileStem(name string) string {
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "_test"), "_spec")
+	return strings.TrimPrefix(name, "test_")
+}
+
+// joinNames lists names the way a sentence does: "a", "a and b", "a, b and
+// c", and "a, b and 3 more" past three.
+func joinNames(names []string) string {
+	switch {
+	case len(names) == 1:
+		return names[0]
+	case len(names) > 3:
+		return fmt.Sprintf("%s, %s and %d more", names[0], names[1], len(names)-2)
+	case len(names) > 1:
+		return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	}
+	return ""
+}
+
+// describeRenames says where renamed files went: "rename a.go to b.go",
+// "move a.go to pkg/util" or "move 3 files to pkg/util".
+func describeRenames(files []git.FileChange) string {
+	if len(files) == 1 {
+		f := files[0]
+		oldDir, oldBase := path.Split(f.OldPath)
+		newDir, newBase := path.Split(f.Path)
+		switch {
+		case oldDir == newDir:
+			return "rename " + oldBase + " to " + newBase
+		case oldBase == newBase && newDir == "":
+			return "move " + newBase + " to the top"
+		case oldBase == newBase:
+			return "move " + newBase + " to " + strings.TrimSuffix(newDir, "/")
+		}
+		return "move " + f.OldPath + " to " + f.Path
+	}
+	dir := path.Dir(files[0].Path)
+	for _, f := range files[1:] {
+		if path.Dir(f.Path) != dir {
+			return fmt.Sprintf("rename %d files", len(files))
+		}
+	}
+	return fmt.Sprintf("move %d files to %s", len(files), dir)
+}
+
+// isManifest reports whether p declares dependencies.
+func isManifest(p string) bool {
+	base := strings.ToLower(path.Base(p))
+	switch base {
+	case "go.mod", "package.json", "pyproject.toml", "cargo.toml", "gemfile", "pipfile",
+		"composer.json", "pom.xml", "build.gradle", "build.gradle.kts":
+		return true
+	}
+	return strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt")
+}
+
+// dependencyLines match a dependency and its version in the manifests
+// isManifest knows: go.mod, package.json and composer.json, requirements
+// files, and Cargo.toml or pyproject.toml.
+var dependencyLines = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:require\s+)?([\w.-]+\.[\w.-]+/[\w./-]+)\s+(v[\w.+-]+)`),
+	regexp.MustCompile(`^\s*"(@?[\w.-]+(?:/[\w.-]+)?)"\s*:\s*"[~^>=]*(\d[^"]*)"`),
+	regexp.MustCompile(`^([A-Za-z0-9_.-]+)(?:\[[\w,]+\])?\s*(?:==|>=|~=)\s*([\w.]+)`),
+	regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=\s*(?:\{\s*version\s*=\s*)?"[~^>=]*(\d[^"]*)"`),
+}
+
+// notDependencies are manifest keys with a version that is not one of a
+// dependency.
+var notDependencies = map[string]bool{
+	"version": true, "edition": true, "rust-version": true, "python": true, "node": true, "npm": true,
+}
+
+// dependencyChanges describes the dependencies the manifests among files
+// add, remove or bump: "bump golang.org/x/term to v0.16.0", "add lodash",
+// or "update yaml, lodash and 2 more dependencies". It is "" when no
+// dependency line changed.
+func dependencyChanges(files []git.FileChange) string {
+	type versions struct{ old, new string }
+	deps := map[string]*versions{}
+	for _, f := range files {
+		if !isManifest(f.Path) {
+			continue
+		}
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				if line == "" || line[0] != '+' && line[0] != '-' {
+					continue
+				}
+				for _, re := range dependencyLines {
+					m := re.FindStringSubmatch(line[1:])
+					if m == nil || notDependencies[m[1]] {
+						continue
+					}
+					if deps[m[1]] == nil {
+						deps[m[1]] = &versions{}
+					}
+					if line[0] == '+' {
+						deps[m[1]].new = m[2]
+					} else {
+						deps[m[1]].old = m[2]
+					}
+					break
+				}
+			}
+		}
+	}
+	var names []string
+	for name, v := range deps {
+		if v.old != v.new {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) > 1:
+		return "update " + joinNames(names) + " dependencies"
+	}
+	name, v := names[0], deps[names[0]]
+	switch {
+	case v.old == "":
+		return "add " + name + " dependency"
+	case v.new == "":
+		return "remove " + name + " dependency"
+	}
+	return "bump " + name + " to " + v.new
+}
+
+// declarationLines match the declaration of a function or type in common
+// languages, with its name as the first group.
+var declarationLines = []*regexp.Regexp{
+	regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^type\s+([A-Za-z_]\w*)\s`),
+	regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:pub(?:\([\w:]+\))?\s+)?(?:async\s+)?fn\s+([A-Za-z_]\w*)`),
+}
+
+// newDeclarations are the functions and types added to files that did not
+// exist before, in order of appearance. A declaration moved or changed has
+// a removed line with its name and does not count.
+func newDeclarations(files []git.FileChange) []string {
+	var added []string
+	removed := map[string]bool{}
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				if line == "" || line[0] != '+' && line[0] != '-' {
+					continue
+				}
+				for _, re := range declarationLines {
+					if m := re.FindStringSubmatch(line[1:]); m != nil {
+						if line[0] == '+' {
+							added = append(added, m[1])
+						} else {
+							removed[m[1]] = true
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range added {
+		if !removed[name] && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// guards are checks whose addition to existing code usually fixes a bug,
+// with how a description puts it, most specific first. Error checks come
+// before nil checks, which they look like in Go.
+var guards = []struct {
+	pattern *regexp.Regexp
+	fix     string
+}{
+	{regexp.MustCompile(`len\([^)]*\)\s*(==|<=|<)\s*[01]\b|==\s*""|\.length\s*===?\s*0|\.isEmpty\(\)|is_empty\(\)|\bnot\s+\w+\s*:`), "handle empty input"},
+	{regexp.MustCompile(`\berr\s*!=\s*nil|\bcatch\s*[({]|\bexcept\b|\brescue\b|\.catch\(|\?;\s*$`), "handle errors"},
+	{regexp.MustCompile(`\b\w+\s*[!=]=\s*nil\b|[!=]==?\s*(null|undefined)\b|\bis (not )?None\b|\.is_none\(\)`), "handle missing values"},
+	{regexp.MustCompile(`\b(>=|>)\s*len\(|<\s*0\b|out of range|\.length\s*(>=|>)`), "check bounds"},
+	{regexp.MustCompile(`\.Lock\(\)|\bsync\.|\batomic\.|synchronized`), "guard concurrent access"},
+}
+
+// hunkFunction matches the function git names in a hunk header.
+var hunkFunction = regexp.MustCompile(`(?:func\s+(?:\([^)]*\)\s*)?|def\s+|function\s+|fn\s+)?([A-Za-z_]\w*)\s*\(`)
+
+// newGuard finds the most specific kind of guard the changes add to
+// existing code, and where: the function of the first hunk adding one, as
+// git names it, or else the file.
+func newGuard(files []git.FileChange) (fix, where string) {
+	best := len(guards)
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				if line == "" || line[0] != '+' {
+					continue
+				}
+				for i, g := range guards[:best] {
+					if g.pattern.MatchString(line[1:]) {
+						best, where = i, fileStem(path.Base(f.Path))
+						if m := hunkFunction.FindStringSubmatch(h.Header); m != nil {
+							where = m[1]
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+	if best == len(guards) {
+		return "", ""
+	}
+	return guards[best].fix, where
+}
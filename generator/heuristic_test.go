@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestHeuristic(t *testing.T) {
+	file := func(status, path string, lines ...string) git.FileChange {
+		f := git.FileChange{ChangedFile: git.ChangedFile{Status: status, Path: path}, Category: git.ClassifyPath(path)}
+		for _, l := range lines {
+			if l[0] == '+' {
+				f.Added++
+			} else if l[0] == '-' {
+				f.Deleted++
+			}
+		}
+		if len(lines) > 0 {
+			f.Hunks = []git.Hunk{{Header: "func (t *Tokenizer) Next() (Token, error) {", Lines: lines}}
+		}
+		return f
+	}
+	renamed := file("R100", "internal/store/cache.go")
+	renamed.OldPath = "internal/store/lru.go"
+
+	tests := []struct {
+		name  string
+		files []git.FileChange
+		want  string
+	}{
+		{"nothing", nil, "chore: update files"},
+		{"new source files", []git.FileChange{file("A", "api/users.go"), file("A", "api/users_test.go"), file("M", "README.md")}, "feat: add users"},
+		{"tests only", []git.FileChange{file("M", "parser/lexer_test.go"), file("A", "parser/parser_test.go")}, "test(parser): update tests for lexer and parser"},
+		{"docs only", []git.FileChange{file("M", "docs/install.md")}, "docs: update install.md"},
+		{"workflow", []git.FileChange{file("A", ".github/workflows/release.yml")}, "ci: add release.yml"},
+		{"rename", []git.FileChange{renamed}, "refactor(store): rename lru.go to cache.go"},
+		{"removed source", []git.FileChange{file("D", "pkg/legacy/v1.go"), file("D", "pkg/legacy/v1_test.go")}, "refactor(legacy): remove v1"},
+		{
+			"dependency bump",
+			[]git.FileChange{
+				file("M", "go.mod", "-\tgolang.org/x/term v0.15.0", "+\tgolang.org/x/term v0.16.0"),
+				file("M", "go.sum", "-golang.org/x/term v0.15.0 h1:x", "+golang.org/x/term v0.16.0 h1:y"),
+			},
+			"build: bump golang.org/x/term to v0.16.0",
+		},
+		{"new dependency", []git.FileChange{file("M", "web/package.json", ` "dependencies": {`, `+    "lodash": "^4.17.21",`)}, "build(web): add lodash dependency"},
+		{"lockfile only", []git.FileChange{file("M", "go.sum")}, "build: update go.sum"},
+		{"new function", []git.FileChange{file("M", "parser/tokenizer.go", "+func Peek(s string) rune {", "+\treturn 0", "+}")}, "feat(parser): add Peek to tokenizer"},
+		{
+			"new guard",
+			[]git.FileChange{file("M", "parser/tokenizer.go", "\tif err != nil {", "+\tif len(t.input) == 0 {", "+\t\treturn Token{}, io.EOF", "+\t}")},
+			"fix(parser): handle empty input in Next",
+		},
+		{
+			"error handling",
+			[]git.FileChange{file("M", "src/server/http.go", "-\tw.Write(body)", "+\tif _, err := w.Write(body); err != nil {", "+\t\tlog.Print(err)", "+\t}")},
+			"fix(server): handle errors in Next",
+		},
+		{"sql index", []git.FileChange{file("A", "db/migrations/004_orders.sql", "+CREATE INDEX idx_orders_created ON orders (created_at);")}, "feat(db): add index on orders.created_at"},
+		{"sql drop", []git.FileChange{file("A", "db/migrations/005_cleanup.sql", "+DROP TABLE IF EXISTS legacy_orders;")}, "refactor(db): drop table legacy_orders"},
+		{"mostly removed", []git.FileChange{file("M", "cmd/tool/run.go", "-a", "-b", "-c", "+d")}, "refactor(tool): simplify run"},
+		{"plain edit", []git.FileChange{file("M", "config.go", "-port := 80", "+port := 8080")}, "chore: update config"},
+		{"config", []git.FileChange{file("M", "deploy/values.yaml", "-replicas: 1", "+replicas: 2")}, "chore(deploy): update values.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commitType, scope, description := Heuristic(&git.ChangeSet{Files: tt.files})
+			got := commitType
+			if scope != "" {
+				got += "(" + scope + ")"
+			}
+			if got += ": " + description; got != tt.want {
+				t.Errorf("Heuristic() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Attempt is a generated message the author turned down, with their
+// feedback on it.
+type Attempt struct {
+	Message  string
+	Feedback string
+}
+
+// Options control how a commit message is asked for.
+type Options struct {
+	// DiffBudget is the most bytes of diff included in the prompt; zero
+	// sends file names only.
+	DiffBudget int
+	// Language is the language of the description and body; empty means
+	// English.
+	Language string
+	// Body asks for a body explaining why, Footer for a BREAKING CHANGE
+	// footer when the change breaks compatibility.
+	Body, Footer bool
+	// Note is the author's rough draft of the message. The model keeps its
+	// intent and expands and formats it rather than starting from the diff.
+	Note string
+	// Why is the author's reason for the change, which the diff cannot
+	// show. It becomes the motivation paragraph of the body.
+	Why string
+	// Notes are free-form notes the author kept while working, summarized
+	// into the body.
+	Notes string
+	// Type and Scope pin the type and scope of the message; the model
+	// writes the rest.
+	Type, Scope string
+	// Types are the commit types the project allows; empty means the
+	// conventional defaults.
+	Types []string
+	// Rules describes the project's other commit rules in words, such as
+	// the longest subject allowed.
+	Rules string
+	// Style describes how the project's recent commits are written, as
+	// returned by DescribeStyle, for the message to match.
+	Style string
+	// Extra is context from the author, such as an issue description.
+	Extra string
+	// Series are the subjects last committed in the same scope, oldest
+	// first, for the message to read as the next step of that work.
+	Series []string
+	// Analysis describes what the changes do that their lines do not
+	// show, such as the resources an infrastructure change creates.
+	Analysis string
+	// Structural holds, by path, structural diffs of files whose line diff
+	// is mostly noise, such as notebooks; the prompt shows them instead.
+	Structural map[string]string
+	// History lists the attempts the author turned down, oldest first, so
+	// a retry can address their feedback.
+	History []Attempt
+	// PromptVersion selects a version of the built-in commit-message
+	// prompt; zero selects the latest.
+	PromptVersion int
+	// PromptTemplate replaces the built-in prompt with a text/template
+	// given the same data.
+	PromptTemplate string
+}
+
+// PromptData is the template data for the commit message prompt.
+func PromptData(changes *git.ChangeSet, opts Options) map[string]string {
+	return map[string]string{
+		"Changes":  changes.Describe(),
+		"Sections": changes.DescribeSections(),
+		"Diff":     StructuredDiffContext(changes, opts.DiffBudget, opts.Structural),
+		"Comments": DescribeCommentDeltas(CommentDeltas(changes)),
+		"Note":     opts.Note,
+		"Why":      opts.Why,
+		"Notes":    opts.Notes,
+		"Type":     opts.Type,
+		"Scope":    opts.Scope,
+		"Style":    opts.Style,
+		"Types":    strings.Join(opts.Types, ", "),
+		"Rules":    opts.Rules,
+		"Extra":    opts.Extra,
+		"Analysis": opts.Analysis,
+		"Language": opts.Language,
+		"Body":     flagText(opts.Body),
+		"Footer":   flagText(opts.Footer),
+		"History":  describeAttempts(opts.History),
+		"Series":   describeSeries(opts.Series),
+	}
+}
+
+// describeAttempts renders earlier attempts as a conversation: each message
+// followed by what the author said about it.
+func describeAttempts(attempts []Attempt) string {
+	var b strings.Builder
+	for i, a := range attempts {
+		fmt.Fprintf(&b, "\nAttempt %d:\n%s\n", i+1, a.Message)
+		if a.Feedback != "" {
+			fmt.Fprintf(&b, "Author: %s\n", a.Feedback)
+		} else {
+			b.WriteString("Author: (no comment; try a different wording)\n")
+		}
+	}
+	return b.String()
+}
+
+// describeSeries lists earlier subjects, one per line.
+func describeSeries(subjects []string) string {
+	var b strings.Builder
+	for _, s := range subjects {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	return b.String()
+}
+
+// flagText turns a switch into template data: "yes" when on, "" when off.
+func flagText(on bool) string {
+	if on {
+		return "yes"
+	}
+	return ""
+}
+
+// CommitPrompt renders the commit message prompt for changes: the custom
+// template if there is one, else the selected built-in version.
+func CommitPrompt(changes *git.ChangeSet, opts Options) (string, error) {
+	data := PromptData(changes, opts)
+	if opts.PromptTemplate == "" {
+		return RenderPromptVersion(PromptCommitMessage, opts.PromptVersion, data)
+	}
+	tmpl, err := template.New("prompt_template").Parse(opts.PromptTemplate)
+	if err != nil {
+		return "", fmt.Errorf("parsing prompt_template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering prompt_template: %v", err)
+	}
+	return b.String(), nil
+}
+
+// CommitMessage asks g for a conventional commit message describing
+// changes. An answer that does not follow the format is turned into one,
+// with the type guessed from the changed files.
+func CommitMessage(ctx context.Context, g Generator, changes *git.ChangeSet, opts Options) (string, error) {
+	prompt, err := CommitPrompt(changes, opts)
+	if err != nil {
+		return "", err
+	}
+	answer, err := g.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	types := opts.Types
+	if len(types) == 0 {
+		types = conventional.DefaultTypes
+	}
+	message := conventional.EnforceTypes(CleanOutput(answer), changes.CommitType(), types)
+	if opts.Type != "" {
+		message = conventional.WithType(message, opts.Type)
+	}
+	if opts.Scope != "" {
+		message = conventional.WithScope(message, opts.Scope)
+	}
+	return message, nil
+}
@@ -0,0 +1,309 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// promptVersion is one revision of a built-in prompt, written as a
+// text/template.
+type promptVersion struct {
+	Version  int
+	Template string
+}
+
+// Names of the built-in prompts.
+const (
+	PromptCommitMessage   = "commit-message"
+	PromptSquashTitle     = "squash-title"
+	PromptCompareSummary  = "compare-summary"
+	PromptBranchSummary   = "branch-summary"
+	PromptConflictSummary = "conflict-summary"
+	PromptExplain         = "explain"
+	PromptPushSummary     = "push-summary"
+	PromptPullSummary     = "pull-request-summary"
+	PromptSplitGroups     = "split-groups"
+	PromptReleaseSummary  = "release-summary"
+	PromptPullRequest     = "pull-request"
+	PromptFileSummaries   = "file-summaries"
+)
+
+// builtinPrompts holds every revision of every prompt, oldest first. Prompts
+// are never edited in place: a change adds a new version, so results can be
+// compared across versions with `smart-commit prompt test --version`.
+var builtinPrompts = map[string][]promptVersion{
+	PromptCommitMessage: {
+		{1, "Generate a concise git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. The changes are: {{.Changes}}"},
+		{2, "Generate a concise git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. The changes are:\n{{.Changes}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{3, "Generate a concise git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one.\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{4, "Generate a concise git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{5, "Generate a concise git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in a short body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{6, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made, then one \"- \" bullet point per group of related files saying what changed there.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{7, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made, then one \"- \" bullet point per group of related files saying what changed there.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{8, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if .Why}} After the subject and a blank line, write a body of one short paragraph on why the change was made, built on the author's reason below.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{9, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore. Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{10, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore.{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}"},
+		{11, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore.{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+		{12, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. Use types like feat, fix, docs, style, refactor, test, chore.{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Style}} Match the style of the project's recent commits, summarized below: tense, emoji use, scope names, subject length and capitalization. The format rules above still apply.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}" +
+			"{{if .Style}}\n\nCommit style of this project:\n{{.Style}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+		{13, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. {{if .Types}}Use one of these types: {{.Types}}.{{else}}Use types like feat, fix, docs, style, refactor, test, chore.{{end}}{{if .Rules}} Follow the project's commit rules: {{.Rules}}{{end}}{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Style}} Match the style of the project's recent commits, summarized below: tense, emoji use, scope names, subject length and capitalization. The format rules above still apply.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}" +
+			"{{if .Style}}\n\nCommit style of this project:\n{{.Style}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+		{14, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. {{if .Types}}Use one of these types: {{.Types}}.{{else}}Use types like feat, fix, docs, style, refactor, test, chore.{{end}}{{if .Rules}} Follow the project's commit rules: {{.Rules}}{{end}}{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Analysis}} The analysis below says what the changes do beyond their lines, such as the infrastructure they create or destroy; let it guide the subject.{{end}}" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Style}} Match the style of the project's recent commits, summarized below: tense, emoji use, scope names, subject length and capitalization. The format rules above still apply.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}" +
+			"{{if .Style}}\n\nCommit style of this project:\n{{.Style}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Analysis}}\nAnalysis:\n{{.Analysis}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+		{15, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. {{if .Types}}Use one of these types: {{.Types}}.{{else}}Use types like feat, fix, docs, style, refactor, test, chore.{{end}}{{if .Rules}} Follow the project's commit rules: {{.Rules}}{{end}}{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Analysis}} The analysis below says what the changes do beyond their lines, such as the infrastructure they create or destroy; let it guide the subject.{{end}}" +
+			"{{if .Series}} The commits made just before in the same area are listed below: when this change continues that work, write the message as the next step of it, consistent in wording and scope, without repeating what they already said.{{end}}" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Style}} Match the style of the project's recent commits, summarized below: tense, emoji use, scope names, subject length and capitalization. The format rules above still apply.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}" +
+			"{{if .Style}}\n\nCommit style of this project:\n{{.Style}}{{end}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Analysis}}\nAnalysis:\n{{.Analysis}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .Series}}\n\nEarlier commits in the same area, oldest first:\n{{.Series}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+		{16, "Generate a git commit message following conventional commit format (type(scope): description) for these changes. {{if .Types}}Use one of these types: {{.Types}}.{{else}}Use types like feat, fix, docs, style, refactor, test, chore.{{end}}{{if .Rules}} Follow the project's commit rules: {{.Rules}}{{end}}{{if .Type}} The type is {{.Type}}.{{end}}{{if .Scope}} The scope is {{.Scope}}.{{end}} Describe what the change does and why, based on the diff when there is one." +
+			" The changed files are grouped by kind, each group with a summary of its files, directories and lines: let the groups guide the type and scope (only tests is test, only docs is docs, new files are usually feat, a rename alone is refactor) and name the scope after the directory most of the change is in." +
+			"{{if .Note}} The author has drafted the message below. It states the intent: keep its meaning and wording where you can, expand it with what the diff shows, and put it in the format above, choosing the type and scope from the diff if the draft has none.{{end}}" +
+			"{{if .Body}} After the subject and a blank line, write a body: a short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}, then one \"- \" bullet point per group of related files saying what changed there.{{else if or .Why .Notes}} After the subject and a blank line, write a body of one short paragraph on why the change was made{{if .Why}}, built on the author's reason below{{end}}.{{else}} Keep it to the subject line unless comment changes are worth a short body.{{end}}" +
+			"{{if .Notes}} The author's working notes are below: weave what in them explains the change (motivation, decisions, trade-offs) into the body in a few sentences, and leave out the rest; never copy them verbatim.{{end}}{{if .Footer}} If the change breaks compatibility (a removed or renamed public API, changed configuration or command-line behavior), add a `!` after the type or scope and end with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\".{{end}}" +
+			" When comment changes are listed, they are strong hints of intent: mention notable ones in the body, e.g. \"Removes the TODO about retry logic.\"" +
+			"{{if .Analysis}} The analysis below says what the changes do beyond their lines, such as the infrastructure they create or destroy; let it guide the subject.{{end}}" +
+			"{{if .Series}} The commits made just before in the same area are listed below: when this change continues that work, write the message as the next step of it, consistent in wording and scope, without repeating what they already said.{{end}}" +
+			"{{if .History}} The author turned down earlier attempts; they are listed below with the author's feedback, oldest first. Write a new message that addresses all of the feedback.{{end}}{{if .Language}} Write the description and body in {{.Language}}; keep the type and scope in English.{{end}}" +
+			"{{if .Style}} Match the style of the project's recent commits, summarized below: tense, emoji use, scope names, subject length and capitalization. The format rules above still apply.{{end}}" +
+			"{{if .Note}}\n\nAuthor's draft:\n{{.Note}}{{end}}" +
+			"{{if .Why}}\n\nAuthor's reason for the change:\n{{.Why}}{{end}}" +
+			"{{if .Notes}}\n\nAuthor's notes:\n{{.Notes}}{{end}}" +
+			"{{if .Style}}\n\nCommit style of this project:\n{{.Style}}{{end}}\n\nChanged files, by kind:\n{{.Sections}}" +
+			"{{if .Comments}}\nComment changes:\n{{.Comments}}{{end}}" +
+			"{{if .Analysis}}\nAnalysis:\n{{.Analysis}}{{end}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}" +
+			"{{if .Extra}}\nAdditional context from the author: {{.Extra}}{{end}}" +
+			"{{if .Series}}\n\nEarlier commits in the same area, oldest first:\n{{.Series}}{{end}}" +
+			"{{if .History}}\n\nEarlier attempts:\n{{.History}}{{end}}"},
+	},
+	PromptSquashTitle: {
+		{1, "Generate a concise conventional commit title (type(scope): description) for squash-merging this pull request, based on its title and commits:\n{{.Changes}}"},
+	},
+	PromptCompareSummary: {
+		{1, "Summarize in one or two short paragraphs of prose what merging {{.Head}} into {{.Base}} would change. Focus on behavior and intent, not on listing files.\n\n" +
+			"Commits:\n{{range .Commits}}- {{.Subject}}\n{{end}}\nChanged files:\n{{.Changes}}"},
+	},
+	PromptBranchSummary: {
+		{1, "In one short line (under 80 characters), summarize the work done on the git branch {{printf \"%q\" .Branch}} given its commits:\n{{range .Commits}}- {{.Subject}}\n{{end}}"},
+	},
+	PromptConflictSummary: {
+		{1, "Two branches changed the file {{.File}} in conflicting ways. In two short lines, starting with \"Ours:\" and \"Theirs:\", summarize what each side changed.\n\nOurs:\n{{.Ours}}\n\nTheirs:\n{{.Theirs}}"},
+	},
+	PromptExplain: {
+		{1, "Explain in a short paragraph of plain language what this git commit does and why it was likely made:\n\n{{.Show}}"},
+	},
+	PromptPushSummary: {
+		{1, "Summarize in a short paragraph what this push to {{.Ref}} changes, based on its commits:\n{{.Commits}}"},
+	},
+	PromptSplitGroups: {
+		{1, "These staged files may contain several unrelated changes. Group them into logical commits, one per independent change; keep tests with the code they test. Answer with one line per commit listing its file numbers separated by commas, e.g. \"1,3\", and nothing else. Every file must appear exactly once.\n\n{{.Files}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}"},
+	},
+	PromptPullSummary: {
+		{1, "Summarize in a short paragraph what the pull request {{printf \"%q\" .Title}} changes, based on its commits:\n{{.Commits}}"},
+	},
+	PromptPullRequest: {
+		{1, "Write a pull request for merging {{.Head}} into {{.Base}}. Answer with the title on the first line, as a conventional commit title (type(scope): description), then a blank line, then the description in Markdown." +
+			"{{if .Template}} Fill in the project's pull request template, keeping its headings:\n\n{{.Template}}\n{{else}} Use the sections \"## Summary\" (what the change does and why, in a short paragraph), \"## Changes\" (a bullet list of the notable changes) and \"## Testing\" (how it was tested, based on the tests that changed, or how to test it).{{end}}\n\n" +
+			"Commits:\n{{range .Commits}}- {{.Subject}}\n{{end}}\nChanged files:\n{{.Changes}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}"},
+	},
+	PromptFileSummaries: {
+		{1, "For a reviewer reading this commit file by file, say in one short line (under 100 characters) what changed in each file and why. Answer with one line per file, \"path: summary\", in the order given, and nothing else.\n\n" +
+			"Commit message:\n{{.Message}}\n\nChanged files:\n{{.Changes}}" +
+			"{{if .Diff}}\nDiff (possibly truncated):\n{{.Diff}}{{end}}"},
+	},
+	PromptReleaseSummary: {
+		{1, "Write a short paragraph for the users of this project summarizing what {{.Version}} brings, based on its commits. Lead with what matters most to them and do not list every commit.\n\nCommits:\n{{range .Commits}}- {{.Subject}}\n{{end}}"},
+	},
+}
+
+// LatestPromptVersion returns the newest version of a prompt.
+func LatestPromptVersion(name string) int {
+	versions := builtinPrompts[name]
+	if len(versions) == 0 {
+		return 0
+	}
+	return versions[len(versions)-1].Version
+}
+
+// RenderPromptVersion renders a specific version of a prompt; version 0
+// selects the latest.
+func RenderPromptVersion(name string, version int, data interface{}) (string, error) {
+	versions := builtinPrompts[name]
+	if version == 0 {
+		version = LatestPromptVersion(name)
+	}
+	for _, v := range versions {
+		if v.Version != version {
+			continue
+		}
+		tmpl, err := template.New(name).Parse(v.Template)
+		if err != nil {
+			return "", fmt.Errorf("prompt %s v%d: %v", name, version, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("prompt %s v%d: %v", name, version, err)
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("unknown prompt %s v%d", name, version)
+}
+
+// RenderPrompt renders the latest version of a built-in prompt. The
+// templates are fixed, so a failure is a programming error.
+func RenderPrompt(name string, data interface{}) string {
+	prompt, err := RenderPromptVersion(name, 0, data)
+	if err != nil {
+		panic(err)
+	}
+	return prompt
+}
+
+// PromptNames returns the built-in prompt names, sorted.
+func PromptNames() []string {
+	names := make([]string, 0, len(builtinPrompts))
+	for name := range builtinPrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PromptVersions returns the versions of a built-in prompt, oldest first.
+func PromptVersions(name string) []int {
+	var versions []int
+	for _, v := range builtinPrompts[name] {
+		versions = append(versions, v.Version)
+	}
+	return versions
+}
@@ -0,0 +1,417 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Copilot shells out to the GitHub Copilot CLI.
+type Copilot struct {
+	// Host is the GitHub Enterprise Server or GHE.com host gh talks to;
+	// empty means github.com, or whatever gh picks on its own.
+	Host string
+}
+
+// command builds a gh command for the configured host, killed when ctx is
+// done.
+func (g *Copilot) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	if g.Host != "" {
+		cmd.Env = append(os.Environ(), "GH_HOST="+g.Host)
+	}
+	return cmd
+}
+
+func (g *Copilot) Name() string { return "GitHub Copilot CLI" }
+
+func (g *Copilot) Check() error {
+	cmd := g.command(context.Background(), "copilot", "--version")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		return fmt.Errorf("GitHub Copilot CLI is not installed or not accessible. Please install it first: https://github.com/github/gh-copilot")
+	}
+	return nil
+}
+
+func (g *Copilot) Generate(ctx context.Context, prompt string) (string, error) {
+	// Feed the prompt on stdin: no shell, so nothing to quote and nothing
+	// that needs sh on Windows
+	cmd := g.command(ctx, "copilot", "suggest")
+	cmd.Stdin = strings.NewReader(prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := git.DefaultRunner.Run(cmd); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("gh copilot suggest: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("gh copilot suggest failed: %v: %s", err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// OpenAI uses the OpenAI chat completions API, or any compatible endpoint.
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAI returns an OpenAI provider. Empty baseURL and model select the
+// public API and gpt-4o-mini; a nil client uses http.DefaultClient.
+func NewOpenAI(baseURL, apiKey, model string, client *http.Client) *OpenAI {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &OpenAI{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, model: model, client: orDefault(client)}
+}
+
+func (g *OpenAI) Name() string { return "OpenAI" }
+
+func (g *OpenAI) Model() string { return g.model }
+
+func (g *OpenAI) Check() error {
+	if g.apiKey == "" {
+		return fmt.Errorf("OPENAI_API_KEY must be set to use the openai provider")
+	}
+	return nil
+}
+
+func (g *OpenAI) Generate(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	req := struct {
+		Model       string    `json:"model"`
+		Messages    []message `json:"messages"`
+		Temperature float64   `json:"temperature"`
+	}{
+		Model:       g.model,
+		Messages:    []message{{Role: "system", Content: SystemPrompt}, {Role: "user", Content: prompt}},
+		Temperature: 0.2,
+	}
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + g.apiKey}
+	if err := postJSON(ctx, g.client, g.baseURL+"/chat/completions", headers, req, &resp); err != nil {
+		return "", fmt.Errorf("openai: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("openai: empty response")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// Anthropic uses the Anthropic Messages API.
+type Anthropic struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewAnthropic returns an Anthropic provider. Empty baseURL and model select
+// the public API and claude-3-5-haiku-latest; a nil client uses
+// http.DefaultClient.
+func NewAnthropic(baseURL, apiKey, model string, client *http.Client) *Anthropic {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	if model == "" {
+		model = "claude-3-5-haiku-latest"
+	}
+	return &Anthropic{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, model: model, client: orDefault(client)}
+}
+
+func (g *Anthropic) Name() string { return "Anthropic" }
+
+func (g *Anthropic) Model() string { return g.model }
+
+func (g *Anthropic) Check() error {
+	if g.apiKey == "" {
+		return fmt.Errorf("ANTHROPIC_API_KEY must be set to use the anthropic provider")
+	}
+	return nil
+}
+
+func (g *Anthropic) Generate(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	req := struct {
+		Model     string    `json:"model"`
+		MaxTokens int       `json:"max_tokens"`
+		System    string    `json:"system"`
+		Messages  []message `json:"messages"`
+	}{
+		Model:     g.model,
+		MaxTokens: 1024,
+		System:    SystemPrompt,
+		Messages:  []message{{Role: "user", Content: prompt}},
+	}
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	headers := map[string]string{"x-api-key": g.apiKey, "anthropic-version": "2023-06-01"}
+	if err := postJSON(ctx, g.client, g.baseURL+"/v1/messages", headers, req, &resp); err != nil {
+		return "", fmt.Errorf("anthropic: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("anthropic: empty response")
+	}
+	return text.String(), nil
+}
+
+// Ollama uses an Ollama server.
+type Ollama struct {
+	host   string
+	model  string
+	client *http.Client
+}
+
+// NewOllama returns an Ollama provider. An empty host selects a local
+// server and an empty model llama3.1; a nil client uses http.DefaultClient.
+func NewOllama(host, model string, client *http.Client) *Ollama {
+	if host == "" {
+		host = "http://localhost:11434"
+	} else if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if model == "" {
+		model = "llama3.1"
+	}
+	return &Ollama{host: strings.TrimRight(host, "/"), model: model, client: orDefault(client)}
+}
+
+func (g *Ollama) Name() string { return "Ollama" }
+
+func (g *Ollama) Model() string { return g.model }
+
+func (g *Ollama) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := g.get(ctx, "/api/tags")
+	if err != nil {
+		return fmt.Errorf("Ollama is not reachable at %s; start it with `ollama serve`", g.host)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (g *Ollama) Generate(ctx context.Context, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	req := struct {
+		Model    string    `json:"model"`
+		Messages []message `json:"messages"`
+		Stream   bool      `json:"stream"`
+	}{
+		Model:    g.model,
+		Messages: []message{{Role: "system", Content: SystemPrompt}, {Role: "user", Content: prompt}},
+	}
+	var resp struct {
+		Message message `json:"message"`
+	}
+	if err := postJSON(ctx, g.client, g.host+"/api/chat", nil, req, &resp); err != nil {
+		return "", fmt.Errorf("ollama: %w", err)
+	}
+	return resp.Message.Content, nil
+}
+
+// get sends a GET request for path to the server.
+func (g *Ollama) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return g.client.Do(req)
+}
+
+// Ping sends the smallest possible completion request.
+func (g *OpenAI) Ping(ctx context.Context) (Status, error) {
+	if err := g.Check(); err != nil {
+		return Status{}, err
+	}
+	req := map[string]interface{}{
+		"model":      g.model,
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		"max_tokens": 1,
+	}
+	start := time.Now()
+	header, err := postJSONHeader(ctx, g.client, g.baseURL+"/chat/completions", map[string]string{"Authorization": "Bearer " + g.apiKey}, req, &struct{}{})
+	if err != nil {
+		return Status{}, fmt.Errorf("openai: %w", err)
+	}
+	return Status{Latency: time.Since(start), Quota: quota(header, "x-ratelimit-remaining-requests", "x-ratelimit-limit-requests", "x-ratelimit-remaining-tokens")}, nil
+}
+
+// Ping sends the smallest possible message request.
+func (g *Anthropic) Ping(ctx context.Context) (Status, error) {
+	if err := g.Check(); err != nil {
+		return Status{}, err
+	}
+	req := map[string]interface{}{
+		"model":      g.model,
+		"max_tokens": 1,
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+	}
+	start := time.Now()
+	headers := map[string]string{"x-api-key": g.apiKey, "anthropic-version": "2023-06-01"}
+	header, err := postJSONHeader(ctx, g.client, g.baseURL+"/v1/messages", headers, req, &struct{}{})
+	if err != nil {
+		return Status{}, fmt.Errorf("anthropic: %w", err)
+	}
+	return Status{Latency: time.Since(start), Quota: quota(header, "anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-limit", "anthropic-ratelimit-tokens-remaining")}, nil
+}
+
+// Ping lists the server's models, failing when the configured one is not
+// pulled.
+func (g *Ollama) Ping(ctx context.Context) (Status, error) {
+	start := time.Now()
+	resp, err := g.get(ctx, "/api/tags")
+	if err != nil {
+		return Status{}, fmt.Errorf("Ollama is not reachable at %s; start it with `ollama serve`", g.host)
+	}
+	defer resp.Body.Close()
+	latency := time.Since(start)
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return Status{}, fmt.Errorf("ollama: %v", err)
+	}
+	for _, m := range tags.Models {
+		if m.Name == g.model || strings.TrimSuffix(m.Name, ":latest") == g.model {
+			return Status{Latency: latency}, nil
+		}
+	}
+	return Status{}, fmt.Errorf("model %s is not pulled; run `ollama pull %s`", g.model, g.model)
+}
+
+// Ping checks that the Copilot CLI is installed and gh is logged in.
+func (g *Copilot) Ping(ctx context.Context) (Status, error) {
+	start := time.Now()
+	if err := g.Check(); err != nil {
+		return Status{}, err
+	}
+	args := []string{"auth", "status"}
+	if g.Host != "" {
+		args = append(args, "--hostname", g.Host)
+	}
+	if err := git.DefaultRunner.Run(g.command(ctx, args...)); err != nil {
+		if g.Host != "" {
+			return Status{}, fmt.Errorf("gh is not logged in to %s; run `gh auth login --hostname %s`", g.Host, g.Host)
+		}
+		return Status{}, fmt.Errorf("gh is not logged in; run `gh auth login`")
+	}
+	return Status{Latency: time.Since(start)}, nil
+}
+
+// quota describes the rate limit left from the response headers named, or
+// returns "" when the provider sent none.
+func quota(header http.Header, remainingRequests, limitRequests, remainingTokens string) string {
+	var parts []string
+	if left := header.Get(remainingRequests); left != "" {
+		if limit := header.Get(limitRequests); limit != "" {
+			left += "/" + limit
+		}
+		parts = append(parts, left+" requests")
+	}
+	if tokens := header.Get(remainingTokens); tokens != "" {
+		parts = append(parts, tokens+" tokens")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, ", ") + " left"
+}
+
+// orDefault returns client, or http.DefaultClient when it is nil.
+func orDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// StatusError is an unsuccessful response from a provider's API.
+type StatusError struct {
+	Code   int
+	Status string
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// postJSON sends in as a JSON POST body with client and decodes the JSON
+// response into out. The request is abandoned when ctx is done.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, in, out interface{}) error {
+	_, err := postJSONHeader(ctx, client, url, headers, in, out)
+	return err
+}
+
+// postJSONHeader is postJSON that also returns the response headers.
+func postJSONHeader(ctx context.Context, client *http.Client, url string, headers map[string]string, in, out interface{}) (http.Header, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.Header, &StatusError{Code: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(out)
+}
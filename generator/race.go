@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Racing asks Primary and, when it has not answered within After, races
+// Fallback against it, taking the first usable answer. It keeps a slow
+// provider from stalling the commit flow.
+type Racing struct {
+	Primary  Generator
+	Fallback Generator
+	After    time.Duration
+	// OnRace, when set, is called as the fallback is started.
+	OnRace func()
+}
+
+func (g *Racing) Name() string { return g.Primary.Name() }
+
+// Model is the primary provider's model, if it names one.
+func (g *Racing) Model() string {
+	if m, ok := g.Primary.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+func (g *Racing) Check() error { return g.Primary.Check() }
+
+func (g *Racing) Generate(ctx context.Context, prompt string) (string, error) {
+	type result struct {
+		answer string
+		err    error
+	}
+	// The request still running when an answer is taken is canceled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ask := func(gen Generator, results chan<- result) {
+		answer, err := gen.Generate(ctx, prompt)
+		if err == nil && CleanOutput(answer) == "" {
+			err = fmt.Errorf("%s: empty answer", strings.ToLower(gen.Name()))
+		}
+		results <- result{answer, err}
+	}
+
+	// Buffered, so the losing request can finish without anyone waiting
+	results := make(chan result, 2)
+	go ask(g.Primary, results)
+	timer := time.NewTimer(g.After)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.answer, r.err
+	case <-timer.C:
+	}
+
+	// An unusable fallback leaves the primary to finish on its own
+	if g.Fallback.Check() != nil {
+		r := <-results
+		return r.answer, r.err
+	}
+	if g.OnRace != nil {
+		g.OnRace()
+	}
+	go ask(g.Fallback, results)
+	first := <-results
+	if first.err == nil {
+		return first.answer, nil
+	}
+	if second := <-results; second.err == nil {
+		return second.answer, nil
+	}
+	return "", first.err
+}
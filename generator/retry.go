@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Retrying bounds each request to Generator by Timeout and asks again when
+// one fails transiently (see Transient), waiting Backoff before the first
+// retry and twice as long before each next one. It gives up after Retries
+// retries, and at once when the caller's context is done.
+type Retrying struct {
+	Generator Generator
+	Retries   int
+	Backoff   time.Duration
+	// Timeout bounds each attempt; zero leaves it to the caller's context.
+	Timeout time.Duration
+	// OnRetry, when set, is called with the failure before each retry.
+	OnRetry func(err error, wait time.Duration)
+}
+
+func (g *Retrying) Name() string { return g.Generator.Name() }
+
+// Model is the wrapped provider's model, if it names one.
+func (g *Retrying) Model() string {
+	if m, ok := g.Generator.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+func (g *Retrying) Check() error { return g.Generator.Check() }
+
+func (g *Retrying) Generate(ctx context.Context, prompt string) (string, error) {
+	wait := g.Backoff
+	for attempt := 0; ; attempt++ {
+		answer, err := g.attempt(ctx, prompt)
+		if err == nil || attempt == g.Retries || ctx.Err() != nil || !Transient(err) {
+			return answer, err
+		}
+		if g.OnRetry != nil {
+			g.OnRetry(err, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", err
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// attempt makes one request, within Timeout.
+func (g *Retrying) attempt(ctx context.Context, prompt string) (string, error) {
+	if g.Timeout <= 0 {
+		return g.Generator.Generate(ctx, prompt)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, g.Timeout)
+	defer cancel()
+	answer, err := g.Generator.Generate(attemptCtx, prompt)
+	if err != nil && ctx.Err() == nil && attemptCtx.Err() != nil {
+		return "", fmt.Errorf("%s did not answer within %s: %w", g.Generator.Name(), g.Timeout, err)
+	}
+	return answer, err
+}
+
+// Transient reports whether a provider error is worth retrying: a network
+// failure, a timed-out request, a rate limit or a server error.
+// Cancellation and other client errors are not.
+func Transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == http.StatusRequestTimeout || status.Code == http.StatusTooManyRequests || status.Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
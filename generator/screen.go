@@ -0,0 +1,32 @@
+package generator
+
+import "context"
+
+// Screened passes every prompt through Screen before Generator sees it, so
+// content the organization does not want sent to a provider is redacted
+// or the request refused. Screen returns the prompt to send, or an error
+// to refuse it.
+type Screened struct {
+	Generator Generator
+	Screen    func(prompt string) (string, error)
+}
+
+func (g *Screened) Name() string { return g.Generator.Name() }
+
+// Model is the wrapped provider's model, if it names one.
+func (g *Screened) Model() string {
+	if m, ok := g.Generator.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
+
+func (g *Screened) Check() error { return g.Generator.Check() }
+
+func (g *Screened) Generate(ctx context.Context, prompt string) (string, error) {
+	prompt, err := g.Screen(prompt)
+	if err != nil {
+		return "", err
+	}
+	return g.Generator.Generate(ctx, prompt)
+}
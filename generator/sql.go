@@ -0,0 +1,215 @@
+package generator
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+// Kinds of SQL statements.
+const (
+	SQLSchema = "DDL"   // CREATE, ALTER, DROP, TRUNCATE and the like
+	SQLData   = "DML"   // INSERT, UPDATE, DELETE and the like
+	SQLQuery  = "query" // SELECT and WITH
+)
+
+// SQLChange is a statement a change adds to a .sql file or edits there.
+type SQLChange struct {
+	Path string
+	Line int
+	Kind string
+	// Statement is the statement on one line, cut short.
+	Statement string
+	// Description says what it does, e.g. "add index on orders.created_at".
+	Description string
+	// Destructive is set for DROP and TRUNCATE, which lose data.
+	Destructive bool
+}
+
+// sqlKinds maps the first keyword of a statement to its kind.
+var sqlKinds = map[string]string{
+	"create": SQLSchema, "alter": SQLSchema, "drop": SQLSchema, "truncate": SQLSchema,
+	"rename": SQLSchema, "comment": SQLSchema, "grant": SQLSchema, "revoke": SQLSchema,
+	"insert": SQLData, "update": SQLData, "delete": SQLData, "merge": SQLData,
+	"upsert": SQLData, "replace": SQLData, "copy": SQLData,
+	"select": SQLQuery, "with": SQLQuery,
+}
+
+// sqlName matches an identifier, possibly qualified and quoted.
+const sqlName = "([`\"\\[\\]\\w.]+)"
+
+// sqlDescriptions say what statements do, first match wins; the last
+// group of each is the object, or the groups are table and column.
+var sqlDescriptions = []struct {
+	re          *regexp.Regexp
+	verb        string
+	destructive bool
+}{
+	{regexp.MustCompile(`(?i)^create (?:unique )?index (?:concurrently )?(?:if not exists )?(?:` + sqlName + ` )?on (?:only )?` + sqlName + `(?: using \w+)? ?\(([^)]*)\)`), "add index on", false},
+	{regexp.MustCompile(`(?i)^alter table (?:if exists )?(?:only )?` + sqlName + ` add (?:column )?(?:if not exists )?` + sqlName), "add column", false},
+	{regexp.MustCompile(`(?i)^alter table (?:if exists )?(?:only )?` + sqlName + ` drop (?:column )?(?:if exists )?` + sqlName), "drop column", true},
+	{regexp.MustCompile(`(?i)^alter table (?:if exists )?(?:only )?` + sqlName + ` rename (?:column )?` + sqlName + ` to ` + sqlName), "rename", false},
+	{regexp.MustCompile(`(?i)^alter table (?:if exists )?(?:only )?` + sqlName + ` alter (?:column )?` + sqlName), "change column", false},
+	{regexp.MustCompile(`(?i)^alter (\w+) (?:if exists )?` + sqlName), "alter", false},
+	{regexp.MustCompile(`(?i)^create (?:or replace )?(?:temp |temporary |unlogged )?(materialized view|table|view|function|procedure|trigger|schema|sequence|type|extension|database) (?:if not exists )?` + sqlName), "create", false},
+	{regexp.MustCompile(`(?i)^drop (materialized view|\w+) (?:concurrently )?(?:if exists )?` + sqlName), "drop", true},
+	{regexp.MustCompile(`(?i)^truncate (?:table )?(?:only )?` + sqlName), "truncate", true},
+	{regexp.MustCompile(`(?i)^insert (?:ignore )?into ` + sqlName), "insert into", false},
+	{regexp.MustCompile(`(?i)^update (?:only )?` + sqlName), "update", false},
+	{regexp.MustCompile(`(?i)^delete from (?:only )?` + sqlName), "delete from", false},
+	{regexp.MustCompile(`(?i)^(?:select|with)\b.*? from ` + sqlName), "query", false},
+}
+
+// maxSQLStatement bounds the length of SQLChange.Statement.
+const maxSQLStatement = 80
+
+// SQLChanges returns the statements the changes add to .sql files or edit
+// there, in diff order. A statement counts when one of its lines is added
+// or removed; the statement a hunk starts in the middle of is known from
+// the hunk header, where git puts the line it starts on.
+func SQLChanges(changes *git.ChangeSet) []SQLChange {
+	var out []SQLChange
+	for _, f := range changes.Files {
+		if strings.ToLower(path.Ext(f.Path)) != ".sql" || f.Status == "D" {
+			continue
+		}
+		for _, h := range f.Hunks {
+			var stmt []string
+			start, touched := 0, false
+			flush := func() {
+				if touched && len(stmt) > 0 {
+					out = append(out, describeSQL(f.Path, start, strings.Join(stmt, " ")))
+				}
+				stmt, touched = nil, false
+			}
+			// A hunk starting in the middle of a statement continues the one
+			// in its header
+			if isSQLStart(h.Header) && len(h.Lines) > 0 && h.Lines[0] != "" && !isSQLStart(h.Lines[0][1:]) {
+				stmt, start = []string{h.Header}, h.NewStart
+			}
+			line := h.NewStart
+			for _, l := range h.Lines {
+				if l == "" || l[0] == '\\' {
+					continue
+				}
+				text := l[1:]
+				if i := strings.Index(text, "--"); i >= 0 {
+					text = text[:i]
+				}
+				text = strings.TrimSpace(text)
+				if l[0] == '-' {
+					// Removed lines change the statement, but a statement
+					// removed whole is not one the change makes
+					touched = touched || len(stmt) > 0
+					continue
+				}
+				if text != "" {
+					if len(stmt) == 0 && isSQLStart(text) {
+						start = line
+					}
+					if len(stmt) > 0 || isSQLStart(text) {
+						stmt = append(stmt, text)
+						touched = touched || l[0] == '+'
+					}
+					if strings.HasSuffix(text, ";") {
+						flush()
+					}
+				}
+				line++
+			}
+			flush()
+		}
+	}
+	return out
+}
+
+// isSQLStart reports whether a line starts a SQL statement.
+func isSQLStart(line string) bool {
+	word := strings.ToLower(strings.TrimSpace(line))
+	if i := strings.IndexAny(word, " \t(;"); i >= 0 {
+		word = word[:i]
+	}
+	return sqlKinds[word] != ""
+}
+
+// describeSQL classifies a statement and says what it does.
+func describeSQL(file string, line int, stmt string) SQLChange {
+	stmt = strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(stmt), ";")), " ")
+	first := strings.ToLower(strings.Fields(stmt)[0])
+	c := SQLChange{Path: file, Line: line, Kind: sqlKinds[strings.TrimRight(first, "(")], Statement: stmt}
+	if r := []rune(stmt); len(r) > maxSQLStatement {
+		c.Statement = string(r[:maxSQLStatement-3]) + "..."
+	}
+	c.Description = strings.ToLower(first) + " statement"
+	for _, d := range sqlDescriptions {
+		m := d.re.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		for i := range m {
+			m[i] = unquoteSQL(m[i])
+		}
+		c.Destructive = d.destructive
+		switch d.verb {
+		case "add index on":
+			table, columns := m[2], strings.Split(m[3], ",")
+			for i := range columns {
+				columns[i] = unquoteSQL(strings.Fields(columns[i] + " ")[0])
+			}
+			if len(columns) == 1 {
+				c.Description = "add index on " + table + "." + columns[0]
+			} else {
+				c.Description = "add index on " + table + "(" + strings.Join(columns, ", ") + ")"
+			}
+		case "add column", "drop column", "change column":
+			c.Description = d.verb + " " + m[1] + "." + m[2]
+		case "rename":
+			c.Description = "rename " + m[1] + "." + m[2] + " to " + m[3]
+		case "alter", "create", "drop":
+			c.Description = d.verb + " " + strings.ToLower(m[1]) + " " + m[2]
+		default:
+			c.Description = d.verb + " " + m[len(m)-1]
+		}
+		break
+	}
+	return c
+}
+
+// unquoteSQL strips the quotes of a quoted identifier.
+func unquoteSQL(name string) string {
+	return strings.NewReplacer("`", "", `"`, "", "[", "", "]", "").Replace(name)
+}
+
+// sqlHeuristic describes changes made only to .sql files by the statements
+// they add: schema changes are a feat in the db scope, or a refactor when
+// they only drop things, and data changes a chore. Queries say nothing the
+// file names do not, so ok is false for them.
+func sqlHeuristic(files []git.FileChange) (commitType, description string, ok bool) {
+	var schema, data []string
+	destructive := true
+	seen := map[string]bool{}
+	for _, c := range SQLChanges(&git.ChangeSet{Files: files}) {
+		if seen[c.Description] {
+			continue
+		}
+		seen[c.Description] = true
+		switch c.Kind {
+		case SQLSchema:
+			schema = append(schema, c.Description)
+			destructive = destructive && c.Destructive
+		case SQLData:
+			data = append(data, c.Description)
+		}
+	}
+	switch {
+	case len(schema) > 0 && destructive:
+		return "refactor", joinNames(schema), true
+	case len(schema) > 0:
+		return "feat", joinNames(schema), true
+	case len(data) > 0:
+		return "chore", joinNames(data), true
+	}
+	return "", "", false
+}
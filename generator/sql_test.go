@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestSQLChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		lines  []string
+		want   []string
+	}{
+		{
+			"migration",
+			"",
+			[]string{
+				"+-- orders are listed by date",
+				"+CREATE INDEX CONCURRENTLY idx_orders_created ON public.orders USING btree (created_at DESC);",
+				"+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total numeric(10, 2);",
+				"+ALTER TABLE \"orders\" RENAME COLUMN \"state\" TO status;",
+				"+CREATE TABLE IF NOT EXISTS refunds (",
+				"+  id bigserial PRIMARY KEY,",
+				"+  order_id bigint REFERENCES orders",
+				"+);",
+				"+CREATE INDEX ON refunds (order_id, created_at);",
+			},
+			[]string{
+				"DDL 2: add index on public.orders.created_at",
+				"DDL 3: add column orders.total",
+				"DDL 4: rename orders.state to status",
+				"DDL 5: create table refunds",
+				"DDL 9: add index on refunds(order_id, created_at)",
+			},
+		},
+		{
+			"destructive",
+			"",
+			[]string{
+				" SELECT 1;",
+				"+TRUNCATE TABLE sessions;",
+				"+ALTER TABLE users DROP COLUMN legacy_id;",
+				"+DROP VIEW IF EXISTS active_users;",
+				"-DROP TABLE kept;",
+			},
+			[]string{
+				"DDL 2: truncate sessions (destructive)",
+				"DDL 3: drop column users.legacy_id (destructive)",
+				"DDL 4: drop view active_users (destructive)",
+			},
+		},
+		{
+			"data and queries",
+			"",
+			[]string{
+				"+INSERT INTO plans (name) VALUES ('pro');",
+				"+UPDATE plans SET price = 10",
+				"+WHERE name = 'pro';",
+				"+SELECT name, price",
+				"+FROM plans p JOIN users u ON u.plan = p.name;",
+			},
+			[]string{
+				"DML 1: insert into plans",
+				"DML 2: update plans",
+				"query 4: query plans",
+			},
+		},
+		{
+			"edited in the middle",
+			"SELECT o.id, o.total",
+			[]string{
+				" FROM orders o",
+				"-WHERE o.total > 0",
+				"+WHERE o.total > 100",
+				" ORDER BY o.id;",
+			},
+			[]string{"query 1: query orders"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := &git.ChangeSet{Files: []git.FileChange{{
+				ChangedFile: git.ChangedFile{Status: "M", Path: "db/schema.sql"},
+				Hunks:       []git.Hunk{{NewStart: 1, Header: tt.header, Lines: tt.lines}},
+			}}}
+			var got []string
+			for _, c := range SQLChanges(cs) {
+				s := fmt.Sprintf("%s %d: %s", c.Kind, c.Line, c.Description)
+				if c.Destructive {
+					s += " (destructive)"
+				}
+				got = append(got, s)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("SQLChanges() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// DescribeStyle summarizes how the subjects of commits are written, for the
+// model to imitate: the share of conventional subjects and emoji, the
+// scopes in use, the usual length and capitalization and whether they are
+// written in the imperative, followed by up to examples subjects. It is
+// empty when there are no commits.
+func DescribeStyle(commits []git.Commit, examples int) string {
+	if len(commits) == 0 {
+		return ""
+	}
+	var conforming, emoji, capitalized, pastTense, total int
+	scopes := map[string]int{}
+	for _, c := range commits {
+		subject := strings.TrimSpace(c.Subject)
+		total += utf8.RuneCountInString(subject)
+		if c.Type != "" {
+			conforming++
+			if c.Scope != "" {
+				scopes[c.Scope]++
+			}
+		}
+		if e, _ := conventional.SplitEmoji(subject); e != "" {
+			emoji++
+		}
+		description := subject
+		if c.Type != "" {
+			description = c.Description
+		}
+		_, description = conventional.SplitEmoji(description)
+		if r, _ := utf8.DecodeRuneInString(description); unicode.IsUpper(r) {
+			capitalized++
+		}
+		if first, _, _ := strings.Cut(description, " "); strings.HasSuffix(strings.ToLower(first), "ed") {
+			pastTense++
+		}
+	}
+
+	n := len(commits)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sampled %d recent commits:\n", n)
+	fmt.Fprintf(&b, "- %s use the conventional format\n", share(conforming, n))
+	if len(scopes) > 0 {
+		fmt.Fprintf(&b, "- scopes in use, most common first: %s\n", strings.Join(rankScopes(scopes, 10), ", "))
+	}
+	fmt.Fprintf(&b, "- %s start with an emoji\n", share(emoji, n))
+	fmt.Fprintf(&b, "- subjects average %d characters\n", total/n)
+	fmt.Fprintf(&b, "- %s start the description with a capital letter\n", share(capitalized, n))
+	if pastTense*2 > n {
+		b.WriteString("- most are written in the past tense (\"added\", \"fixed\")\n")
+	} else {
+		b.WriteString("- most are written in the imperative (\"add\", \"fix\")\n")
+	}
+	if examples > len(commits) {
+		examples = len(commits)
+	}
+	if examples > 0 {
+		b.WriteString("Examples:\n")
+		for _, c := range commits[:examples] {
+			fmt.Fprintf(&b, "%s\n", c.Subject)
+		}
+	}
+	return b.String()
+}
+
+// share describes count out of total in words.
+func share(count, total int) string {
+	switch {
+	case count == 0:
+		return "none"
+	case count == total:
+		return "all"
+	}
+	return fmt.Sprintf("%d%%", count*100/total)
+}
+
+// rankScopes returns up to limit scopes, most used first.
+func rankScopes(counts map[string]int, limit int) []string {
+	scopes := make([]string, 0, len(counts))
+	for scope := range counts {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if counts[scopes[i]] != counts[scopes[j]] {
+			return counts[scopes[i]] > counts[scopes[j]]
+		}
+		return scopes[i] < scopes[j]
+	})
+	if len(scopes) > limit {
+		scopes = scopes[:limit]
+	}
+	return scopes
+}
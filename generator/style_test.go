@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/git"
+)
+
+func TestDescribeStyle(t *testing.T) {
+	commit := func(subject string) git.Commit {
+		c := git.Commit{Subject: subject}
+		git.ClassifyCommit(&c)
+		return c
+	}
+
+	if got := DescribeStyle(nil, 5); got != "" {
+		t.Errorf("DescribeStyle(nil) = %q, want empty", got)
+	}
+
+	tests := []struct {
+		name    string
+		commits []git.Commit
+		want    []string
+	}{
+		{
+			name:    "conventional imperative",
+			commits: []git.Commit{commit("feat(api): add users"), commit("fix(api): handle nil"), commit("docs: fix typo")},
+			want: []string{
+				"Sampled 3 recent commits",
+				"- all use the conventional format",
+				"- scopes in use, most common first: api\n",
+				"- none start with an emoji",
+				"- none start the description with a capital letter",
+				"imperative",
+				"Examples:\nfeat(api): add users\nfix(api): handle nil\n",
+			},
+		},
+		{
+			name:    "emoji past tense",
+			commits: []git.Commit{commit("✨ Added dark mode"), commit(":bug: Fixed login"), commit("Updated docs")},
+			want: []string{
+				"- none use the conventional format",
+				"- 66% start with an emoji",
+				"- all start the description with a capital letter",
+				"past tense",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DescribeStyle(tt.commits, 2)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("DescribeStyle() = %q, missing %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangedFile is one entry of `git diff --name-status` output.
+type ChangedFile struct {
+	// Status is the change letter: A, C, D, M, R, T, U (unmerged) or X.
+	Status string `json:"status"`
+	// OldPath is the source path of a rename or copy, empty otherwise.
+	OldPath string `json:"old_path,omitempty"`
+	// Path is the file's path after the change.
+	Path string `json:"path"`
+	// Score is the similarity percentage of a rename or copy, or the
+	// dissimilarity of a modification when git reports one.
+	Score int `json:"score,omitempty"`
+}
+
+// Renamed reports whether the entry is a rename or copy.
+func (f ChangedFile) Renamed() bool {
+	return f.OldPath != ""
+}
+
+func (f ChangedFile) String() string {
+	if f.Renamed() {
+		return fmt.Sprintf("%s %s -> %s", f.Status, f.OldPath, f.Path)
+	}
+	return fmt.Sprintf("%s %s", f.Status, f.Path)
+}
+
+// parseStatusField splits a status field such as "R100" into its letter and
+// score.
+func parseStatusField(field string) (status string, score int) {
+	if field == "" {
+		return "", 0
+	}
+	score, _ = strconv.Atoi(field[1:])
+	return field[:1], score
+}
+
+// ParseNameStatusZ parses `git diff -z --name-status` output, where every
+// field is NUL-terminated and paths are never quoted.
+func ParseNameStatusZ(out string) []ChangedFile {
+	var files []ChangedFile
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+1 < len(fields); {
+		if fields[i] == "" {
+			break
+		}
+		f := ChangedFile{}
+		f.Status, f.Score = parseStatusField(fields[i])
+		if f.Status == "R" || f.Status == "C" {
+			if i+2 >= len(fields) {
+				break
+			}
+			f.OldPath, f.Path = fields[i+1], fields[i+2]
+			i += 3
+		} else {
+			f.Path = fields[i+1]
+			i += 2
+		}
+		files = append(files, f)
+	}
+	return files
+}
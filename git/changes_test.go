@@ -0,0 +1,30 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNameStatusZ(t *testing.T) {
+	tests := []struct {
+		name, out string
+		want      []ChangedFile
+	}{
+		{"empty", "", nil},
+		{
+			"modified and added", "M\x00main.go\x00A\x00docs/new file.md\x00",
+			[]ChangedFile{{Status: "M", Path: "main.go"}, {Status: "A", Path: "docs/new file.md"}},
+		},
+		{
+			"rename with score", "R087\x00old.go\x00new.go\x00D\x00gone.go\x00",
+			[]ChangedFile{{Status: "R", Score: 87, OldPath: "old.go", Path: "new.go"}, {Status: "D", Path: "gone.go"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseNameStatusZ(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNameStatusZ(%q) = %+v, want %+v", tt.out, got, tt.want)
+			}
+		})
+	}
+}
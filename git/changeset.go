@@ -0,0 +1,366 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+)
+
+// ChangeSet is a parsed diff: every changed file with its line counts,
+// hunks and classification. It is built once per diff and shared by prompt
+// building, commit type detection and reports, so no feature re-parses raw
+// git output.
+type ChangeSet struct {
+	Files []FileChange `json:"files"`
+}
+
+// FileChange is one file of a ChangeSet.
+type FileChange struct {
+	ChangedFile
+	Added    int    `json:"added"`
+	Deleted  int    `json:"deleted"`
+	Binary   bool   `json:"binary,omitempty"`
+	Category string `json:"category"`
+	Hunks    []Hunk `json:"hunks,omitempty"`
+}
+
+// Hunk is one @@ section of a file's patch.
+type Hunk struct {
+	OldStart int `json:"old_start"`
+	OldLines int `json:"old_lines"`
+	NewStart int `json:"new_start"`
+	NewLines int `json:"new_lines"`
+	// Header is the function context git prints after the range, if any.
+	Header string `json:"header,omitempty"`
+	// Lines are the hunk's body lines, each starting with ' ', '+' or '-'.
+	Lines []string `json:"lines"`
+}
+
+// File categories assigned by ClassifyPath.
+const (
+	CategorySource    = "source"
+	CategoryTest      = "test"
+	CategoryDocs      = "docs"
+	CategoryCI        = "ci"
+	CategoryBuild     = "build"
+	CategoryLockfile  = "lockfile"
+	CategoryGenerated = "generated"
+	CategoryConfig    = "config"
+)
+
+// LoadChangeSet diffs with the given git diff arguments (e.g. "--cached" or
+// a range) and parses the result.
+func LoadChangeSet(args ...string) (*ChangeSet, error) {
+	return Repo{}.LoadChangeSet(args...)
+}
+
+// LoadChangeSet is the package LoadChangeSet for r.
+func (r Repo) LoadChangeSet(args ...string) (*ChangeSet, error) {
+	run := func(extra ...string) (string, error) {
+		out, err := r.Output(append(append([]string{"diff", "--no-color", "--no-ext-diff"}, extra...), args...)...)
+		if err != nil {
+			return "", fmt.Errorf("diffing %s: %v", strings.Join(args, " "), err)
+		}
+		return out, nil
+	}
+
+	// -z output keeps paths raw: no quoting, and renames as separate fields
+	nameStatus, err := run("-z", "--name-status")
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := run("-z", "--numstat")
+	if err != nil {
+		return nil, err
+	}
+	patch, err := run("--patch")
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &ChangeSet{}
+	index := map[string]int{}
+	for _, f := range ParseNameStatusZ(nameStatus) {
+		index[f.Path] = len(cs.Files)
+		cs.Files = append(cs.Files, FileChange{ChangedFile: f, Category: ClassifyPath(f.Path)})
+	}
+
+	fields := strings.Split(numstat, "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		p := parts[2]
+		// A rename has an empty path followed by the old and new paths
+		if p == "" && i+2 < len(fields) {
+			p = fields[i+2]
+			i += 2
+		}
+		c, ok := index[p]
+		if !ok {
+			continue
+		}
+		if parts[0] == "-" {
+			cs.Files[c].Binary = true
+			continue
+		}
+		cs.Files[c].Added, _ = strconv.Atoi(parts[0])
+		cs.Files[c].Deleted, _ = strconv.Atoi(parts[1])
+	}
+
+	// git prints patches in the same order as the name-status entries
+	patches := splitPatch(patch)
+	if len(patches) == len(cs.Files) {
+		for i := range cs.Files {
+			cs.Files[i].Hunks = parseHunks(patches[i])
+		}
+	}
+	return cs, nil
+}
+
+// splitPatch splits a multi-file patch into per-file sections.
+func splitPatch(patch string) [][]string {
+	var files [][]string
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, nil)
+		}
+		if len(files) > 0 {
+			files[len(files)-1] = append(files[len(files)-1], line)
+		}
+	}
+	return files
+}
+
+// parseHunks extracts the hunks of one file's patch section.
+func parseHunks(lines []string) []Hunk {
+	var hunks []Hunk
+	for _, line := range lines {
+		if strings.HasPrefix(line, "@@ ") {
+			var h Hunk
+			ranges, header, _ := strings.Cut(strings.TrimPrefix(line, "@@ "), " @@")
+			oldRange, newRange, _ := strings.Cut(ranges, " ")
+			h.OldStart, h.OldLines = parseHunkRange(strings.TrimPrefix(oldRange, "-"))
+			h.NewStart, h.NewLines = parseHunkRange(strings.TrimPrefix(newRange, "+"))
+			h.Header = strings.TrimSpace(header)
+			hunks = append(hunks, h)
+			continue
+		}
+		if len(hunks) == 0 || line == "" {
+			continue
+		}
+		switch line[0] {
+		case ' ', '+', '-', '\\':
+			hunks[len(hunks)-1].Lines = append(hunks[len(hunks)-1].Lines, line)
+		}
+	}
+	return hunks
+}
+
+// parseHunkRange parses "start,count" where a missing count means 1.
+func parseHunkRange(r string) (start, count int) {
+	s, c, found := strings.Cut(r, ",")
+	start, _ = strconv.Atoi(s)
+	count = 1
+	if found {
+		count, _ = strconv.Atoi(c)
+	}
+	return start, count
+}
+
+// ClassifyPath assigns a file to a category from its path alone.
+func ClassifyPath(p string) string {
+	lower := strings.ToLower(p)
+	base := path.Base(lower)
+	ext := path.Ext(base)
+
+	switch {
+	case base == "go.sum" || base == "package-lock.json" || base == "yarn.lock" || base == "pnpm-lock.yaml" ||
+		base == "cargo.lock" || base == "poetry.lock" || base == "gemfile.lock" || base == "composer.lock":
+		return CategoryLockfile
+	case strings.HasSuffix(base, ".pb.go") || strings.Contains(base, "_generated.") || strings.HasSuffix(base, ".min.js") ||
+		strings.HasPrefix(lower, "vendor/") || strings.Contains(lower, "/vendor/") || strings.Contains(lower, "node_modules/"):
+		return CategoryGenerated
+	case strings.HasPrefix(lower, ".github/workflows/") || base == ".gitlab-ci.yml" || strings.HasPrefix(lower, ".circleci/") ||
+		base == "jenkinsfile" || base == "azure-pipelines.yml":
+		return CategoryCI
+	case strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(base, "test_") || strings.HasPrefix(lower, "test/") || strings.HasPrefix(lower, "tests/") ||
+		strings.Contains(lower, "/test/") || strings.Contains(lower, "/tests/") || strings.Contains(lower, "testdata/"):
+		return CategoryTest
+	case ext == ".md" || ext == ".rst" || ext == ".adoc" || strings.HasPrefix(lower, "docs/") ||
+		strings.HasPrefix(base, "readme") || strings.HasPrefix(base, "license") || strings.HasPrefix(base, "changelog"):
+		return CategoryDocs
+	case base == "makefile" || base == "dockerfile" || base == "go.mod" || base == "package.json" || base == "pom.xml" ||
+		base == "build.gradle" || base == "cmakelists.txt" || ext == ".mk":
+		return CategoryBuild
+	case ext == ".yml" || ext == ".yaml" || ext == ".json" || ext == ".toml" || ext == ".ini" || strings.HasPrefix(base, ".env"):
+		return CategoryConfig
+	}
+	return CategorySource
+}
+
+// Paths returns the changed paths, as they are after the change.
+func (cs *ChangeSet) Paths() []string {
+	paths := make([]string, 0, len(cs.Files))
+	for _, f := range cs.Files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// Stats returns the total added and deleted line counts.
+func (cs *ChangeSet) Stats() (added, deleted int) {
+	for _, f := range cs.Files {
+		added += f.Added
+		deleted += f.Deleted
+	}
+	return added, deleted
+}
+
+// Describe renders the change set as prompt context: one line per file with
+// its status, category and line counts.
+func (cs *ChangeSet) Describe() string {
+	var b strings.Builder
+	for _, f := range cs.Files {
+		counts := fmt.Sprintf("+%d/-%d", f.Added, f.Deleted)
+		if f.Binary {
+			counts = "binary"
+		}
+		fmt.Fprintf(&b, "%s (%s, %s)\n", f.ChangedFile, f.Category, counts)
+	}
+	return b.String()
+}
+
+// Section is a group of changed files the prompt presents together.
+type Section struct {
+	Name  string
+	Files []FileChange
+}
+
+// sectionNames are the sections of Sections, in order.
+var sectionNames = []string{"Added files", "Modified", "Deleted", "Renamed", "Tests", "Docs"}
+
+// Sections groups the files into sections: tests and docs by category
+// whatever their status, the rest by status (copies count as added).
+// Empty sections are left out.
+func (cs *ChangeSet) Sections() []Section {
+	files := map[string][]FileChange{}
+	for _, f := range cs.Files {
+		name := "Modified"
+		switch {
+		case f.Category == CategoryTest:
+			name = "Tests"
+		case f.Category == CategoryDocs:
+			name = "Docs"
+		case f.Status == "A" || f.Status == "C":
+			name = "Added files"
+		case f.Status == "D":
+			name = "Deleted"
+		case f.Status == "R":
+			name = "Renamed"
+		}
+		files[name] = append(files[name], f)
+	}
+	var sections []Section
+	for _, name := range sectionNames {
+		if len(files[name]) > 0 {
+			sections = append(sections, Section{Name: name, Files: files[name]})
+		}
+	}
+	return sections
+}
+
+// Summary describes the section in a line: how many files, where, and how
+// many lines they gained and lost.
+func (s Section) Summary() string {
+	var dirs []string
+	seen := map[string]bool{}
+	added, deleted := 0, 0
+	for _, f := range s.Files {
+		added += f.Added
+		deleted += f.Deleted
+		dir := path.Dir(f.Path)
+		if dir == "." {
+			dir = "the top level"
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	files := "1 file"
+	if len(s.Files) != 1 {
+		files = fmt.Sprintf("%d files", len(s.Files))
+	}
+	where := strings.Join(dirs, ", ")
+	if len(dirs) > 3 {
+		where = fmt.Sprintf("%s and %d more", strings.Join(dirs[:3], ", "), len(dirs)-3)
+	}
+	return fmt.Sprintf("%s in %s, +%d/-%d", files, where, added, deleted)
+}
+
+// DescribeSections renders the change set as prompt context section by
+// section: a heading with the section's summary, then one line per file.
+func (cs *ChangeSet) DescribeSections() string {
+	var b strings.Builder
+	for i, s := range cs.Sections() {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%s):\n", s.Name, s.Summary())
+		for _, f := range s.Files {
+			counts := fmt.Sprintf("+%d/-%d", f.Added, f.Deleted)
+			if f.Binary {
+				counts = "binary"
+			}
+			name := f.Path
+			if f.Renamed() {
+				name = f.OldPath + " -> " + f.Path
+			}
+			fmt.Fprintf(&b, "- %s (%s, %s)\n", name, f.Category, counts)
+		}
+	}
+	return b.String()
+}
+
+// CommitType guesses a conventional commit type from the file categories,
+// falling back to keyword heuristics on the paths.
+func (cs *ChangeSet) CommitType() string {
+	if len(cs.Files) == 0 {
+		return "chore"
+	}
+	counts := map[string]int{}
+	added := false
+	for _, f := range cs.Files {
+		counts[f.Category]++
+		if f.Status == "A" && f.Category == CategorySource {
+			added = true
+		}
+	}
+	only := func(categories ...string) bool {
+		n := 0
+		for _, c := range categories {
+			n += counts[c]
+		}
+		return n == len(cs.Files)
+	}
+
+	switch {
+	case only(CategoryTest):
+		return "test"
+	case only(CategoryDocs):
+		return "docs"
+	case only(CategoryCI):
+		return "ci"
+	case only(CategoryBuild, CategoryLockfile):
+		return "build"
+	case added:
+		return "feat"
+	}
+	return conventional.DetermineType(strings.Join(cs.Paths(), "\n"))
+}
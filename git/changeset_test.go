@@ -0,0 +1,192 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// fakeGit replaces DefaultRunner for the duration of a test, answering
+// each command with the output of the first response whose key appears in
+// its arguments.
+func fakeGit(t *testing.T, responses map[string]string) {
+	t.Helper()
+	saved := DefaultRunner
+	t.Cleanup(func() { DefaultRunner = saved })
+	DefaultRunner = RunnerFunc(func(cmd *exec.Cmd) error {
+		args := strings.Join(cmd.Args, " ")
+		for key, out := range responses {
+			if strings.Contains(args, key) {
+				_, err := io.WriteString(cmd.Stdout, out)
+				return err
+			}
+		}
+		return fmt.Errorf("unexpected command: %s", args)
+	})
+}
+
+func TestLoadChangeSet(t *testing.T) {
+	fakeGit(t, map[string]string{
+		"--name-status": "M\x00main.go\x00R090\x00old_test.go\x00parser_test.go\x00A\x00logo.png\x00",
+		"--numstat":     "3\t1\tmain.go\x002\t0\t\x00old_test.go\x00parser_test.go\x00-\t-\tlogo.png\x00",
+		"--patch": "diff --git a/main.go b/main.go\n" +
+			"@@ -10,2 +10,4 @@ func main() {\n" +
+			" \tx := 1\n-\ty := 2\n+\ty := 3\n+\tz := 4\n+\tw := 5\n" +
+			"diff --git a/old_test.go b/parser_test.go\n" +
+			"@@ -1 +1,3 @@\n+a\n+b\n c\n" +
+			"diff --git a/logo.png b/logo.png\nBinary files differ\n",
+	})
+
+	cs, err := LoadChangeSet("--cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.Files) != 3 {
+		t.Fatalf("got %d files, want 3", len(cs.Files))
+	}
+
+	main := cs.Files[0]
+	if main.Path != "main.go" || main.Added != 3 || main.Deleted != 1 || main.Category != CategorySource {
+		t.Errorf("main.go = %+v", main)
+	}
+	if len(main.Hunks) != 1 {
+		t.Fatalf("main.go has %d hunks, want 1", len(main.Hunks))
+	}
+	h := main.Hunks[0]
+	if h.OldStart != 10 || h.OldLines != 2 || h.NewStart != 10 || h.NewLines != 4 || h.Header != "func main() {" || len(h.Lines) != 5 {
+		t.Errorf("main.go hunk = %+v", h)
+	}
+
+	renamed := cs.Files[1]
+	if renamed.OldPath != "old_test.go" || renamed.Added != 2 || renamed.Category != CategoryTest {
+		t.Errorf("renamed file = %+v", renamed)
+	}
+	if h := renamed.Hunks[0]; h.OldStart != 1 || h.OldLines != 1 || h.NewLines != 3 {
+		t.Errorf("renamed file hunk = %+v", h)
+	}
+
+	if logo := cs.Files[2]; !logo.Binary || len(logo.Hunks) != 0 {
+		t.Errorf("logo.png = %+v", logo)
+	}
+	if added, deleted := cs.Stats(); added != 5 || deleted != 1 {
+		t.Errorf("Stats() = %d, %d; want 5, 1", added, deleted)
+	}
+}
+
+func TestLoadChangeSetError(t *testing.T) {
+	fakeGit(t, nil)
+	if _, err := LoadChangeSet("--cached"); err == nil {
+		t.Error("LoadChangeSet succeeded when git failed")
+	}
+}
+
+func TestClassifyPath(t *testing.T) {
+	tests := map[string]string{
+		"cmd/main.go":                  CategorySource,
+		"internal/parser_test.go":      CategoryTest,
+		"web/src/app.spec.ts":          CategoryTest,
+		"README.md":                    CategoryDocs,
+		"docs/guide.txt":               CategoryDocs,
+		".github/workflows/ci.yml":     CategoryCI,
+		"Makefile":                     CategoryBuild,
+		"go.mod":                       CategoryBuild,
+		"go.sum":                       CategoryLockfile,
+		"web/package-lock.json":        CategoryLockfile,
+		"api/v1/service.pb.go":         CategoryGenerated,
+		"vendor/github.com/x/y/y.go":   CategoryGenerated,
+		"deploy/values.yaml":           CategoryConfig,
+		".env.example":                 CategoryConfig,
+		"testdata/fixtures/input.json": CategoryTest,
+	}
+	for path, want := range tests {
+		if got := ClassifyPath(path); got != want {
+			t.Errorf("ClassifyPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCommitType(t *testing.T) {
+	file := func(status, path string) FileChange {
+		return FileChange{ChangedFile: ChangedFile{Status: status, Path: path}, Category: ClassifyPath(path)}
+	}
+	tests := []struct {
+		name  string
+		files []FileChange
+		want  string
+	}{
+		{"nothing", nil, "chore"},
+		{"tests only", []FileChange{file("M", "a_test.go"), file("A", "b_test.go")}, "test"},
+		{"docs only", []FileChange{file("M", "README.md")}, "docs"},
+		{"ci only", []FileChange{file("M", ".github/workflows/ci.yml")}, "ci"},
+		{"dependencies", []FileChange{file("M", "go.mod"), file("M", "go.sum")}, "build"},
+		{"new source file", []FileChange{file("A", "export.go"), file("M", "README.md")}, "feat"},
+		{"path keywords", []FileChange{file("M", "bugfix.go")}, "fix"},
+	}
+	for _, tt := range tests {
+		cs := &ChangeSet{Files: tt.files}
+		if got := cs.CommitType(); got != tt.want {
+			t.Errorf("%s: CommitType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeSections(t *testing.T) {
+	file := func(status, old, path string, added, deleted int) FileChange {
+		return FileChange{ChangedFile: ChangedFile{Status: status, OldPath: old, Path: path}, Added: added, Deleted: deleted, Category: ClassifyPath(path)}
+	}
+	tests := []struct {
+		name  string
+		files []FileChange
+		want  string
+	}{
+		{"nothing", nil, ""},
+		{
+			"every section",
+			[]FileChange{
+				file("M", "", "api/users.go", 3, 1),
+				file("A", "", "api/export.go", 40, 0),
+				file("A", "", "api/export_test.go", 25, 0),
+				file("D", "", "legacy/csv.go", 0, 80),
+				file("R", "util/strings.go", "text/strings.go", 0, 0),
+				file("M", "", "README.md", 4, 0),
+			},
+			"Added files (1 file in api, +40/-0):\n- api/export.go (source, +40/-0)\n\n" +
+				"Modified (1 file in api, +3/-1):\n- api/users.go (source, +3/-1)\n\n" +
+				"Deleted (1 file in legacy, +0/-80):\n- legacy/csv.go (source, +0/-80)\n\n" +
+				"Renamed (1 file in text, +0/-0):\n- util/strings.go -> text/strings.go (source, +0/-0)\n\n" +
+				"Tests (1 file in api, +25/-0):\n- api/export_test.go (test, +25/-0)\n\n" +
+				"Docs (1 file in the top level, +4/-0):\n- README.md (docs, +4/-0)\n",
+		},
+		{
+			"many directories",
+			[]FileChange{file("M", "", "a/x.go", 1, 0), file("M", "", "b/x.go", 1, 0), file("M", "", "c/x.go", 1, 0), file("M", "", "d/x.go", 1, 0)},
+			"Modified (4 files in a, b, c and 1 more, +4/-0):\n- a/x.go (source, +1/-0)\n- b/x.go (source, +1/-0)\n- c/x.go (source, +1/-0)\n- d/x.go (source, +1/-0)\n",
+		},
+	}
+	for _, tt := range tests {
+		cs := &ChangeSet{Files: tt.files}
+		if got := cs.DescribeSections(); got != tt.want {
+			t.Errorf("%s: DescribeSections() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRepoDir(t *testing.T) {
+	saved := DefaultRunner
+	t.Cleanup(func() { DefaultRunner = saved })
+	var dirs []string
+	DefaultRunner = RunnerFunc(func(cmd *exec.Cmd) error {
+		dirs = append(dirs, cmd.Dir)
+		return nil
+	})
+
+	Repo{Dir: "/srv/git/api"}.LoadChangeSet("--cached")
+	Repo{Dir: "/srv/git/api"}.LoadCommits("HEAD")
+	LoadCommits("HEAD")
+	want := []string{"/srv/git/api", "/srv/git/api", "/srv/git/api", "/srv/git/api", ""}
+	if strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("git ran in %q, want %q", dirs, want)
+	}
+}
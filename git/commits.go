@@ -0,0 +1,98 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chalfel/smart-commit/conventional"
+)
+
+// Commit is a single commit read from git history, with its subject
+// broken down into conventional commit parts when it follows the format.
+type Commit struct {
+	Hash        string
+	Parents     []string
+	Author      string
+	Email       string
+	Date        string
+	Subject     string
+	Body        string
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+	Tickets     []string
+}
+
+// Message returns the full commit message: subject, then body if any.
+func (c Commit) Message() string {
+	if c.Body == "" {
+		return c.Subject
+	}
+	return c.Subject + "\n\n" + c.Body
+}
+
+// Field and record separators used in git log pretty formats so subjects
+// containing tabs or pipes cannot break parsing.
+const (
+	LogFieldSep  = "\x1f"
+	LogRecordSep = "\x1e"
+)
+
+// LoadCommits runs git log with the given extra arguments and returns the
+// commits it lists, newest first.
+func LoadCommits(args ...string) ([]Commit, error) {
+	return Repo{}.LoadCommits(args...)
+}
+
+// LoadCommits is the package LoadCommits for r.
+func (r Repo) LoadCommits(args ...string) ([]Commit, error) {
+	format := "--format=" + strings.Join([]string{"%H", "%P", "%an", "%ae", "%ad", "%s", "%b"}, LogFieldSep) + LogRecordSep
+	gitArgs := append([]string{"log", format, "--date=short"}, args...)
+	out, err := r.Output(gitArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("reading git history: %v", err)
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(out, LogRecordSep) {
+		record = strings.TrimSpace(record)
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, LogFieldSep)
+		if len(fields) < 7 {
+			continue
+		}
+		c := Commit{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Author:  fields[2],
+			Email:   fields[3],
+			Date:    fields[4],
+			Subject: fields[5],
+			Body:    strings.TrimSpace(fields[6]),
+		}
+		ClassifyCommit(&c)
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// ClassifyCommit fills in the conventional commit parts and ticket
+// references of c from its subject and body.
+func ClassifyCommit(c *Commit) {
+	c.Type, c.Scope, c.Description, c.Breaking, _ = conventional.ParseSubject(c.Subject)
+	if strings.Contains(c.Body, "BREAKING CHANGE:") || strings.Contains(c.Body, "BREAKING-CHANGE:") {
+		c.Breaking = true
+	}
+	c.Tickets = conventional.ExtractTickets(c.Subject + "\n" + c.Body)
+}
+
+// ShortHash returns the abbreviated form of a commit hash.
+func ShortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,47 @@
+// Package git reads changes and history from the git repository in the
+// current directory, or the one a Repo names: parsed diffs, commits, refs
+// and remotes.
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// Repo is a repository in Dir, or in the current directory when Dir is
+// empty. Servers answering requests about several repositories use it
+// instead of the package functions, which read the current directory.
+type Repo struct {
+	Dir string
+}
+
+// Output runs git with args and returns its standard output. The error
+// includes what git printed on standard error.
+func Output(args ...string) (string, error) {
+	return Repo{}.Output(args...)
+}
+
+// Output runs git with args in r.
+func (r Repo) Output(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := DefaultRunner.Run(cmd); err != nil {
+		return "", fmt.Errorf("command failed: %v: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// Run runs git with args in r, passing what it prints to stdout and stderr
+// (discarding it where they are nil) and stopping it when ctx is done.
+func (r Repo) Run(ctx context.Context, stdout, stderr io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return DefaultRunner.Run(cmd)
+}
@@ -0,0 +1,51 @@
+package git
+
+import (
+	"os"
+	"strings"
+)
+
+// CommentChar returns the character git starts comment lines of commit
+// messages with: core.commentChar, or "#" when it is unset or "auto".
+func CommentChar() string {
+	out, err := Output("config", "core.commentChar")
+	if c := strings.TrimSpace(out); err == nil && c != "" && c != "auto" {
+		return c
+	}
+	return "#"
+}
+
+// MessageFile is a commit message file, such as the one git passes to the
+// prepare-commit-msg hook, read along with the repository's comment
+// character.
+type MessageFile struct {
+	Path    string
+	Content string
+	Comment string
+}
+
+// ReadMessageFile reads the message file at path.
+func ReadMessageFile(path string) (*MessageFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageFile{Path: path, Content: string(data), Comment: CommentChar()}, nil
+}
+
+// Empty reports whether the file holds no message yet, only blank lines and
+// git's comments.
+func (f *MessageFile) Empty() bool {
+	for _, line := range strings.Split(f.Content, "\n") {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, f.Comment) {
+			return false
+		}
+	}
+	return true
+}
+
+// Prefill writes message above the file's content, keeping git's comment
+// block below it.
+func (f *MessageFile) Prefill(message string) error {
+	return os.WriteFile(f.Path, []byte(message+"\n"+f.Content), 0644)
+}
@@ -0,0 +1,54 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMessageFile(t *testing.T) {
+	fakeGit(t, map[string]string{"core.commentChar": ";\n"})
+	tests := []struct {
+		content string
+		empty   bool
+	}{
+		{"", true},
+		{"\n; Please enter the commit message.\n;\n", true},
+		{"  \n; comment\n", true},
+		{"# not a comment here\n; comment\n", false},
+		{"fix: typo\n; comment\n", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+		os.WriteFile(path, []byte(tt.content), 0644)
+		f, err := ReadMessageFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Comment != ";" {
+			t.Fatalf("Comment = %q, want the configured %q", f.Comment, ";")
+		}
+		if got := f.Empty(); got != tt.empty {
+			t.Errorf("Empty() for %q = %v, want %v", tt.content, got, tt.empty)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	os.WriteFile(path, []byte("\n; comment\n"), 0644)
+	f, _ := ReadMessageFile(path)
+	if err := f.Prefill("feat: add x"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "feat: add x\n\n; comment\n" {
+		t.Errorf("after Prefill the file holds %q", data)
+	}
+}
+
+func TestCommentChar(t *testing.T) {
+	for _, tt := range []struct{ config, want string }{{"%\n", "%"}, {"auto\n", "#"}, {"", "#"}} {
+		fakeGit(t, map[string]string{"core.commentChar": tt.config})
+		if got := CommentChar(); got != tt.want {
+			t.Errorf("CommentChar() with %q = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CurrentBranch returns the short name of the checked-out branch, or an
+// error on a detached HEAD.
+func CurrentBranch() (string, error) {
+	out, err := Output("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("HEAD is detached; check out a branch first")
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// DefaultBranch guesses the repository's base branch: the branch origin/HEAD
+// points to, falling back to a local main or master.
+func DefaultBranch() (string, error) {
+	if out, err := Output("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(out), "origin/"), nil
+	}
+	for _, name := range []string{"main", "master"} {
+		if RefExists("refs/heads/" + name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("cannot determine the base branch; pass it explicitly")
+}
+
+// RefExists reports whether ref resolves to a commit.
+func RefExists(ref string) bool {
+	_, err := Output("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return err == nil
+}
+
+// ResolveCommit returns the hash of the commit rev names in r. Revisions
+// from untrusted callers go through it first: one starting with "-" would
+// otherwise be read as an option, such as --output=FILE, so it is refused.
+func (r Repo) ResolveCommit(rev string) (string, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("invalid revision %q", rev)
+	}
+	out, err := r.Output("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
@@ -0,0 +1,42 @@
+package git
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResolveCommit(t *testing.T) {
+	saved := DefaultRunner
+	t.Cleanup(func() { DefaultRunner = saved })
+	var ran []string
+	DefaultRunner = RunnerFunc(func(cmd *exec.Cmd) error {
+		ran = append(ran, strings.Join(cmd.Args[1:], " "))
+		_, err := cmd.Stdout.Write([]byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"))
+		return err
+	})
+
+	tests := []struct {
+		rev, want, err string
+	}{
+		{"v1.2.0", "4b825dc642cb6eb9a060e54bf8d69288fbee4904", ""},
+		{"--output=/tmp/x", "", "invalid revision"},
+		{"-p", "", "invalid revision"},
+		{"", "", "invalid revision"},
+	}
+	for _, tt := range tests {
+		got, err := Repo{Dir: "/srv/git/api"}.ResolveCommit(tt.rev)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ResolveCommit(%q) error = %v, want %q", tt.rev, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveCommit(%q) = %q, %v; want %q", tt.rev, got, err, tt.want)
+		}
+	}
+	if want := "rev-parse --verify --quiet --end-of-options v1.2.0^{commit}"; len(ran) != 1 || ran[0] != want {
+		t.Errorf("ran %q, want only %q", ran, want)
+	}
+}
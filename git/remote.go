@@ -0,0 +1,107 @@
+package git
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Remote is a hosted repository a remote URL points at, normalized across
+// the ways the same repository can be written: scp-like SSH
+// (git@host:owner/repo.git), ssh://, https:// with credentials or a port,
+// and git://.
+type Remote struct {
+	// Scheme is ssh, https, http or git.
+	Scheme string
+	// Host is the lower-cased host name, without user or port.
+	Host string
+	// Port is the port the URL names, if any.
+	Port string
+	// Owner is the namespace holding the repository: a user or
+	// organization, or a group path such as group/subgroup on GitLab.
+	Owner string
+	// Repo is the repository name, without .git.
+	Repo string
+}
+
+// scpLikeURL matches the scp-like SSH syntax: [user@]host:path.
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// ParseRemote parses a remote URL. Local paths and file:// URLs are not
+// hosted repositories and are rejected.
+func ParseRemote(rawURL string) (*Remote, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	r := &Remote{}
+	var repoPath string
+	switch {
+	case strings.Contains(rawURL, "://"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %q: %v", rawURL, err)
+		}
+		r.Scheme = strings.ToLower(u.Scheme)
+		switch r.Scheme {
+		case "git+ssh", "ssh+git":
+			r.Scheme = "ssh"
+		case "ssh", "https", "http", "git":
+		default:
+			return nil, fmt.Errorf("remote %q is not a hosted repository", rawURL)
+		}
+		r.Host, r.Port = strings.ToLower(u.Hostname()), u.Port()
+		repoPath = u.Path
+	case scpLikeURL.MatchString(rawURL) && !strings.HasPrefix(rawURL, "/"):
+		m := scpLikeURL.FindStringSubmatch(rawURL)
+		r.Scheme, r.Host, repoPath = "ssh", strings.ToLower(m[1]), m[2]
+	default:
+		return nil, fmt.Errorf("remote %q is not a hosted repository", rawURL)
+	}
+
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	i := strings.LastIndex(repoPath, "/")
+	if r.Host == "" || i <= 0 || i == len(repoPath)-1 {
+		return nil, fmt.Errorf("remote %q does not name an owner and repository", rawURL)
+	}
+	r.Owner, r.Repo = repoPath[:i], repoPath[i+1:]
+	return r, nil
+}
+
+// RemoteURL parses the URL of the named remote, with git's url.*.insteadOf
+// rewrites applied.
+func RemoteURL(name string) (*Remote, error) {
+	out, err := Output("remote", "get-url", name)
+	if err != nil {
+		return nil, fmt.Errorf("reading remote %s: %v", name, err)
+	}
+	return ParseRemote(out)
+}
+
+// Slug is owner/repo, as APIs and web URLs name the repository.
+func (r *Remote) Slug() string {
+	return r.Owner + "/" + r.Repo
+}
+
+// WebURL is the repository's page. The port of an HTTP(S) remote is kept;
+// an SSH port says nothing about the web server.
+func (r *Remote) WebURL() string {
+	host := r.Host
+	if r.Port != "" && (r.Scheme == "https" || r.Scheme == "http") {
+		host += ":" + r.Port
+	}
+	scheme := "https"
+	if r.Scheme == "http" {
+		scheme = "http"
+	}
+	return scheme + "://" + host + "/" + r.Slug()
+}
+
+// Platform guesses the hosting platform from the host name: github,
+// gitlab or bitbucket, or "" when the host gives no hint.
+func (r *Remote) Platform() string {
+	for _, platform := range []string{"github", "gitlab", "bitbucket"} {
+		if strings.Contains(r.Host, platform) {
+			return platform
+		}
+	}
+	return ""
+}
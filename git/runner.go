@@ -0,0 +1,28 @@
+package git
+
+import "os/exec"
+
+// Runner runs external commands. Every command smart-commit starts, git or
+// otherwise, goes through DefaultRunner, so tests can replace it with a fake
+// that inspects cmd.Args, reads cmd.Stdin and writes to cmd.Stdout.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+}
+
+// RunnerFunc adapts an ordinary function to the Runner interface.
+type RunnerFunc func(cmd *exec.Cmd) error
+
+// Run calls f(cmd).
+func (f RunnerFunc) Run(cmd *exec.Cmd) error {
+	return f(cmd)
+}
+
+// execRunner runs commands for real.
+type execRunner struct{}
+
+func (execRunner) Run(cmd *exec.Cmd) error {
+	return cmd.Run()
+}
+
+// DefaultRunner is the Runner commands go through.
+var DefaultRunner Runner = execRunner{}
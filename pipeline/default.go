@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/conventional"
+	"github.com/chalfel/smart-commit/generator"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// State is what the stages hand on to each other.
+type State struct {
+	// Stage is the stage running, or the one a failed run stopped at.
+	Stage Stage
+	// Amend has collect read the changes of the last commit as well as
+	// the staged ones, and commit rewrite that commit.
+	Amend bool
+	// AllowEmpty lets collect find nothing staged and commit make an empty
+	// commit with the message it is given.
+	AllowEmpty bool
+	// Changes are the changes being committed, read by collect.
+	Changes *git.ChangeSet
+	// Base is the commit Changes are measured from when amending.
+	Base string
+	// Context is what enrich adds to the prompt, such as the description
+	// of the issue being worked on.
+	Context []string
+	// Message is the commit message; generate leaves it alone when it is
+	// already set.
+	Message string
+	// ProviderErr is why generate worked the message out from the changes
+	// instead of using the provider's.
+	ProviderErr error
+	// CommitArgs are more arguments to git commit, such as signing
+	// options.
+	CommitArgs []string
+	// Commit is the hash of the commit made.
+	Commit string
+}
+
+// ErrNothingStaged is collect's error when there is nothing to commit.
+var ErrNothingStaged = errors.New("nothing is staged")
+
+// Default returns smart-commit's commit flow on repo with the settings in
+// cfg: collect reads the staged changes, generate asks gen for a message
+// with the context enrich added, validate checks the subject is
+// conventional and commit runs git commit. Enrich, transform and publish
+// do nothing until handlers are set; the smart-commit CLI and hook add
+// their own stages to this flow the way embedders do.
+func Default(repo git.Repo, gen generator.Generator, cfg *config.Config) *Pipeline {
+	generate := GenerateMessage(gen, cfg.CommitOptions())
+	return New().
+		Handle(Collect, CollectChanges(repo)).
+		Handle(Generate, func(ctx context.Context, s *State) error {
+			if t := cfg.RequestTimeout(); t > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, t)
+				defer cancel()
+			}
+			return generate(ctx, s)
+		}).
+		Handle(Validate, ValidateSubject).
+		Handle(Commit, GitCommit(repo, nil, nil))
+}
+
+// CollectChanges returns a handler reading the changes to commit in repo,
+// unless s has changes already: what is staged or, when amending, what the
+// amended commit will hold.
+func CollectChanges(repo git.Repo) Handler {
+	return func(ctx context.Context, s *State) error {
+		if s.Changes != nil {
+			return nil
+		}
+		args := []string{"--cached"}
+		if s.Amend {
+			base, err := amendBase(repo)
+			if err != nil {
+				return err
+			}
+			s.Base = base
+			args = append(args, base)
+		}
+		changes, err := repo.LoadChangeSet(args...)
+		if err != nil {
+			return fmt.Errorf("reading staged changes: %v", err)
+		}
+		if len(changes.Files) == 0 && !s.AllowEmpty {
+			return ErrNothingStaged
+		}
+		s.Changes = changes
+		return nil
+	}
+}
+
+// amendBase is the commit an amended commit is compared with: its parent,
+// or the empty tree for a root commit.
+func amendBase(repo git.Repo) (string, error) {
+	if _, err := repo.ResolveCommit("HEAD^"); err == nil {
+		return "HEAD^", nil
+	}
+	if _, err := repo.ResolveCommit("HEAD"); err != nil {
+		return "", errors.New("there is no commit to amend")
+	}
+	tree, err := repo.Output("hash-object", "-t", "tree", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// GenerateMessage returns a handler asking gen for the message with opts
+// and the context enrich added, unless s has a message already. When the
+// provider fails, the message is worked out from the changes and
+// s.ProviderErr says why.
+func GenerateMessage(gen generator.Generator, opts generator.Options) Handler {
+	return func(ctx context.Context, s *State) error {
+		if s.Message != "" {
+			return nil
+		}
+		if len(s.Changes.Files) == 0 {
+			return errors.New("an empty commit needs a message")
+		}
+		o := opts
+		o.Extra = strings.TrimSpace(strings.Join(append([]string{opts.Extra}, s.Context...), "\n\n"))
+		message, err := generator.CommitMessage(ctx, gen, s.Changes, o)
+		if err != nil {
+			commitType, scope, description := generator.Heuristic(s.Changes)
+			message = conventional.WithScope(commitType+": "+description, scope)
+			s.ProviderErr = fmt.Errorf("%s: %v", gen.Name(), err)
+		}
+		s.Message = message
+		return nil
+	}
+}
+
+// ValidateSubject checks that the message has a conventional subject.
+func ValidateSubject(ctx context.Context, s *State) error {
+	subject, _, _ := strings.Cut(s.Message, "\n")
+	if _, _, _, _, ok := conventional.ParseSubject(subject); !ok {
+		return fmt.Errorf("%q is not a conventional commit subject", subject)
+	}
+	return nil
+}
+
+// GitCommit returns a handler committing in repo with the message, passing
+// what git prints to stdout and stderr, and recording the hash.
+func GitCommit(repo git.Repo, stdout, stderr io.Writer) Handler {
+	return func(ctx context.Context, s *State) error {
+		args := []string{"commit", "-m", s.Message}
+		if s.Amend {
+			args = append(args, "--amend")
+		}
+		if s.AllowEmpty && len(s.Changes.Files) == 0 {
+			args = append(args, "--allow-empty")
+		}
+		var printed bytes.Buffer
+		errOut := io.Writer(&printed)
+		if stderr != nil {
+			errOut = stderr
+		}
+		if err := repo.Run(ctx, stdout, errOut, append(args, s.CommitArgs...)...); err != nil {
+			if msg := strings.TrimSpace(printed.String()); msg != "" {
+				return fmt.Errorf("committing changes: %v: %s", err, msg)
+			}
+			return fmt.Errorf("committing changes: %v", err)
+		}
+		hash, err := repo.Output("rev-parse", "HEAD")
+		if err != nil {
+			return err
+		}
+		s.Commit = strings.TrimSpace(hash)
+		return nil
+	}
+}
@@ -0,0 +1,164 @@
+// Package pipeline runs the commit flow as a chain of stages: collect the
+// staged changes, enrich the prompt with context, generate the message,
+// validate it, transform it, commit and publish. Default is smart-commit's
+// own flow; embedders start from it, replace stages with Handle and wrap
+// them with Use to add their own steps without forking the flow.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stage names a step of the commit flow.
+type Stage string
+
+// The stages, in the order Run runs them.
+const (
+	Collect   Stage = "collect"
+	Enrich    Stage = "enrich"
+	Generate  Stage = "generate"
+	Validate  Stage = "validate"
+	Transform Stage = "transform"
+	Commit    Stage = "commit"
+	Publish   Stage = "publish"
+)
+
+// Stages lists every stage in order.
+var Stages = []Stage{Collect, Enrich, Generate, Validate, Transform, Commit, Publish}
+
+// Handler runs a stage, reading and updating s.
+type Handler func(ctx context.Context, s *State) error
+
+// Middleware wraps the handler of a stage. It is given every stage, and
+// returns next unchanged for those it does not apply to.
+type Middleware func(stage Stage, next Handler) Handler
+
+// ErrStop ends a run early without failing it, as when a dry run has shown
+// the message and has nothing left to do.
+var ErrStop = errors.New("pipeline stopped")
+
+// Error is the failure of a stage.
+type Error struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Pipeline is a commit flow: a handler per stage and the middleware
+// wrapping them. The zero value has no handlers, so every stage does
+// nothing until one is set.
+type Pipeline struct {
+	handlers   map[Stage]Handler
+	middleware []Middleware
+}
+
+// New returns a pipeline with no handlers; see Default for one that
+// commits what is staged.
+func New() *Pipeline {
+	return &Pipeline{}
+}
+
+// Handle makes h the handler of stage, replacing the one it had.
+func (p *Pipeline) Handle(stage Stage, h Handler) *Pipeline {
+	if p.handlers == nil {
+		p.handlers = map[Stage]Handler{}
+	}
+	p.handlers[stage] = h
+	return p
+}
+
+// Use adds middleware. The first added is the outermost.
+func (p *Pipeline) Use(mw ...Middleware) *Pipeline {
+	p.middleware = append(p.middleware, mw...)
+	return p
+}
+
+// handler is the handler of stage wrapped in the middleware.
+func (p *Pipeline) handler(stage Stage) Handler {
+	h := p.handlers[stage]
+	if h == nil {
+		h = func(context.Context, *State) error { return nil }
+	}
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		h = p.middleware[i](stage, h)
+	}
+	return h
+}
+
+// Run runs every stage in order until one fails or stops the run. The
+// error of a failed stage is an *Error naming it; stopping is not an
+// error.
+func (p *Pipeline) Run(ctx context.Context, s *State) error {
+	return p.RunFrom(ctx, Collect, s)
+}
+
+// RunFrom is Run starting at stage, for a flow resumed where it stopped:
+// s must hold what the earlier stages left.
+func (p *Pipeline) RunFrom(ctx context.Context, stage Stage, s *State) error {
+	start := -1
+	for i, st := range Stages {
+		if st == stage {
+			start = i
+		}
+	}
+	if start < 0 {
+		return fmt.Errorf("unknown stage %q (expected %s)", stage, joinStages())
+	}
+	for _, st := range Stages[start:] {
+		if err := ctx.Err(); err != nil {
+			return &Error{Stage: st, Err: err}
+		}
+		s.Stage = st
+		if err := p.handler(st)(ctx, s); errors.Is(err, ErrStop) {
+			return nil
+		} else if err != nil {
+			return &Error{Stage: st, Err: err}
+		}
+	}
+	return nil
+}
+
+// Before returns middleware running h before the handler of stage; an
+// error from h skips the stage.
+func Before(stage Stage, h Handler) Middleware {
+	return func(st Stage, next Handler) Handler {
+		if st != stage {
+			return next
+		}
+		return func(ctx context.Context, s *State) error {
+			if err := h(ctx, s); err != nil {
+				return err
+			}
+			return next(ctx, s)
+		}
+	}
+}
+
+// After returns middleware running h once the handler of stage succeeds.
+func After(stage Stage, h Handler) Middleware {
+	return func(st Stage, next Handler) Handler {
+		if st != stage {
+			return next
+		}
+		return func(ctx context.Context, s *State) error {
+			if err := next(ctx, s); err != nil {
+				return err
+			}
+			return h(ctx, s)
+		}
+	}
+}
+
+func joinStages() string {
+	names := make([]string, len(Stages))
+	for i, st := range Stages {
+		names[i] = string(st)
+	}
+	return strings.Join(names, ", ")
+}
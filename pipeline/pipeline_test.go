@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chalfel/smart-commit/config"
+	"github.com/chalfel/smart-commit/git"
+)
+
+// record returns a handler noting the stage it ran in.
+func record(log *[]string, name string) Handler {
+	return func(ctx context.Context, s *State) error {
+		*log = append(*log, name+":"+string(s.Stage))
+		return nil
+	}
+}
+
+func TestRunOrder(t *testing.T) {
+	var log []string
+	p := New()
+	for _, st := range Stages {
+		p.Handle(st, record(&log, "h"))
+	}
+	p.Use(Before(Generate, record(&log, "before")), After(Commit, record(&log, "after")))
+	if err := p.Run(context.Background(), &State{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"h:collect", "h:enrich", "before:generate", "h:generate", "h:validate", "h:transform", "h:commit", "after:commit", "h:publish"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, want %v", log, want)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var log []string
+	wrap := func(name string) Middleware {
+		return func(st Stage, next Handler) Handler {
+			return func(ctx context.Context, s *State) error {
+				if st == Validate {
+					log = append(log, name)
+				}
+				return next(ctx, s)
+			}
+		}
+	}
+	p := New().Use(wrap("outer"), wrap("inner")).Handle(Validate, record(&log, "h"))
+	if err := p.Run(context.Background(), &State{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"outer", "inner", "h:validate"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("ran %v, want %v", log, want)
+	}
+}
+
+func TestRunStopsAndFails(t *testing.T) {
+	var log []string
+	p := New().
+		Handle(Generate, func(ctx context.Context, s *State) error { return ErrStop }).
+		Handle(Commit, record(&log, "h"))
+	if err := p.Run(context.Background(), &State{}); err != nil || len(log) != 0 {
+		t.Errorf("Run() = %v after running %v, want it stopped cleanly at generate", err, log)
+	}
+
+	failure := errors.New("hook failed")
+	p = New().Handle(Commit, func(ctx context.Context, s *State) error { return failure }).Handle(Publish, record(&log, "h"))
+	s := &State{}
+	err := p.Run(context.Background(), s)
+	var stageErr *Error
+	if !errors.As(err, &stageErr) || stageErr.Stage != Commit || !errors.Is(err, failure) || s.Stage != Commit || len(log) != 0 {
+		t.Errorf("Run() = %v with state at %s, want the commit stage's failure", err, s.Stage)
+	}
+
+	// Resuming starts at the stage that failed
+	p.Handle(Commit, record(&log, "h"))
+	if err := p.RunFrom(context.Background(), s.Stage, s); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"h:commit", "h:publish"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("resumed %v, want %v", log, want)
+	}
+	if err := p.RunFrom(context.Background(), "deploy", s); err == nil {
+		t.Error("RunFrom accepted an unknown stage")
+	}
+}
+
+// fakeGenerator answers every prompt with answer and records the prompts.
+type fakeGenerator struct {
+	answer  string
+	err     error
+	prompts []string
+}
+
+func (g *fakeGenerator) Name() string { return "fake" }
+func (g *fakeGenerator) Check() error { return nil }
+func (g *fakeGenerator) Generate(ctx context.Context, prompt string) (string, error) {
+	g.prompts = append(g.prompts, prompt)
+	return g.answer, g.err
+}
+
+func TestDefault(t *testing.T) {
+	var commands []string
+	saved := git.DefaultRunner
+	t.Cleanup(func() { git.DefaultRunner = saved })
+	git.DefaultRunner = git.RunnerFunc(func(cmd *exec.Cmd) error {
+		args := strings.Join(cmd.Args[1:], " ")
+		commands = append(commands, args)
+		out := ""
+		switch {
+		case strings.Contains(args, "--name-status"):
+			out = "A\x00users.go\x00"
+		case strings.Contains(args, "--numstat"):
+			out = "1\t0\tusers.go\x00"
+		case strings.Contains(args, "--patch"):
+			out = "diff --git a/users.go b/users.go\n@@ -0,0 +1 @@\n+package api\n"
+		case args == "rev-parse HEAD":
+			out = "abc123\n"
+		case strings.HasPrefix(args, "commit"):
+			return nil
+		default:
+			return fmt.Errorf("unexpected command: %s", args)
+		}
+		_, err := io.WriteString(cmd.Stdout, out)
+		return err
+	})
+
+	gen := &fakeGenerator{answer: "feat: add users"}
+	p := Default(git.Repo{}, gen, &config.Config{}).Use(Before(Generate, func(ctx context.Context, s *State) error {
+		s.Context = append(s.Context, "Issue PROJ-1: let users sign up")
+		return nil
+	}))
+	s := &State{}
+	if err := p.Run(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Message != "feat: add users" || s.Commit != "abc123" || len(s.Changes.Files) != 1 {
+		t.Errorf("state = %+v", s)
+	}
+	if len(gen.prompts) != 1 || !strings.Contains(gen.prompts[0], "PROJ-1") {
+		t.Errorf("the prompt left out the context enrich added: %q", gen.prompts)
+	}
+	if !contains(commands, "commit -m feat: add users") {
+		t.Errorf("ran %q, want a commit with the message", commands)
+	}
+
+	gen.answer = "added users"
+	s = &State{Message: "added users"}
+	if err := p.Run(context.Background(), s); err == nil || s.Stage != Validate {
+		t.Errorf("Run() = %v at %s, want a non-conventional message refused by validate", err, s.Stage)
+	}
+
+	// A failing provider leaves a message worked out from the changes
+	gen.err = errors.New("overloaded")
+	s = &State{CommitArgs: []string{"--signoff"}}
+	if err := p.Run(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ProviderErr == nil || !strings.HasPrefix(s.Message, "feat: add users") {
+		t.Errorf("fell back to %q for %v, want the heuristic message", s.Message, s.ProviderErr)
+	}
+	if !contains(commands, "commit -m "+s.Message+" --signoff") {
+		t.Errorf("ran %q, want a commit with the extra arguments", commands)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
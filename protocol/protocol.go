@@ -0,0 +1,113 @@
+// Package protocol defines the messages exchanged with `smart-commit serve
+// --stdio`, the long-running mode editor plugins talk to.
+//
+// The transport is newline-delimited JSON-RPC 2.0: every request and
+// response is a single JSON object on its own line. A session starts with an
+// "initialize" request that negotiates the protocol version and the set of
+// capabilities both sides will use.
+package protocol
+
+import "encoding/json"
+
+// Version is the newest protocol version this package describes. Servers
+// also accept every version listed in SupportedVersions.
+const Version = 1
+
+// SupportedVersions lists the protocol versions a server can speak.
+var SupportedVersions = []int{1}
+
+// Method names.
+const (
+	MethodInitialize = "initialize"
+	MethodSuggest    = "suggest"
+	MethodCompare    = "compare"
+	MethodShutdown   = "shutdown"
+)
+
+// Capabilities a server may advertise. Each one enables the method of the
+// same name.
+const (
+	CapabilitySuggest = "suggest"
+	CapabilityCompare = "compare"
+)
+
+// Error codes returned in Response.Error.
+const (
+	ErrParse             = -32700
+	ErrInvalidRequest    = -32600
+	ErrMethodNotFound    = -32601
+	ErrInvalidParams     = -32602
+	ErrInternal          = -32603
+	ErrNotInitialized    = -32002
+	ErrVersionMismatch   = -32003
+	ErrCapabilityMissing = -32004
+)
+
+// Request is a JSON-RPC request sent by the client.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is the server's answer to a Request with the same ID.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error describes a failed request.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// InitializeParams opens a session. ProtocolVersions lists every version the
+// client understands; the server picks the highest one it also supports.
+// Capabilities lists the features the client wants to use.
+type InitializeParams struct {
+	ClientName       string   `json:"clientName"`
+	ProtocolVersions []int    `json:"protocolVersions"`
+	Capabilities     []string `json:"capabilities"`
+}
+
+// InitializeResult reports the negotiated version and the capabilities that
+// are enabled for the session: those requested by the client that the server
+// supports.
+type InitializeResult struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	ServerVersion   string   `json:"serverVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// SuggestParams asks for a commit message for the staged changes of the
+// repository at Dir (the server's working directory when empty).
+type SuggestParams struct {
+	Dir string `json:"dir,omitempty"`
+}
+
+// SuggestResult carries the suggested commit message. Fallback is set when
+// the AI backend failed and a heuristic message was produced instead.
+type SuggestResult struct {
+	Message  string `json:"message"`
+	Fallback bool   `json:"fallback"`
+}
+
+// CompareParams asks for a summary of what merging Head into Base changes.
+type CompareParams struct {
+	Dir  string `json:"dir,omitempty"`
+	Base string `json:"base"`
+	Head string `json:"head"`
+}
+
+// CompareResult is a Markdown report of the comparison.
+type CompareResult struct {
+	Markdown string `json:"markdown"`
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"net"
+)
+
+// Listen listens on addr for a network transport. An address without a
+// host, such as ":50051", listens on the loopback interface only. Anyone
+// who can reach the server can read the repositories it serves, so other
+// hosts are refused unless requests are authenticated.
+func Listen(addr string, authenticated bool) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %v", addr, err)
+	}
+	if host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	} else if !authenticated && !loopback(host) {
+		return nil, fmt.Errorf("%s is reachable from other machines; serving it requires authentication", addr)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %v", addr, err)
+	}
+	return lis, nil
+}
+
+// loopback reports whether host names the local machine only.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
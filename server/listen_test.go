@@ -0,0 +1,41 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	tests := []struct {
+		addr          string
+		authenticated bool
+		host, err     string
+	}{
+		{":0", false, "127.0.0.1:", ""},
+		{"127.0.0.1:0", false, "127.0.0.1:", ""},
+		{"localhost:0", false, "127.0.0.1:", ""},
+		{"0.0.0.0:0", false, "", "requires authentication"},
+		{"0.0.0.0:0", true, "", ""},
+		{"50051", false, "", "invalid address"},
+	}
+	for _, tt := range tests {
+		lis, err := Listen(tt.addr, tt.authenticated)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Listen(%s) error = %v, want %q", tt.addr, err, tt.err)
+			}
+			if lis != nil {
+				lis.Close()
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Listen(%s): %v", tt.addr, err)
+			continue
+		}
+		if got := lis.Addr().String(); !strings.HasPrefix(got, tt.host) {
+			t.Errorf("Listen(%s) listens on %s, want %s...", tt.addr, got, tt.host)
+		}
+		lis.Close()
+	}
+}
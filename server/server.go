@@ -0,0 +1,211 @@
+// Package server serves the smart-commit stdio protocol, the other end of
+// package client. A Server is given a handler per method and takes care of
+// the session: the initialize handshake, negotiating the version and
+// capabilities, answering requests in order and reporting errors.
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chalfel/smart-commit/protocol"
+)
+
+// Handler answers a request: the result is sent back as is. An error that
+// is a *protocol.Error is sent with its code, any other as ErrInternal.
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// method is a handled method and the capability it needs.
+type method struct {
+	capability string
+	handler    Handler
+}
+
+// Server is a protocol server. Each Serve call is a session of its own.
+type Server struct {
+	// Version is the server version reported to clients.
+	Version string
+	// Observe, when set, is called after every request with its method,
+	// the error code of the response (0 when it succeeded) and how long it
+	// took.
+	Observe func(method string, code int, took time.Duration)
+
+	methods      map[string]method
+	capabilities []string
+}
+
+// New returns a server reporting version, with no methods.
+func New(version string) *Server {
+	return &Server{Version: version, methods: map[string]method{}}
+}
+
+// Handle makes h the handler of name, offered to clients that ask for
+// capability.
+func (s *Server) Handle(name, capability string, h Handler) *Server {
+	s.methods[name] = method{capability: capability, handler: h}
+	for _, c := range s.capabilities {
+		if c == capability {
+			return s
+		}
+	}
+	s.capabilities = append(s.capabilities, capability)
+	return s
+}
+
+// Capabilities lists the capabilities the server offers.
+func (s *Server) Capabilities() []string {
+	return append([]string(nil), s.capabilities...)
+}
+
+// session is the state of one connection.
+type session struct {
+	initialized  bool
+	version      int
+	capabilities map[string]bool
+}
+
+// Serve reads requests from r until EOF or a shutdown request, writing one
+// response line per request to w. Nothing else may be written to w.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+	sess := &session{capabilities: map[string]bool{}}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req protocol.Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			encoder.Encode(ErrorResponse(0, protocol.ErrParse, "invalid JSON: %v", err))
+			continue
+		}
+
+		start := time.Now()
+		resp := s.handle(sess, req)
+		if s.Observe != nil {
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			s.Observe(req.Method, code, time.Since(start))
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+		if req.Method == protocol.MethodShutdown {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// handle dispatches a request after checking the session state.
+func (s *Server) handle(sess *session, req protocol.Request) protocol.Response {
+	if req.Method == protocol.MethodInitialize {
+		return s.initialize(sess, req)
+	}
+	if req.Method == protocol.MethodShutdown {
+		return ResultResponse(req.ID, struct{}{})
+	}
+	if !sess.initialized {
+		return ErrorResponse(req.ID, protocol.ErrNotInitialized, "initialize must be called first")
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return ErrorResponse(req.ID, protocol.ErrMethodNotFound, "unknown method %q", req.Method)
+	}
+	if !sess.capabilities[m.capability] {
+		return ErrorResponse(req.ID, protocol.ErrCapabilityMissing, "capability %q was not negotiated", m.capability)
+	}
+	result, err := m.handler(req.Params)
+	var perr *protocol.Error
+	switch {
+	case errors.As(err, &perr):
+		return protocol.Response{JSONRPC: "2.0", ID: req.ID, Error: perr}
+	case err != nil:
+		return ErrorResponse(req.ID, protocol.ErrInternal, "%v", err)
+	}
+	return ResultResponse(req.ID, result)
+}
+
+// initialize negotiates the protocol version and capabilities for the
+// session.
+func (s *Server) initialize(sess *session, req protocol.Request) protocol.Response {
+	var params protocol.InitializeParams
+	if err := Params(req.Params, &params); err != nil {
+		return ErrorResponse(req.ID, protocol.ErrInvalidParams, "%v", err)
+	}
+
+	// Pick the newest version both sides speak
+	chosen := 0
+	for _, v := range params.ProtocolVersions {
+		for _, supported := range protocol.SupportedVersions {
+			if v == supported && v > chosen {
+				chosen = v
+			}
+		}
+	}
+	if chosen == 0 {
+		return ErrorResponse(req.ID, protocol.ErrVersionMismatch, "no common protocol version; server supports %v", protocol.SupportedVersions)
+	}
+
+	offered := map[string]bool{}
+	for _, c := range s.capabilities {
+		offered[c] = true
+	}
+	sess.capabilities = map[string]bool{}
+	enabled := []string{}
+	for _, c := range params.Capabilities {
+		if offered[c] && !sess.capabilities[c] {
+			sess.capabilities[c] = true
+			enabled = append(enabled, c)
+		}
+	}
+
+	sess.initialized = true
+	sess.version = chosen
+	return ResultResponse(req.ID, protocol.InitializeResult{
+		ProtocolVersion: chosen,
+		ServerVersion:   s.Version,
+		Capabilities:    enabled,
+	})
+}
+
+// Params decodes request params into v, treating missing params as empty.
+// The error is an ErrInvalidParams *protocol.Error.
+func Params(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &protocol.Error{Code: protocol.ErrInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
+
+// ResultResponse is the response carrying result.
+func ResultResponse(id int64, result interface{}) protocol.Response {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return ErrorResponse(id, protocol.ErrInternal, "encoding result: %v", err)
+	}
+	return protocol.Response{JSONRPC: "2.0", ID: id, Result: data}
+}
+
+// ErrorResponse is the response failing a request with code.
+func ErrorResponse(id int64, code int, format string, args ...interface{}) protocol.Response {
+	return protocol.Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &protocol.Error{Code: code, Message: fmt.Sprintf(format, args...)},
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chalfel/smart-commit/protocol"
+)
+
+func TestServe(t *testing.T) {
+	s := New("1.2.3").
+		Handle(protocol.MethodSuggest, protocol.CapabilitySuggest, func(raw json.RawMessage) (interface{}, error) {
+			var params protocol.SuggestParams
+			if err := Params(raw, &params); err != nil {
+				return nil, err
+			}
+			if params.Dir == "broken" {
+				return nil, errors.New("no repository")
+			}
+			return protocol.SuggestResult{Message: "feat: add " + params.Dir}, nil
+		}).
+		Handle(protocol.MethodCompare, protocol.CapabilityCompare, func(json.RawMessage) (interface{}, error) {
+			return protocol.CompareResult{}, nil
+		})
+	var observed []int
+	s.Observe = func(method string, code int, _ time.Duration) { observed = append(observed, code) }
+
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"suggest"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"initialize","params":{"protocolVersions":[1,7],"capabilities":["suggest","unknown"]}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"suggest","params":{"dir":"users"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"compare","params":{}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"suggest","params":{"dir":"broken"}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"suggest","params":"users"}`,
+		`{"jsonrpc":"2.0","id":7,"method":"rename"}`,
+		`{"jsonrpc":"2.0","id":8,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":9,"method":"suggest"}`,
+	}
+	var out bytes.Buffer
+	if err := s.Serve(strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var responses []protocol.Response
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp protocol.Response
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 8 {
+		t.Fatalf("got %d responses, want 8 (none after shutdown):\n%s", len(responses), out.String())
+	}
+
+	var init protocol.InitializeResult
+	json.Unmarshal(responses[1].Result, &init)
+	if init.ProtocolVersion != 1 || init.ServerVersion != "1.2.3" || len(init.Capabilities) != 1 || init.Capabilities[0] != "suggest" {
+		t.Errorf("initialize result = %+v", init)
+	}
+	var suggested protocol.SuggestResult
+	json.Unmarshal(responses[2].Result, &suggested)
+	if suggested.Message != "feat: add users" {
+		t.Errorf("suggest result = %+v", suggested)
+	}
+
+	wantCodes := []int{protocol.ErrNotInitialized, 0, 0, protocol.ErrCapabilityMissing, protocol.ErrInternal, protocol.ErrInvalidParams, protocol.ErrMethodNotFound, 0}
+	for i, want := range wantCodes {
+		got := 0
+		if responses[i].Error != nil {
+			got = responses[i].Error.Code
+		}
+		if got != want || observed[i] != want {
+			t.Errorf("response %d has code %d (observed %d), want %d", i+1, got, observed[i], want)
+		}
+	}
+}